@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// DeleteAccount permanently deletes the user's Charm account and everything
+// stored with it on the server: linked public keys, encrypt keys, files and
+// KV backups. It returns charm.ErrLinkedKeys if other keys are still linked
+// to the account, unless force is true.
+//
+// Local data, such as KV databases and the SSH keys themselves, isn't
+// touched. Authenticating with the same SSH key again creates a new, empty
+// account.
+func (cc *Client) DeleteAccount(force bool) error {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.DeleteAccountWithContext(ctx, force)
+}
+
+// DeleteAccountWithContext permanently deletes the user's Charm account with
+// context.
+func (cc *Client) DeleteAccountWithContext(ctx context.Context, force bool) error {
+	path := "/v1/account"
+	if force {
+		path += "?force=true"
+	}
+	resp, err := cc.AuthedRequestWithContext(ctx, "DELETE", path, nil, nil)
+	if resp != nil {
+		defer resp.Body.Close() // nolint:errcheck
+		if resp.StatusCode == http.StatusConflict {
+			return charm.ErrLinkedKeys
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// The JWT belongs to the deleted account
+	cc.InvalidateAuth()
+	return nil
+}
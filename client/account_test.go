@@ -0,0 +1,49 @@
+// ABOUTME: Unit tests for account deletion on the client.
+// ABOUTME: Checks the request sent and how a refusal over linked keys is reported.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	var method, query string
+	linked := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/account" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		method, query = r.Method, r.URL.RawQuery
+		if linked && r.URL.Query().Get("force") != "true" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(charm.Message{Message: charm.ErrLinkedKeys.Error()})
+		}
+	}))
+	defer ts.Close()
+	cc := NewClientForTestServer(ts)
+
+	if err := cc.DeleteAccount(false); !errors.Is(err, charm.ErrLinkedKeys) {
+		t.Fatalf("expected ErrLinkedKeys, got %v", err)
+	}
+	if method != http.MethodDelete || query != "" {
+		t.Errorf("expected DELETE without query, got %s ?%s", method, query)
+	}
+
+	if err := cc.DeleteAccount(true); err != nil {
+		t.Fatalf("expected forced delete to succeed, got %v", err)
+	}
+	if query != "force=true" {
+		t.Errorf("expected force=true, got %q", query)
+	}
+	if cc.claims != nil {
+		t.Error("expected auth to be invalidated after deleting the account")
+	}
+}
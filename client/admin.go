@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// AdminListUsers lists the server's users, 50 per page, oldest first. Only
+// accounts with a public key listed in the server's admin keys may list
+// users; others get a 403 error.
+func (cc *Client) AdminListUsers(page int) ([]*charm.User, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.AdminListUsersWithContext(ctx, page)
+}
+
+// AdminListUsersWithContext lists the server's users with context.
+func (cc *Client) AdminListUsersWithContext(ctx context.Context, page int) ([]*charm.User, error) {
+	var us []*charm.User
+	if err := cc.AuthedJSONRequestWithContext(ctx, "GET", fmt.Sprintf("/v1/admin/users?page=%d", page), nil, &us); err != nil {
+		return nil, err
+	}
+	return us, nil
+}
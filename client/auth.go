@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	charm "github.com/charmbracelet/charm/proto"
 	jwt "github.com/golang-jwt/jwt/v4"
@@ -10,18 +12,27 @@ import (
 // Auth will authenticate a client and cache the result. It will return a
 // proto.Auth with the JWT and encryption keys for a user.
 func (cc *Client) Auth() (*charm.Auth, error) {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.AuthWithContext(ctx)
+}
+
+// AuthWithContext authenticates a client and caches the result with context.
+// The context bounds connecting to the server when there is no valid cached
+// JWT.
+func (cc *Client) AuthWithContext(ctx context.Context) (*charm.Auth, error) {
 	cc.authLock.Lock()
 	defer cc.authLock.Unlock()
 
 	if cc.claims == nil || cc.claims.Valid() != nil {
 		auth := &charm.Auth{}
-		s, err := cc.sshSession()
+		s, err := cc.sshSessionWithContext(ctx)
 		if err != nil {
 			return nil, charm.ErrAuthFailed{Err: err}
 		}
 		defer s.Close() // nolint:errcheck
 
-		b, err := s.Output("api-auth")
+		b, err := s.OutputContext(ctx, "api-auth")
 		if err != nil {
 			return nil, charm.ErrAuthFailed{Err: err}
 		}
@@ -44,6 +55,55 @@ func (cc *Client) Auth() (*charm.Auth, error) {
 	return cc.auth, nil
 }
 
+// AuthClaims returns the claims of the JWT the client authenticates with,
+// authenticating first if there's no valid cached JWT. The JWT isn't verified;
+// it came from the server over SSH.
+func (cc *Client) AuthClaims() (map[string]any, error) {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.AuthClaimsWithContext(ctx)
+}
+
+// AuthClaimsWithContext returns the claims of the JWT the client
+// authenticates with, with context.
+func (cc *Client) AuthClaimsWithContext(ctx context.Context) (map[string]any, error) {
+	auth, err := cc.AuthWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := (&jwt.Parser{}).ParseUnverified(auth.JWT, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// AuthExpiry returns when the JWT the client authenticates with expires,
+// authenticating first if there's no valid cached JWT. Once it has expired,
+// the next request fetches a new one. The zero time means it doesn't expire.
+func (cc *Client) AuthExpiry() (time.Time, error) {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.AuthExpiryWithContext(ctx)
+}
+
+// AuthExpiryWithContext returns when the JWT the client authenticates with
+// expires, with context.
+func (cc *Client) AuthExpiryWithContext(ctx context.Context) (time.Time, error) {
+	auth, err := cc.AuthWithContext(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := (&jwt.Parser{}).ParseUnverified(auth.JWT, claims); err != nil {
+		return time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return time.Time{}, nil
+	}
+	return claims.ExpiresAt.Time, nil
+}
+
 // InvalidateAuth clears the JWT auth cache, forcing subsequent Auth() to fetch
 // a new JWT from the server.
 func (cc *Client) InvalidateAuth() {
@@ -52,3 +112,15 @@ func (cc *Client) InvalidateAuth() {
 	cc.claims = nil
 	cc.auth = nil
 }
+
+// invalidateAuthIfCurrent clears the JWT auth cache if it still holds jwt.
+// Requests that fail with the same expired JWT at once then fetch a new one
+// only once, instead of each discarding the JWT another just fetched.
+func (cc *Client) invalidateAuthIfCurrent(jwt string) {
+	cc.authLock.Lock()
+	defer cc.authLock.Unlock()
+	if cc.auth != nil && cc.auth.JWT == jwt {
+		cc.claims = nil
+		cc.auth = nil
+	}
+}
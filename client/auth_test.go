@@ -0,0 +1,204 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	gliderssh "github.com/charmbracelet/ssh"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/ssh"
+)
+
+// authSSHServer starts an SSH server answering api-auth with a new JWT, and
+// points cc at it. The returned counter tracks how often a JWT was fetched.
+func authSSHServer(t *testing.T, cc *Client, token string) *atomic.Int32 {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := &gliderssh.Server{Handler: func(s gliderssh.Session) {
+		if s.RawCommand() != "api-auth" {
+			_ = s.Exit(1)
+			return
+		}
+		fetches.Add(1)
+		_ = json.NewEncoder(s).Encode(&charm.Auth{JWT: token, HTTPScheme: "http"})
+		_ = s.Exit(0)
+	}}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	cc.Config.SSHPort = l.Addr().(*net.TCPAddr).Port
+	cc.sshConfig = &ssh.ClientConfig{User: "charm", HostKeyCallback: ssh.InsecureIgnoreHostKey()} // nolint
+	return &fetches
+}
+
+func newTestJWT(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// acceptOnly returns a handler that answers 401 unless the request carries
+// token, recording the body of each accepted request.
+func acceptOnly(token string, requests *atomic.Int32, bodies chan<- string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if bodies != nil {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func TestAuthedRequest_RefreshesJWTOn401(t *testing.T) {
+	token := newTestJWT(t)
+	var requests atomic.Int32
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(acceptOnly(token, &requests, bodies))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	fetches := authSSHServer(t, cc, token)
+
+	resp, err := cc.AuthedRequest("POST", "/v1/test", nil, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("expected the request to succeed with a new JWT, got %v", err)
+	}
+	resp.Body.Close() // nolint:errcheck
+	if got := <-bodies; got != "payload" {
+		t.Errorf("expected the body to be sent again, got %q", got)
+	}
+	if requests.Load() != 2 || fetches.Load() != 1 {
+		t.Errorf("expected 2 requests and 1 JWT fetch, got %d and %d", requests.Load(), fetches.Load())
+	}
+}
+
+func TestAuthedRequest_RetriesOnlyOnceOn401(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(acceptOnly("never", &requests, nil))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	fetches := authSSHServer(t, cc, newTestJWT(t))
+
+	resp, err := cc.AuthedRequest("GET", "/v1/test", nil, nil)
+	if err == nil {
+		t.Fatal("expected an error when the new JWT is refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the 401 response, got %v", resp)
+	}
+	resp.Body.Close() // nolint:errcheck
+	if requests.Load() != 2 || fetches.Load() != 1 {
+		t.Errorf("expected 2 requests and 1 JWT fetch, got %d and %d", requests.Load(), fetches.Load())
+	}
+}
+
+func TestAuthedRequest_UnreplayableBodyNotRetriedOn401(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(acceptOnly("never", &requests, nil))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	fetches := authSSHServer(t, cc, newTestJWT(t))
+
+	body := io.NopCloser(strings.NewReader("payload"))
+	resp, err := cc.AuthedRequest("POST", "/v1/test", nil, body)
+	if err == nil {
+		t.Fatal("expected the 401 to be returned")
+	}
+	resp.Body.Close() // nolint:errcheck
+	if requests.Load() != 1 || fetches.Load() != 0 {
+		t.Errorf("expected 1 request and no JWT fetch, got %d and %d", requests.Load(), fetches.Load())
+	}
+}
+
+func TestAuthedJSONRequest_ConcurrentRefresh(t *testing.T) {
+	token := newTestJWT(t)
+	var requests atomic.Int32
+	ts := httptest.NewServer(acceptOnly(token, &requests, nil))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	fetches := authSSHServer(t, cc, token)
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- cc.AuthedJSONRequest("GET", fmt.Sprintf("/v1/test/%d", i), nil, &struct{}{})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected every request to succeed, got %v", err)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("expected the JWT to be fetched once, got %d", fetches.Load())
+	}
+}
+
+func TestAuthClaimsAndExpiry(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "charm-id",
+		"exp": exp.Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewClientForTest(&Config{Host: "localhost"})
+	cc.auth.JWT = token
+
+	got, err := cc.AuthExpiry()
+	if err != nil {
+		t.Fatalf("AuthExpiry failed: %v", err)
+	}
+	if !got.Equal(exp) {
+		t.Errorf("expected expiry %v, got %v", exp, got)
+	}
+	claims, err := cc.AuthClaims()
+	if err != nil {
+		t.Fatalf("AuthClaims failed: %v", err)
+	}
+	if claims["sub"] != "charm-id" {
+		t.Errorf("expected subject charm-id, got %v", claims["sub"])
+	}
+}
+
+func TestAuthExpiry_InvalidJWT(t *testing.T) {
+	cc := NewClientForTest(&Config{Host: "localhost"})
+	if _, err := cc.AuthExpiry(); err == nil {
+		t.Error("expected an error for a malformed JWT")
+	}
+}
@@ -4,18 +4,20 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	env "github.com/caarlos0/env/v6"
 	charm "github.com/charmbracelet/charm/proto"
@@ -28,18 +30,56 @@ import (
 
 var nameValidator = regexp.MustCompile("^[a-zA-Z0-9]{1,50}$")
 
+// ErrNameTaken is returned by SetName when another user already has the
+// name. It's charm.ErrNameTaken, so errors.Is matches either.
+var ErrNameTaken = charm.ErrNameTaken
+
+// ErrMissingUser is returned by SetName and Bio when the server has no
+// account for the client, such as after it's been deleted. It's
+// charm.ErrMissingUser, so errors.Is matches either.
+var ErrMissingUser = charm.ErrMissingUser
+
 // Config contains the Charm client configuration.
 type Config struct {
 	Host        string `env:"CHARM_HOST" envDefault:"charm.2389.dev"`
 	SSHPort     int    `env:"CHARM_SSH_PORT" envDefault:"35353"`
 	HTTPPort    int    `env:"CHARM_HTTP_PORT" envDefault:"35354"`
+	HealthPort  int    `env:"CHARM_HEALTH_PORT" envDefault:"35356"`
 	Debug       bool   `env:"CHARM_DEBUG" envDefault:"false"`
 	Logfile     string `env:"CHARM_LOGFILE" envDefault:""`
 	KeyType     string `env:"CHARM_KEY_TYPE" envDefault:"ed25519"`
 	DataDir     string `env:"CHARM_DATA_DIR" envDefault:""`
 	IdentityKey string `env:"CHARM_IDENTITY_KEY" envDefault:""`
+	// IdentityKeys are tried in order when authenticating. IdentityKey, if
+	// set, is tried after them.
+	IdentityKeys []string `env:"CHARM_IDENTITY_KEYS" envSeparator:","`
+	// SSHDialTimeout bounds connecting to the SSH server.
+	SSHDialTimeout time.Duration `env:"CHARM_SSH_DIAL_TIMEOUT" envDefault:"30s"`
+	// HTTPTimeout bounds each HTTP request, including reading the response.
+	HTTPTimeout time.Duration `env:"CHARM_HTTP_TIMEOUT" envDefault:"30s"`
+	// CommandTimeout bounds running an SSH command, including connecting,
+	// when it's called without a context.
+	CommandTimeout time.Duration `env:"CHARM_COMMAND_TIMEOUT" envDefault:"30s"`
+	// HTTPRetries is how many times a request failing with a connection
+	// error, a 429 or a 5xx response is retried. See WithRetry for which
+	// requests are retried; WithRetry overrides it.
+	HTTPRetries int `env:"CHARM_HTTP_RETRIES" envDefault:"0"`
+	// HTTPRetryBackoff is the delay before the first retry, doubling with
+	// each retry.
+	HTTPRetryBackoff time.Duration `env:"CHARM_HTTP_RETRY_BACKOFF" envDefault:"1s"`
+	// ProxyJump is an SSH jump host, given as [user@]host[:port], to reach
+	// the server's SSH port through, like OpenSSH's ProxyJump.
+	ProxyJump string `env:"CHARM_PROXY_JUMP"`
+	// ProxyJumpHTTP sends HTTP requests through the ProxyJump host too.
+	ProxyJumpHTTP bool `env:"CHARM_PROXY_JUMP_HTTP" envDefault:"false"`
+	// HTTPProxy is the URL of a proxy for HTTP requests. When it's unset,
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+	HTTPProxy string `env:"CHARM_HTTP_PROXY"`
 }
 
+// defaultTimeout is used for timeouts left unset in a Config.
+const defaultTimeout = 30 * time.Second
+
 // Client is the Charm client.
 type Client struct {
 	Config               *Config
@@ -52,6 +92,11 @@ type Client struct {
 	plainTextEncryptKeys []*charm.EncryptKey
 	authKeyPaths         []string
 	encryptKeyLock       *sync.Mutex
+	retryAttempts        int
+	retryBaseDelay       time.Duration
+	retryNonIdempotent   bool
+	identityKeyUsed      atomic.Value
+	signer               ssh.Signer
 }
 
 // ConfigFromEnv loads the configuration from the environment.
@@ -64,34 +109,56 @@ func ConfigFromEnv() (*Config, error) {
 }
 
 // NewClient creates a new Charm client.
-func NewClient(cfg *Config) (*Client, error) {
+func NewClient(cfg *Config, opts ...Option) (*Client, error) {
 	cc := &Client{
 		Config:         cfg,
 		auth:           &charm.Auth{},
 		authLock:       &sync.Mutex{},
 		encryptKeyLock: &sync.Mutex{},
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:          100,
-				MaxIdleConnsPerHost:   10,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		},
-	}
-
-	var sshKeys []string
+	}
 	var err error
-	if cfg.IdentityKey != "" {
-		sshKeys = []string{cfg.IdentityKey}
+	cc.httpClient, err = cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	cc.retryAttempts = cfg.HTTPRetries + 1
+	cc.retryBaseDelay = cfg.HTTPRetryBackoff
+	if cc.retryBaseDelay <= 0 {
+		cc.retryBaseDelay = defaultRetryBackoff
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+
+	var signers []ssh.Signer
+	if cc.signer != nil {
+		if err := checkKeyAlgo(cc.signer); err != nil {
+			return nil, err
+		}
+		signers = []ssh.Signer{cc.signer}
 	} else {
+		signers, err = cc.keySigners()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cc.sshConfig = &ssh.ClientConfig{
+		User:            "charm",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // nolint
+		Timeout:         orDefaultTimeout(cfg.SSHDialTimeout),
+	}
+	return cc, nil
+}
+
+// keySigners returns signers for the configured identity keys, or for the
+// keys found in the data directory, generating one if there are none.
+func (cc *Client) keySigners() ([]ssh.Signer, error) {
+	cfg := cc.Config
+	sshKeys := cfg.identityKeys()
+	if len(sshKeys) == 0 {
+		var err error
 		sshKeys, err = cc.findAuthKeys(cfg.KeyType)
 		if err != nil {
 			return nil, err
@@ -113,34 +180,36 @@ func NewClient(cfg *Config) (*Client, error) {
 		}
 	}
 
-	var pkam ssh.AuthMethod
-	for i := 0; i < len(sshKeys); i++ {
-		signer, err := parseKey(sshKeys[i])
-		if err != nil && i == len(sshKeys)-1 {
-			return nil, charm.ErrMissingSSHAuth
+	// Offer every usable key; the server accepts the first one linked to the
+	// account
+	var signers []ssh.Signer
+	err := charm.ErrMissingSSHAuth
+	for _, kp := range sshKeys {
+		signer, perr := parseKey(kp)
+		if perr != nil {
+			err = charm.ErrMissingSSHAuth
+			continue
 		}
-		if err := checkKeyAlgo(signer); err != nil && i == len(sshKeys)-1 {
-			return nil, err
+		if aerr := checkKeyAlgo(signer); aerr != nil {
+			err = aerr
+			continue
 		}
-		pkam = ssh.PublicKeys(signer)
+		signers = append(signers, cc.identitySigner(kp, signer))
+		cc.authKeyPaths = append(cc.authKeyPaths, kp)
 	}
-	cc.authKeyPaths = sshKeys
-
-	cc.sshConfig = &ssh.ClientConfig{
-		User:            "charm",
-		Auth:            []ssh.AuthMethod{pkam},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // nolint
+	if len(signers) == 0 && len(sshKeys) > 0 {
+		return nil, err
 	}
-	return cc, nil
+	return signers, nil
 }
 
 // NewClientWithDefaults creates a new Charm client with default values.
-func NewClientWithDefaults() (*Client, error) {
+func NewClientWithDefaults(opts ...Option) (*Client, error) {
 	cfg, err := ConfigFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	cc, err := NewClient(cfg)
+	cc, err := NewClient(cfg, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +218,7 @@ func NewClientWithDefaults() (*Client, error) {
 
 // JWT returns a JSON web token for the user.
 func (cc *Client) JWT(aud ...string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := cc.commandContext()
 	defer cancel()
 	return cc.JWTWithContext(ctx, aud...)
 }
@@ -161,7 +230,7 @@ func (cc *Client) JWTWithContext(ctx context.Context, aud ...string) (string, er
 		return "", err
 	}
 	defer s.Close() // nolint:errcheck
-	jwt, err := s.Output(strings.Join(append([]string{"jwt"}, aud...), " "))
+	jwt, err := s.OutputContext(ctx, strings.Join(append([]string{"jwt"}, aud...), " "))
 	if err != nil {
 		return "", err
 	}
@@ -170,7 +239,7 @@ func (cc *Client) JWTWithContext(ctx context.Context, aud ...string) (string, er
 
 // ID returns the user's ID.
 func (cc *Client) ID() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := cc.commandContext()
 	defer cancel()
 	return cc.IDWithContext(ctx)
 }
@@ -182,7 +251,7 @@ func (cc *Client) IDWithContext(ctx context.Context) (string, error) {
 		return "", err
 	}
 	defer s.Close() // nolint:errcheck
-	id, err := s.Output("id")
+	id, err := s.OutputContext(ctx, "id")
 	if err != nil {
 		return "", err
 	}
@@ -191,7 +260,7 @@ func (cc *Client) IDWithContext(ctx context.Context) (string, error) {
 
 // AuthorizedKeys returns the keys linked to a user's account.
 func (cc *Client) AuthorizedKeys() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := cc.commandContext()
 	defer cancel()
 	return cc.AuthorizedKeysWithContext(ctx)
 }
@@ -203,16 +272,17 @@ func (cc *Client) AuthorizedKeysWithContext(ctx context.Context) (string, error)
 		return "", err
 	}
 	defer s.Close() // nolint:errcheck
-	keys, err := s.Output("keys")
+	keys, err := s.OutputContext(ctx, "keys")
 	if err != nil {
 		return "", err
 	}
 	return string(keys), nil
 }
 
-// AuthorizedKeysWithMetadata fetches keys linked to a user's account, with metadata.
+// AuthorizedKeysWithMetadata fetches keys linked to a user's account, with
+// metadata such as creation dates and labels. Keys are listed oldest first.
 func (cc *Client) AuthorizedKeysWithMetadata() (*charm.Keys, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := cc.commandContext()
 	defer cancel()
 	return cc.AuthorizedKeysWithMetadataWithContext(ctx)
 }
@@ -225,7 +295,42 @@ func (cc *Client) AuthorizedKeysWithMetadataWithContext(ctx context.Context) (*c
 	}
 	defer s.Close() // nolint:errcheck
 
-	b, err := s.Output("api-keys")
+	b, err := s.OutputContext(ctx, "api-keys")
+	if err != nil {
+		return nil, err
+	}
+
+	var k charm.Keys
+	err = json.Unmarshal(b, &k)
+	return &k, err
+}
+
+// AuthorizedKeysPage fetches up to limit of the keys linked to a user's
+// account starting at offset, with metadata. Keys are listed oldest first and
+// the result's Total is the number of keys on the account, so an account with
+// many linked machines can be listed a page at a time.
+func (cc *Client) AuthorizedKeysPage(offset int, limit int) (*charm.Keys, error) {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.AuthorizedKeysPageWithContext(ctx, offset, limit)
+}
+
+// AuthorizedKeysPageWithContext fetches a page of the keys linked to a user's
+// account, with metadata, with context.
+func (cc *Client) AuthorizedKeysPageWithContext(ctx context.Context, offset int, limit int) (*charm.Keys, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("invalid limit %d", limit)
+	}
+	s, err := cc.sshSessionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close() // nolint:errcheck
+
+	b, err := s.OutputContext(ctx, fmt.Sprintf("api-keys %d %d", offset, limit))
 	if err != nil {
 		return nil, err
 	}
@@ -242,7 +347,15 @@ func (cc *Client) AuthKeyPaths() []string {
 
 // UnlinkAuthorizedKey removes an authorized key from the user's Charm account.
 func (cc *Client) UnlinkAuthorizedKey(key string) error {
-	s, err := cc.sshSession()
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.UnlinkAuthorizedKeyWithContext(ctx, key)
+}
+
+// UnlinkAuthorizedKeyWithContext removes an authorized key from the user's
+// Charm account with context.
+func (cc *Client) UnlinkAuthorizedKeyWithContext(ctx context.Context, key string) error {
+	s, err := cc.sshSessionWithContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -259,7 +372,7 @@ func (cc *Client) UnlinkAuthorizedKey(key string) error {
 	if err != nil {
 		return err
 	}
-	b, err := s.Output(fmt.Sprintf("api-unlink %s", string(j)))
+	b, err := s.OutputContext(ctx, fmt.Sprintf("api-unlink %s", string(j)))
 	if err != nil {
 		return err
 	}
@@ -269,6 +382,144 @@ func (cc *Client) UnlinkAuthorizedKey(key string) error {
 	return nil
 }
 
+// UnlinkKey removes one of the keys linked to the user's Charm account. Unlike
+// UnlinkAuthorizedKey it won't remove the key the client is using, returning
+// charm.ErrUnlinkActiveKey instead, and it returns charm.ErrMissingKey if the
+// key isn't linked to the account.
+func (cc *Client) UnlinkKey(publicKey string) error {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.UnlinkKeyWithContext(ctx, publicKey)
+}
+
+// UnlinkKeyWithContext removes one of the keys linked to the user's Charm
+// account with context. See UnlinkKey.
+func (cc *Client) UnlinkKeyWithContext(ctx context.Context, publicKey string) error {
+	want, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return cc.unlinkKeyMatching(ctx, func(k ssh.PublicKey) bool {
+		return bytes.Equal(k.Marshal(), want.Marshal())
+	})
+}
+
+// UnlinkKeyByFingerprint removes the key with the given SHA256 fingerprint,
+// as printed by ssh-keygen -l, from the user's Charm account. The "SHA256:"
+// prefix is optional. Like UnlinkKey, it won't remove the key the client is
+// using.
+func (cc *Client) UnlinkKeyByFingerprint(fingerprint string) error {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.UnlinkKeyByFingerprintWithContext(ctx, fingerprint)
+}
+
+// UnlinkKeyByFingerprintWithContext removes the key with the given SHA256
+// fingerprint from the user's Charm account with context. See
+// UnlinkKeyByFingerprint.
+func (cc *Client) UnlinkKeyByFingerprintWithContext(ctx context.Context, fingerprint string) error {
+	fp := "SHA256:" + strings.TrimPrefix(fingerprint, "SHA256:")
+	return cc.unlinkKeyMatching(ctx, func(k ssh.PublicKey) bool {
+		return ssh.FingerprintSHA256(k) == fp
+	})
+}
+
+// unlinkKeyMatching unlinks the first of the user's keys match reports true
+// for, refusing to unlink the active key.
+func (cc *Client) unlinkKeyMatching(ctx context.Context, match func(ssh.PublicKey) bool) error {
+	keys, err := cc.AuthorizedKeysWithMetadataWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	for i, k := range keys.Keys {
+		pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.Key))
+		if err != nil || !match(pk) {
+			continue
+		}
+		if i == keys.ActiveKey {
+			return charm.ErrUnlinkActiveKey
+		}
+		return cc.UnlinkAuthorizedKeyWithContext(ctx, k.Key)
+	}
+	return charm.ErrMissingKey
+}
+
+// SetKeyLabel labels one of the keys linked to the user's account, such as
+// with the name of the device it's on. keyID is the ID of a key returned by
+// AuthorizedKeysWithMetadata, and an empty label removes the key's label.
+// Labels are limited to charm.MaxKeyLabelLength characters.
+func (cc *Client) SetKeyLabel(keyID int, label string) error {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.SetKeyLabelWithContext(ctx, keyID, label)
+}
+
+// SetKeyLabelWithContext labels one of the keys linked to the user's account
+// with context.
+func (cc *Client) SetKeyLabelWithContext(ctx context.Context, keyID int, label string) error {
+	if utf8.RuneCountInString(label) > charm.MaxKeyLabelLength {
+		return charm.ErrKeyLabelInvalid
+	}
+	s, err := cc.sshSessionWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close() // nolint:errcheck
+	in, err := s.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(in).Encode(charm.KeyLabelRequest{ID: keyID, Label: label}); err != nil {
+		return err
+	}
+	b, err := s.OutputContext(ctx, "api-key-label")
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	var msg charm.Message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return fmt.Errorf("could not label key: %s", b)
+	}
+	switch msg.Message {
+	case charm.ErrMissingKey.Error():
+		return charm.ErrMissingKey
+	case charm.ErrKeyLabelInvalid.Error():
+		return charm.ErrKeyLabelInvalid
+	}
+	return fmt.Errorf("could not label key: %s", msg.Message)
+}
+
+// commandContext returns a context bounded by the configured CommandTimeout,
+// for SSH commands called without a context.
+func (cc *Client) commandContext() (context.Context, context.CancelFunc) {
+	var d time.Duration
+	if cc.Config != nil {
+		d = cc.Config.CommandTimeout
+	}
+	return context.WithTimeout(context.Background(), orDefaultTimeout(d))
+}
+
+// httpContext returns a context bounded by the configured HTTPTimeout, for
+// HTTP requests made without a context.
+func (cc *Client) httpContext() (context.Context, context.CancelFunc) {
+	var d time.Duration
+	if cc.Config != nil {
+		d = cc.Config.HTTPTimeout
+	}
+	return context.WithTimeout(context.Background(), orDefaultTimeout(d))
+}
+
+// orDefaultTimeout returns d, or defaultTimeout if d isn't set.
+func orDefaultTimeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultTimeout
+	}
+	return d
+}
+
 // KeygenType returns the keygen key type.
 func (cfg *Config) KeygenType() keygen.KeyType {
 	kt := strings.ToLower(cfg.KeyType)
@@ -284,7 +535,7 @@ func (cfg *Config) KeygenType() keygen.KeyType {
 
 // SetName sets the account's username.
 func (cc *Client) SetName(name string) (*charm.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := cc.httpContext()
 	defer cancel()
 	return cc.SetNameWithContext(ctx, name)
 }
@@ -296,7 +547,7 @@ func (cc *Client) SetNameWithContext(ctx context.Context, name string) (*charm.U
 	}
 	u := &charm.User{}
 	u.Name = name
-	err := cc.AuthedJSONRequestWithContext(ctx, "POST", "/v1/bio", u, u)
+	err := cc.userJSONRequest(ctx, "POST", "/v1/bio", u, u)
 	if err != nil {
 		return nil, err
 	}
@@ -305,7 +556,7 @@ func (cc *Client) SetNameWithContext(ctx context.Context, name string) (*charm.U
 
 // Bio returns the user's profile.
 func (cc *Client) Bio() (*charm.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := cc.httpContext()
 	defer cancel()
 	return cc.BioWithContext(ctx)
 }
@@ -317,7 +568,7 @@ func (cc *Client) BioWithContext(ctx context.Context) (*charm.User, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = cc.AuthedJSONRequestWithContext(ctx, "GET", fmt.Sprintf("/v1/id/%s", id), u, u)
+	err = cc.userJSONRequest(ctx, "GET", fmt.Sprintf("/v1/id/%s", id), u, u)
 	if err != nil {
 		return nil, err
 	}
@@ -327,41 +578,89 @@ func (cc *Client) BioWithContext(ctx context.Context) (*charm.User, error) {
 	return u, nil
 }
 
+// userJSONRequest sends an authorized JSON request about the user's account,
+// returning ErrNameTaken and ErrMissingUser for the server's 409 and 404
+// responses.
+func (cc *Client) userJSONRequest(ctx context.Context, method string, path string, reqBody interface{}, respBody interface{}) error {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(reqBody); err != nil {
+		return err
+	}
+	headers := http.Header{
+		"Content-Type": []string{"application/json"},
+	}
+	resp, err := cc.AuthedRequestWithContext(ctx, method, path, headers, buf)
+	if resp != nil {
+		defer resp.Body.Close() // nolint:errcheck
+		switch resp.StatusCode {
+		case http.StatusConflict:
+			return ErrNameTaken
+		case http.StatusNotFound:
+			return ErrMissingUser
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
+
 // ValidateName validates a given name.
 func ValidateName(name string) bool {
 	return nameValidator.MatchString(name)
 }
 
-func (cc *Client) sshSession() (*ssh.Session, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	return cc.sshSessionWithContext(ctx)
+// sshSession is an SSH session along with the connection it runs on, so
+// closing the session also closes the connection.
+type sshSession struct {
+	*ssh.Session
+	conn *ssh.Client
 }
 
-func (cc *Client) sshSessionWithContext(ctx context.Context) (*ssh.Session, error) {
-	cfg := cc.Config
+// Close closes the session and its connection.
+func (s *sshSession) Close() error {
+	_ = s.Session.Close()
+	return s.conn.Close()
+}
 
+// OutputContext runs cmd and returns its standard output like Output, but
+// closes the session if ctx is done before the command finishes.
+func (s *sshSession) OutputContext(ctx context.Context, cmd string) ([]byte, error) {
+	defer s.watch(ctx)()
+	b, err := s.Output(cmd)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return b, err
+}
+
+// watch closes the session when ctx is done, interrupting any command
+// running on it. Calling the returned function stops watching.
+func (s *sshSession) watch(ctx context.Context) func() bool {
+	return context.AfterFunc(ctx, func() { _ = s.Close() })
+}
+
+func (cc *Client) sshSessionWithContext(ctx context.Context) (*sshSession, error) {
 	// Create a channel to receive the result
 	type result struct {
-		session *ssh.Session
-		conn    *ssh.Client
+		session *sshSession
 		err     error
 	}
 	resultCh := make(chan result, 1)
 
 	go func() {
-		c, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", cfg.Host, cfg.SSHPort), cc.sshConfig)
+		c, err := cc.dialSSH()
 		if err != nil {
-			resultCh <- result{nil, nil, err}
+			resultCh <- result{nil, err}
 			return
 		}
 		s, err := c.NewSession()
 		if err != nil {
 			c.Close() // nolint:errcheck
-			resultCh <- result{nil, nil, err}
+			resultCh <- result{nil, err}
 			return
 		}
-		resultCh <- result{s, c, nil}
+		resultCh <- result{&sshSession{Session: s, conn: c}, nil}
 	}()
 
 	select {
@@ -374,9 +673,6 @@ func (cc *Client) sshSessionWithContext(ctx context.Context) (*ssh.Session, erro
 				if res.session != nil {
 					res.session.Close() // nolint:errcheck
 				}
-				if res.conn != nil {
-					res.conn.Close() // nolint:errcheck
-				}
 			case <-time.After(100 * time.Millisecond):
 				// Connection goroutine didn't finish in time - it will clean up itself
 			}
@@ -1,6 +1,10 @@
 package client
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"sync"
@@ -62,3 +66,26 @@ func TestNameValidation(t *testing.T) {
 		t.Error("validated a 51-character-string, which should have failed")
 	}
 }
+
+func TestSetNameErrors(t *testing.T) {
+	status := http.StatusConflict
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(charm.Message{Message: "nope"})
+	}))
+	defer ts.Close()
+	cc := NewClientForTestServer(ts)
+
+	if _, err := cc.SetName("taken"); !errors.Is(err, ErrNameTaken) || !errors.Is(err, charm.ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+	status = http.StatusNotFound
+	if _, err := cc.SetName("deleted"); !errors.Is(err, ErrMissingUser) {
+		t.Errorf("expected ErrMissingUser, got %v", err)
+	}
+	status = http.StatusInternalServerError
+	if _, err := cc.SetName("broken"); err == nil || errors.Is(err, ErrNameTaken) || errors.Is(err, ErrMissingUser) {
+		t.Errorf("expected a generic server error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	gliderssh "github.com/charmbracelet/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewsListWithContext_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	cc := NewClientForTestServer(ts)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cc.NewsListWithContext(ctx, nil, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expected the request to be cancelled with the context")
+	}
+}
+
+func TestAuthWithContext_UsesCachedAuth(t *testing.T) {
+	cc := NewClientForTest(&Config{Host: "localhost"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Valid cached claims mean no connection is made, even with a done context
+	auth, err := cc.AuthWithContext(ctx)
+	if err != nil {
+		t.Fatalf("expected cached auth, got %v", err)
+	}
+	if auth.JWT != "test-token" {
+		t.Errorf("expected cached JWT, got %q", auth.JWT)
+	}
+}
+
+func TestAuthWithContext_Cancelled(t *testing.T) {
+	cc := NewClientForTest(&Config{Host: "localhost", SSHPort: 1})
+	cc.sshConfig = &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()} // nolint
+	cc.InvalidateAuth()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cc.AuthWithContext(ctx)
+	var authErr charm.ErrAuthFailed
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+}
+
+// hungSSHServer starts an SSH server that accepts any client and runs every
+// command forever.
+func hungSSHServer(t *testing.T) *Client {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &gliderssh.Server{Handler: func(s gliderssh.Session) {
+		<-s.Context().Done()
+	}}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	cc := NewClientForTest(&Config{Host: "127.0.0.1", SSHPort: l.Addr().(*net.TCPAddr).Port})
+	cc.sshConfig = &ssh.ClientConfig{User: "charm", HostKeyCallback: ssh.InsecureIgnoreHostKey()} // nolint
+	return cc
+}
+
+func TestIDWithContext_HungCommand(t *testing.T) {
+	cc := hungSSHServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cc.IDWithContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expected the deadline to interrupt the running command")
+	}
+}
+
+func TestLinkWithContext_Cancelled(t *testing.T) {
+	cc := hungSSHServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	err := cc.LinkWithContext(ctx, nil, "code")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -2,22 +2,36 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/google/uuid"
+	homedir "github.com/mitchellh/go-homedir"
 	"github.com/muesli/sasquatch"
 )
 
 // KeyForID returns the decrypted EncryptKey for a given key ID.
 func (cc *Client) KeyForID(gid string) (*charm.EncryptKey, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.KeyForIDWithContext(ctx, gid)
+}
+
+// KeyForIDWithContext returns the decrypted EncryptKey for a given key ID with
+// context.
+func (cc *Client) KeyForIDWithContext(ctx context.Context, gid string) (*charm.EncryptKey, error) {
 	if len(cc.plainTextEncryptKeys) == 0 {
-		err := cc.cryptCheck()
+		err := cc.cryptCheck(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed crypt check: %w", err)
 		}
@@ -41,11 +55,24 @@ func (cc *Client) DefaultEncryptKey() (*charm.EncryptKey, error) {
 	return cc.KeyForID("")
 }
 
+// DefaultEncryptKeyWithContext returns the default EncryptKey for an authed
+// user with context.
+func (cc *Client) DefaultEncryptKeyWithContext(ctx context.Context) (*charm.EncryptKey, error) {
+	return cc.KeyForIDWithContext(ctx, "")
+}
+
 func (cc *Client) findIdentities() ([]sasquatch.Identity, error) {
 	keys, err := cc.findAuthKeys(cc.Config.KeyType)
 	if err != nil {
 		return nil, err
 	}
+	// Encrypt keys may be encrypted for any configured identity key
+	for _, kp := range cc.authKeyPaths {
+		p, err := homedir.Expand(kp)
+		if err == nil && !slices.Contains(keys, p) {
+			keys = append(keys, p)
+		}
+	}
 	var ids []sasquatch.Identity
 	for _, v := range keys {
 		id, err := sasquatch.ParseIdentitiesFile(v)
@@ -58,13 +85,239 @@ func (cc *Client) findIdentities() ([]sasquatch.Identity, error) {
 
 // EncryptKeys returns all of the symmetric encrypt keys for the authed user.
 func (cc *Client) EncryptKeys() ([]*charm.EncryptKey, error) {
-	if err := cc.cryptCheck(); err != nil {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.EncryptKeysWithContext(ctx)
+}
+
+// EncryptKeysWithContext returns all of the symmetric encrypt keys for the
+// authed user with context.
+func (cc *Client) EncryptKeysWithContext(ctx context.Context) ([]*charm.EncryptKey, error) {
+	if err := cc.cryptCheck(ctx); err != nil {
 		return nil, err
 	}
 	return cc.plainTextEncryptKeys, nil
 }
 
-func (cc *Client) addEncryptKey(pk string, gid string, key string, createdAt *time.Time) error {
+// RotateEncryptKey creates a new encrypt key, shares it with every public key
+// linked to the account and makes it the default, so it's used for all new
+// encryption. Old keys are kept so existing data can still be decrypted; use
+// the ReEncryptAll methods of the kv and fs packages to re-encrypt it with
+// the new key.
+func (cc *Client) RotateEncryptKey() (*charm.EncryptKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return cc.RotateEncryptKeyWithContext(ctx)
+}
+
+// RotateEncryptKeyWithContext creates a new default encrypt key with context.
+func (cc *Client) RotateEncryptKeyWithContext(ctx context.Context) (*charm.EncryptKey, error) {
+	eks, err := cc.EncryptKeysWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cks, err := cc.AuthorizedKeysWithMetadataWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k, err := newEncryptKey()
+	if err != nil {
+		return nil, err
+	}
+	// The new key must sort after the existing ones by creation time, which
+	// decides the key used to encrypt file names (see crypt.EncryptLookupField)
+	createdAt := time.Now().UTC()
+	for _, ek := range eks {
+		if ek.CreatedAt != nil && !createdAt.After(*ek.CreatedAt) {
+			createdAt = ek.CreatedAt.Add(time.Second)
+		}
+	}
+	ek := &charm.EncryptKey{
+		ID:        uuid.New().String(),
+		Key:       k,
+		CreatedAt: &createdAt,
+	}
+	for _, pk := range cks.Keys {
+		if err := cc.addEncryptKey(ctx, pk.Key, ek.ID, ek.Key, ek.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := cc.SetDefaultEncryptKeyWithContext(ctx, ek.ID); err != nil {
+		return nil, err
+	}
+	return ek, nil
+}
+
+// SetDefaultEncryptKey makes the encrypt key with the given ID the default,
+// so it's used for all new encryption in charm/kv and charm/fs. Other keys are
+// kept for decryption, and EncryptKeys lists the default first. It returns
+// charm.ErrMissingEncryptKey if there's no key with the ID.
+func (cc *Client) SetDefaultEncryptKey(id string) error {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.SetDefaultEncryptKeyWithContext(ctx, id)
+}
+
+// SetDefaultEncryptKeyWithContext makes the encrypt key with the given ID the
+// default with context.
+func (cc *Client) SetDefaultEncryptKeyWithContext(ctx context.Context, id string) error {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(&charm.EncryptKey{ID: id}); err != nil {
+		return err
+	}
+	headers := http.Header{
+		"Content-Type": []string{"application/json"},
+	}
+	resp, err := cc.AuthedRequestWithContext(ctx, "POST", "/v1/encrypt-key/default", headers, buf)
+	if resp != nil {
+		defer resp.Body.Close() // nolint:errcheck
+		if resp.StatusCode == http.StatusNotFound {
+			return charm.ErrMissingEncryptKey
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Fetch the keys again, with the new default first
+	cc.encryptKeyLock.Lock()
+	cc.plainTextEncryptKeys = nil
+	cc.encryptKeyLock.Unlock()
+	cc.InvalidateAuth()
+	return nil
+}
+
+// DeleteEncryptKey deletes the encrypt key with the given ID, such as one
+// that's been compromised. Data encrypted with the key can no longer be
+// decrypted, so re-encrypt it with another key first. File names in charm/fs
+// are encrypted with the oldest key, so deleting that key makes them
+// unreadable. It returns
+// charm.ErrLastEncryptKey rather than delete the account's only encrypt key,
+// and charm.ErrMissingEncryptKey if there's no key with the ID.
+func (cc *Client) DeleteEncryptKey(id string) error {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.DeleteEncryptKeyWithContext(ctx, id)
+}
+
+// DeleteEncryptKeyWithContext deletes the encrypt key with the given ID with
+// context.
+func (cc *Client) DeleteEncryptKeyWithContext(ctx context.Context, id string) error {
+	resp, err := cc.AuthedRequestWithContext(ctx, "DELETE", "/v1/encrypt-key/"+url.PathEscape(id), nil, nil)
+	if resp != nil {
+		defer resp.Body.Close() // nolint:errcheck
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return charm.ErrMissingEncryptKey
+		case http.StatusConflict:
+			return charm.ErrLastEncryptKey
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Fetch the remaining keys again
+	cc.encryptKeyLock.Lock()
+	cc.plainTextEncryptKeys = nil
+	cc.encryptKeyLock.Unlock()
+	cc.InvalidateAuth()
+	return nil
+}
+
+// ExportEncryptKeys returns the decrypted encrypt keys of the account, to
+// back up offline for disaster recovery. Anyone holding them can decrypt all
+// of the account's data, so store them as carefully as a private key.
+// ImportEncryptKeys adds them back to an account.
+func (cc *Client) ExportEncryptKeys() ([]*charm.EncryptKey, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.ExportEncryptKeysWithContext(ctx)
+}
+
+// ExportEncryptKeysWithContext returns the decrypted encrypt keys of the
+// account with context.
+func (cc *Client) ExportEncryptKeysWithContext(ctx context.Context) ([]*charm.EncryptKey, error) {
+	eks, err := cc.EncryptKeysWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*charm.EncryptKey, 0, len(eks))
+	for _, ek := range eks {
+		// The public key a key was shared with means nothing to another
+		// account, so leave it out
+		keys = append(keys, &charm.EncryptKey{
+			ID:        ek.ID,
+			Key:       ek.Key,
+			CreatedAt: ek.CreatedAt,
+		})
+	}
+	return keys, nil
+}
+
+// ImportEncryptKeys adds encrypt keys exported with ExportEncryptKeys to the
+// account, sharing each with every linked public key, so data encrypted with
+// them can be decrypted again, such as from a new account after losing the
+// old one. Keys keep their IDs and creation dates, and keys the account
+// already has are skipped. The default key isn't changed; use
+// SetDefaultEncryptKey to pick an imported key for new encryption.
+func (cc *Client) ImportEncryptKeys(keys []*charm.EncryptKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return cc.ImportEncryptKeysWithContext(ctx, keys)
+}
+
+// ImportEncryptKeysWithContext adds exported encrypt keys to the account with
+// context.
+func (cc *Client) ImportEncryptKeysWithContext(ctx context.Context, keys []*charm.EncryptKey) error {
+	for _, k := range keys {
+		if k == nil || k.ID == "" || k.Key == "" {
+			return fmt.Errorf("invalid encrypt key: missing id or key")
+		}
+	}
+	eks, err := cc.EncryptKeysWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	cks, err := cc.AuthorizedKeysWithMetadataWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	imported := false
+	for _, k := range keys {
+		if slices.ContainsFunc(eks, func(ek *charm.EncryptKey) bool { return ek.ID == k.ID }) {
+			continue
+		}
+		for _, pk := range cks.Keys {
+			if err := cc.addEncryptKey(ctx, pk.Key, k.ID, k.Key, k.CreatedAt); err != nil {
+				return err
+			}
+		}
+		imported = true
+		eks = append(eks, k)
+	}
+	if !imported {
+		return nil
+	}
+
+	// Fetch the keys again, with the imported ones
+	cc.encryptKeyLock.Lock()
+	cc.plainTextEncryptKeys = nil
+	cc.encryptKeyLock.Unlock()
+	cc.InvalidateAuth()
+	return nil
+}
+
+// newEncryptKey returns a new random encrypt key.
+func newEncryptKey() (string, error) {
+	b := make([]byte, 64)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (cc *Client) addEncryptKey(ctx context.Context, pk string, gid string, key string, createdAt *time.Time) error {
 	buf := bytes.NewBuffer(nil)
 	r, err := sasquatch.ParseRecipient(pk)
 	if err != nil {
@@ -88,30 +341,31 @@ func (cc *Client) addEncryptKey(pk string, gid string, key string, createdAt *ti
 	ek.Key = encKey
 	ek.CreatedAt = createdAt
 
-	return cc.AuthedJSONRequest("POST", "/v1/encrypt-key", &ek, nil)
+	return cc.AuthedJSONRequestWithContext(ctx, "POST", "/v1/encrypt-key", &ek, nil)
 }
 
-func (cc *Client) cryptCheck() error {
+func (cc *Client) cryptCheck(ctx context.Context) error {
 	cc.encryptKeyLock.Lock()
 	defer cc.encryptKeyLock.Unlock()
-	auth, err := cc.Auth()
+	auth, err := cc.AuthWithContext(ctx)
 	if err != nil {
 		return err
 	}
 
 	if len(auth.EncryptKeys) == 0 && len(cc.plainTextEncryptKeys) == 0 {
 		// if there are no encrypt keys, make one for the public key returned from auth
-		b := make([]byte, 64)
-		_, err := rand.Read(b)
+		k, err := newEncryptKey()
 		if err != nil {
 			return err
 		}
-		k := base64.StdEncoding.EncodeToString(b)
+		// Record the creation time here too, so the cached key has it
+		createdAt := time.Now().UTC()
 		ek := &charm.EncryptKey{}
 		ek.PublicKey = auth.PublicKey
 		ek.ID = uuid.New().String()
 		ek.Key = k
-		err = cc.addEncryptKey(ek.PublicKey, ek.ID, ek.Key, nil)
+		ek.CreatedAt = &createdAt
+		err = cc.addEncryptKey(ctx, ek.PublicKey, ek.ID, ek.Key, ek.CreatedAt)
 		if err != nil {
 			return err
 		}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	charm "github.com/charmbracelet/charm/proto"
 )
@@ -26,7 +25,7 @@ func (err ErrRequestTooLarge) Error() string {
 
 // AuthedJSONRequest sends an authorized JSON request to the Charm and Glow HTTP servers.
 func (cc *Client) AuthedJSONRequest(method string, path string, reqBody interface{}, respBody interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := cc.httpContext()
 	defer cancel()
 	return cc.AuthedJSONRequestWithContext(ctx, method, path, reqBody, respBody)
 }
@@ -61,9 +60,11 @@ func (cc *Client) AuthedRequest(method string, path string, headers http.Header,
 }
 
 // AuthedRequestWithContext sends an authorized request to the Charm and Glow HTTP servers with context.
+// If the server rejects the cached JWT with a 401, a new JWT is fetched and
+// the request is sent once more, provided its body can be replayed.
 func (cc *Client) AuthedRequestWithContext(ctx context.Context, method string, path string, headers http.Header, reqBody io.Reader) (*http.Response, error) {
 	cfg := cc.Config
-	auth, err := cc.Auth()
+	auth, err := cc.AuthWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +82,10 @@ func (cc *Client) AuthedRequestWithContext(ctx context.Context, method string, p
 		}
 	}
 	req.Header.Add("Authorization", fmt.Sprintf("bearer %s", jwt))
-	resp, err := cc.httpClient.Do(req)
+	resp, err := cc.doWithRetry(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && replayable(req) {
+		resp, err = cc.retryWithNewAuth(ctx, req, jwt, resp)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +104,25 @@ func (cc *Client) AuthedRequestWithContext(ctx context.Context, method string, p
 	return resp, nil
 }
 
+// retryWithNewAuth sends req again with a freshly fetched JWT after the server
+// rejected jwt with resp. It's only tried once per request, so a server that
+// keeps refusing the new JWT gets its 401 returned.
+func (cc *Client) retryWithNewAuth(ctx context.Context, req *http.Request, jwt string, resp *http.Response) (*http.Response, error) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	cc.invalidateAuthIfCurrent(jwt)
+	auth, err := cc.AuthWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	next, err := rewind(req)
+	if err != nil {
+		return nil, err
+	}
+	next.Header.Set("Authorization", fmt.Sprintf("bearer %s", auth.JWT))
+	return cc.doWithRetry(next)
+}
+
 // AuthedRawRequest sends an authorized request with no request body to the Charm and Glow HTTP servers.
 func (cc *Client) AuthedRawRequest(method string, path string) (*http.Response, error) {
 	return cc.AuthedRequest(method, path, nil, nil)
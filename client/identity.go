@@ -0,0 +1,74 @@
+package client
+
+import (
+	"io"
+	"slices"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// identityKeys returns the configured identity key paths in the order they
+// should be tried.
+func (cfg *Config) identityKeys() []string {
+	keys := slices.Clone(cfg.IdentityKeys)
+	if cfg.IdentityKey != "" && !slices.Contains(keys, cfg.IdentityKey) {
+		keys = append(keys, cfg.IdentityKey)
+	}
+	return keys
+}
+
+// WithSigner authenticates the client with signer instead of a key file, for
+// environments where keys shouldn't be written to disk. Key discovery and
+// generation are skipped, and IdentityKey and IdentityKeys are ignored. Like
+// key files, only RSA and Ed25519 signers are supported.
+//
+// The account's encrypt keys are encrypted for its SSH keys, and decrypting
+// them takes the private key itself, which a signer doesn't expose. A client
+// with only a signer can authenticate and use the account, but not the
+// encrypted data in charm/kv or charm/fs.
+func WithSigner(signer ssh.Signer) Option {
+	return func(cc *Client) {
+		cc.signer = signer
+	}
+}
+
+// IdentityKeyUsed returns the path of the identity key that last
+// authenticated with the server, or an empty string if the client hasn't
+// connected yet. With several identity keys configured, this tells which one
+// is linked to the account.
+func (cc *Client) IdentityKeyUsed() string {
+	p, _ := cc.identityKeyUsed.Load().(string)
+	return p
+}
+
+// identitySigner wraps the signer for the key at path to record when the
+// server accepts it. SSH clients only sign with a key once the server has
+// agreed to it, so a signature means the key is linked to the account.
+func (cc *Client) identitySigner(path string, signer ssh.Signer) ssh.Signer {
+	ms, ok := signer.(ssh.MultiAlgorithmSigner)
+	if !ok {
+		return signer
+	}
+	return &recordingSigner{
+		MultiAlgorithmSigner: ms,
+		used: func() {
+			cc.identityKeyUsed.Store(path)
+		},
+	}
+}
+
+// recordingSigner calls used before every signature.
+type recordingSigner struct {
+	ssh.MultiAlgorithmSigner
+	used func()
+}
+
+func (s *recordingSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	s.used()
+	return s.MultiAlgorithmSigner.Sign(rand, data)
+}
+
+func (s *recordingSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	s.used()
+	return s.MultiAlgorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+}
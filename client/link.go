@@ -1,20 +1,35 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	charm "github.com/charmbracelet/charm/proto"
 )
 
 // LinkGen initiates a linking session.
 func (cc *Client) LinkGen(lh charm.LinkHandler) error {
-	s, err := cc.sshSession()
+	return cc.LinkGenWithContext(context.Background(), lh)
+}
+
+// LinkGenWithContext initiates a linking session with context. Linking waits
+// on the other device, so the context bounds the whole session rather than
+// just connecting to the server.
+func (cc *Client) LinkGenWithContext(ctx context.Context, lh charm.LinkHandler) (err error) {
+	s, err := cc.sshSessionWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer s.Close() // nolint:errcheck
+	defer s.watch(ctx)()
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
 	out, err := s.StdoutPipe()
 	if err != nil {
 		return err
@@ -69,7 +84,7 @@ func (cc *Client) LinkGen(lh charm.LinkHandler) error {
 	if err != nil {
 		return err
 	}
-	err = cc.SyncEncryptKeys()
+	err = cc.syncEncryptKeysAfterLink(ctx)
 	if err != nil {
 		return err
 	}
@@ -79,11 +94,23 @@ func (cc *Client) LinkGen(lh charm.LinkHandler) error {
 
 // Link joins in on a linking session initiated by LinkGen.
 func (cc *Client) Link(lh charm.LinkHandler, code string) error {
-	s, err := cc.sshSession()
+	return cc.LinkWithContext(context.Background(), lh, code)
+}
+
+// LinkWithContext joins in on a linking session initiated by LinkGen with
+// context. The context bounds the whole session.
+func (cc *Client) LinkWithContext(ctx context.Context, lh charm.LinkHandler, code string) (err error) {
+	s, err := cc.sshSessionWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer s.Close() // nolint:errcheck
+	defer s.watch(ctx)()
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
 	out, err := s.StdoutPipe()
 	if err != nil {
 		return err
@@ -114,7 +141,7 @@ func (cc *Client) Link(lh charm.LinkHandler, code string) error {
 	if err != nil {
 		return err
 	}
-	err = cc.SyncEncryptKeys()
+	err = cc.syncEncryptKeysAfterLink(ctx)
 	if err != nil {
 		return err
 	}
@@ -125,18 +152,27 @@ func (cc *Client) Link(lh charm.LinkHandler, code string) error {
 // SyncEncryptKeys re-encodes all of the encrypt keys associated for this
 // public key with all other linked public keys.
 func (cc *Client) SyncEncryptKeys() error {
+	// Allow for one request per key and linked public key
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return cc.SyncEncryptKeysWithContext(ctx)
+}
+
+// SyncEncryptKeysWithContext re-encodes all of the encrypt keys associated
+// for this public key with all other linked public keys with context.
+func (cc *Client) SyncEncryptKeysWithContext(ctx context.Context) error {
 	cc.InvalidateAuth()
-	eks, err := cc.EncryptKeys()
+	eks, err := cc.EncryptKeysWithContext(ctx)
 	if err != nil {
 		return err
 	}
-	cks, err := cc.AuthorizedKeysWithMetadata()
+	cks, err := cc.AuthorizedKeysWithMetadataWithContext(ctx)
 	if err != nil {
 		return err
 	}
 	for _, k := range cks.Keys {
 		for _, ek := range eks {
-			err := cc.addEncryptKey(k.Key, ek.ID, ek.Key, ek.CreatedAt)
+			err := cc.addEncryptKey(ctx, k.Key, ek.ID, ek.Key, ek.CreatedAt)
 			if err != nil {
 				return err
 			}
@@ -145,6 +181,14 @@ func (cc *Client) SyncEncryptKeys() error {
 	return cc.deleteUserData()
 }
 
+// syncEncryptKeysAfterLink syncs the encrypt keys with the same time limit
+// as SyncEncryptKeys, within ctx.
+func (cc *Client) syncEncryptKeysAfterLink(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	return cc.SyncEncryptKeysWithContext(ctx)
+}
+
 func (cc *Client) deleteUserData() error {
 	// nolint: godox
 	// TODO find a better place for this, or do something more sophisticated than
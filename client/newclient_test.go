@@ -7,13 +7,19 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/keygen"
+	gliderssh "github.com/charmbracelet/ssh"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
 )
@@ -411,3 +417,203 @@ func (m *mockPublicKey) Marshal() []byte {
 func (m *mockPublicKey) Verify([]byte, *ssh.Signature) error {
 	return nil
 }
+
+// TestNewClient_IdentityKeys tests that every usable identity key is offered,
+// skipping keys that can't be read, with IdentityKey tried last.
+func TestNewClient_IdentityKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldKey := filepath.Join(tmpDir, "old_ed25519")
+	newKey := filepath.Join(tmpDir, "new_ed25519")
+	for _, kp := range []string{oldKey, newKey} {
+		if _, err := keygen.New(kp, keygen.WithKeyType(keygen.Ed25519), keygen.WithWrite()); err != nil {
+			t.Fatalf("failed to generate test key: %v", err)
+		}
+	}
+
+	cfg := &Config{
+		Host:         "test.charm.sh",
+		KeyType:      "ed25519",
+		DataDir:      tmpDir,
+		IdentityKeys: []string{filepath.Join(tmpDir, "missing"), newKey},
+		IdentityKey:  oldKey,
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("expected NewClient to skip the missing key, got error: %v", err)
+	}
+	want := []string{newKey, oldKey}
+	if strings.Join(client.AuthKeyPaths(), ",") != strings.Join(want, ",") {
+		t.Errorf("expected auth key paths %v, got %v", want, client.AuthKeyPaths())
+	}
+	if client.IdentityKeyUsed() != "" {
+		t.Errorf("expected no key used before connecting, got %q", client.IdentityKeyUsed())
+	}
+}
+
+// TestNewClient_IdentityKeysAllInvalid tests that NewClient fails when none
+// of the identity keys can be used.
+func TestNewClient_IdentityKeysAllInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{
+		Host:         "test.charm.sh",
+		KeyType:      "ed25519",
+		DataDir:      tmpDir,
+		IdentityKeys: []string{filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")},
+	}
+	if _, err := NewClient(cfg); err == nil {
+		t.Fatal("expected error when no identity key is usable, got nil")
+	}
+}
+
+// TestConfig_IdentityKeys tests that IdentityKey is appended to IdentityKeys
+// unless it's already listed.
+func TestConfig_IdentityKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"none", Config{}, nil},
+		{"singular", Config{IdentityKey: "a"}, []string{"a"}},
+		{"plural", Config{IdentityKeys: []string{"a", "b"}}, []string{"a", "b"}},
+		{"both", Config{IdentityKeys: []string{"a"}, IdentityKey: "b"}, []string{"a", "b"}},
+		{"duplicate", Config{IdentityKeys: []string{"a", "b"}, IdentityKey: "a"}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cfg.identityKeys()
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("identityKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewClient_Timeouts tests that the configured timeouts are applied, and
+// that unset ones fall back to the default.
+func TestNewClient_Timeouts(t *testing.T) {
+	t.Setenv("CHARM_SSH_DIAL_TIMEOUT", "5s")
+	t.Setenv("CHARM_HTTP_TIMEOUT", "1m")
+	t.Setenv("CHARM_COMMAND_TIMEOUT", "2s")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv failed: %v", err)
+	}
+	cfg.DataDir = t.TempDir()
+	cc, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if cc.sshConfig.Timeout != 5*time.Second {
+		t.Errorf("expected an SSH dial timeout of 5s, got %v", cc.sshConfig.Timeout)
+	}
+	if cc.httpClient.Timeout != time.Minute {
+		t.Errorf("expected an HTTP timeout of 1m, got %v", cc.httpClient.Timeout)
+	}
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	if dl, ok := ctx.Deadline(); !ok || time.Until(dl) > 2*time.Second {
+		t.Errorf("expected a command deadline within 2s, got %v", dl)
+	}
+
+	cc, err = NewClient(&Config{KeyType: "ed25519", DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if cc.sshConfig.Timeout != defaultTimeout || cc.httpClient.Timeout != defaultTimeout {
+		t.Errorf("expected default timeouts, got %v and %v", cc.sshConfig.Timeout, cc.httpClient.Timeout)
+	}
+}
+
+func TestNewClient_Retries(t *testing.T) {
+	t.Setenv("CHARM_HTTP_RETRIES", "2")
+	t.Setenv("CHARM_HTTP_RETRY_BACKOFF", "250ms")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv failed: %v", err)
+	}
+	cfg.DataDir = t.TempDir()
+	cc, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if cc.retryAttempts != 3 || cc.retryBaseDelay != 250*time.Millisecond {
+		t.Errorf("expected 3 attempts 250ms apart, got %d and %v", cc.retryAttempts, cc.retryBaseDelay)
+	}
+
+	cc, err = NewClient(cfg, WithRetry(5, time.Second))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if cc.retryAttempts != 5 {
+		t.Errorf("expected WithRetry to override the config, got %d attempts", cc.retryAttempts)
+	}
+}
+
+// TestNewClient_WithSigner tests that a signer passed with WithSigner is used
+// to authenticate, without looking for or generating key files.
+func TestNewClient_WithSigner(t *testing.T) {
+	_, pk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offered atomic.Value
+	srv := &gliderssh.Server{
+		Handler: func(s gliderssh.Session) {
+			_ = json.NewEncoder(s).Encode(&charm.Auth{JWT: newTestJWT(t), HTTPScheme: "http"})
+			_ = s.Exit(0)
+		},
+		PublicKeyHandler: func(_ gliderssh.Context, key gliderssh.PublicKey) bool {
+			offered.Store(ssh.FingerprintSHA256(key))
+			return true
+		},
+	}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	tmpDir := t.TempDir()
+	cfg := &Config{
+		Host:    "127.0.0.1",
+		SSHPort: l.Addr().(*net.TCPAddr).Port,
+		KeyType: "ed25519",
+		DataDir: tmpDir,
+	}
+	cc, err := NewClient(cfg, WithSigner(signer))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err := cc.Auth(); err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if got := offered.Load(); got != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("expected the signer's key to be offered, got %v", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tmpDir, "*", "charm_*")); len(matches) > 0 {
+		t.Errorf("expected no keys to be generated, found %v", matches)
+	}
+}
+
+// TestNewClient_WithSignerRejectsECDSA tests that unsupported signers are
+// rejected like unsupported key files.
+func TestNewClient_WithSignerRejectsECDSA(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewClient(&Config{Host: "test.charm.sh", DataDir: t.TempDir()}, WithSigner(signer))
+	if err == nil || !strings.Contains(err.Error(), "ecdsa") {
+		t.Errorf("expected the ECDSA signer to be rejected, got %v", err)
+	}
+}
@@ -1,32 +1,85 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
-	"strings"
+	"strconv"
 
 	charm "github.com/charmbracelet/charm/proto"
 )
 
-// NewsList lists the server news.
+// NewsList lists the server news tagged with any of the given tags. A nil
+// tags lists news tagged "server".
 func (cc *Client) NewsList(tags []string, page int) ([]*charm.News, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.NewsListWithContext(ctx, tags, page)
+}
+
+// NewsListWithContext lists the server news with context.
+func (cc *Client) NewsListWithContext(ctx context.Context, tags []string, page int) ([]*charm.News, error) {
+	var nl []*charm.News
+	err := cc.AuthedJSONRequestWithContext(ctx, "GET", newsListPath(tags, page), nil, &nl)
+	if err != nil {
+		return nil, err
+	}
+	return nl, nil
+}
+
+// NewsListWithCount lists the server news like NewsList, along with the total
+// number of news tagged with any of the given tags across all pages.
+func (cc *Client) NewsListWithCount(tags []string, page int) ([]*charm.News, int, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.NewsListWithCountWithContext(ctx, tags, page)
+}
+
+// NewsListWithCountWithContext lists the server news along with the total
+// number of news with context.
+func (cc *Client) NewsListWithCountWithContext(ctx context.Context, tags []string, page int) ([]*charm.News, int, error) {
+	resp, err := cc.AuthedRawRequestWithContext(ctx, "GET", newsListPath(tags, page))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid news count from server: %w", err)
+	}
 	var nl []*charm.News
+	if err := json.NewDecoder(resp.Body).Decode(&nl); err != nil {
+		return nil, 0, err
+	}
+	return nl, total, nil
+}
 
+// newsListPath returns the request path for a page of news tagged with any of
+// tags, or with "server" if tags is nil.
+func newsListPath(tags []string, page int) string {
 	if tags == nil {
 		tags = []string{"server"}
 	}
-	tq := url.QueryEscape(strings.Join(tags, ","))
-	err := cc.AuthedJSONRequest("GET", fmt.Sprintf("/v1/news?page=%d&tags=%s", page, tq), nil, &nl)
-	if err != nil {
-		return nil, err
+	q := url.Values{}
+	q.Set("page", strconv.Itoa(page))
+	for _, t := range tags {
+		q.Add("tag", t)
 	}
-	return nl, nil
+	return "/v1/news?" + q.Encode()
 }
 
 // News shows a given news.
 func (cc *Client) News(id string) (*charm.News, error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.NewsWithContext(ctx, id)
+}
+
+// NewsWithContext shows a given news with context.
+func (cc *Client) NewsWithContext(ctx context.Context, id string) (*charm.News, error) {
 	var n *charm.News
-	err := cc.AuthedJSONRequest("GET", fmt.Sprintf("/v1/news/%s", url.QueryEscape(id)), nil, &n)
+	err := cc.AuthedJSONRequestWithContext(ctx, "GET", fmt.Sprintf("/v1/news/%s", url.QueryEscape(id)), nil, &n)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrHTTPUnreachable is returned by Ping when the server's HTTP health check
+// can't be reached or doesn't report the server as healthy.
+type ErrHTTPUnreachable struct {
+	Err error
+}
+
+// Error returns the boxed error string.
+func (e ErrHTTPUnreachable) Error() string { return fmt.Sprintf("http unreachable: %s", e.Err) }
+
+// Unwrap returns the boxed error.
+func (e ErrHTTPUnreachable) Unwrap() error { return e.Err }
+
+// ErrSSHUnreachable is returned by Ping when no SSH session can be opened
+// with the server.
+type ErrSSHUnreachable struct {
+	Err error
+}
+
+// Error returns the boxed error string.
+func (e ErrSSHUnreachable) Error() string { return fmt.Sprintf("ssh unreachable: %s", e.Err) }
+
+// Unwrap returns the boxed error.
+func (e ErrSSHUnreachable) Unwrap() error { return e.Err }
+
+// Ping checks that the server can be reached over both HTTP and SSH. It
+// returns ErrHTTPUnreachable if the health check on the HealthPort fails,
+// and ErrSSHUnreachable if no SSH session can be opened.
+func (cc *Client) Ping() error {
+	ctx, cancel := cc.commandContext()
+	defer cancel()
+	return cc.PingWithContext(ctx)
+}
+
+// PingWithContext checks that the server can be reached over both HTTP and
+// SSH with context.
+func (cc *Client) PingWithContext(ctx context.Context) error {
+	if err := cc.PingHTTPWithContext(ctx); err != nil {
+		return err
+	}
+	s, err := cc.sshSessionWithContext(ctx)
+	if err != nil {
+		return ErrSSHUnreachable{Err: err}
+	}
+	return s.Close()
+}
+
+// PingHTTP checks that the server's HTTP health check reports the server as
+// healthy, without connecting over SSH. It returns ErrHTTPUnreachable if not.
+func (cc *Client) PingHTTP() error {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.PingHTTPWithContext(ctx)
+}
+
+// PingHTTPWithContext checks the server's HTTP health check with context.
+func (cc *Client) PingHTTPWithContext(ctx context.Context) error {
+	cc.authLock.Lock()
+	scheme := cc.httpScheme
+	cc.authLock.Unlock()
+	if scheme != "" {
+		return cc.pingHealth(ctx, scheme)
+	}
+	// The scheme is learnt when authenticating, so try HTTPS first and fall
+	// back to HTTP in case the server doesn't use TLS. The health check sends
+	// no credentials, so nothing leaks over plain HTTP.
+	err := cc.pingHealth(ctx, "https")
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if cc.pingHealth(ctx, "http") == nil {
+		return nil
+	}
+	return err
+}
+
+// pingHealth requests the health check using scheme.
+func (cc *Client) pingHealth(ctx context.Context, scheme string) error {
+	url := fmt.Sprintf("%s://%s:%d/", scheme, cc.Config.Host, cc.Config.HealthPort)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return ErrHTTPUnreachable{Err: err}
+	}
+	resp, err := cc.httpClient.Do(req)
+	if err != nil {
+		return ErrHTTPUnreachable{Err: err}
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return ErrHTTPUnreachable{Err: fmt.Errorf("health check returned %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPingHTTP_FallsBackToHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("We live!"))
+	}))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	cc.Config.HealthPort = cc.Config.HTTPPort
+	// No scheme is known before authenticating
+	cc.httpScheme = ""
+	if err := cc.PingHTTP(); err != nil {
+		t.Fatalf("expected the health check to pass over HTTP, got %v", err)
+	}
+}
+
+func TestPingHTTP_Unhealthy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	cc := NewClientForTestServer(ts)
+	cc.Config.HealthPort = cc.Config.HTTPPort
+	var herr ErrHTTPUnreachable
+	if err := cc.PingHTTP(); !errors.As(err, &herr) {
+		t.Fatalf("expected ErrHTTPUnreachable, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/user"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newHTTPClient returns the HTTP client for the configured timeout and
+// proxies.
+func (cc *Client) newHTTPClient() (*http.Client, error) {
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if cc.Config.HTTPProxy != "" {
+		u, err := url.Parse(cc.Config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http proxy: %w", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if cc.Config.ProxyJump != "" && cc.Config.ProxyJumpHTTP {
+		t.DialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return cc.dialJump(network, addr)
+		}
+	}
+	return &http.Client{
+		Timeout:   orDefaultTimeout(cc.Config.HTTPTimeout),
+		Transport: t,
+	}, nil
+}
+
+// dialSSH connects to the server's SSH port, through the ProxyJump host if
+// one is configured.
+func (cc *Client) dialSSH() (*ssh.Client, error) {
+	addr := fmt.Sprintf("%s:%d", cc.Config.Host, cc.Config.SSHPort)
+	if cc.Config.ProxyJump == "" {
+		return ssh.Dial("tcp", addr, cc.sshConfig)
+	}
+	conn, err := cc.dialJump("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cc.sshConfig)
+	if err != nil {
+		conn.Close() // nolint:errcheck
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// dialJump connects to addr through the ProxyJump host, authenticating with
+// the same keys as the server. Closing the connection also closes the one to
+// the jump host.
+func (cc *Client) dialJump(network, addr string) (net.Conn, error) {
+	u, jaddr, err := parseProxyJump(cc.Config.ProxyJump)
+	if err != nil {
+		return nil, err
+	}
+	jcfg := *cc.sshConfig
+	jcfg.User = u
+	jump, err := ssh.Dial("tcp", jaddr, &jcfg)
+	if err != nil {
+		return nil, fmt.Errorf("proxy jump %s: %w", jaddr, err)
+	}
+	conn, err := jump.Dial(network, addr)
+	if err != nil {
+		jump.Close() // nolint:errcheck
+		return nil, fmt.Errorf("proxy jump %s: %w", jaddr, err)
+	}
+	return &jumpConn{Conn: conn, jump: jump}, nil
+}
+
+// jumpConn is a connection tunnelled through a jump host.
+type jumpConn struct {
+	net.Conn
+	jump *ssh.Client
+}
+
+// Close closes the connection and the one to the jump host.
+func (c *jumpConn) Close() error {
+	err := c.Conn.Close()
+	_ = c.jump.Close()
+	return err
+}
+
+// parseProxyJump splits a [user@]host[:port] jump host into the user and
+// address to connect to. The user defaults to the current user and the port
+// to 22, as with OpenSSH.
+func parseProxyJump(spec string) (string, string, error) {
+	name, hostport, ok := strings.Cut(spec, "@")
+	if !ok {
+		hostport = spec
+		cu, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("proxy jump user: %w", err)
+		}
+		name = cu.Username
+	}
+	if hostport == "" || name == "" {
+		return "", "", fmt.Errorf("invalid proxy jump: %q", spec)
+	}
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		hostport = net.JoinHostPort(strings.Trim(hostport, "[]"), "22")
+	}
+	return name, hostport, nil
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gliderssh "github.com/charmbracelet/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSSH starts srv on a random port and returns the port.
+func serveSSH(t *testing.T, srv *gliderssh.Server) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// jumpHost starts an SSH server that forwards connections, recording the
+// user and destination of each.
+type jumpHost struct {
+	mu       sync.Mutex
+	forwards []string
+}
+
+func (j *jumpHost) start(t *testing.T) int {
+	return serveSSH(t, &gliderssh.Server{
+		LocalPortForwardingCallback: func(ctx gliderssh.Context, host string, port uint32) bool {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			j.forwards = append(j.forwards, fmt.Sprintf("%s@%s:%d", ctx.User(), host, port))
+			return true
+		},
+		ChannelHandlers: map[string]gliderssh.ChannelHandler{
+			"session":      gliderssh.DefaultSessionHandler,
+			"direct-tcpip": gliderssh.DirectTCPIPHandler,
+		},
+	})
+}
+
+func (j *jumpHost) Forwards() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.forwards...)
+}
+
+func TestProxyJump_SSH(t *testing.T) {
+	port := serveSSH(t, &gliderssh.Server{Handler: func(s gliderssh.Session) {
+		_, _ = s.Write([]byte("charm-id"))
+	}})
+	jump := &jumpHost{}
+	jumpPort := jump.start(t)
+
+	cc := NewClientForTest(&Config{
+		Host:      "127.0.0.1",
+		SSHPort:   port,
+		ProxyJump: fmt.Sprintf("bastion@127.0.0.1:%d", jumpPort),
+	})
+	cc.sshConfig = &ssh.ClientConfig{User: "charm", HostKeyCallback: ssh.InsecureIgnoreHostKey()} // nolint
+
+	id, err := cc.ID()
+	if err != nil {
+		t.Fatalf("ID failed: %v", err)
+	}
+	if id != "charm-id" {
+		t.Errorf("expected charm-id, got %q", id)
+	}
+	want := fmt.Sprintf("bastion@127.0.0.1:%d", port)
+	if fs := jump.Forwards(); len(fs) != 1 || fs[0] != want {
+		t.Errorf("expected one forward to %s, got %v", want, fs)
+	}
+}
+
+func TestProxyJump_HTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	jump := &jumpHost{}
+	jumpPort := jump.start(t)
+
+	cc := NewClientForTestServer(ts)
+	cc.Config.ProxyJump = fmt.Sprintf("bastion@127.0.0.1:%d", jumpPort)
+	cc.Config.ProxyJumpHTTP = true
+	cc.sshConfig = &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()} // nolint
+	hc, err := cc.newHTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.httpClient = hc
+
+	resp, err := cc.AuthedRawRequest("GET", "/v1/test")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close() // nolint:errcheck
+	if fs := jump.Forwards(); len(fs) != 1 {
+		t.Errorf("expected the request to go through the jump host, got %v", fs)
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		spec, user, addr string
+	}{
+		{"me@bastion", "me", "bastion:22"},
+		{"me@bastion:2222", "me", "bastion:2222"},
+		{"me@[::1]:2222", "me", "[::1]:2222"},
+		{"me@::1", "me", "[::1]:22"},
+	}
+	for _, tt := range tests {
+		u, addr, err := parseProxyJump(tt.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if u != tt.user || addr != tt.addr {
+			t.Errorf("%s: expected %s and %s, got %s and %s", tt.spec, tt.user, tt.addr, u, addr)
+		}
+	}
+	if u, addr, err := parseProxyJump("bastion"); err != nil || u == "" || addr != "bastion:22" {
+		t.Errorf("expected the current user and port 22, got %q, %q, %v", u, addr, err)
+	}
+	if _, _, err := parseProxyJump("me@"); err == nil {
+		t.Error("expected an error without a host")
+	}
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryDelay caps the backoff between attempts, including any delay asked
+// for by a Retry-After header.
+const maxRetryDelay = 30 * time.Second
+
+// defaultRetryBackoff is the delay before the first retry when a Config
+// leaves HTTPRetryBackoff unset.
+const defaultRetryBackoff = time.Second
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRetry retries authorized HTTP requests that fail with a connection
+// error, a 429 or a 5xx response other than 507. maxAttempts is the total
+// number of attempts (1 or less disables retries). baseDelay is the delay before the first retry
+// and doubles with each attempt, unless the server sends a Retry-After header.
+//
+// Only idempotent requests (GET, HEAD, OPTIONS, PUT and DELETE) are retried;
+// use WithRetryNonIdempotent to retry POST and PATCH as well, or RetrySafe to
+// mark single requests as safe to retry. Requests whose body can't be
+// replayed are never retried, so a file upload (a POST to /v1/fs) is only
+// retried if it's marked safe and its body is rewindable, such as a
+// *bytes.Reader.
+//
+// The Config's HTTPRetries and HTTPRetryBackoff set the same, for clients
+// created without WithRetry.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(cc *Client) {
+		cc.retryAttempts = maxAttempts
+		cc.retryBaseDelay = baseDelay
+	}
+}
+
+// WithRetryNonIdempotent allows WithRetry to retry non-idempotent requests
+// such as POST. Only use it when repeating a write is harmless.
+func WithRetryNonIdempotent() Option {
+	return func(cc *Client) {
+		cc.retryNonIdempotent = true
+	}
+}
+
+// retrySafeKey marks a context whose requests are safe to retry.
+type retrySafeKey struct{}
+
+// RetrySafe returns a copy of ctx marking the requests made with it as safe
+// to retry even if they aren't idempotent, for when repeating them is
+// harmless.
+func RetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+// doWithRetry sends req, retrying transient failures as configured with
+// WithRetry. The returned response is the one from the last attempt.
+func (cc *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := cc.httpClient.Do(req)
+		if attempt >= cc.retryAttempts || !cc.canRetry(req) || !isTransient(resp, err) {
+			return resp, err
+		}
+		delay := retryDelay(cc.retryBaseDelay, attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		next, rerr := rewind(req)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
+
+// canRetry reports whether req may be sent again.
+func (cc *Client) canRetry(req *http.Request) bool {
+	if !replayable(req) {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		safe, _ := req.Context().Value(retrySafeKey{}).(bool)
+		return cc.retryNonIdempotent || safe
+	}
+}
+
+// isTransient reports whether a request failure is worth retrying. A 507
+// means the user is out of storage, which retrying won't fix.
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	if resp.StatusCode == http.StatusInsufficientStorage {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryDelay returns how long to wait before the retry following attempt.
+func retryDelay(base time.Duration, attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(d, maxRetryDelay)
+		}
+	}
+	d := base
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// replayable reports whether req's body can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req with a fresh body, ready to be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
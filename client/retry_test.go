@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with status, then echoes the
+// request body back. It counts every request it receives.
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if n <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestWithRetry_RetriesServerErrors(t *testing.T) {
+	ts, calls := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	resp, err := cc.AuthedRequest("PUT", "/v1/fs/a", nil, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("expected the body to be replayed, got %q", body)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestWithRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	ts, calls := flakyServer(t, 10, http.StatusTooManyRequests, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	_, err := cc.AuthedRawRequest("GET", "/v1/fs/a")
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected a 429 error, got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestWithRetry_SkipsClientErrors(t *testing.T) {
+	ts, calls := flakyServer(t, 10, http.StatusNotFound, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	if _, err := cc.AuthedRawRequest("GET", "/v1/fs/a"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestWithRetry_SkipsInsufficientStorage(t *testing.T) {
+	ts, calls := flakyServer(t, 10, http.StatusInsufficientStorage, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	if _, err := cc.AuthedRequest("PUT", "/v1/fs/a", nil, strings.NewReader("hello")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestWithRetry_NonIdempotent(t *testing.T) {
+	ts, calls := flakyServer(t, 1, http.StatusBadGateway, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	if _, err := cc.AuthedRequest("POST", "/v1/seq/a", nil, strings.NewReader("x")); err == nil {
+		t.Fatal("expected POST not to be retried")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+
+	WithRetryNonIdempotent()(cc)
+	calls.Store(0)
+	resp, err := cc.AuthedRequest("POST", "/v1/seq/a", nil, strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("expected POST to be retried, got %v", err)
+	}
+	_ = resp.Body.Close()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestWithRetry_RetrySafe(t *testing.T) {
+	ts, calls := flakyServer(t, 1, http.StatusBadGateway, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	ctx := RetrySafe(context.Background())
+	resp, err := cc.AuthedRequestWithContext(ctx, "POST", "/v1/fs/a", nil, strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("expected a POST marked safe to be retried, got %v", err)
+	}
+	_ = resp.Body.Close()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestWithRetry_UnreplayableBody(t *testing.T) {
+	ts, calls := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("x"))
+		_ = pw.Close()
+	}()
+	if _, err := cc.AuthedRequest("PUT", "/v1/fs/a", nil, pr); err == nil {
+		t.Fatal("expected a streamed body not to be retried")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestWithRetry_ConnectionError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	cc := NewClientForTestServer(ts)
+	ts.Close()
+	WithRetry(2, time.Millisecond)(cc)
+
+	if _, err := cc.AuthedRawRequest("GET", "/v1/fs/a"); err == nil {
+		t.Fatal("expected a connection error")
+	}
+}
+
+func TestWithRetry_ContextCancelled(t *testing.T) {
+	ts, calls := flakyServer(t, 10, http.StatusServiceUnavailable, http.Header{"Retry-After": []string{"10"}})
+	cc := NewClientForTestServer(ts)
+	WithRetry(3, time.Millisecond)(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := cc.AuthedRawRequestWithContext(ctx, "GET", "/v1/fs/a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expected cancellation to interrupt the Retry-After wait")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Now()
+	date := func(d time.Duration) http.Header {
+		return http.Header{"Retry-After": []string{now.Add(d).UTC().Format(http.TimeFormat)}}
+	}
+	tests := []struct {
+		name    string
+		attempt int
+		header  http.Header
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"first retry", 1, nil, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"doubles", 3, nil, 400 * time.Millisecond, 400 * time.Millisecond},
+		{"capped", 20, nil, maxRetryDelay, maxRetryDelay},
+		{"retry-after seconds", 1, http.Header{"Retry-After": []string{"2"}}, 2 * time.Second, 2 * time.Second},
+		{"retry-after date", 1, date(5 * time.Second), 3 * time.Second, 5 * time.Second},
+		{"retry-after capped", 1, http.Header{"Retry-After": []string{"3600"}}, maxRetryDelay, maxRetryDelay},
+		{"retry-after invalid", 2, http.Header{"Retry-After": []string{"soon"}}, 200 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: tt.header}
+			got := retryDelay(100*time.Millisecond, tt.attempt, resp)
+			if got < tt.min || got > tt.max {
+				t.Errorf("retryDelay = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
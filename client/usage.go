@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// StorageUsage returns how many bytes the server stores for the user's files
+// and KV backups, and the most it will store for them. A limit of 0 means
+// there's no limit. Writes that would go over the limit fail with a 507.
+func (cc *Client) StorageUsage() (used, limit int64, err error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.StorageUsageWithContext(ctx)
+}
+
+// StorageUsageWithContext returns the user's storage usage and limit with
+// context.
+func (cc *Client) StorageUsageWithContext(ctx context.Context) (used, limit int64, err error) {
+	var u charm.FSUsage
+	if err := cc.AuthedJSONRequestWithContext(ctx, "GET", "/v1/fs/usage", nil, &u); err != nil {
+		return 0, 0, err
+	}
+	return u.Used, u.Limit, nil
+}
+
+// FSStats returns the number of files the user has stored and their total
+// size in bytes. Files are stored encrypted, so the size is slightly more
+// than that of their contents.
+func (cc *Client) FSStats() (files int, bytes int64, err error) {
+	ctx, cancel := cc.httpContext()
+	defer cancel()
+	return cc.FSStatsWithContext(ctx)
+}
+
+// FSStatsWithContext returns the number and total size of the user's files
+// with context.
+func (cc *Client) FSStatsWithContext(ctx context.Context) (files int, bytes int64, err error) {
+	var st charm.FSStats
+	if err := cc.AuthedJSONRequestWithContext(ctx, "GET", "/v1/fs/stats", nil, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Files, st.Bytes, nil
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 
 	"github.com/charmbracelet/charm/crypt"
+	charm "github.com/charmbracelet/charm/proto"
 	"github.com/spf13/cobra"
 )
 
@@ -56,8 +57,30 @@ var (
 		Args:   cobra.ExactArgs(1),
 		RunE:   cryptDecryptLookup,
 	}
+
+	cryptExportKeysCmd = &cobra.Command{
+		Use:    "export-keys",
+		Hidden: false,
+		Short:  "Export your decrypted encryption keys for disaster recovery",
+		Long:   paragraph(fmt.Sprintf("%s your decrypted Charm encryption keys to a JSON file, to store securely offline. Anyone with the file can decrypt all of your data. You can add the keys back to an account with %s.", keyword("Export"), code("charm crypt import-keys"))),
+		Args:   cobra.NoArgs,
+		RunE:   cryptExportKeys,
+	}
+
+	cryptImportKeysCmd = &cobra.Command{
+		Use:    "import-keys FILE",
+		Hidden: false,
+		Short:  "Import encryption keys exported with export-keys",
+		Long:   paragraph(fmt.Sprintf("%s encryption keys exported with %s into your Charm account, so data encrypted with them can be decrypted again.", keyword("Import"), code("charm crypt export-keys"))),
+		Args:   cobra.ExactArgs(1),
+		RunE:   cryptImportKeys,
+	}
 )
 
+var cryptExportOutputFile string
+
+const encryptKeysWarning = "WARNING: this file holds your decrypted encryption keys. Anyone who gets it can decrypt all of your Charm data. Store it offline, somewhere safe, and never share it."
+
 type cryptFile struct {
 	Data string `json:"data"`
 }
@@ -153,9 +176,69 @@ func cryptDecryptLookup(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+func cryptExportKeys(cmd *cobra.Command, _ []string) error {
+	cc, err := initCharmClient()
+	if err != nil {
+		return err
+	}
+	keys, err := cc.ExportEncryptKeys()
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s\n\n", encryptKeysWarning)
+	if cryptExportOutputFile == "-" {
+		_, err = cmd.OutOrStdout().Write(out)
+		return err
+	}
+	f, err := os.OpenFile(cryptExportOutputFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		return fmt.Errorf("not exporting keys: %s already exists", cryptExportOutputFile)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(out); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Done! Saved %d encryption keys to %s.\n", len(keys), code(cryptExportOutputFile))
+	return nil
+}
+
+func cryptImportKeys(_ *cobra.Command, args []string) error {
+	b, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var keys []*charm.EncryptKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("invalid encryption keys file: %w", err)
+	}
+	cc, err := initCharmClient()
+	if err != nil {
+		return err
+	}
+	if err := cc.ImportEncryptKeys(keys); err != nil {
+		return err
+	}
+	fmt.Printf("Done! Imported encryption keys from %s.\n", code(args[0]))
+	return nil
+}
+
 func init() {
+	cryptExportKeysCmd.Flags().StringVarP(&cryptExportOutputFile, "output", "o", "charm-encrypt-keys.json", "keys export filepath, or - for stdout")
 	CryptCmd.AddCommand(cryptEncryptCmd)
 	CryptCmd.AddCommand(cryptDecryptCmd)
 	CryptCmd.AddCommand(cryptEncryptLookupCmd)
 	CryptCmd.AddCommand(cryptDecryptLookupCmd)
+	CryptCmd.AddCommand(cryptExportKeysCmd)
+	CryptCmd.AddCommand(cryptImportKeysCmd)
 }
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -17,6 +20,15 @@ var (
 	valuesIterate    bool
 	showBinary       bool
 	delimiterIterate string
+	kvExportFile     string
+	kvImportFile     string
+	kvDiffPathA      string
+	kvDiffPathB      string
+	kvDiffQuiet      bool
+
+	// errKVStoresDiffer is returned by kv diff so it exits non-zero when the
+	// stores differ.
+	errKVStoresDiffer = errors.New("stores differ")
 
 	// KVCmd is the cobra.Command for a user to use the Charm key value store.
 	KVCmd = &cobra.Command{
@@ -77,6 +89,33 @@ var (
 		Args:   cobra.MaximumNArgs(1),
 		RunE:   kvReset,
 	}
+
+	kvExportCmd = &cobra.Command{
+		Use:    "export [@DB]",
+		Hidden: false,
+		Short:  "Export all key value pairs as JSON lines.",
+		Long:   paragraph("Export every key and decrypted value from a db with an optional @ db, one JSON object per line with base64-encoded keys and values. The export is plaintext and can be imported into any account."),
+		Args:   cobra.MaximumNArgs(1),
+		RunE:   kvExport,
+	}
+
+	kvImportCmd = &cobra.Command{
+		Use:    "import [@DB]",
+		Hidden: false,
+		Short:  "Import key value pairs from an export.",
+		Long:   paragraph("Set every key value pair from an export into a db with an optional @ db. Existing keys are overwritten."),
+		Args:   cobra.MaximumNArgs(1),
+		RunE:   kvImport,
+	}
+
+	kvDiffCmd = &cobra.Command{
+		Use:    "diff DB_A DB_B",
+		Hidden: false,
+		Short:  "Compare two local dbs.",
+		Long:   paragraph("Compare the local copies of two dbs without syncing them. Keys only in the first db are printed with a -, keys only in the second with a + and keys whose values differ with a ~. Exits non-zero if the dbs differ."),
+		Args:   cobra.ExactArgs(2),
+		RunE:   kvDiff,
+	}
 )
 
 func kvSet(_ *cobra.Command, args []string) error {
@@ -241,6 +280,129 @@ func kvReset(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+func kvExport(cmd *cobra.Command, args []string) error {
+	n, err := nameFromArgs(args)
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if kvExportFile != "" && kvExportFile != "-" {
+		f, err := os.Create(kvExportFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		w = f
+	}
+	return kv.DoReadOnly(kvName(n), func(db *kv.KV) error {
+		if err := db.Sync(); err != nil {
+			return err
+		}
+		return db.Export(w)
+	})
+}
+
+func kvImport(cmd *cobra.Command, args []string) error {
+	n, err := nameFromArgs(args)
+	if err != nil {
+		return err
+	}
+	r := cmd.InOrStdin()
+	if kvImportFile != "" && kvImportFile != "-" {
+		f, err := os.Open(kvImportFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		r = f
+	}
+	err = kv.Do(kvName(n), func(db *kv.KV) error {
+		if err := db.Import(r); err != nil {
+			return err
+		}
+		return db.Sync()
+	})
+	if err != nil {
+		return err
+	}
+	dbName := n
+	if dbName == "" {
+		dbName = "default"
+	}
+	fmt.Fprintf(os.Stderr, "Imported %s\n", dbName)
+	return nil
+}
+
+func kvDiff(cmd *cobra.Command, args []string) error {
+	a, err := kvDiffValues(args[0], kvDiffPathA)
+	if err != nil {
+		return err
+	}
+	b, err := kvDiffValues(args[1], kvDiffPathB)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	w := cmd.OutOrStdout()
+	differ := false
+	for _, k := range keys {
+		av, inA := a[k]
+		bv, inB := b[k]
+		var mark string
+		switch {
+		case !inB:
+			mark = "-"
+		case !inA:
+			mark = "+"
+		case !bytes.Equal(av, bv):
+			mark = "~"
+		default:
+			continue
+		}
+		differ = true
+		if kvDiffQuiet {
+			break
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, k)
+	}
+	if differ {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return errKVStoresDiffer
+	}
+	return nil
+}
+
+// kvDiffValues reads every key and decrypted value of a local db, opened
+// read-only from path if it's set.
+func kvDiffValues(name string, path string) (map[string][]byte, error) {
+	var opts []kv.Option
+	if path != "" {
+		opts = append(opts, kv.WithPath(path))
+	}
+	var vs map[string][]byte
+	err := kv.DoReadOnly(kvName(strings.ToLower(strings.TrimPrefix(name, "@"))), func(db *kv.KV) error {
+		keys, err := db.Keys()
+		if err != nil {
+			return err
+		}
+		vs, err = db.GetMulti(keys)
+		return err
+	}, opts...)
+	return vs, err
+}
+
 func nameFromArgs(args []string) (string, error) {
 	if len(args) == 0 {
 		return "", nil
@@ -283,11 +445,16 @@ func keyParser(k string) ([]byte, string, error) {
 	return []byte(key), db, nil
 }
 
-func openKV(name string) (*kv.KV, error) {
+// kvName returns the name of the store for a db given on the command line.
+func kvName(name string) string {
 	if name == "" {
-		name = "charm.sh.kv.user.default"
+		return "charm.sh.kv.user.default"
 	}
-	return kv.OpenWithDefaults(name)
+	return name
+}
+
+func openKV(name string) (*kv.KV, error) {
+	return kv.OpenWithDefaults(kvName(name))
 }
 
 func init() {
@@ -297,6 +464,11 @@ func init() {
 	kvListCmd.Flags().BoolVarP(&showBinary, "show-binary", "b", false, "print binary values")
 	kvGetCmd.Flags().BoolVarP(&showBinary, "show-binary", "b", false, "print binary values")
 	kvListCmd.Flags().StringVarP(&delimiterIterate, "delimiter", "d", "\t", "delimiter to separate keys and values")
+	kvExportCmd.Flags().StringVarP(&kvExportFile, "output", "o", "", "export filepath, or - for stdout")
+	kvImportCmd.Flags().StringVarP(&kvImportFile, "input", "i", "", "export filepath to import, or - for stdin")
+	kvDiffCmd.Flags().StringVar(&kvDiffPathA, "pathA", "", "data directory of the first db")
+	kvDiffCmd.Flags().StringVar(&kvDiffPathB, "pathB", "", "data directory of the second db")
+	kvDiffCmd.Flags().BoolVarP(&kvDiffQuiet, "quiet", "q", false, "print nothing, only exit non-zero if the dbs differ")
 
 	KVCmd.AddCommand(kvGetCmd)
 	KVCmd.AddCommand(kvSetCmd)
@@ -304,4 +476,7 @@ func init() {
 	KVCmd.AddCommand(kvListCmd)
 	KVCmd.AddCommand(kvSyncCmd)
 	KVCmd.AddCommand(kvResetCmd)
+	KVCmd.AddCommand(kvExportCmd)
+	KVCmd.AddCommand(kvImportCmd)
+	KVCmd.AddCommand(kvDiffCmd)
 }
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/charm/kv"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestKVExportImport(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+
+	err := kv.Do("export-src", func(db *kv.KV) error {
+		if err := db.Set([]byte("a"), []byte("1")); err != nil {
+			return err
+		}
+		return db.Set([]byte("bin"), []byte{0xff, 0x00, 0xfe})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(t.TempDir(), "dump.jsonl")
+	KVCmd.SetArgs([]string{"export", "@export-src", "-o", f})
+	if err := KVCmd.Execute(); err != nil {
+		t.Fatalf("export failed: %s", err)
+	}
+	KVCmd.SetArgs([]string{"import", "@export-dst", "-i", f})
+	if err := KVCmd.Execute(); err != nil {
+		t.Fatalf("import failed: %s", err)
+	}
+
+	err = kv.DoReadOnly("export-dst", func(db *kv.KV) error {
+		if v, err := db.Get([]byte("a")); err != nil || string(v) != "1" {
+			t.Errorf("expected %q, got %q, %v", "1", v, err)
+		}
+		if v, err := db.Get([]byte("bin")); err != nil || !bytes.Equal(v, []byte{0xff, 0x00, 0xfe}) {
+			t.Errorf("expected the binary value to survive, got %q, %v", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKVExportToStdout(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+
+	err := kv.Do("export-stdout", func(db *kv.KV) error {
+		return db.Set([]byte("k"), []byte("v"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	KVCmd.SetArgs([]string{"export", "@export-stdout", "-o", "-"})
+	KVCmd.SetOut(&out)
+	t.Cleanup(func() { KVCmd.SetOut(nil) })
+	if err := KVCmd.Execute(); err != nil {
+		t.Fatalf("export failed: %s", err)
+	}
+	if want := `{"key":"aw==","value":"dg=="}` + "\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestKVDiff(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+	pathB := t.TempDir()
+
+	err := kv.Do("diff", func(db *kv.KV) error {
+		for k, v := range map[string]string{"same": "1", "changed": "a", "only-a": "x"} {
+			if err := db.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kv.Do("diff-b", func(db *kv.KV) error {
+		for k, v := range map[string]string{"same": "1", "changed": "b", "only-b": "y"} {
+			if err := db.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, kv.WithPath(pathB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	KVCmd.SetOut(&out)
+	t.Cleanup(func() { KVCmd.SetOut(nil) })
+	KVCmd.SetArgs([]string{"diff", "diff", "@diff-b", "--pathB", pathB})
+	if err := KVCmd.Execute(); !errors.Is(err, errKVStoresDiffer) {
+		t.Fatalf("expected errKVStoresDiffer, got %v", err)
+	}
+	if want := "~ changed\n- only-a\n+ only-b\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	KVCmd.SetArgs([]string{"diff", "diff", "@diff-b", "--pathB", pathB, "--quiet"})
+	t.Cleanup(func() { kvDiffQuiet = false })
+	if err := KVCmd.Execute(); !errors.Is(err, errKVStoresDiffer) {
+		t.Fatalf("expected errKVStoresDiffer in quiet mode, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output in quiet mode, got %q", out.String())
+	}
+
+	KVCmd.SetArgs([]string{"diff", "diff", "diff", "--pathB", ""})
+	if err := KVCmd.Execute(); err != nil {
+		t.Errorf("expected a db to equal itself, got %v", err)
+	}
+}
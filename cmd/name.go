@@ -1,10 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/charmbracelet/charm/client"
-	charm "github.com/charmbracelet/charm/proto"
 	"github.com/muesli/reflow/indent"
 	"github.com/spf13/cobra"
 )
@@ -36,7 +36,7 @@ var NameCmd = &cobra.Command{
 				return fmt.Errorf("%s is invalid.\n\nUsernames must be basic latin letters, numerals, and no more than 50 characters. And no emojis, kid", n)
 			}
 			u, err := cc.SetName(n)
-			if err == charm.ErrNameTaken {
+			if errors.Is(err, client.ErrNameTaken) {
 				return fmt.Errorf("user name %s is already taken. Try a different, cooler name", n)
 			}
 			if err != nil {
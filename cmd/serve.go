@@ -32,6 +32,7 @@ var (
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := server.DefaultConfig()
+			cfg.Version = cmd.Root().Version
 			if serverHTTPPort != 0 {
 				cfg.HTTPPort = serverHTTPPort
 			}
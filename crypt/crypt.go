@@ -2,6 +2,7 @@
 package crypt
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -17,6 +18,21 @@ import (
 // incorrect for the encrypted data.
 var ErrIncorrectEncryptKeys = fmt.Errorf("incorrect or missing encrypt keys")
 
+// ErrUnknownVersion is returned when encrypted data was written with a
+// version of the encryption scheme this package doesn't know, such as by a
+// newer client.
+var ErrUnknownVersion = fmt.Errorf("unknown encryption version")
+
+// Version1 is the encryption scheme used by NewEncryptedWriter: a sasquatch
+// stream encrypted for a scrypt recipient derived from the encrypt key.
+const Version1 = 1
+
+// versionMarker starts the header written before the encrypted stream. It's
+// followed by a single version byte. Data written before the header was
+// added starts straight with the sasquatch header, which never starts with
+// this byte, and is read as Version1.
+const versionMarker = 0x00
+
 // Crypt manages the account and encryption keys used for encrypting and
 // decrypting.
 type Crypt struct {
@@ -42,6 +58,12 @@ func NewCrypt() (*Crypt, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewCryptWithClient(cc)
+}
+
+// NewCryptWithClient returns a Crypt using the encryption keys of the given
+// client's account.
+func NewCryptWithClient(cc *client.Client) (*Crypt, error) {
 	eks, err := cc.EncryptKeys()
 	if err != nil {
 		return nil, err
@@ -55,33 +77,43 @@ func NewCrypt() (*Crypt, error) {
 // NewDecryptedReader creates a new Reader that will read from and decrypt the
 // passed in io.Reader of encrypted data.
 func (cr *Crypt) NewDecryptedReader(r io.Reader) (*DecryptedReader, error) {
-	var sdr io.Reader
-	dr := &DecryptedReader{}
+	version, r, err := readVersion(r)
+	if err != nil {
+		return nil, err
+	}
+	if version != Version1 {
+		return nil, ErrUnknownVersion
+	}
+	// Pass every key at once: a failed attempt consumes the header from r,
+	// so keys can't be tried one after another
+	ids := make([]sasquatch.Identity, 0, len(cr.keys))
 	for _, k := range cr.keys {
 		id, err := sasquatch.NewScryptIdentity(k.Key)
 		if err != nil {
 			return nil, err
 		}
-		sdr, err = sasquatch.Decrypt(r, id)
-		if err == nil {
-			break
-		}
+		ids = append(ids, id)
 	}
-	if sdr == nil {
+	sdr, err := sasquatch.Decrypt(r, ids...)
+	if err != nil {
 		return nil, ErrIncorrectEncryptKeys
 	}
-	dr.r = sdr
-	return dr, nil
+	return &DecryptedReader{r: sdr}, nil
 }
 
 // NewEncryptedWriter creates a new Writer that encrypts all data and writes
-// the encrypted data to the supplied io.Writer.
+// the encrypted data to the supplied io.Writer. Data is encrypted with the
+// account's default key, and starts with a header naming the version of the
+// encryption scheme, Version1, so NewDecryptedReader can tell schemes apart.
 func (cr *Crypt) NewEncryptedWriter(w io.Writer) (*EncryptedWriter, error) {
 	ew := &EncryptedWriter{}
 	rec, err := sasquatch.NewScryptRecipient(cr.keys[0].Key)
 	if err != nil {
 		return ew, err
 	}
+	if _, err := w.Write([]byte{versionMarker, Version1}); err != nil {
+		return ew, err
+	}
 	sew, err := sasquatch.Encrypt(w, rec)
 	if err != nil {
 		return ew, err
@@ -90,6 +122,27 @@ func (cr *Crypt) NewEncryptedWriter(w io.Writer) (*EncryptedWriter, error) {
 	return ew, nil
 }
 
+// ReEncrypt reads data encrypted with oldKey from r and writes it to w
+// encrypted with newKey instead, such as to move data off a key after
+// rotating it. The data is streamed, so it's never held in memory whole. It
+// returns ErrIncorrectEncryptKeys if r wasn't encrypted with oldKey. On any
+// error w may hold a partial result.
+func ReEncrypt(r io.Reader, w io.Writer, oldKey, newKey *charm.EncryptKey) error {
+	dr, err := (&Crypt{keys: []*charm.EncryptKey{oldKey}}).NewDecryptedReader(r)
+	if err != nil {
+		return err
+	}
+	ew, err := (&Crypt{keys: []*charm.EncryptKey{newKey}}).NewEncryptedWriter(w)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, dr); err != nil {
+		_ = ew.Close()
+		return err
+	}
+	return ew.Close()
+}
+
 // Keys returns the EncryptKeys this Crypt is using.
 func (cr *Crypt) Keys() []*charm.EncryptKey {
 	return cr.keys
@@ -100,11 +153,15 @@ func (cr *Crypt) Keys() []*charm.EncryptKey {
 // EncryptKey. This is useful if you need to look up an encrypted value without
 // knowing the plaintext on the storage side. For writing encrypted data, use
 // EncryptedWriter which is non-deterministic.
+//
+// Lookup fields are always encrypted with the account's oldest key rather
+// than the default, so that names, such as file paths, still match after the
+// default key is rotated.
 func (cr *Crypt) EncryptLookupField(field string) (string, error) {
 	if field == "" {
 		return "", nil
 	}
-	keyBytes, err := decodeKey(cr.keys[0].Key)
+	keyBytes, err := decodeKey(cr.lookupKey().Key)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode encryption key: %w", err)
 	}
@@ -141,6 +198,18 @@ func (cr *Crypt) DecryptLookupField(field string) (string, error) {
 	return string(pt), nil
 }
 
+// lookupKey returns the key used by EncryptLookupField: the one created
+// first. Keys without a creation time are only used if no key has one.
+func (cr *Crypt) lookupKey() *charm.EncryptKey {
+	k := cr.keys[0]
+	for _, ek := range cr.keys[1:] {
+		if ek.CreatedAt != nil && (k.CreatedAt == nil || ek.CreatedAt.Before(*k.CreatedAt)) {
+			k = ek
+		}
+	}
+	return k
+}
+
 // Read decrypts and reads data from the underlying io.Reader.
 func (dr *DecryptedReader) Read(p []byte) (int, error) {
 	return dr.r.Read(p)
@@ -156,6 +225,23 @@ func (ew *EncryptedWriter) Close() error {
 	return ew.w.Close()
 }
 
+// readVersion reads the version header from the start of r, returning the
+// version and a reader for the encrypted stream that follows. Data without a
+// header is Version1.
+func readVersion(r io.Reader) (int, io.Reader, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, ErrIncorrectEncryptKeys
+	}
+	if b[0] != versionMarker {
+		return Version1, io.MultiReader(bytes.NewReader(b), r), nil
+	}
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, ErrIncorrectEncryptKeys
+	}
+	return int(b[0]), r, nil
+}
+
 // decodeKey decodes a key string that may be either base64 or hex encoded.
 // It tries base64 first (the current format), then falls back to hex (for test compatibility).
 func decodeKey(key string) ([]byte, error) {
@@ -0,0 +1,131 @@
+// ABOUTME: Unit tests for using a Crypt after its default key is rotated.
+// ABOUTME: Covers decrypting with older keys and keeping lookup fields stable.
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// newTestKey returns a random key created at the given time.
+func newTestKey(t *testing.T, id string, createdAt time.Time) *charm.EncryptKey {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate test key: %v", err)
+	}
+	return &charm.EncryptKey{ID: id, Key: hex.EncodeToString(key), CreatedAt: &createdAt}
+}
+
+func encryptString(t *testing.T, cr *Crypt, s string) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	w, err := cr.NewEncryptedWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncryptedWriter failed: %v", err)
+	}
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewDecryptedReader_OlderKey(t *testing.T) {
+	now := time.Now()
+	old := newTestKey(t, "old", now.Add(-time.Hour))
+	rotated := newTestKey(t, "new", now)
+
+	ct := encryptString(t, &Crypt{keys: []*charm.EncryptKey{old}}, "secret")
+
+	// The rotated key is the default now, but the old one still decrypts
+	cr := &Crypt{keys: []*charm.EncryptKey{rotated, old}}
+	dr, err := cr.NewDecryptedReader(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatalf("NewDecryptedReader failed: %v", err)
+	}
+	pt, err := io.ReadAll(dr)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(pt) != "secret" {
+		t.Errorf("decrypted %q, want %q", pt, "secret")
+	}
+
+	// Without the old key it can't
+	cr = &Crypt{keys: []*charm.EncryptKey{rotated}}
+	if _, err := cr.NewDecryptedReader(bytes.NewReader(ct)); err != ErrIncorrectEncryptKeys {
+		t.Errorf("expected ErrIncorrectEncryptKeys, got %v", err)
+	}
+}
+
+func TestEncryptLookupField_StableAcrossRotation(t *testing.T) {
+	now := time.Now()
+	old := newTestKey(t, "old", now.Add(-time.Hour))
+	rotated := newTestKey(t, "new", now)
+
+	before, err := (&Crypt{keys: []*charm.EncryptKey{old}}).EncryptLookupField("docs")
+	if err != nil {
+		t.Fatalf("EncryptLookupField failed: %v", err)
+	}
+	after, err := (&Crypt{keys: []*charm.EncryptKey{rotated, old}}).EncryptLookupField("docs")
+	if err != nil {
+		t.Fatalf("EncryptLookupField failed: %v", err)
+	}
+	if before != after {
+		t.Errorf("lookup field changed after rotation: %s != %s", before, after)
+	}
+}
+
+func TestReEncrypt(t *testing.T) {
+	now := time.Now()
+	old := newTestKey(t, "old", now.Add(-time.Hour))
+	rotated := newTestKey(t, "new", now)
+	pt := bytes.Repeat([]byte("secret "), 100_000)
+
+	buf := bytes.NewBuffer(nil)
+	w, err := (&Crypt{keys: []*charm.EncryptKey{old}}).NewEncryptedWriter(buf)
+	if err != nil {
+		t.Fatalf("NewEncryptedWriter failed: %v", err)
+	}
+	if _, err := w.Write(pt); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := ReEncrypt(bytes.NewReader(buf.Bytes()), out, old, rotated); err != nil {
+		t.Fatalf("ReEncrypt failed: %v", err)
+	}
+
+	// Only the new key decrypts the result
+	if _, err := (&Crypt{keys: []*charm.EncryptKey{old}}).NewDecryptedReader(bytes.NewReader(out.Bytes())); err != ErrIncorrectEncryptKeys {
+		t.Errorf("expected ErrIncorrectEncryptKeys with the old key, got %v", err)
+	}
+	dr, err := (&Crypt{keys: []*charm.EncryptKey{rotated}}).NewDecryptedReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("NewDecryptedReader failed: %v", err)
+	}
+	got, err := io.ReadAll(dr)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, pt) {
+		t.Errorf("re-encrypted data decrypted to %d bytes, want %d", len(got), len(pt))
+	}
+
+	// Data not encrypted with the old key is refused
+	if err := ReEncrypt(bytes.NewReader(out.Bytes()), io.Discard, old, rotated); err != ErrIncorrectEncryptKeys {
+		t.Errorf("expected ErrIncorrectEncryptKeys, got %v", err)
+	}
+}
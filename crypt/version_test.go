@@ -0,0 +1,76 @@
+// ABOUTME: Unit tests for the version header of encrypted streams.
+// ABOUTME: Covers writing the header, reading data without one and unknown versions.
+package crypt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/muesli/sasquatch"
+)
+
+func TestNewEncryptedWriter_VersionHeader(t *testing.T) {
+	k := newTestKey(t, "k", time.Now())
+	cr := &Crypt{keys: []*charm.EncryptKey{k}}
+
+	ct := encryptString(t, cr, "secret")
+	if !bytes.HasPrefix(ct, []byte{versionMarker, Version1}) {
+		t.Fatalf("expected the version 1 header, got % x", ct[:2])
+	}
+	dr, err := cr.NewDecryptedReader(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatalf("NewDecryptedReader failed: %v", err)
+	}
+	pt, err := io.ReadAll(dr)
+	if err != nil || string(pt) != "secret" {
+		t.Errorf("decrypted %q, %v, want %q", pt, err, "secret")
+	}
+}
+
+func TestNewDecryptedReader_WithoutVersionHeader(t *testing.T) {
+	k := newTestKey(t, "k", time.Now())
+	cr := &Crypt{keys: []*charm.EncryptKey{k}}
+
+	// Data written before the header was added is a bare sasquatch stream
+	rec, err := sasquatch.NewScryptRecipient(k.Key)
+	if err != nil {
+		t.Fatalf("NewScryptRecipient failed: %v", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	w, err := sasquatch.Encrypt(buf, rec)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := w.Write([]byte("old secret")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dr, err := cr.NewDecryptedReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewDecryptedReader failed: %v", err)
+	}
+	pt, err := io.ReadAll(dr)
+	if err != nil || string(pt) != "old secret" {
+		t.Errorf("decrypted %q, %v, want %q", pt, err, "old secret")
+	}
+}
+
+func TestNewDecryptedReader_UnknownVersion(t *testing.T) {
+	k := newTestKey(t, "k", time.Now())
+	cr := &Crypt{keys: []*charm.EncryptKey{k}}
+
+	ct := encryptString(t, cr, "secret")
+	ct[1] = 99
+	if _, err := cr.NewDecryptedReader(bytes.NewReader(ct)); err != ErrUnknownVersion {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+	if _, err := cr.NewDecryptedReader(bytes.NewReader(nil)); err != ErrIncorrectEncryptKeys {
+		t.Errorf("expected ErrIncorrectEncryptKeys for empty data, got %v", err)
+	}
+}
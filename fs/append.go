@@ -0,0 +1,74 @@
+// ABOUTME: Append writes for Charm Cloud files
+// ABOUTME: Re-uploads the file with new data added, serializing appends per path
+
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Append adds the data read from r to the end of the file at name, creating
+// the file with mode 0644 if it doesn't exist. An existing file keeps its
+// mode.
+//
+// Files are stored encrypted as a single object, so an append downloads and
+// decrypts the current content and re-encrypts and uploads all of it. The
+// cost of each append grows with the size of the file; for large logs,
+// consider writing to a new file per period instead.
+//
+// The new content replaces the old in a single upload, which the server
+// only puts in place once it's complete. A concurrent ReadFile sees either
+// the old or the full new content, never part of an append.
+//
+// Appends to the same path through this FS are serialized, so they can't
+// lose each other's data. The server has no append operation, though, so
+// a concurrent append or write from another client or process can still be
+// lost.
+func (cfs *FS) Append(name string, r io.Reader) error {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return err
+	}
+	mu := cfs.appendLock(ep)
+	mu.Lock()
+	defer mu.Unlock()
+
+	info := &FileInfo{FileInfo: charm.FileInfo{
+		Name: path.Base(name),
+		Mode: 0o644,
+	}}
+	src := r
+	f, err := cfs.Open(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		defer f.Close() // nolint:errcheck
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return &fs.PathError{Op: "append", Path: name, Err: errors.New("is a directory")}
+		}
+		info.FileInfo.Mode = fi.Mode()
+		src = io.MultiReader(f, r)
+	}
+	info.FileInfo.ModTime = time.Now()
+
+	return cfs.WriteFile(name, &File{data: io.NopCloser(src), info: info})
+}
+
+// appendLock returns the mutex serializing appends to the encrypted path ep.
+func (cfs *FS) appendLock(ep string) *sync.Mutex {
+	mu, _ := cfs.appendLocks.LoadOrStore(ep, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
@@ -0,0 +1,105 @@
+// ABOUTME: In-memory LRU cache of decrypted file contents for FS
+// ABOUTME: Avoids a network round-trip and decrypt when re-reading a file
+
+package fs
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Option configures an FS.
+type Option func(*FS)
+
+// WithCache enables an in-memory cache of decrypted file contents holding up
+// to maxBytes of data. Open and ReadFile are served from the cache when
+// possible; WriteFile and Remove invalidate the paths they change. Directory
+// listings are never cached.
+//
+// The cache only sees writes made through this FS, so a file changed by
+// another client can be served stale until it's evicted.
+func WithCache(maxBytes int64) Option {
+	return func(cfs *FS) {
+		if maxBytes > 0 {
+			cfs.cache = newFileCache(maxBytes)
+		}
+	}
+}
+
+// fileCache is a size-bounded LRU of file contents keyed by encrypted path.
+type fileCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	entries  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+	info charm.FileInfo
+}
+
+func newFileCache(maxBytes int64) *fileCache {
+	return &fileCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached contents and file info for key, if present.
+func (c *fileCache) get(key string) ([]byte, charm.FileInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, charm.FileInfo{}, false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	return e.data, e.info, true
+}
+
+// put stores data for key, evicting the least recently used entries to stay
+// within maxBytes. Files larger than the whole cache aren't stored.
+func (c *fileCache) put(key string, data []byte, info charm.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, data: data, info: info})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.removeLocked(c.order.Back().Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops key and, since it may be a directory, everything under it.
+func (c *fileCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			c.removeLocked(k)
+		}
+	}
+}
+
+func (c *fileCache) removeLocked(key string) {
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(el)
+	delete(c.entries, key)
+	c.size -= int64(len(el.Value.(*cacheEntry).data))
+}
@@ -0,0 +1,118 @@
+// ABOUTME: Unit tests for the FS read-through cache.
+// ABOUTME: Covers LRU eviction, size limits, prefix invalidation, and cache hits in Open.
+package fs
+
+import (
+	"io"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestFileCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newFileCache(10)
+	c.put("a", []byte("1234"), charm.FileInfo{})
+	c.put("b", []byte("1234"), charm.FileInfo{})
+	// Touch a so b becomes the eviction candidate
+	if _, _, ok := c.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.put("c", []byte("1234"), charm.FileInfo{})
+
+	if _, _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, _, ok := c.get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+	if c.size != 8 {
+		t.Errorf("size = %d, want 8", c.size)
+	}
+}
+
+func TestFileCache_SkipsOversizedFiles(t *testing.T) {
+	c := newFileCache(4)
+	c.put("a", []byte("12"), charm.FileInfo{})
+	c.put("big", []byte("12345"), charm.FileInfo{})
+
+	if _, _, ok := c.get("big"); ok {
+		t.Error("expected oversized file not to be cached")
+	}
+	if _, _, ok := c.get("a"); !ok {
+		t.Error("expected a to survive an oversized put")
+	}
+}
+
+func TestFileCache_InvalidatePrefix(t *testing.T) {
+	c := newFileCache(100)
+	c.put("dir/a", []byte("1"), charm.FileInfo{})
+	c.put("dir/sub/b", []byte("2"), charm.FileInfo{})
+	c.put("dirx", []byte("3"), charm.FileInfo{})
+
+	c.invalidate("dir")
+
+	for _, k := range []string{"dir/a", "dir/sub/b"} {
+		if _, _, ok := c.get(k); ok {
+			t.Errorf("expected %s to be invalidated", k)
+		}
+	}
+	if _, _, ok := c.get("dirx"); !ok {
+		t.Error("expected sibling dirx to stay cached")
+	}
+	if c.size != 1 {
+		t.Errorf("size = %d, want 1", c.size)
+	}
+}
+
+func TestOpen_ServedFromCache(t *testing.T) {
+	// The test FS points at a server that isn't running, so only a cache hit
+	// can succeed.
+	cfs := createTestFS(t)
+	WithCache(1024)(cfs)
+
+	ep, err := cfs.EncryptPath("config.toml")
+	if err != nil {
+		t.Fatalf("EncryptPath failed: %v", err)
+	}
+	cfs.cache.put(ep, []byte("cached"), charm.FileInfo{Name: "config.toml", Size: 6, Mode: 0o600})
+
+	data, err := cfs.ReadFile("config.toml")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "cached" {
+		t.Errorf("ReadFile = %q, want %q", data, "cached")
+	}
+
+	f, err := cfs.Open("config.toml")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Name() != "config.toml" || info.Size() != 6 || info.Mode() != 0o600 {
+		t.Errorf("unexpected file info: %+v", info)
+	}
+	if b, _ := io.ReadAll(f); string(b) != "cached" {
+		t.Errorf("Open content = %q, want %q", b, "cached")
+	}
+
+	// Reading must not mutate the cached copy
+	data[0] = 'X'
+	if again, _ := cfs.ReadFile("config.toml"); string(again) != "cached" {
+		t.Errorf("cached data was modified: %q", again)
+	}
+}
+
+func TestWithCache_Disabled(t *testing.T) {
+	cfs := createTestFS(t)
+	WithCache(0)(cfs)
+	if cfs.cache != nil {
+		t.Error("expected WithCache(0) to leave the cache disabled")
+	}
+}
@@ -0,0 +1,39 @@
+// ABOUTME: Content checksums for Charm Cloud files
+// ABOUTME: Hashes the decrypted content so it can be compared with local files
+
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// Checksum returns the hex-encoded SHA-256 hash of the decrypted content of
+// the file at name. It matches the hash of an identical local file, so sync
+// tools can skip uploading files that haven't changed.
+//
+// Files are encrypted with a fresh key for every upload, so the server can't
+// tell whether two files have the same content. Checksum downloads and
+// decrypts the file to hash it, unless it's cached (see WithCache).
+func (cfs *FS) Checksum(name string) (string, error) {
+	f, err := cfs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", &fs.PathError{Op: "checksum", Path: name, Err: errors.New("is a directory")}
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", &fs.PathError{Op: "checksum", Path: name, Err: err}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
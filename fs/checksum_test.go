@@ -0,0 +1,34 @@
+// ABOUTME: Unit tests for FS.Checksum.
+// ABOUTME: Serves the file from the cache so no server is needed to hash it.
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestChecksum(t *testing.T) {
+	// The test FS points at a server that isn't running, so the file is
+	// served from the cache.
+	cfs := createTestFS(t)
+	WithCache(1024)(cfs)
+
+	ep, err := cfs.EncryptPath("notes.txt")
+	if err != nil {
+		t.Fatalf("EncryptPath failed: %v", err)
+	}
+	content := []byte("hello checksum")
+	cfs.cache.put(ep, content, charm.FileInfo{Name: "notes.txt", Size: int64(len(content)), Mode: 0o644})
+
+	got, err := cfs.Checksum("notes.txt")
+	if err != nil {
+		t.Fatalf("Checksum failed: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if want := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("Checksum = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+// ABOUTME: File and recursive directory copies within Charm Cloud storage
+// ABOUTME: Streams each file through Open and WriteFile, keeping file modes
+
+package fs
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Copy copies the file at srcPath to dstPath, keeping its mode. Use CopyDir
+// for directories.
+//
+// The source is read and encrypted in full before anything is uploaded, and
+// the server only puts the destination in place once the upload completes,
+// so a failed copy doesn't leave a partial file at dstPath.
+func (cfs *FS) Copy(srcPath, dstPath string) error {
+	f, err := cfs.Open(srcPath)
+	if err != nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+	defer f.Close() // nolint:errcheck
+	info, err := f.Stat()
+	if err != nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: fmt.Errorf("is a directory")}
+	}
+	return cfs.WriteFile(dstPath, f)
+}
+
+// CopyDir copies the directory srcPath and everything under it to dstPath,
+// keeping each file's mode. Files already at the destination are overwritten.
+//
+// Copying stops at the first failure and returns an *fs.PathError naming the
+// source file or directory that failed; everything copied before that point
+// is left in place.
+func (cfs *FS) CopyDir(srcPath, dstPath string) error {
+	f, err := cfs.Open(srcPath)
+	if err != nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: fmt.Errorf("not a directory")}
+	}
+	return cfs.copyDir(srcPath, dstPath)
+}
+
+func (cfs *FS) copyDir(srcPath, dstPath string) error {
+	des, err := cfs.ReadDir(srcPath)
+	if err != nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+	for _, de := range des {
+		src := path.Join(srcPath, de.Name())
+		dst := path.Join(dstPath, de.Name())
+		if de.IsDir() {
+			if err := cfs.copyDir(src, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cfs.copyFile(src, dst); err != nil {
+			return &fs.PathError{Op: "copy", Path: src, Err: err}
+		}
+	}
+	return nil
+}
+
+// copyFile copies a single file. WriteFile takes the mode from the opened
+// source file's FileInfo.
+func (cfs *FS) copyFile(src, dst string) error {
+	f, err := cfs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	return cfs.WriteFile(dst, f)
+}
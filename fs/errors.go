@@ -0,0 +1,49 @@
+// ABOUTME: Error values for FS requests to the Charm Cloud server
+// ABOUTME: Lets callers tell missing files, auth failures, and server faults apart
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+// ErrUnauthorized is wrapped by errors from requests the server rejected
+// with 401 or 403, such as when the JWT has expired. The client's cached
+// auth is invalidated first, so retrying the operation re-authenticates.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrStorageLimit is wrapped by errors from writes the server rejected
+// because they would take the user past their storage limit.
+var ErrStorageLimit = errors.New("storage limit exceeded")
+
+// ErrServer is wrapped by errors from requests that failed with a 5xx
+// status.
+var ErrServer = errors.New("server error")
+
+// requestError classifies an error returned with resp by an authed request.
+// A 404 becomes fs.ErrNotExist; 401 and 403 wrap ErrUnauthorized, 507 and 413
+// wrap ErrStorageLimit and other 5xx wrap ErrServer, keeping the original
+// error too. Servers before 507 was used reject writes over the limit with a
+// 413.
+// Errors without a response, such as network failures, are returned
+// unchanged.
+func (cfs *FS) requestError(resp *http.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	switch code := resp.StatusCode; {
+	case code == http.StatusNotFound:
+		return fs.ErrNotExist
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		cfs.cc.InvalidateAuth()
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case code == http.StatusInsufficientStorage || code == http.StatusRequestEntityTooLarge:
+		return fmt.Errorf("%w: %w", ErrStorageLimit, err)
+	case code >= 500:
+		return fmt.Errorf("%w: %w", ErrServer, err)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+// ABOUTME: Unit tests for FS request error classification.
+// ABOUTME: Verifies 404, 401/403, 413/507, and 5xx responses map to the right sentinels.
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"testing"
+)
+
+func TestRequestError(t *testing.T) {
+	cfs := createTestFS(t)
+	base := errors.New("server error: boom")
+
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		target error
+	}{
+		{"not found", &http.Response{StatusCode: http.StatusNotFound}, fs.ErrNotExist},
+		{"unauthorized", &http.Response{StatusCode: http.StatusUnauthorized}, ErrUnauthorized},
+		{"forbidden", &http.Response{StatusCode: http.StatusForbidden}, ErrUnauthorized},
+		{"too large", &http.Response{StatusCode: http.StatusRequestEntityTooLarge}, ErrStorageLimit},
+		{"insufficient storage", &http.Response{StatusCode: http.StatusInsufficientStorage}, ErrStorageLimit},
+		{"internal", &http.Response{StatusCode: http.StatusInternalServerError}, ErrServer},
+		{"unavailable", &http.Response{StatusCode: http.StatusServiceUnavailable}, ErrServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfs.requestError(tt.resp, base)
+			if !errors.Is(err, tt.target) {
+				t.Errorf("requestError = %v, want it to wrap %v", err, tt.target)
+			}
+			if tt.target != fs.ErrNotExist && !errors.Is(err, base) {
+				t.Errorf("requestError = %v, want it to keep the original error", err)
+			}
+		})
+	}
+}
+
+func TestRequestError_Unclassified(t *testing.T) {
+	cfs := createTestFS(t)
+	base := errors.New("dial tcp: connection refused")
+
+	// No response means the request never reached the server
+	if err := cfs.requestError(nil, base); err != base {
+		t.Errorf("requestError(nil) = %v, want the original error", err)
+	}
+	err := cfs.requestError(&http.Response{StatusCode: http.StatusBadRequest}, base)
+	if errors.Is(err, ErrServer) || errors.Is(err, ErrUnauthorized) || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("requestError(400) = %v, want no sentinel", err)
+	}
+}
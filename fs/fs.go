@@ -3,15 +3,19 @@ package fs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"mime/multipart"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -27,14 +31,30 @@ import (
 type FS struct {
 	cc    *client.Client
 	crypt *crypt.Crypt
+	cache *fileCache // nil unless WithCache is used
+
+	// progress is called as files are transferred, see WithProgress
+	progress func(bytesDone, bytesTotal int64)
+
+	appendLocks sync.Map // encrypted path -> *sync.Mutex, see Append
 }
 
-// File implements the fs.File interface.
+// File implements the fs.File interface. Directories also implement
+// fs.ReadDirFile.
 type File struct {
 	data io.ReadCloser
 	info *FileInfo
+
+	// dirOffset is how many directory entries ReadDir has returned
+	dirOffset int
 }
 
+var (
+	_ fs.ReadDirFS   = (*FS)(nil)
+	_ fs.ReadFileFS  = (*FS)(nil)
+	_ fs.ReadDirFile = (*File)(nil)
+)
+
 // FileInfo implements the fs.FileInfo interface.
 type FileInfo struct {
 	charm.FileInfo
@@ -71,25 +91,36 @@ func (df *DirFile) Close() error {
 }
 
 // NewFS returns an FS with the default configuration.
-func NewFS() (*FS, error) {
+func NewFS(opts ...Option) (*FS, error) {
 	cc, err := client.NewClientWithDefaults()
 	if err != nil {
 		return nil, err
 	}
-	return NewFSWithClient(cc)
+	return NewFSWithClient(cc, opts...)
 }
 
 // NewFSWithClient returns an FS with a custom *client.Client.
-func NewFSWithClient(cc *client.Client) (*FS, error) {
-	crypt, err := crypt.NewCrypt()
+func NewFSWithClient(cc *client.Client, opts ...Option) (*FS, error) {
+	crypt, err := crypt.NewCryptWithClient(cc)
 	if err != nil {
 		return nil, err
 	}
-	return &FS{cc: cc, crypt: crypt}, nil
+	cfs := &FS{cc: cc, crypt: crypt}
+	for _, opt := range opts {
+		opt(cfs)
+	}
+	return cfs, nil
 }
 
 // Open implements Open for fs.FS.
 func (cfs *FS) Open(name string) (fs.File, error) {
+	return cfs.OpenContext(context.Background(), name)
+}
+
+// OpenContext is like Open but cancels the download when ctx is done. The
+// file is read in full before OpenContext returns, so ctx has no effect on
+// the returned fs.File.
+func (cfs *FS) OpenContext(ctx context.Context, name string) (fs.File, error) {
 	f := &File{
 		info: &FileInfo{},
 	}
@@ -97,12 +128,23 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 	if err != nil {
 		return nil, pathError(name, err)
 	}
+	if cfs.cache != nil {
+		if data, info, ok := cfs.cache.get(ep); ok {
+			f.data = io.NopCloser(bytes.NewReader(data))
+			f.info.FileInfo = info
+			if cfs.progress != nil && !info.IsDir {
+				cfs.progress(info.Size, info.Size)
+			}
+			return f, nil
+		}
+	}
 	p := fmt.Sprintf("/v1/fs/%s", ep)
-	resp, err := cfs.cc.AuthedRawRequest("GET", p)
-	if resp != nil && resp.StatusCode == http.StatusNotFound {
-		return nil, fs.ErrNotExist
-	} else if err != nil {
-		return nil, pathError(name, err)
+	resp, err := cfs.cc.AuthedRawRequestWithContext(ctx, "GET", p)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		return nil, pathError(name, cfs.requestError(resp, err))
 	}
 	defer resp.Body.Close() // nolint:errcheck
 
@@ -115,24 +157,15 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 			return nil, pathError(name, err)
 		}
 		f.info.FileInfo = *dir
-		var des []fs.DirEntry
-		for _, de := range dir.Files {
-			p := fmt.Sprintf("%s/%s", strings.Trim(ep, "/"), de.Name)
-			sf := sysFuture{
-				fs:   cfs,
-				path: p,
-			}
-			dn, err := cfs.crypt.DecryptLookupField(de.Name)
-			if err != nil {
-				return nil, pathError(name, err)
-			}
-			dei := FileInfo{
-				FileInfo: de,
-				sys:      sf,
-			}
-			dei.FileInfo.Name = dn
-			des = append(des, &dei)
+		// The server only knows the encrypted name
+		f.info.FileInfo.Name = path.Base(name)
+		des, err := cfs.dirEntries(name, dir.Files)
+		if err != nil {
+			return nil, pathError(name, err)
 		}
+		// fs.ReadDirFS requires entries sorted by name, and the order of
+		// encrypted names says nothing about the plaintext order
+		sort.Slice(des, func(i, j int) bool { return des[i].Name() < des[j].Name() })
 		f.info.sys = des
 	case "application/octet-stream":
 		f.info.FileInfo.Name = path.Base(name)
@@ -142,7 +175,8 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 		}
 		f.info.FileInfo.Mode = fs.FileMode(m)
 		b := bytes.NewBuffer(nil)
-		dec, err := cfs.crypt.NewDecryptedReader(resp.Body)
+		body := newProgressReader(resp.Body, cfs.progress, resp.ContentLength, resp.ContentLength)
+		dec, err := cfs.crypt.NewDecryptedReader(body)
 		if err != nil {
 			return nil, pathError(name, err)
 		}
@@ -154,20 +188,52 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 		if err != nil {
 			return nil, pathError(name, err)
 		}
-		f.data = io.NopCloser(b)
 		f.info.FileInfo.Size = int64(b.Len())
 		f.info.FileInfo.ModTime = modTime
 		f.info.FileInfo.IsDir = false
+		if cfs.cache != nil {
+			cfs.cache.put(ep, bytes.Clone(b.Bytes()), f.info.FileInfo)
+		}
+		f.data = io.NopCloser(b)
 	default:
 		return nil, pathError(name, fmt.Errorf("invalid content-type returned from server"))
 	}
 	return f, nil
 }
 
+// dirEntries decrypts the names of the files listed in the directory name.
+func (cfs *FS) dirEntries(name string, files []charm.FileInfo) ([]fs.DirEntry, error) {
+	// An empty directory lists as an empty slice, not nil
+	des := make([]fs.DirEntry, 0, len(files))
+	for _, de := range files {
+		dn, err := cfs.crypt.DecryptLookupField(de.Name)
+		if err != nil {
+			return nil, err
+		}
+		sf := sysFuture{
+			fs:   cfs,
+			path: path.Join(name, dn),
+		}
+		dei := FileInfo{
+			FileInfo: de,
+			sys:      sf,
+		}
+		dei.FileInfo.Name = dn
+		des = append(des, &dei)
+	}
+	return des, nil
+}
+
 // ReadFile implements fs.ReadFileFS.
 func (cfs *FS) ReadFile(name string) ([]byte, error) {
+	return cfs.ReadFileContext(context.Background(), name)
+}
+
+// ReadFileContext is like ReadFile but cancels the download when ctx is
+// done.
+func (cfs *FS) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	f, err := cfs.Open(name)
+	f, err := cfs.OpenContext(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -184,6 +250,13 @@ func (cfs *FS) ReadFile(name string) ([]byte, error) {
 // in a directory that doesn't exist, it and any needed subdirectories are
 // created.
 func (cfs *FS) WriteFile(name string, src fs.File) error {
+	return cfs.WriteFileContext(context.Background(), name, src)
+}
+
+// WriteFileContext is like WriteFile but cancels the upload when ctx is done,
+// so a deadline can be enforced on large uploads over slow links. A
+// cancelled upload leaves any existing file at name unchanged.
+func (cfs *FS) WriteFileContext(ctx context.Context, name string, src fs.File) error {
 	info, err := src.Stat()
 	if err != nil {
 		return err
@@ -199,6 +272,9 @@ func (cfs *FS) WriteFile(name string, src fs.File) error {
 	if err := eb.Close(); err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
 	// To calculate the Content Length of a multipart request, we need to split
 	// the multipart into header, data body, and boundary footer and then
 	// calculate the length of each.
@@ -228,6 +304,8 @@ func (cfs *FS) WriteFile(name string, src fs.File) error {
 	if err != nil {
 		return err
 	}
+	// Drop the cached copy once the upload is done, even if it failed
+	defer cfs.invalidate(ep)
 	// pipe the multipart request to the server
 	rr, rw := io.Pipe()
 	defer rr.Close() // nolint:errcheck
@@ -262,29 +340,51 @@ func (cfs *FS) WriteFile(name string, src fs.File) error {
 		"Content-Type":   []string{w.FormDataContentType()},
 		"Content-Length": []string{fmt.Sprintf("%d", contentLength)},
 	}
-	resp, err := cfs.cc.AuthedRequest("POST", path, headers, rr)
+	body := newProgressReader(rr, cfs.progress, contentLength, info.Size())
+	resp, err := cfs.cc.AuthedRequestWithContext(ctx, "POST", path, headers, body)
 	if err != nil {
 		if resp != nil {
 			resp.Body.Close() // nolint:errcheck
 		}
-		return err
+		return &fs.PathError{Op: "write", Path: name, Err: cfs.requestError(resp, err)}
 	}
 	return resp.Body.Close()
 }
 
 // Remove deletes a file from the Charm Cloud server.
 func (cfs *FS) Remove(name string) error {
+	return cfs.remove("remove", name)
+}
+
+// RemoveAll deletes name and everything in it, like os.RemoveAll, so a
+// whole directory tree can be deleted with one request. It returns nil if
+// name doesn't exist. The root can't be removed; delete the account to
+// remove every file.
+func (cfs *FS) RemoveAll(name string) error {
+	if isRoot(name) {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	err := cfs.remove("removeall", name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// remove deletes name and anything in it, reporting errors as op.
+func (cfs *FS) remove(op string, name string) error {
 	ep, err := cfs.EncryptPath(name)
 	if err != nil {
 		return err
 	}
+	defer cfs.invalidate(ep)
 	path := fmt.Sprintf("/v1/fs/%s", ep)
 	resp, err := cfs.cc.AuthedRequest("DELETE", path, nil, nil)
 	if err != nil {
 		if resp != nil {
 			resp.Body.Close() // nolint:errcheck
 		}
-		return err
+		return &fs.PathError{Op: op, Path: name, Err: cfs.requestError(resp, err)}
 	}
 	return resp.Body.Close()
 }
@@ -292,7 +392,7 @@ func (cfs *FS) Remove(name string) error {
 // ReadDir reads the named directory and returns a list of directory entries.
 func (cfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
 	f, err := cfs.Open(name)
-	if err == fs.ErrNotExist {
+	if errors.Is(err, fs.ErrNotExist) {
 		return []fs.DirEntry{}, nil
 	}
 	if err != nil {
@@ -302,6 +402,13 @@ func (cfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
 	return f.(*File).ReadDir(0)
 }
 
+// invalidate drops the encrypted path ep from the cache, if enabled.
+func (cfs *FS) invalidate(ep string) {
+	if cfs.cache != nil {
+		cfs.cache.invalidate(ep)
+	}
+}
+
 // Client returns the underlying *client.Client.
 func (cfs *FS) Client() *client.Client {
 	return cfs.cc
@@ -318,8 +425,13 @@ func (f *File) Read(b []byte) (int, error) {
 	return f.data.Read(b)
 }
 
-// ReadDir returns the directory entries for the directory file. If needed, the
-// directory listing will be resolved from the Charm Cloud server.
+// ReadDir returns the directory entries for the directory file, sorted by
+// name. If needed, the directory listing will be resolved from the Charm
+// Cloud server.
+//
+// As with fs.ReadDirFile, if n > 0 ReadDir returns at most n entries, picking
+// up where the previous call left off, and io.EOF once none are left. If
+// n <= 0 it returns all remaining entries.
 func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
 	fi, err := f.Stat()
 	if err != nil {
@@ -345,9 +457,18 @@ func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
 	default:
 		return nil, fmt.Errorf("invalid FileInfo sys type")
 	}
-	if n > 0 && n < len(des) {
-		return des[:n], nil
+	des = des[min(f.dirOffset, len(des)):]
+	if n <= 0 {
+		f.dirOffset += len(des)
+		return des, nil
+	}
+	if len(des) == 0 {
+		return nil, io.EOF
+	}
+	if n < len(des) {
+		des = des[:n]
 	}
+	f.dirOffset += len(des)
 	return des, nil
 }
 
@@ -586,3 +586,35 @@ func _testReadDir_Integration(t *testing.T) {
 		t.Errorf("ReadDir on nonexistent path returned %d entries, want 0", len(entries))
 	}
 }
+
+// TestFileReadDir_Paging tests that ReadDir(n) continues where the previous call left off.
+func TestFileReadDir_Paging(t *testing.T) {
+	var des []fs.DirEntry
+	for _, n := range []string{"a", "b", "c"} {
+		des = append(des, &FileInfo{FileInfo: charm.FileInfo{Name: n}})
+	}
+	f := &File{info: &FileInfo{FileInfo: charm.FileInfo{Name: "dir", IsDir: true}, sys: des}}
+
+	var got []string
+	for {
+		page, err := f.ReadDir(2)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadDir returned error: %v", err)
+		}
+		for _, de := range page {
+			got = append(got, de.Name())
+		}
+	}
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("ReadDir pages = %v, want [a b c]", got)
+	}
+
+	// Nothing remains, but n <= 0 never returns io.EOF
+	rest, err := f.ReadDir(0)
+	if err != nil || len(rest) != 0 {
+		t.Errorf("ReadDir(0) after paging = (%v, %v), want empty and nil", rest, err)
+	}
+}
@@ -0,0 +1,96 @@
+// ABOUTME: Explicit directory creation in Charm Cloud storage
+// ABOUTME: Lets empty directories exist without a file written under them
+
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"path"
+)
+
+// dirMode is the mode directories are created with.
+const dirMode = fs.ModeDir | 0o755
+
+// Mkdir creates the directory name. Its parent directory must already exist.
+// If name already exists, Mkdir returns an *fs.PathError wrapping
+// fs.ErrExist.
+func (cfs *FS) Mkdir(name string) error {
+	if isRoot(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if _, err := cfs.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if parent := path.Dir(name); !isRoot(parent) {
+		info, err := cfs.Stat(parent)
+		if err != nil {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fmt.Errorf("%s is not a directory", parent)}
+		}
+	}
+	return cfs.mkdir(name)
+}
+
+// MkdirAll creates the directory name along with any missing parents. It
+// does nothing if name is already a directory.
+func (cfs *FS) MkdirAll(name string) error {
+	if isRoot(name) {
+		return nil
+	}
+	info, err := cfs.Stat(name)
+	if err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return cfs.mkdir(name)
+}
+
+// mkdir asks the server to create the directory name and any missing
+// parents.
+func (cfs *FS) mkdir(name string) error {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	// The server expects a file part even though a directory has no content
+	buf := bytes.NewBuffer(nil)
+	w := multipart.NewWriter(buf)
+	if _, err := w.CreateFormFile("data", path.Base(ep)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	p := fmt.Sprintf("/v1/fs/%s?mode=%d", ep, uint32(dirMode))
+	headers := http.Header{
+		"Content-Type": []string{w.FormDataContentType()},
+	}
+	resp, err := cfs.cc.AuthedRequest("POST", p, headers, buf)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: cfs.requestError(resp, err)}
+	}
+	return resp.Body.Close()
+}
+
+// isRoot reports whether name is the root of the user's files.
+func isRoot(name string) bool {
+	name = path.Clean(name)
+	return name == "/" || name == "."
+}
@@ -0,0 +1,23 @@
+// ABOUTME: Unit tests for Mkdir and MkdirAll.
+// ABOUTME: Covers the root directory, which never needs a server round-trip.
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestMkdir_Root(t *testing.T) {
+	// The test FS points at a server that isn't running, so these must be
+	// answered without a request.
+	cfs := createTestFS(t)
+	for _, name := range []string{"/", ".", ""} {
+		if err := cfs.Mkdir(name); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Mkdir(%q) = %v, want fs.ErrExist", name, err)
+		}
+		if err := cfs.MkdirAll(name); err != nil {
+			t.Errorf("MkdirAll(%q) = %v, want nil", name, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// ABOUTME: Progress reporting for FS uploads and downloads
+// ABOUTME: Counts the bytes of a request or response body as they're transferred
+
+package fs
+
+import "io"
+
+// WithProgress calls fn as WriteFile and WriteFileContext upload a file and
+// as Open, OpenContext, ReadFile and ReadFileContext download one, so a UI
+// can show how far along a big transfer is. fn gets the number of bytes
+// transferred so far and the size of the whole transfer, and is called from
+// the goroutine doing the transfer.
+//
+// For uploads bytesTotal is the size of the file being written. For
+// downloads it's the size of the encrypted file sent by the server, which is
+// a little bigger than the file, or -1 if the server didn't say. A file
+// served from the cache reports its whole size at once.
+func WithProgress(fn func(bytesDone, bytesTotal int64)) Option {
+	return func(cfs *FS) {
+		cfs.progress = fn
+	}
+}
+
+// progressReader calls fn with how much of r has been read. The bytes read
+// are reported scaled from size, the length of r, to total, so the encrypted
+// data sent for a file can be reported in terms of the file's size.
+type progressReader struct {
+	r     io.Reader
+	fn    func(bytesDone, bytesTotal int64)
+	read  int64
+	size  int64
+	total int64
+}
+
+// newProgressReader returns r reporting its progress to fn, or r itself if
+// fn is nil. A size below 0 means the length of r is unknown.
+func newProgressReader(r io.Reader, fn func(int64, int64), size int64, total int64) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, fn: fn, size: size, total: total}
+}
+
+// Read implements io.Reader.
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	if n > 0 {
+		pr.read += int64(n)
+		done := pr.read
+		if pr.size > 0 && pr.size != pr.total {
+			done = min(pr.read*pr.total/pr.size, pr.total)
+		}
+		pr.fn(done, pr.total)
+	}
+	return n, err
+}
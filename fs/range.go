@@ -0,0 +1,81 @@
+// ABOUTME: Partial reads of Charm Cloud files
+// ABOUTME: Streams and decrypts from the start, discarding data before the offset
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OpenRange returns a reader for length bytes of the file at name, starting
+// at offset. A negative length reads to the end of the file. Reading past the
+// end of the file returns fewer bytes, or none at all; it's not an error.
+//
+// Files are encrypted as a single stream, so the server can't seek into
+// them: OpenRange still downloads and decrypts the file from the start,
+// discarding everything before offset. Unlike Open it doesn't hold the file
+// in memory, and closing the reader stops the download, so reading the
+// start of a large file is cheap while reading its end costs about as much
+// as a full download. Files in the cache (see WithCache) are read from
+// memory.
+func (cfs *FS) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	return cfs.OpenRangeContext(context.Background(), name, offset, length)
+}
+
+// OpenRangeContext is like OpenRange but cancels the download when ctx is
+// done, including while the returned reader is being read.
+func (cfs *FS) OpenRangeContext(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, pathError(name, errors.New("negative offset"))
+	}
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	if cfs.cache != nil {
+		if data, _, ok := cfs.cache.get(ep); ok {
+			data = data[min(offset, int64(len(data))):]
+			if length >= 0 && length < int64(len(data)) {
+				data = data[:length]
+			}
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	resp, err := cfs.cc.AuthedRawRequestWithContext(ctx, "GET", fmt.Sprintf("/v1/fs/%s", ep))
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		return nil, pathError(name, cfs.requestError(resp, err))
+	}
+	switch resp.Header.Get("Content-Type") {
+	case "application/octet-stream":
+	case "application/json":
+		resp.Body.Close() // nolint:errcheck
+		return nil, pathError(name, errors.New("is a directory"))
+	default:
+		resp.Body.Close() // nolint:errcheck
+		return nil, pathError(name, fmt.Errorf("invalid content-type returned from server"))
+	}
+	dec, err := cfs.crypt.NewDecryptedReader(resp.Body)
+	if err != nil {
+		resp.Body.Close() // nolint:errcheck
+		return nil, pathError(name, err)
+	}
+	if _, err := io.CopyN(io.Discard, dec, offset); err != nil && err != io.EOF {
+		resp.Body.Close() // nolint:errcheck
+		return nil, pathError(name, err)
+	}
+	var r io.Reader = dec
+	if length >= 0 {
+		r = io.LimitReader(dec, length)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}, nil
+}
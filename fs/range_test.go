@@ -0,0 +1,57 @@
+// ABOUTME: Unit tests for FS.OpenRange.
+// ABOUTME: Serves the file from the cache so ranges can be checked without a server.
+package fs
+
+import (
+	"io"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestOpenRange(t *testing.T) {
+	// The test FS points at a server that isn't running, so the file is
+	// served from the cache.
+	cfs := createTestFS(t)
+	WithCache(1024)(cfs)
+
+	ep, err := cfs.EncryptPath("log.txt")
+	if err != nil {
+		t.Fatalf("EncryptPath failed: %v", err)
+	}
+	content := []byte("0123456789")
+	cfs.cache.put(ep, content, charm.FileInfo{Name: "log.txt", Size: int64(len(content)), Mode: 0o644})
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+	}{
+		{"start", 0, 3, "012"},
+		{"middle", 4, 3, "456"},
+		{"to end", 7, -1, "789"},
+		{"past end", 8, 5, "89"},
+		{"beyond file", 20, 5, ""},
+		{"empty", 2, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := cfs.OpenRange("log.txt", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("OpenRange failed: %v", err)
+			}
+			defer r.Close() // nolint:errcheck
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("OpenRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := cfs.OpenRange("log.txt", -1, 1); err == nil {
+		t.Error("expected an error for a negative offset")
+	}
+}
@@ -0,0 +1,62 @@
+// ABOUTME: Paginated directory listings for large Charm Cloud directories
+// ABOUTME: Fetches and decrypts only a window of entries per request
+
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strconv"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// ReadDirPage reads up to limit entries of the directory name, skipping the
+// first offset entries, so huge directories can be listed a page at a time.
+// Fewer than limit entries means the end of the directory was reached.
+//
+// Entries are in the server's order, which is stable between calls but isn't
+// sorted by name: names are encrypted on the server, so sorting them needs
+// the full listing ReadDir fetches.
+func (cfs *FS) ReadDirPage(name string, offset, limit int) ([]fs.DirEntry, error) {
+	return cfs.ReadDirPageContext(context.Background(), name, offset, limit)
+}
+
+// ReadDirPageContext is like ReadDirPage but cancels the request when ctx is
+// done.
+func (cfs *FS) ReadDirPageContext(ctx context.Context, name string, offset, limit int) ([]fs.DirEntry, error) {
+	if offset < 0 || limit < 1 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	q := url.Values{}
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(limit))
+	resp, err := cfs.cc.AuthedRawRequestWithContext(ctx, "GET", fmt.Sprintf("/v1/fs/%s?%s", ep, q.Encode()))
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		return nil, pathError(name, cfs.requestError(resp, err))
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.Header.Get("Content-Type") != "application/json" {
+		return nil, pathError(name, errors.New("not a directory"))
+	}
+	dir := &charm.FileInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(dir); err != nil {
+		return nil, pathError(name, err)
+	}
+	des, err := cfs.dirEntries(name, dir.Files)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	return des, nil
+}
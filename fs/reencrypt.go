@@ -0,0 +1,56 @@
+// ABOUTME: Re-encryption of every Charm Cloud file after an encrypt key rotation
+// ABOUTME: Walks the user's whole tree and rewrites each file under the default key
+
+package fs
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/charmbracelet/charm/crypt"
+)
+
+// ReEncryptAll rewrites every file the user has stored so its content is
+// encrypted with the current default encrypt key. Run it after
+// client.RotateEncryptKey so old keys no longer protect any file content.
+//
+// Each file is downloaded, decrypted with whichever key it was written with,
+// and uploaded again at the same path with the same mode; modification times
+// are reset. Paths are encrypted with the account's oldest key and don't
+// change.
+//
+// This is heavy: it transfers every file twice and stops at the first
+// failure, leaving files already rewritten in place, so it's safe to run
+// again. Run it offline, while no other client is writing files.
+func (cfs *FS) ReEncryptAll() error {
+	cr, err := crypt.NewCryptWithClient(cfs.cc)
+	if err != nil {
+		return err
+	}
+	cfs.crypt = cr
+	return cfs.Walk("/", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A user who never stored a file has no root directory
+			if name == "/" && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return cfs.reEncrypt(name)
+	})
+}
+
+func (cfs *FS) reEncrypt(name string) error {
+	f, err := cfs.Open(name)
+	if err != nil {
+		return &fs.PathError{Op: "re-encrypt", Path: name, Err: err}
+	}
+	defer f.Close() // nolint:errcheck
+	if err := cfs.WriteFile(name, f); err != nil {
+		return &fs.PathError{Op: "re-encrypt", Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// ABOUTME: Rename/move for Charm Cloud files and directories
+// ABOUTME: Copies to the new path and then removes the old one, client-side
+
+package fs
+
+import (
+	"io/fs"
+)
+
+// Rename moves the file or directory at oldPath to newPath, keeping file
+// modes. Either path may use the charm: prefix. If oldPath doesn't exist,
+// the returned error satisfies errors.Is(err, fs.ErrNotExist).
+//
+// The server has no rename operation, so the content is copied to newPath
+// and then oldPath is removed. A rename is therefore as expensive as a
+// download and upload of everything moved, and isn't atomic: if it fails
+// partway, newPath may be partially written while oldPath is left intact.
+func (cfs *FS) Rename(oldPath, newPath string) error {
+	oldEP, err := cfs.EncryptPath(oldPath)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: err}
+	}
+	newEP, err := cfs.EncryptPath(newPath)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newPath, Err: err}
+	}
+	// Copying onto itself and then removing would delete the file
+	if oldEP == newEP {
+		return nil
+	}
+
+	f, err := cfs.Open(oldPath)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: err}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: err}
+	}
+	if info.IsDir() {
+		_ = f.Close()
+		if err := cfs.copyDir(oldPath, newPath); err != nil {
+			return err
+		}
+	} else {
+		err := cfs.WriteFile(newPath, f)
+		_ = f.Close()
+		if err != nil {
+			return &fs.PathError{Op: "rename", Path: newPath, Err: err}
+		}
+	}
+	if err := cfs.Remove(oldPath); err != nil {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// ABOUTME: File info for Charm Cloud files without downloading them
+// ABOUTME: Reads the mode, size and modification time from a HEAD request
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Stat returns the FileInfo of the file at name without downloading it, so
+// modification times can be compared cheaply, such as when deciding which
+// files to sync. It implements fs.StatFS.
+//
+// The size of a file is that of the encrypted file stored on the server,
+// which is a little bigger than the file, like the size of entries listed by
+// ReadDir. Directories are listed to get their info, and so are the parents
+// of files whose size the server doesn't send.
+func (cfs *FS) Stat(name string) (fs.FileInfo, error) {
+	return cfs.StatContext(context.Background(), name)
+}
+
+// StatContext is like Stat but cancels the request when ctx is done.
+func (cfs *FS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return nil, statError(name, err)
+	}
+	resp, err := cfs.cc.AuthedRawRequestWithContext(ctx, "HEAD", fmt.Sprintf("/v1/fs/%s", ep))
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		return nil, statError(name, cfs.requestError(resp, err))
+	}
+	resp.Body.Close() // nolint:errcheck
+
+	switch resp.Header.Get("Content-Type") {
+	case "application/json":
+		f, err := cfs.OpenContext(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() // nolint:errcheck
+		return f.Stat()
+	case "application/octet-stream":
+	default:
+		return nil, statError(name, fmt.Errorf("invalid content-type returned from server"))
+	}
+	m, err := strconv.ParseUint(resp.Header.Get("X-File-Mode"), 10, 32)
+	if err != nil {
+		return nil, statError(name, err)
+	}
+	modTime, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return nil, statError(name, err)
+	}
+	if resp.ContentLength < 0 {
+		// Servers that compress files don't know their size until they've
+		// been sent, but still list it
+		return cfs.statFromParent(ctx, name)
+	}
+	fi := &FileInfo{}
+	fi.FileInfo.Name = path.Base(name)
+	fi.FileInfo.Mode = fs.FileMode(m)
+	fi.FileInfo.Size = resp.ContentLength
+	fi.FileInfo.ModTime = modTime
+	return fi, nil
+}
+
+// statFromParent returns the FileInfo of the file at name from the listing
+// of its parent directory.
+func (cfs *FS) statFromParent(ctx context.Context, name string) (fs.FileInfo, error) {
+	f, err := cfs.OpenContext(ctx, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	des, err := f.(*File).ReadDir(-1)
+	if err != nil {
+		return nil, statError(name, err)
+	}
+	for _, de := range des {
+		if de.Name() == path.Base(name) {
+			return de.Info()
+		}
+	}
+	return nil, statError(name, fs.ErrNotExist)
+}
+
+func statError(name string, err error) *fs.PathError {
+	return &fs.PathError{Op: "stat", Path: name, Err: err}
+}
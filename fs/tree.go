@@ -0,0 +1,110 @@
+// ABOUTME: Concurrent upload of a local directory tree to Charm Cloud storage
+// ABOUTME: Walks the directory and writes its files in parallel through WriteFile
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// WriteTree uploads every regular file under the local directory localDir
+// to remotePrefix, keeping its path relative to localDir and its mode. Up to
+// concurrency files are uploaded at once, so a tree of many small files
+// isn't held up by one round trip per file. A concurrency below 1 uploads
+// one file at a time. Symlinks and other non-regular files are skipped.
+//
+// A failed file doesn't stop the others. The returned error joins an
+// *fs.PathError for each local file or directory that failed, sorted by
+// path. If WithProgress is used, its callback is called for each file, from
+// several goroutines at once.
+func (cfs *FS) WriteTree(localDir, remotePrefix string, concurrency int) error {
+	return cfs.WriteTreeContext(context.Background(), localDir, remotePrefix, concurrency)
+}
+
+// WriteTreeContext is like WriteTree but stops when ctx is done. Uploads in
+// progress are cancelled, files not yet started are skipped, and the
+// returned error includes ctx.Err().
+func (cfs *FS) WriteTreeContext(ctx context.Context, localDir, remotePrefix string, concurrency int) error {
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "write", Path: localDir, Err: errors.New("not a directory")}
+	}
+	concurrency = max(concurrency, 1)
+
+	var mu sync.Mutex
+	var errs []*fs.PathError
+	fail := func(p string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, &fs.PathError{Op: "write", Path: p, Err: err})
+	}
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				rel, err := filepath.Rel(localDir, p)
+				if err != nil {
+					fail(p, err)
+					continue
+				}
+				if err := cfs.writeLocalFile(ctx, p, path.Join(remotePrefix, filepath.ToSlash(rel))); err != nil {
+					fail(p, err)
+				}
+			}
+		}()
+	}
+	walkErr := filepath.WalkDir(localDir, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			fail(p, err)
+			return nil
+		}
+		if !de.Type().IsRegular() {
+			return nil
+		}
+		select {
+		case paths <- p:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(paths)
+	wg.Wait()
+
+	// Files cancelled mid-upload fail with ctx.Err() too; report it once
+	all := make([]error, 0, len(errs)+1)
+	if walkErr != nil {
+		all = append(all, walkErr)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	for _, err := range errs {
+		if walkErr == nil || !errors.Is(err, walkErr) {
+			all = append(all, err)
+		}
+	}
+	return errors.Join(all...)
+}
+
+// writeLocalFile uploads the local file at p to name.
+func (cfs *FS) writeLocalFile(ctx context.Context, p string, name string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	return cfs.WriteFileContext(ctx, name, f)
+}
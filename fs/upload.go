@@ -0,0 +1,292 @@
+// ABOUTME: Resumable uploads of large files in parts
+// ABOUTME: Stages the encrypted file locally so an interrupted upload can carry on
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/google/uuid"
+)
+
+// DefaultChunkSize is the size of the parts WriteFileChunked uploads when
+// it's given a chunk size of 0 or less.
+const DefaultChunkSize int64 = 8 * 1024 * 1024
+
+// UploadError is returned by WriteFileChunked and ResumeUpload when an upload
+// is interrupted, such as by a dropped connection. Pass its ID to
+// ResumeUpload to carry on from the last part the server received.
+type UploadError struct {
+	ID  string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload %s interrupted: %s", e.ID, e.Err)
+}
+
+// Unwrap returns the error that interrupted the upload.
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// WriteFileChunked encrypts the data read from r and uploads it to name in
+// parts of chunkSize bytes, which the server puts together once all of them
+// have arrived. If r is an fs.File its mode is kept, otherwise the file gets
+// mode 0644.
+//
+// Unlike WriteFile, an interrupted upload doesn't have to start over: the
+// returned *UploadError holds an ID that ResumeUpload takes to send the
+// remaining parts. The encrypted file is staged in the client's data
+// directory until the upload completes or is aborted with AbortUpload, so it
+// needs as much free disk space as the file.
+func (cfs *FS) WriteFileChunked(name string, r io.Reader, chunkSize int64) error {
+	return cfs.WriteFileChunkedContext(context.Background(), name, r, chunkSize)
+}
+
+// WriteFileChunkedContext is like WriteFileChunked but stops uploading when
+// ctx is done. The upload can then be resumed like any interrupted one.
+func (cfs *FS) WriteFileChunkedContext(ctx context.Context, name string, r io.Reader, chunkSize int64) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	mode := fs.FileMode(0o644)
+	if f, ok := r.(fs.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		mode = info.Mode()
+	}
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return err
+	}
+	dir, err := cfs.uploadsDir()
+	if err != nil {
+		return err
+	}
+
+	// Encrypt the whole file first, so parts can be read again on resume
+	tmp, err := os.CreateTemp(dir, "staging-*")
+	if err != nil {
+		return err
+	}
+	staged := tmp.Name()
+	defer func() {
+		if staged != "" {
+			_ = os.Remove(staged)
+		}
+	}()
+	size, err := cfs.encryptTo(tmp, r)
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
+
+	u := &charm.Upload{Path: ep, Mode: mode, Size: size, ChunkSize: chunkSize}
+	body, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	if _, err := cfs.uploadRequest(ctx, "POST", "/v1/uploads", headers, bytes.NewReader(body), u); err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
+	p := filepath.Join(dir, u.ID)
+	if err := os.Rename(staged, p); err != nil {
+		return err
+	}
+	staged = ""
+
+	defer cfs.invalidate(ep)
+	return cfs.sendUpload(ctx, u, p)
+}
+
+// ResumeUpload sends the parts of an interrupted upload that the server
+// hasn't received yet, then completes it. uploadID is the ID of the
+// *UploadError returned by WriteFileChunked or a previous ResumeUpload, or
+// one listed by PendingUploads. Uploads not resumed within a day are
+// discarded by the server, and resuming one returns an error satisfying
+// errors.Is(err, fs.ErrNotExist).
+func (cfs *FS) ResumeUpload(uploadID string) error {
+	return cfs.ResumeUploadContext(context.Background(), uploadID)
+}
+
+// ResumeUploadContext is like ResumeUpload but stops uploading when ctx is
+// done.
+func (cfs *FS) ResumeUploadContext(ctx context.Context, uploadID string) error {
+	p, err := cfs.uploadPath(uploadID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err != nil {
+		return fmt.Errorf("no staged data for upload %s: %w", uploadID, err)
+	}
+	u := &charm.Upload{}
+	if _, err := cfs.uploadRequest(ctx, "GET", "/v1/uploads/"+uploadID, nil, nil, u); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			_ = os.Remove(p)
+			return fmt.Errorf("upload %s: %w", uploadID, err)
+		}
+		return &UploadError{ID: uploadID, Err: err}
+	}
+	if u.ChunkSize <= 0 {
+		u.ChunkSize = DefaultChunkSize
+	}
+	defer cfs.invalidate(u.Path)
+	return cfs.sendUpload(ctx, u, p)
+}
+
+// AbortUpload discards an interrupted upload, both on the server and its
+// data staged locally.
+func (cfs *FS) AbortUpload(uploadID string) error {
+	p, err := cfs.uploadPath(uploadID)
+	if err != nil {
+		return err
+	}
+	_, err = cfs.uploadRequest(context.Background(), "DELETE", "/v1/uploads/"+uploadID, nil, nil, nil)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PendingUploads returns the IDs of the uploads that were interrupted and
+// still have data staged locally, for passing to ResumeUpload or
+// AbortUpload.
+func (cfs *FS) PendingUploads() ([]string, error) {
+	dir, err := cfs.uploadsDir()
+	if err != nil {
+		return nil, err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(des))
+	for _, de := range des {
+		if !de.IsDir() && !strings.HasPrefix(de.Name(), "staging-") {
+			ids = append(ids, de.Name())
+		}
+	}
+	return ids, nil
+}
+
+// sendUpload sends the parts of u from the staged file at p, starting at the
+// data the server has received, and completes the upload. The staged file is
+// removed once the server has put the file in place.
+func (cfs *FS) sendUpload(ctx context.Context, u *charm.Upload, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	buf := make([]byte, min(u.ChunkSize, u.Size))
+	for u.Received < u.Size {
+		part := buf[:min(u.ChunkSize, u.Size-u.Received)]
+		if _, err := f.ReadAt(part, u.Received); err != nil {
+			return &UploadError{ID: u.ID, Err: err}
+		}
+		next := &charm.Upload{}
+		headers := http.Header{"Content-Type": []string{"application/octet-stream"}}
+		path := fmt.Sprintf("/v1/uploads/%s?offset=%d", u.ID, u.Received)
+		status, err := cfs.uploadRequest(ctx, "PUT", path, headers, bytes.NewReader(part), next)
+		if status == http.StatusConflict {
+			// The server has a different amount than expected, such as when
+			// a part arrived but the response didn't: carry on from there
+			_, err = cfs.uploadRequest(ctx, "GET", "/v1/uploads/"+u.ID, nil, nil, next)
+		}
+		if err != nil {
+			return &UploadError{ID: u.ID, Err: err}
+		}
+		u.Received = next.Received
+	}
+	_, err = cfs.uploadRequest(ctx, "POST", "/v1/uploads/"+u.ID+"/complete", nil, nil, nil)
+	if err != nil {
+		return &UploadError{ID: u.ID, Err: err}
+	}
+	_ = f.Close()
+	return os.Remove(p)
+}
+
+// encryptTo encrypts the data read from r into f, closing it, and returns
+// the size of the encrypted data.
+func (cfs *FS) encryptTo(f *os.File, r io.Reader) (int64, error) {
+	defer f.Close() // nolint:errcheck
+	ew, err := cfs.crypt.NewEncryptedWriter(f)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(ew, r); err != nil {
+		return 0, err
+	}
+	if err := ew.Close(); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), f.Close()
+}
+
+// uploadRequest sends a request about an upload, decoding the response into
+// u if it's not nil. It returns the response's status code, or 0 if there
+// was no response, along with the error classified by requestError.
+func (cfs *FS) uploadRequest(ctx context.Context, method string, path string, headers http.Header, body io.Reader, u *charm.Upload) (int, error) {
+	resp, err := cfs.cc.AuthedRequestWithContext(ctx, method, path, headers, body)
+	if err != nil {
+		if resp == nil {
+			return 0, err
+		}
+		resp.Body.Close() // nolint:errcheck
+		return resp.StatusCode, cfs.requestError(resp, err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if u == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(u)
+}
+
+// uploadsDir returns the directory uploads are staged in, creating it if
+// needed.
+func (cfs *FS) uploadsDir() (string, error) {
+	dp, err := cfs.cc.DataPath()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(dp, "uploads")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// uploadPath returns the path of the staged data for the upload with the
+// given ID.
+func (cfs *FS) uploadPath(id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", fmt.Errorf("invalid upload ID %q: %w", id, err)
+	}
+	dir, err := cfs.uploadsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id), nil
+}
@@ -0,0 +1,22 @@
+// ABOUTME: Storage usage queries for the user's Charm Cloud files
+// ABOUTME: Reports the bytes the server stores on the user's behalf
+
+package fs
+
+import (
+	"context"
+)
+
+// Usage returns the total size in bytes of the user's files stored on the
+// server. Files are stored encrypted, so this is slightly more than the size
+// of their contents. The client's StorageUsage also reports the server's
+// storage limit.
+func (cfs *FS) Usage() (int64, error) {
+	return cfs.UsageContext(context.Background())
+}
+
+// UsageContext is like Usage but cancels the request when ctx is done.
+func (cfs *FS) UsageContext(ctx context.Context) (int64, error) {
+	used, _, err := cfs.cc.StorageUsageWithContext(ctx)
+	return used, err
+}
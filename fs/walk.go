@@ -0,0 +1,15 @@
+// ABOUTME: Recursive traversal of a Charm Cloud directory tree
+// ABOUTME: Thin wrapper over fs.WalkDir using the FS's ReadDir listings
+
+package fs
+
+import "io/fs"
+
+// Walk walks the file tree rooted at root, calling fn for each file and
+// directory in lexical order, including root. Names passed to fn are
+// plaintext paths. Returning fs.SkipDir from fn skips the directory's
+// contents, and fs.SkipAll stops the walk. It behaves like fs.WalkDir(cfs,
+// root, fn).
+func (cfs *FS) Walk(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(cfs, root, fn)
+}
@@ -4,18 +4,30 @@ package integration
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/charmbracelet/charm/client"
 	charmfs "github.com/charmbracelet/charm/fs"
 	"github.com/charmbracelet/charm/kv"
+	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/testserver"
 )
 
@@ -242,6 +254,88 @@ func TestE2E_Auth_CharmID(t *testing.T) {
 	}
 }
 
+func TestE2E_Auth_IdentityKeys(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	keyPath := cl.AuthKeyPaths()[0]
+	if got := cl.IdentityKeyUsed(); got != keyPath {
+		t.Errorf("IdentityKeyUsed() = %q, want %q", got, keyPath)
+	}
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("ID() failed: %v", err)
+	}
+
+	// A client listing an unusable key first falls back to the linked one
+	cfg := *cl.Config
+	cfg.IdentityKeys = []string{filepath.Join(t.TempDir(), "missing"), keyPath}
+	other, err := client.NewClient(&cfg)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	otherID, err := other.ID()
+	if err != nil {
+		t.Fatalf("ID() failed: %v", err)
+	}
+	if otherID != id {
+		t.Errorf("expected the same account %q, got %q", id, otherID)
+	}
+	if got := other.IdentityKeyUsed(); got != keyPath {
+		t.Errorf("IdentityKeyUsed() = %q, want %q", got, keyPath)
+	}
+}
+
+func TestE2E_Auth_KeyLabels(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	keys, err := cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata() failed: %v", err)
+	}
+	if len(keys.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys.Keys))
+	}
+	k := keys.Keys[0]
+	if k.CreatedAt == nil || k.CreatedAt.IsZero() {
+		t.Error("expected the key to have a creation date")
+	}
+	if k.Label != "" {
+		t.Errorf("expected no label on a new key, got %q", k.Label)
+	}
+
+	if err := cl.SetKeyLabel(k.ID, "work laptop"); err != nil {
+		t.Fatalf("SetKeyLabel failed: %v", err)
+	}
+	keys, err = cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata() failed: %v", err)
+	}
+	if got := keys.Keys[0].Label; got != "work laptop" {
+		t.Errorf("expected label %q, got %q", "work laptop", got)
+	}
+
+	// An empty label removes it
+	if err := cl.SetKeyLabel(k.ID, ""); err != nil {
+		t.Fatalf("SetKeyLabel with an empty label failed: %v", err)
+	}
+	keys, err = cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata() failed: %v", err)
+	}
+	if got := keys.Keys[0].Label; got != "" {
+		t.Errorf("expected the label to be removed, got %q", got)
+	}
+
+	if err := cl.SetKeyLabel(k.ID+1000, "nope"); !errors.Is(err, charm.ErrMissingKey) {
+		t.Errorf("expected ErrMissingKey for a key that isn't linked, got %v", err)
+	}
+	if err := cl.SetKeyLabel(k.ID, strings.Repeat("x", charm.MaxKeyLabelLength+1)); !errors.Is(err, charm.ErrKeyLabelInvalid) {
+		t.Errorf("expected ErrKeyLabelInvalid for a long label, got %v", err)
+	}
+}
+
 func TestE2E_Auth_AuthorizedKeys(t *testing.T) {
 	cl := setupClient(t)
 	mustAuth(t, cl)
@@ -316,145 +410,1206 @@ func TestE2E_FS_Overwrite(t *testing.T) {
 func TestE2E_FS_ReadNonexistent(t *testing.T) {
 	_, cfs := setupFS(t)
 
-	_, err := cfs.ReadFile("does-not-exist.txt")
-	if err == nil {
-		t.Error("ReadFile on nonexistent file should return error")
+	_, err := cfs.ReadFile("does-not-exist.txt")
+	if err == nil {
+		t.Error("ReadFile on nonexistent file should return error")
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "does-not-exist.txt" {
+		t.Errorf("expected a PathError naming the file, got %v", err)
+	}
+	if errors.Is(err, charmfs.ErrUnauthorized) || errors.Is(err, charmfs.ErrServer) {
+		t.Errorf("a missing file isn't an auth or server error: %v", err)
+	}
+}
+
+func TestE2E_FS_ReadDir(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	// Create some files in a directory
+	writeTestFile(t, cfs, "mydir/file1.txt", []byte("content1"))
+	writeTestFile(t, cfs, "mydir/file2.txt", []byte("content2"))
+	writeTestFile(t, cfs, "mydir/file3.txt", []byte("content3"))
+
+	// List directory
+	entries, err := cfs.ReadDir("mydir")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("ReadDir returned %d entries, expected 3", len(entries))
+	}
+
+	// Check that all files are present
+	names := make(map[string]bool)
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+
+	for _, expected := range []string{"file1.txt", "file2.txt", "file3.txt"} {
+		if !names[expected] {
+			t.Errorf("ReadDir missing file: %s", expected)
+		}
+	}
+}
+
+func TestE2E_FS_ReadDirEmpty(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	// Reading a nonexistent directory should return empty slice, not error
+	entries, err := cfs.ReadDir("nonexistent-dir")
+	if err != nil {
+		t.Logf("ReadDir on nonexistent returned error (may be expected): %v", err)
+	}
+
+	// Per fs.go behavior, should return empty slice
+	if entries == nil {
+		t.Log("ReadDir returned nil slice for nonexistent directory")
+	}
+}
+
+func TestE2E_FS_Remove(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	path := "to-delete.txt"
+
+	// Create file
+	writeTestFile(t, cfs, path, []byte("delete me"))
+
+	// Verify it exists
+	_, err := cfs.ReadFile(path)
+	if err != nil {
+		t.Fatalf("File should exist before delete: %v", err)
+	}
+
+	// Delete it
+	err = cfs.Remove(path)
+	if err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	// Verify it's gone
+	_, err = cfs.ReadFile(path)
+	if err == nil {
+		t.Error("File should not exist after delete")
+	}
+}
+
+func TestE2E_FS_RemoveAll(t *testing.T) {
+	_, cfs := setupFS(t)
+	writeTestFile(t, cfs, "/deploy/a.txt", []byte("a"))
+	writeTestFile(t, cfs, "/deploy/sub/b.txt", []byte("b"))
+	writeTestFile(t, cfs, "/keep.txt", []byte("keep"))
+
+	if err := cfs.RemoveAll("/deploy"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	for _, p := range []string{"/deploy", "/deploy/a.txt", "/deploy/sub/b.txt"} {
+		if _, err := cfs.Stat(p); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s to be removed, got %v", p, err)
+		}
+	}
+	assertFileContent(t, cfs, "/keep.txt", []byte("keep"))
+
+	// Like os.RemoveAll, a missing path isn't an error
+	if err := cfs.RemoveAll("/deploy"); err != nil {
+		t.Errorf("RemoveAll of a missing path failed: %v", err)
+	}
+	if err := cfs.RemoveAll("/"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid removing the root, got %v", err)
+	}
+}
+
+func TestE2E_FS_Open(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	content := []byte("file content for Open test")
+	writeTestFile(t, cfs, "open-test.txt", content)
+
+	// Open the file
+	f, err := cfs.Open("open-test.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	// Read via the file handle
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll from opened file failed: %v", err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Errorf("Open content mismatch\ngot:  %q\nwant: %q", data, content)
+	}
+}
+
+func TestE2E_FS_CharmPrefix(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	content := []byte("charm prefix test")
+
+	// Write with charm: prefix
+	writeTestFile(t, cfs, "charm:prefixed.txt", content)
+
+	// Read without prefix - should find the same file
+	assertFileContent(t, cfs, "prefixed.txt", content)
+
+	// Read with prefix - should also work
+	assertFileContent(t, cfs, "charm:prefixed.txt", content)
+}
+
+func TestE2E_FS_SpecialCharactersInPath(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	// Test various special characters in filenames
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"spaces", "file with spaces.txt"},
+		{"dots", "file.multiple.dots.txt"},
+		{"underscores", "file_with_underscores.txt"},
+		{"dashes", "file-with-dashes.txt"},
+		{"mixed", "file with spaces-and_mixed.chars.txt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := []byte("content for " + tc.name)
+			writeTestFile(t, cfs, tc.path, content)
+			assertFileContent(t, cfs, tc.path, content)
+		})
+	}
+}
+
+func TestE2E_FS_Cache(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+	cfs, err := charmfs.NewFSWithClient(cl, charmfs.WithCache(1<<20))
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+
+	// A second FS without a cache sees what's really on the server
+	other, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+
+	writeTestFile(t, cfs, "cached/config.toml", []byte("v1"))
+	assertFileContent(t, cfs, "cached/config.toml", []byte("v1"))
+
+	// Writes made elsewhere aren't seen while the file is cached
+	writeTestFile(t, other, "cached/config.toml", []byte("v2"))
+	assertFileContent(t, cfs, "cached/config.toml", []byte("v1"))
+
+	// Writing through the cached FS invalidates its copy
+	writeTestFile(t, cfs, "cached/config.toml", []byte("v3"))
+	assertFileContent(t, cfs, "cached/config.toml", []byte("v3"))
+
+	// Removing the directory invalidates the files under it
+	if err := cfs.Remove("cached"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := cfs.ReadFile("cached/config.toml"); err == nil {
+		t.Error("expected removed file not to be served from the cache")
+	}
+
+	// Directory listings always come from the server
+	writeTestFile(t, cfs, "listed/a.txt", []byte("a"))
+	writeTestFile(t, other, "listed/b.txt", []byte("b"))
+	des, err := cfs.ReadDir("listed")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(des) != 2 {
+		t.Errorf("ReadDir returned %d entries, want 2", len(des))
+	}
+}
+
+func TestE2E_FS_Progress(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+	type report struct{ done, total int64 }
+	var reports []report
+	cfs, err := charmfs.NewFSWithClient(cl, charmfs.WithProgress(func(done, total int64) {
+		reports = append(reports, report{done, total})
+	}))
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	checkReports := func(op string, total int64) {
+		t.Helper()
+		if len(reports) < 2 {
+			t.Fatalf("%s reported progress %d times, want several", op, len(reports))
+		}
+		for i, r := range reports {
+			if r.total != total {
+				t.Fatalf("%s reported a total of %d, want %d", op, r.total, total)
+			}
+			if i > 0 && r.done < reports[i-1].done {
+				t.Fatalf("%s progress went backwards: %v", op, reports)
+			}
+		}
+		if last := reports[len(reports)-1]; last.done != total {
+			t.Errorf("%s finished at %d of %d bytes", op, last.done, total)
+		}
+	}
+
+	content := bytes.Repeat([]byte("progress"), 256*1024)
+	writeTestFile(t, cfs, "progress.bin", content)
+	checkReports("WriteFile", int64(len(content)))
+
+	// Downloads are reported in terms of the encrypted file
+	reports = nil
+	assertFileContent(t, cfs, "progress.bin", content)
+	if len(reports) == 0 || reports[0].total <= int64(len(content)) {
+		t.Fatalf("expected a download total bigger than the file, got %v", reports)
+	}
+	checkReports("ReadFile", reports[0].total)
+}
+
+func TestE2E_FS_CopyDir(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	writeTestFile(t, cfs, "src/top.txt", []byte("top"))
+	writeTestFile(t, cfs, "src/nested/deep/leaf.txt", []byte("leaf"))
+	err := cfs.WriteFile("src/nested/secret.txt", &memFile{
+		name:    "secret.txt",
+		content: bytes.NewReader([]byte("secret")),
+		size:    6,
+		mode:    0600,
+	})
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := cfs.CopyDir("src", "backup/src"); err != nil {
+		t.Fatalf("CopyDir failed: %v", err)
+	}
+
+	assertFileContent(t, cfs, "backup/src/top.txt", []byte("top"))
+	assertFileContent(t, cfs, "backup/src/nested/deep/leaf.txt", []byte("leaf"))
+	assertFileContent(t, cfs, "backup/src/nested/secret.txt", []byte("secret"))
+
+	f, err := cfs.Open("backup/src/nested/secret.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("copied mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+
+	// The source is untouched
+	assertFileContent(t, cfs, "src/top.txt", []byte("top"))
+}
+
+func TestE2E_FS_Checksum(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	content := []byte("checksum me")
+	writeTestFile(t, cfs, "sums/a.txt", content)
+	sum, err := cfs.Checksum("sums/a.txt")
+	if err != nil {
+		t.Fatalf("Checksum failed: %v", err)
+	}
+	want := sha256.Sum256(content)
+	if sum != hex.EncodeToString(want[:]) {
+		t.Errorf("Checksum = %s, want the SHA-256 of the content", sum)
+	}
+
+	// Same content uploaded again hashes the same, despite new encryption
+	writeTestFile(t, cfs, "sums/b.txt", content)
+	if other, err := cfs.Checksum("sums/b.txt"); err != nil || other != sum {
+		t.Errorf("Checksum of identical file = (%s, %v), want %s", other, err, sum)
+	}
+
+	writeTestFile(t, cfs, "sums/a.txt", []byte("changed"))
+	if changed, err := cfs.Checksum("sums/a.txt"); err != nil || changed == sum {
+		t.Errorf("expected a new checksum after a change, got (%s, %v)", changed, err)
+	}
+
+	if _, err := cfs.Checksum("sums"); err == nil {
+		t.Error("expected an error for a directory")
+	}
+	if _, err := cfs.Checksum("sums/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestE2E_FS_Copy(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	err := cfs.WriteFile("original.txt", &memFile{
+		name:    "original.txt",
+		content: bytes.NewReader([]byte("duplicate me")),
+		size:    12,
+		mode:    0600,
+	})
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := cfs.Copy("original.txt", "copies/copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	assertFileContent(t, cfs, "original.txt", []byte("duplicate me"))
+	assertFileContent(t, cfs, "copies/copy.txt", []byte("duplicate me"))
+
+	f, err := cfs.Open("copies/copy.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("copied mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+
+	if err := cfs.Copy("missing.txt", "dst.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing source, got %v", err)
+	}
+	if err := cfs.Copy("copies", "dst"); err == nil {
+		t.Error("expected an error copying a directory")
+	}
+
+	// The destination's parent is a file, so it can't be created
+	err = cfs.Copy("original.txt", "original.txt/nested.txt")
+	if err == nil {
+		t.Fatal("expected an error when the destination parent is a file")
+	}
+	assertFileContent(t, cfs, "original.txt", []byte("duplicate me"))
+}
+
+func TestE2E_FS_WriteTree(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	dir := t.TempDir()
+	files := map[string][]byte{}
+	for i := range 20 {
+		name := fmt.Sprintf("page%02d.html", i)
+		if i%4 == 0 {
+			name = path.Join("assets", fmt.Sprintf("sub%d", i%3), name)
+		}
+		files[name] = []byte(fmt.Sprintf("<html>%d</html>", i))
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, content, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cfs.WriteTree(dir, "site", 4); err != nil {
+		t.Fatalf("WriteTree failed: %v", err)
+	}
+	for name, content := range files {
+		assertFileContent(t, cfs, path.Join("site", name), content)
+	}
+	f, err := cfs.Open("site/page01.html")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode() != 0o640 {
+		t.Errorf("expected mode 0640, got %o", info.Mode())
+	}
+
+	// A cancelled context uploads nothing more and says why
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cfs.WriteTreeContext(ctx, dir, "cancelled", 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	var pathErr *fs.PathError
+	if err := cfs.WriteTree(filepath.Join(dir, "page01.html"), "site", 4); !errors.As(err, &pathErr) {
+		t.Errorf("expected an *fs.PathError for a file, got %v", err)
+	}
+}
+
+func TestE2E_FS_CopyDirErrors(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	var pathErr *fs.PathError
+	err := cfs.CopyDir("missing", "dst")
+	if !errors.As(err, &pathErr) || pathErr.Path != "missing" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not-exist PathError for missing, got %v", err)
+	}
+
+	writeTestFile(t, cfs, "plain.txt", []byte("x"))
+	if err := cfs.CopyDir("plain.txt", "dst"); !errors.As(err, &pathErr) || pathErr.Path != "plain.txt" {
+		t.Errorf("expected PathError for a file source, got %v", err)
+	}
+}
+
+func TestE2E_FS_WalkDir(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	writeTestFile(t, cfs, "site/index.html", []byte("<html>"))
+	writeTestFile(t, cfs, "site/css/main.css", []byte("body{}"))
+	writeTestFile(t, cfs, "site/css/about.css", []byte("p{}"))
+	writeTestFile(t, cfs, "site/js/app.js", []byte("go()"))
+
+	var walked []string
+	err := fs.WalkDir(cfs, "site", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path.Base(p) != d.Name() {
+			t.Errorf("entry %q has name %q", p, d.Name())
+		}
+		if d.IsDir() {
+			p += "/"
+		}
+		walked = append(walked, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+
+	want := []string{
+		"site/",
+		"site/css/",
+		"site/css/about.css",
+		"site/css/main.css",
+		"site/index.html",
+		"site/js/",
+		"site/js/app.js",
+	}
+	if strings.Join(walked, "\n") != strings.Join(want, "\n") {
+		t.Errorf("WalkDir visited:\n%s\nwant:\n%s", strings.Join(walked, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The standard library helpers work too
+	data, err := fs.ReadFile(cfs, "site/css/main.css")
+	if err != nil || string(data) != "body{}" {
+		t.Errorf("fs.ReadFile = (%q, %v)", data, err)
+	}
+	matches, err := fs.Glob(cfs, "site/css/*.css")
+	if err != nil || len(matches) != 2 {
+		t.Errorf("fs.Glob = (%v, %v), want 2 matches", matches, err)
+	}
+}
+
+func TestE2E_FS_Walk_SkipDir(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	writeTestFile(t, cfs, "tree/a.txt", []byte("a"))
+	writeTestFile(t, cfs, "tree/skip/b.txt", []byte("b"))
+	writeTestFile(t, cfs, "tree/skip/deep/c.txt", []byte("c"))
+	writeTestFile(t, cfs, "tree/keep/d.txt", []byte("d"))
+
+	var walked []string
+	err := cfs.Walk("tree", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "skip" {
+			return fs.SkipDir
+		}
+		walked = append(walked, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	want := []string{"tree", "tree/a.txt", "tree/keep", "tree/keep/d.txt"}
+	if strings.Join(walked, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Walk visited:\n%s\nwant:\n%s", strings.Join(walked, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A missing root is reported to fn
+	err = cfs.Walk("missing", func(p string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing root, got %v", err)
+	}
+}
+
+func TestE2E_FS_Append(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	// Appending to a missing file creates it
+	if err := cfs.Append("logs/app.log", strings.NewReader("one\n")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := cfs.Append("logs/app.log", strings.NewReader("two\n")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	assertFileContent(t, cfs, "logs/app.log", []byte("one\ntwo\n"))
+
+	// Concurrent appends through one FS don't lose data
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cfs.Append("logs/app.log", strings.NewReader(fmt.Sprintf("line %d\n", i))); err != nil {
+				t.Errorf("Append %d failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := cfs.ReadFile("logs/app.log")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "one\ntwo\n") {
+		t.Errorf("existing content was lost: %q", data)
+	}
+	for i := 0; i < 5; i++ {
+		if !strings.Contains(string(data), fmt.Sprintf("line %d\n", i)) {
+			t.Errorf("missing line %d in %q", i, data)
+		}
+	}
+
+	// Readers racing appends see whole appends only
+	writeTestFile(t, cfs, "logs/race.log", []byte("0\n"))
+	want := "0\n"
+	states := map[string]bool{want: true}
+	for i := 1; i <= 5; i++ {
+		want += fmt.Sprintf("%d\n", i)
+		states[want] = true
+	}
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := cfs.ReadFile("logs/race.log")
+			if err != nil {
+				t.Errorf("ReadFile during append failed: %v", err)
+				return
+			}
+			if !states[string(data)] {
+				t.Errorf("read a partial append: %q", data)
+				return
+			}
+		}
+	}()
+	for i := 1; i <= 5; i++ {
+		if err := cfs.Append("logs/race.log", strings.NewReader(fmt.Sprintf("%d\n", i))); err != nil {
+			t.Errorf("Append %d failed: %v", i, err)
+			break
+		}
+	}
+	close(done)
+	readers.Wait()
+	assertFileContent(t, cfs, "logs/race.log", []byte(want))
+
+	// An existing file keeps its mode
+	err = cfs.WriteFile("logs/private.log", &memFile{
+		name:    "private.log",
+		content: bytes.NewReader([]byte("a")),
+		size:    1,
+		mode:    0600,
+	})
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := cfs.Append("logs/private.log", strings.NewReader("b")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	f, err := cfs.Open("logs/private.log")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode after append = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
+	}
+	assertFileContent(t, cfs, "logs/private.log", []byte("ab"))
+
+	// Directories can't be appended to
+	if err := cfs.Append("logs", strings.NewReader("x")); err == nil {
+		t.Error("expected an error appending to a directory")
+	}
+}
+
+func TestE2E_FS_Context(t *testing.T) {
+	_, cfs := setupFS(t)
+	ctx := context.Background()
+
+	content := []byte("context-aware content")
+	src := &memFile{name: "a.txt", content: bytes.NewReader(content), size: int64(len(content)), mode: 0o644}
+	if err := cfs.WriteFileContext(ctx, "/ctx/a.txt", src); err != nil {
+		t.Fatalf("WriteFileContext failed: %v", err)
+	}
+	got, err := cfs.ReadFileContext(ctx, "/ctx/a.txt")
+	if err != nil {
+		t.Fatalf("ReadFileContext failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("ReadFileContext = %q, want %q", got, content)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := cfs.OpenContext(cancelled, "/ctx/a.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected OpenContext to fail with context.Canceled, got %v", err)
+	}
+	if _, err := cfs.ReadFileContext(cancelled, "/ctx/a.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ReadFileContext to fail with context.Canceled, got %v", err)
+	}
+	src = &memFile{name: "b.txt", content: strings.NewReader("never uploaded"), size: 14, mode: 0o644}
+	if err := cfs.WriteFileContext(cancelled, "/ctx/b.txt", src); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected WriteFileContext to fail with context.Canceled, got %v", err)
+	}
+	if _, err := cfs.ReadFile("/ctx/b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a cancelled upload not to create the file, got %v", err)
+	}
+}
+
+func TestE2E_FS_OpenRange(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	// Larger than a single encryption chunk so the offset spans several
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	writeTestFile(t, cfs, "/range/big.txt", content)
+
+	readRange := func(offset, length int64) []byte {
+		t.Helper()
+		r, err := cfs.OpenRange("/range/big.txt", offset, length)
+		if err != nil {
+			t.Fatalf("OpenRange(%d, %d) failed: %v", offset, length, err)
+		}
+		defer r.Close() // nolint:errcheck
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reading range (%d, %d) failed: %v", offset, length, err)
+		}
+		return got
+	}
+
+	if got := readRange(0, 1024); !bytes.Equal(got, content[:1024]) {
+		t.Error("range at the start doesn't match")
+	}
+	if got := readRange(150001, 20); !bytes.Equal(got, content[150001:150021]) {
+		t.Errorf("range in the middle = %q, want %q", got, content[150001:150021])
+	}
+	if got := readRange(199990, -1); !bytes.Equal(got, content[199990:]) {
+		t.Errorf("range to the end = %q, want %q", got, content[199990:])
+	}
+	if got := readRange(300000, 10); len(got) != 0 {
+		t.Errorf("expected nothing past the end, got %d bytes", len(got))
+	}
+
+	// Closing early stops the download without error
+	r, err := cfs.OpenRange("/range/big.txt", 0, -1)
+	if err != nil {
+		t.Fatalf("OpenRange failed: %v", err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 10)); err != nil {
+		t.Fatalf("reading the start failed: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	if _, err := cfs.OpenRange("/range", 0, 1); err == nil {
+		t.Error("expected an error opening a range of a directory")
+	}
+	if _, err := cfs.OpenRange("/range/missing.txt", 0, 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestE2E_FS_Stat(t *testing.T) {
+	_, cfs := setupFS(t)
+	content := bytes.Repeat([]byte("stat me "), 10000)
+	writeTestFile(t, cfs, "/stat/a.txt", content)
+
+	fi, err := cfs.Stat("/stat/a.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if fi.Name() != "a.txt" || fi.IsDir() {
+		t.Errorf("expected the file a.txt, got %q (dir: %v)", fi.Name(), fi.IsDir())
+	}
+	if fi.Size() < int64(len(content)) {
+		t.Errorf("expected the encrypted size to be at least %d, got %d", len(content), fi.Size())
+	}
+
+	// It matches the info of the opened file and of the directory entry
+	f, err := cfs.Open("/stat/a.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	ofi, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat on the file failed: %v", err)
+	}
+	if !fi.ModTime().Equal(ofi.ModTime()) || fi.Mode() != ofi.Mode() {
+		t.Errorf("expected mod time %v and mode %v, got %v and %v", ofi.ModTime(), ofi.Mode(), fi.ModTime(), fi.Mode())
+	}
+	des, err := cfs.ReadDir("/stat")
+	if err != nil || len(des) != 1 {
+		t.Fatalf("ReadDir returned %d entries, %v", len(des), err)
+	}
+	dfi, err := des[0].Info()
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if dfi.Size() != fi.Size() {
+		t.Errorf("expected the size of the directory entry, %d, got %d", dfi.Size(), fi.Size())
+	}
+
+	// FS implements fs.StatFS
+	if _, err := fs.Stat(cfs, "/stat/a.txt"); err != nil {
+		t.Errorf("fs.Stat failed: %v", err)
+	}
+	if dir, err := cfs.Stat("/stat"); err != nil || !dir.IsDir() {
+		t.Errorf("expected /stat to be a directory, got %v, %v", dir, err)
+	}
+	if _, err := cfs.Stat("/stat/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestE2E_FS_DirSize(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	writeTestFile(t, cfs, "/sized/a.txt", []byte("hello"))
+	writeTestFile(t, cfs, "/sized/sub/b.txt", []byte("hello world"))
+
+	f, err := cfs.Open("/sized")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	des, err := cfs.ReadDir("/sized")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	// Sizes are of the stored, encrypted files
+	var total int64
+	for _, de := range des {
+		di, err := de.Info()
+		if err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+		if di.Size() <= 0 {
+			t.Errorf("expected %s to have a size, got %d", de.Name(), di.Size())
+		}
+		total += di.Size()
+	}
+	if info.Size() == 0 || info.Size() != total {
+		t.Errorf("expected directory size %d, got %d", total, info.Size())
+	}
+}
+
+func TestE2E_FS_Usage(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	used, err := cfs.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if used != 0 {
+		t.Errorf("expected no usage before writing, got %d", used)
+	}
+
+	writeTestFile(t, cfs, "/usage/a.txt", []byte("hello"))
+	writeTestFile(t, cfs, "/usage/sub/b.txt", []byte("hello world"))
+	used, err = cfs.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	// Files are stored encrypted, so they take up more than their contents
+	if used < 16 {
+		t.Errorf("expected at least 16 bytes used, got %d", used)
+	}
+
+	f, err := cfs.Open("/usage")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != used {
+		t.Errorf("expected usage %d to match the directory size %d", used, info.Size())
+	}
+}
+
+func TestE2E_FS_StorageStats(t *testing.T) {
+	cl, cfs := setupFS(t)
+
+	files, size, err := cl.FSStats()
+	if err != nil {
+		t.Fatalf("FSStats failed: %v", err)
+	}
+	if files != 0 || size != 0 {
+		t.Errorf("expected no files before writing, got %d files of %d bytes", files, size)
+	}
+
+	writeTestFile(t, cfs, "/stats/a.txt", []byte("hello"))
+	writeTestFile(t, cfs, "/stats/sub/b.txt", []byte("hello world"))
+	files, size, err = cl.FSStats()
+	if err != nil {
+		t.Fatalf("FSStats failed: %v", err)
+	}
+	if files != 2 {
+		t.Errorf("expected 2 files, got %d", files)
+	}
+	used, err := cfs.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if size != used {
+		t.Errorf("expected the size %d to match the usage %d", size, used)
+	}
+}
+
+func TestE2E_FS_ReadDirPage(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("file%d.txt", i)
+		want[name] = true
+		writeTestFile(t, cfs, "/paged/"+name, []byte("x"))
+	}
+
+	got := map[string]bool{}
+	for offset := 0; ; offset += 2 {
+		des, err := cfs.ReadDirPage("/paged", offset, 2)
+		if err != nil {
+			t.Fatalf("ReadDirPage at offset %d failed: %v", offset, err)
+		}
+		for _, de := range des {
+			if got[de.Name()] {
+				t.Errorf("entry %s listed twice", de.Name())
+			}
+			got[de.Name()] = true
+		}
+		if len(des) < 2 {
+			break
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d entries across pages, got %v", len(want), got)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("expected %s to be listed", name)
+		}
+	}
+
+	des, err := cfs.ReadDirPage("/paged", 10, 2)
+	if err != nil || len(des) != 0 {
+		t.Errorf("expected no entries past the end, got %v, %v", des, err)
+	}
+	if _, err := cfs.ReadDirPage("/paged", 0, 0); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid for a zero limit, got %v", err)
+	}
+	if _, err := cfs.ReadDirPage("/paged/file0.txt", 0, 2); err == nil {
+		t.Error("expected an error paging a file")
+	}
+	if _, err := cfs.ReadDirPage("/missing", 0, 2); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestE2E_FS_StorageLimit(t *testing.T) {
+	t.Setenv("CHARM_SERVER_USER_MAX_STORAGE", "1024")
+	cl, cfs := setupFS(t)
+
+	used, limit, err := cl.StorageUsage()
+	if err != nil {
+		t.Fatalf("StorageUsage failed: %v", err)
+	}
+	if used != 0 || limit != 1024 {
+		t.Errorf("expected 0 of 1024 bytes used, got %d of %d", used, limit)
+	}
+
+	content := bytes.Repeat([]byte("a"), 600)
+	writeTestFile(t, cfs, "/limit/a.txt", content)
+	// Replacing a file only counts its new size
+	writeTestFile(t, cfs, "/limit/a.txt", content)
+
+	err = cfs.WriteFile("/limit/b.txt", &memFile{name: "b.txt", content: bytes.NewReader(content), size: int64(len(content)), mode: 0o644})
+	if !errors.Is(err, charmfs.ErrStorageLimit) {
+		t.Fatalf("expected ErrStorageLimit, got %v", err)
+	}
+	if errors.Is(err, charmfs.ErrServer) || !strings.Contains(err.Error(), "507") {
+		t.Errorf("expected a 507 storage limit error, got %v", err)
+	}
+	if _, err := cfs.Open("/limit/b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the rejected file not to exist, got %v", err)
+	}
+}
+
+func TestE2E_FS_WriteFileChunked(t *testing.T) {
+	_, cfs := setupFS(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := cfs.WriteFileChunked("/chunked/big.bin", bytes.NewReader(content), 1024); err != nil {
+		t.Fatalf("WriteFileChunked failed: %v", err)
+	}
+	assertFileContent(t, cfs, "/chunked/big.bin", content)
+
+	// An fs.File keeps its mode
+	err := cfs.WriteFileChunked("/chunked/empty.txt", &memFile{name: "empty.txt", content: bytes.NewReader(nil), mode: 0o600}, 0)
+	if err != nil {
+		t.Fatalf("WriteFileChunked of an empty file failed: %v", err)
+	}
+	assertFileContent(t, cfs, "/chunked/empty.txt", []byte{})
+	f, err := cfs.Open("/chunked/empty.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	if fi, _ := f.Stat(); fi.Mode() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", fi.Mode())
+	}
+
+	if ids, err := cfs.PendingUploads(); err != nil || len(ids) != 0 {
+		t.Errorf("expected no pending uploads, got %v, %v", ids, err)
 	}
 }
 
-func TestE2E_FS_ReadDir(t *testing.T) {
-	_, cfs := setupFS(t)
-
-	// Create some files in a directory
-	writeTestFile(t, cfs, "mydir/file1.txt", []byte("content1"))
-	writeTestFile(t, cfs, "mydir/file2.txt", []byte("content2"))
-	writeTestFile(t, cfs, "mydir/file3.txt", []byte("content3"))
-
-	// List directory
-	entries, err := cfs.ReadDir("mydir")
+func TestE2E_FS_ResumeUpload(t *testing.T) {
+	cl, cfs := setupFS(t)
+
+	// Drop parts after the first two until the link comes back
+	var puts atomic.Int32
+	var down atomic.Bool
+	down.Store(true)
+	rp := &httputil.ReverseProxy{Director: func(*http.Request) {}}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && down.Load() && puts.Add(1) > 2 {
+			http.Error(w, "connection dropped", http.StatusBadGateway)
+			return
+		}
+		rp.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	cfg := *cl.Config
+	cfg.HTTPProxy = proxy.URL
+	pc, err := client.NewClient(&cfg)
 	if err != nil {
-		t.Fatalf("ReadDir failed: %v", err)
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	pfs, err := charmfs.NewFSWithClient(pc)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
 	}
 
-	if len(entries) != 3 {
-		t.Errorf("ReadDir returned %d entries, expected 3", len(entries))
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	err = pfs.WriteFileChunked("/resume/big.bin", bytes.NewReader(content), 1024)
+	var uerr *charmfs.UploadError
+	if !errors.As(err, &uerr) || !errors.Is(err, charmfs.ErrServer) {
+		t.Fatalf("expected an UploadError from the dropped part, got %v", err)
+	}
+	if ids, err := pfs.PendingUploads(); err != nil || len(ids) != 1 || ids[0] != uerr.ID {
+		t.Errorf("expected upload %s to be pending, got %v, %v", uerr.ID, ids, err)
+	}
+	if _, err := cfs.Open("/resume/big.bin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the interrupted file not to exist yet, got %v", err)
 	}
 
-	// Check that all files are present
-	names := make(map[string]bool)
-	for _, e := range entries {
-		names[e.Name()] = true
+	down.Store(false)
+	if err := pfs.ResumeUpload(uerr.ID); err != nil {
+		t.Fatalf("ResumeUpload failed: %v", err)
+	}
+	assertFileContent(t, cfs, "/resume/big.bin", content)
+	if ids, err := pfs.PendingUploads(); err != nil || len(ids) != 0 {
+		t.Errorf("expected no pending uploads, got %v, %v", ids, err)
+	}
+	if err := pfs.ResumeUpload(uerr.ID); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected resuming a completed upload to fail, got %v", err)
 	}
 
-	for _, expected := range []string{"file1.txt", "file2.txt", "file3.txt"} {
-		if !names[expected] {
-			t.Errorf("ReadDir missing file: %s", expected)
-		}
+	// An aborted upload can't be resumed
+	puts.Store(0)
+	down.Store(true)
+	err = pfs.WriteFileChunked("/resume/aborted.bin", bytes.NewReader(content), 1024)
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected an UploadError, got %v", err)
+	}
+	if err := pfs.AbortUpload(uerr.ID); err != nil {
+		t.Fatalf("AbortUpload failed: %v", err)
+	}
+	if err := pfs.ResumeUpload(uerr.ID); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected resuming an aborted upload to fail, got %v", err)
+	}
+	if _, err := cfs.Open("/resume/aborted.bin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the aborted file not to exist, got %v", err)
 	}
 }
 
-func TestE2E_FS_ReadDirEmpty(t *testing.T) {
-	_, cfs := setupFS(t)
+func TestE2E_Ping(t *testing.T) {
+	cl := setupClient(t)
+	if err := cl.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
 
-	// Reading a nonexistent directory should return empty slice, not error
-	entries, err := cfs.ReadDir("nonexistent-dir")
-	if err != nil {
-		t.Logf("ReadDir on nonexistent returned error (may be expected): %v", err)
+	sshPort := cl.Config.SSHPort
+	cl.Config.SSHPort = 1
+	var serr client.ErrSSHUnreachable
+	if err := cl.Ping(); !errors.As(err, &serr) {
+		t.Errorf("expected ErrSSHUnreachable, got %v", err)
 	}
+	cl.Config.SSHPort = sshPort
 
-	// Per fs.go behavior, should return empty slice
-	if entries == nil {
-		t.Log("ReadDir returned nil slice for nonexistent directory")
+	cl.Config.HealthPort = 1
+	var herr client.ErrHTTPUnreachable
+	if err := cl.Ping(); !errors.As(err, &herr) {
+		t.Errorf("expected ErrHTTPUnreachable, got %v", err)
 	}
 }
 
-func TestE2E_FS_Remove(t *testing.T) {
+func TestE2E_FS_Mkdir(t *testing.T) {
 	_, cfs := setupFS(t)
 
-	path := "to-delete.txt"
-
-	// Create file
-	writeTestFile(t, cfs, path, []byte("delete me"))
-
-	// Verify it exists
-	_, err := cfs.ReadFile(path)
+	if err := cfs.Mkdir("/scaffold"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	des, err := cfs.ReadDir("/scaffold")
 	if err != nil {
-		t.Fatalf("File should exist before delete: %v", err)
+		t.Fatalf("ReadDir on an empty directory failed: %v", err)
+	}
+	if des == nil || len(des) != 0 {
+		t.Errorf("expected an empty, non-nil listing, got %#v", des)
+	}
+	f, err := cfs.Open("/scaffold")
+	if err != nil {
+		t.Fatalf("Open on an empty directory failed: %v", err)
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil || !info.IsDir() {
+		t.Errorf("expected a directory, got %v, %v", info, err)
 	}
 
-	// Delete it
-	err = cfs.Remove(path)
+	if err := cfs.Mkdir("/scaffold"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist creating an existing directory, got %v", err)
+	}
+	if err := cfs.Mkdir("/missing/child"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist without a parent, got %v", err)
+	}
+
+	if err := cfs.MkdirAll("/scaffold/a/b/c"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := cfs.MkdirAll("/scaffold/a/b/c"); err != nil {
+		t.Errorf("expected MkdirAll on an existing directory to succeed, got %v", err)
+	}
+	des, err = cfs.ReadDir("/scaffold/a/b")
 	if err != nil {
-		t.Fatalf("Remove failed: %v", err)
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(des) != 1 || des[0].Name() != "c" || !des[0].IsDir() {
+		t.Errorf("expected the directory c, got %v", des)
 	}
 
-	// Verify it's gone
-	_, err = cfs.ReadFile(path)
-	if err == nil {
-		t.Error("File should not exist after delete")
+	writeTestFile(t, cfs, "/scaffold/file.txt", []byte("x"))
+	if err := cfs.MkdirAll("/scaffold/file.txt"); err == nil {
+		t.Error("expected an error creating a directory over a file")
+	}
+	if err := cfs.Mkdir("/scaffold/file.txt/sub"); err == nil {
+		t.Error("expected an error creating a directory under a file")
 	}
 }
 
-func TestE2E_FS_Open(t *testing.T) {
+func TestE2E_FS_Rename(t *testing.T) {
 	_, cfs := setupFS(t)
 
-	content := []byte("file content for Open test")
-	writeTestFile(t, cfs, "open-test.txt", content)
+	err := cfs.WriteFile("old.txt", &memFile{
+		name:    "old.txt",
+		content: bytes.NewReader([]byte("moving")),
+		size:    6,
+		mode:    0600,
+	})
+	if err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
 
-	// Open the file
-	f, err := cfs.Open("open-test.txt")
+	if err := cfs.Rename("charm:old.txt", "moved/new.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertFileContent(t, cfs, "moved/new.txt", []byte("moving"))
+	if _, err := cfs.ReadFile("old.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected old path to be gone, got %v", err)
+	}
+
+	f, err := cfs.Open("moved/new.txt")
 	if err != nil {
 		t.Fatalf("Open failed: %v", err)
 	}
 	defer f.Close()
-
-	// Read via the file handle
-	data, err := io.ReadAll(f)
+	info, err := f.Stat()
 	if err != nil {
-		t.Fatalf("ReadAll from opened file failed: %v", err)
+		t.Fatalf("Stat failed: %v", err)
 	}
-
-	if !bytes.Equal(data, content) {
-		t.Errorf("Open content mismatch\ngot:  %q\nwant: %q", data, content)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode after rename = %v, want %v", info.Mode().Perm(), fs.FileMode(0600))
 	}
-}
-
-func TestE2E_FS_CharmPrefix(t *testing.T) {
-	_, cfs := setupFS(t)
-
-	content := []byte("charm prefix test")
-
-	// Write with charm: prefix
-	writeTestFile(t, cfs, "charm:prefixed.txt", content)
-
-	// Read without prefix - should find the same file
-	assertFileContent(t, cfs, "prefixed.txt", content)
-
-	// Read with prefix - should also work
-	assertFileContent(t, cfs, "charm:prefixed.txt", content)
-}
 
-func TestE2E_FS_SpecialCharactersInPath(t *testing.T) {
-	_, cfs := setupFS(t)
+	// Renaming to the same path is a no-op, not a delete
+	if err := cfs.Rename("moved/new.txt", "charm:moved/new.txt"); err != nil {
+		t.Fatalf("Rename to same path failed: %v", err)
+	}
+	assertFileContent(t, cfs, "moved/new.txt", []byte("moving"))
 
-	// Test various special characters in filenames
-	testCases := []struct {
-		name string
-		path string
-	}{
-		{"spaces", "file with spaces.txt"},
-		{"dots", "file.multiple.dots.txt"},
-		{"underscores", "file_with_underscores.txt"},
-		{"dashes", "file-with-dashes.txt"},
-		{"mixed", "file with spaces-and_mixed.chars.txt"},
+	// Directories move with everything under them
+	writeTestFile(t, cfs, "dir/a.txt", []byte("a"))
+	writeTestFile(t, cfs, "dir/sub/b.txt", []byte("b"))
+	if err := cfs.Rename("dir", "renamed"); err != nil {
+		t.Fatalf("Rename of directory failed: %v", err)
+	}
+	assertFileContent(t, cfs, "renamed/a.txt", []byte("a"))
+	assertFileContent(t, cfs, "renamed/sub/b.txt", []byte("b"))
+	if _, err := cfs.ReadFile("dir/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected old directory to be gone, got %v", err)
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			content := []byte("content for " + tc.name)
-			writeTestFile(t, cfs, tc.path, content)
-			assertFileContent(t, cfs, tc.path, content)
-		})
+	if err := cfs.Rename("missing.txt", "other.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing source, got %v", err)
 	}
 }
 
@@ -598,6 +1753,41 @@ func TestE2E_KV_Keys(t *testing.T) {
 	}
 }
 
+func TestE2E_KV_GetMulti(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	db, err := kv.OpenWithDefaults("test-kv-getmulti")
+	if err != nil {
+		t.Fatalf("OpenWithDefaults failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, k := range []string{"apple", "banana", "cherry"} {
+		if err := db.Set([]byte(k), []byte("value-"+k)); err != nil {
+			t.Fatalf("Set %q failed: %v", k, err)
+		}
+	}
+
+	values, err := db.GetMulti([][]byte{[]byte("apple"), []byte("cherry"), []byte("durian")})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+
+	if len(values) != 2 {
+		t.Errorf("GetMulti returned %d values, expected 2", len(values))
+	}
+	if !bytes.Equal(values["apple"], []byte("value-apple")) {
+		t.Errorf("GetMulti apple = %q", values["apple"])
+	}
+	if !bytes.Equal(values["cherry"], []byte("value-cherry")) {
+		t.Errorf("GetMulti cherry = %q", values["cherry"])
+	}
+	if _, ok := values["durian"]; ok {
+		t.Error("GetMulti should omit missing keys")
+	}
+}
+
 func TestE2E_KV_BinaryData(t *testing.T) {
 	cl := setupClient(t)
 	mustAuth(t, cl)
@@ -784,6 +1974,221 @@ func TestE2E_EncryptKey_KeyForID_NotFound(t *testing.T) {
 	}
 }
 
+func TestE2E_EncryptKey_RotateAndReEncrypt(t *testing.T) {
+	cl, cfs := setupFS(t)
+
+	// Nothing stored yet: re-encrypting is a no-op
+	if err := cfs.ReEncryptAll(); err != nil {
+		t.Fatalf("ReEncryptAll on an empty account failed: %v", err)
+	}
+
+	content := []byte("written before the rotation")
+	writeTestFile(t, cfs, "/rotate/a.txt", content)
+	encPath, err := cfs.EncryptPath("/rotate/a.txt")
+	if err != nil {
+		t.Fatalf("EncryptPath failed: %v", err)
+	}
+	db, err := openKVAtPath(cl, "rotate-kv", t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenKV failed: %v", err)
+	}
+	defer db.Close() // nolint:errcheck
+	if err := db.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := db.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	old, err := cl.DefaultEncryptKey()
+	if err != nil {
+		t.Fatalf("DefaultEncryptKey failed: %v", err)
+	}
+	nk, err := cl.RotateEncryptKey()
+	if err != nil {
+		t.Fatalf("RotateEncryptKey failed: %v", err)
+	}
+	if nk.ID == old.ID {
+		t.Fatal("RotateEncryptKey returned the old key")
+	}
+	eks, err := cl.EncryptKeys()
+	if err != nil {
+		t.Fatalf("EncryptKeys failed: %v", err)
+	}
+	if len(eks) != 2 || eks[0].ID != nk.ID {
+		t.Fatalf("expected the new key first of 2, got %d keys starting with %s", len(eks), eks[0].ID)
+	}
+
+	// Paths don't change with the default key
+	rotated, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	if p, err := rotated.EncryptPath("/rotate/a.txt"); err != nil || p != encPath {
+		t.Errorf("expected the encrypted path to survive rotation, got %q, %v", p, err)
+	}
+	assertFileContent(t, rotated, "/rotate/a.txt", content)
+
+	if err := rotated.ReEncryptAll(); err != nil {
+		t.Fatalf("FS ReEncryptAll failed: %v", err)
+	}
+	assertFileContent(t, rotated, "/rotate/a.txt", content)
+
+	if err := db.ReEncryptAll(); err != nil {
+		t.Fatalf("KV ReEncryptAll failed: %v", err)
+	}
+	if v, err := db.Get([]byte("k")); err != nil || string(v) != "v" {
+		t.Errorf("expected %q after re-encryption, got %q, %v", "v", v, err)
+	}
+
+	// A fresh machine restores the re-encrypted backup
+	fresh, err := openKVAtPath(cl, "rotate-kv", t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenKV on a fresh machine failed: %v", err)
+	}
+	defer fresh.Close() // nolint:errcheck
+	if err := fresh.Sync(); err != nil {
+		t.Fatalf("Sync on a fresh machine failed: %v", err)
+	}
+	if v, err := fresh.Get([]byte("k")); err != nil || string(v) != "v" {
+		t.Errorf("expected %q on a fresh machine, got %q, %v", "v", v, err)
+	}
+}
+
+func TestE2E_EncryptKey_Delete(t *testing.T) {
+	cl, cfs := setupFS(t)
+	content := []byte("written with the first key")
+	writeTestFile(t, cfs, "/delete-key/a.txt", content)
+
+	old, err := cl.DefaultEncryptKey()
+	if err != nil {
+		t.Fatalf("DefaultEncryptKey failed: %v", err)
+	}
+	if err := cl.DeleteEncryptKey(old.ID); !errors.Is(err, charm.ErrLastEncryptKey) {
+		t.Fatalf("expected ErrLastEncryptKey deleting the only key, got %v", err)
+	}
+	if err := cl.DeleteEncryptKey("missing"); !errors.Is(err, charm.ErrMissingEncryptKey) {
+		t.Errorf("expected ErrMissingEncryptKey, got %v", err)
+	}
+
+	nk, err := cl.RotateEncryptKey()
+	if err != nil {
+		t.Fatalf("RotateEncryptKey failed: %v", err)
+	}
+	if err := cl.DeleteEncryptKey(nk.ID); err != nil {
+		t.Fatalf("DeleteEncryptKey failed: %v", err)
+	}
+	eks, err := cl.EncryptKeys()
+	if err != nil {
+		t.Fatalf("EncryptKeys failed: %v", err)
+	}
+	if len(eks) != 1 || eks[0].ID != old.ID {
+		t.Fatalf("expected only the first key to remain, got %d keys", len(eks))
+	}
+	if k, err := cl.DefaultEncryptKey(); err != nil || k.ID != old.ID {
+		t.Errorf("expected the remaining key to be the default, got %v, %v", k, err)
+	}
+
+	// Data encrypted with the remaining key is still readable
+	remaining, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	assertFileContent(t, remaining, "/delete-key/a.txt", content)
+}
+
+func TestE2E_EncryptKey_ExportImport(t *testing.T) {
+	cl, cfs := setupFS(t)
+	encPath, err := cfs.EncryptPath("/recover/a.txt")
+	if err != nil {
+		t.Fatalf("EncryptPath failed: %v", err)
+	}
+	keys, err := cl.ExportEncryptKeys()
+	if err != nil {
+		t.Fatalf("ExportEncryptKeys failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID == "" || keys[0].Key == "" {
+		t.Fatalf("expected 1 exported key, got %+v", keys)
+	}
+	if keys[0].PublicKey != "" {
+		t.Error("expected the exported key without a public key")
+	}
+	if keys[0].CreatedAt == nil {
+		t.Error("expected the exported key to have a creation date")
+	}
+
+	// A fresh account on another server can decrypt with the imported key
+	other := setupClient(t)
+	mustAuth(t, other)
+	if err := other.ImportEncryptKeys(keys); err != nil {
+		t.Fatalf("ImportEncryptKeys failed: %v", err)
+	}
+	eks, err := other.EncryptKeys()
+	if err != nil {
+		t.Fatalf("EncryptKeys failed: %v", err)
+	}
+	if len(eks) != 2 {
+		t.Fatalf("expected 2 keys after importing, got %d", len(eks))
+	}
+	k, err := other.KeyForID(keys[0].ID)
+	if err != nil || k.Key != keys[0].Key {
+		t.Fatalf("expected the imported key, got %v, %v", k, err)
+	}
+	restored, err := charmfs.NewFSWithClient(other)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	if p, err := restored.EncryptPath("/recover/a.txt"); err != nil || p != encPath {
+		t.Errorf("expected the imported key to encrypt paths, got %q, %v", p, err)
+	}
+
+	// Importing again is a no-op
+	if err := other.ImportEncryptKeys(keys); err != nil {
+		t.Fatalf("ImportEncryptKeys again failed: %v", err)
+	}
+	if eks, err := other.EncryptKeys(); err != nil || len(eks) != 2 {
+		t.Errorf("expected 2 keys after importing again, got %d, %v", len(eks), err)
+	}
+	if err := other.ImportEncryptKeys([]*charm.EncryptKey{{ID: "missing-key"}}); err == nil {
+		t.Error("expected an error importing a key without a key")
+	}
+}
+
+func TestE2E_EncryptKey_SetDefault(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	old, err := cl.DefaultEncryptKey()
+	if err != nil {
+		t.Fatalf("DefaultEncryptKey failed: %v", err)
+	}
+	nk, err := cl.RotateEncryptKey()
+	if err != nil {
+		t.Fatalf("RotateEncryptKey failed: %v", err)
+	}
+	if err := cl.SetDefaultEncryptKey("missing"); !errors.Is(err, charm.ErrMissingEncryptKey) {
+		t.Errorf("expected ErrMissingEncryptKey, got %v", err)
+	}
+
+	// Switch back to the first key, keeping the new one for decryption
+	if err := cl.SetDefaultEncryptKey(old.ID); err != nil {
+		t.Fatalf("SetDefaultEncryptKey failed: %v", err)
+	}
+	eks, err := cl.EncryptKeys()
+	if err != nil {
+		t.Fatalf("EncryptKeys failed: %v", err)
+	}
+	if len(eks) != 2 || eks[0].ID != old.ID || eks[1].ID != nk.ID {
+		t.Fatalf("expected the first key, then the rotated one, got %d keys", len(eks))
+	}
+	if k, err := cl.DefaultEncryptKey(); err != nil || k.ID != old.ID {
+		t.Errorf("expected the first key to be the default, got %v, %v", k, err)
+	}
+	if _, err := cl.KeyForID(nk.ID); err != nil {
+		t.Errorf("expected the rotated key to remain available, got %v", err)
+	}
+}
+
 // =============================================================================
 // User Lifecycle Tests
 // =============================================================================
@@ -830,6 +2235,43 @@ func TestE2E_User_SetName_Invalid(t *testing.T) {
 	}
 }
 
+func TestE2E_User_DeleteAccount(t *testing.T) {
+	cl, cfs := setupFS(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("ID() failed: %v", err)
+	}
+	writeTestFile(t, cfs, "/account/a.txt", []byte("personal data"))
+	// The test server keeps its data next to the client's
+	userDir := filepath.Join(filepath.Dir(cl.Config.DataDir), ".data", "files", id)
+	if _, err := os.Stat(userDir); err != nil {
+		t.Fatalf("expected the server to store files in %s: %v", userDir, err)
+	}
+
+	if err := cl.DeleteAccount(false); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+
+	// The same key now authenticates as a brand new, empty account
+	newID, err := cl.ID()
+	if err != nil {
+		t.Fatalf("ID() after deletion failed: %v", err)
+	}
+	if newID == id {
+		t.Error("expected a new account after deletion")
+	}
+	fresh, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	if _, err := fresh.ReadFile("/account/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected files to be deleted with the account, got %v", err)
+	}
+	if _, err := os.Stat(userDir); !os.IsNotExist(err) {
+		t.Errorf("expected the server to remove %s, got %v", userDir, err)
+	}
+}
+
 func TestE2E_User_Bio(t *testing.T) {
 	cl := setupClient(t)
 	mustAuth(t, cl)
@@ -1208,6 +2650,550 @@ func TestE2E_KV_MultipleOverwriteSync(t *testing.T) {
 	}
 }
 
+func TestE2E_KV_IncrementalSync(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	dbName := "test-incremental-sync"
+	machineAPath := t.TempDir()
+	machineBPath := t.TempDir()
+
+	open := func(path string) *kv.KV {
+		t.Helper()
+		db, err := kv.Open(cl, dbName, kv.WithPath(path), kv.WithIncrementalSync())
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}
+
+	// Machine A: first write uploads a baseline snapshot plus an op batch
+	dbA := open(machineAPath)
+	if err := dbA.Set([]byte("k1"), []byte("v1")); err != nil {
+		t.Fatalf("Machine A: Set failed: %v", err)
+	}
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: Sync failed: %v", err)
+	}
+
+	// Machine B: pulls the op batch
+	dbB := open(machineBPath)
+	defer dbB.Close()
+	if err := dbB.Sync(); err != nil {
+		t.Fatalf("Machine B: Sync failed: %v", err)
+	}
+	got, err := dbB.Get([]byte("k1"))
+	if err != nil {
+		t.Fatalf("Machine B: Get k1 failed: %v", err)
+	}
+	if !bytes.Equal(got, []byte("v1")) {
+		t.Errorf("Machine B: expected v1, got %q", got)
+	}
+
+	// Machine A: a small change is pushed as a new op batch
+	if err := dbA.Set([]byte("k2"), []byte("v2")); err != nil {
+		t.Fatalf("Machine A: Set k2 failed: %v", err)
+	}
+	if err := dbA.Delete([]byte("k1")); err != nil {
+		t.Fatalf("Machine A: Delete k1 failed: %v", err)
+	}
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: second Sync failed: %v", err)
+	}
+	dbA.Close()
+
+	if err := dbB.Sync(); err != nil {
+		t.Fatalf("Machine B: second Sync failed: %v", err)
+	}
+	got, err = dbB.Get([]byte("k2"))
+	if err != nil {
+		t.Fatalf("Machine B: Get k2 failed: %v", err)
+	}
+	if !bytes.Equal(got, []byte("v2")) {
+		t.Errorf("Machine B: expected v2, got %q", got)
+	}
+	if _, err := dbB.Get([]byte("k1")); err != kv.ErrMissingKey {
+		t.Errorf("Machine B: expected k1 to be deleted, got %v", err)
+	}
+
+	// Only op batches were uploaded after the baseline snapshot
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	batches, err := cfs.ReadDir(dbName + "/ops")
+	if err != nil {
+		t.Fatalf("ReadDir ops failed: %v", err)
+	}
+	if len(batches) != 2 {
+		t.Errorf("expected 2 op batches, got %d", len(batches))
+	}
+	entries, err := cfs.ReadDir(dbName)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var snapshots int
+	for _, e := range entries {
+		if !e.IsDir() && e.Name() != "manifest.json" {
+			snapshots++
+		}
+	}
+	if snapshots != 1 {
+		t.Errorf("expected only the baseline snapshot, got %d snapshots", snapshots)
+	}
+}
+
+func TestE2E_KV_ConflictResolver(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	dbName := "test-conflict-resolver"
+	union := func(_, local, remote []byte) []byte {
+		seen := map[string]bool{}
+		var items []string
+		for _, v := range [][]byte{local, remote} {
+			for _, item := range strings.Split(string(v), ",") {
+				if item != "" && !seen[item] {
+					seen[item] = true
+					items = append(items, item)
+				}
+			}
+		}
+		sort.Strings(items)
+		return []byte(strings.Join(items, ","))
+	}
+	open := func() *kv.KV {
+		t.Helper()
+		db, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()), kv.WithIncrementalSync(),
+			kv.WithConflictResolver(union), kv.WithManualBackup())
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}
+
+	dbA := open()
+	defer dbA.Close()
+	if err := dbA.Set([]byte("tags"), []byte("a")); err != nil {
+		t.Fatalf("Machine A: Set failed: %v", err)
+	}
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: Sync failed: %v", err)
+	}
+	dbB := open()
+	defer dbB.Close()
+	if err := dbB.Sync(); err != nil {
+		t.Fatalf("Machine B: Sync failed: %v", err)
+	}
+
+	// Both machines add a different tag before syncing
+	if err := dbA.Set([]byte("tags"), []byte("a,b")); err != nil {
+		t.Fatalf("Machine A: Set failed: %v", err)
+	}
+	if err := dbB.Set([]byte("tags"), []byte("a,c")); err != nil {
+		t.Fatalf("Machine B: Set failed: %v", err)
+	}
+	for _, db := range []*kv.KV{dbA, dbB, dbA} {
+		if err := db.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+	}
+
+	for name, db := range map[string]*kv.KV{"A": dbA, "B": dbB} {
+		got, err := db.Get([]byte("tags"))
+		if err != nil || string(got) != "a,b,c" {
+			t.Errorf("Machine %s: Get = %q, %v, want %q", name, got, err, "a,b,c")
+		}
+	}
+}
+
+func TestE2E_KV_DeviceID(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	dbName := "test-device-id"
+	pathA := t.TempDir()
+	open := func(path string, opts ...kv.Option) *kv.KV {
+		t.Helper()
+		db, err := kv.Open(cl, dbName, append([]kv.Option{kv.WithPath(path)}, opts...)...)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}
+
+	// Machines on the same account get different IDs, kept across reopens
+	dbA := open(pathA)
+	idA := dbA.DeviceID()
+	dbA.Close()
+	dbB := open(t.TempDir())
+	defer dbB.Close()
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("ID failed: %v", err)
+	}
+	if idA == "" || idA == dbB.DeviceID() || idA == id {
+		t.Errorf("expected a per-machine device ID, got %q and %q for Charm ID %q", idA, dbB.DeviceID(), id)
+	}
+	dbA = open(pathA)
+	defer dbA.Close()
+	if dbA.DeviceID() != idA {
+		t.Errorf("expected device ID %q after reopening, got %q", idA, dbA.DeviceID())
+	}
+
+	laptop := open(t.TempDir(), kv.WithDeviceID("laptop"))
+	defer laptop.Close()
+	if laptop.DeviceID() != "laptop" {
+		t.Errorf("expected device ID %q, got %q", "laptop", laptop.DeviceID())
+	}
+	if a, b := laptop.HLCNow(), laptop.HLCNow(); b != a {
+		t.Errorf("expected reading HLCNow not to advance it, got %d then %d", a, b)
+	}
+}
+
+func TestE2E_KV_IncrementalSyncBootstrapsFreshMachine(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	dbName := "test-incremental-bootstrap"
+	machineAPath := t.TempDir()
+	machineCPath := t.TempDir()
+
+	// Machine A: data written with full snapshot sync before opting in
+	dbA, err := openKVAtPath(cl, dbName, machineAPath)
+	if err != nil {
+		t.Fatalf("Machine A: Open failed: %v", err)
+	}
+	for _, k := range []string{"k1", "k2"} {
+		if err := dbA.Set([]byte(k), []byte("v-"+k)); err != nil {
+			t.Fatalf("Machine A: Set %s failed: %v", k, err)
+		}
+	}
+	dbA.Close()
+
+	// Machine A: switch to incremental sync
+	dbA, err = kv.Open(cl, dbName, kv.WithPath(machineAPath), kv.WithIncrementalSync())
+	if err != nil {
+		t.Fatalf("Machine A: incremental Open failed: %v", err)
+	}
+	defer dbA.Close()
+	if err := dbA.Set([]byte("k3"), []byte("v-k3")); err != nil {
+		t.Fatalf("Machine A: Set k3 failed: %v", err)
+	}
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: Sync failed: %v", err)
+	}
+
+	// Machine C: fresh machine with an unsynced local write
+	dbC, err := kv.Open(cl, dbName, kv.WithPath(machineCPath), kv.WithIncrementalSync())
+	if err != nil {
+		t.Fatalf("Machine C: Open failed: %v", err)
+	}
+	defer dbC.Close()
+	if err := dbC.Set([]byte("local"), []byte("from-c")); err != nil {
+		t.Fatalf("Machine C: Set failed: %v", err)
+	}
+	if err := dbC.Sync(); err != nil {
+		t.Fatalf("Machine C: Sync failed: %v", err)
+	}
+
+	for _, k := range []string{"k1", "k2", "k3"} {
+		got, err := dbC.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("Machine C: Get %s failed: %v", k, err)
+		}
+		if !bytes.Equal(got, []byte("v-"+k)) {
+			t.Errorf("Machine C: %s = %q", k, got)
+		}
+	}
+	got, err := dbC.Get([]byte("local"))
+	if err != nil {
+		t.Fatalf("Machine C: local write lost during bootstrap: %v", err)
+	}
+	if !bytes.Equal(got, []byte("from-c")) {
+		t.Errorf("Machine C: local = %q", got)
+	}
+
+	// Machine A: picks up C's write incrementally
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: second Sync failed: %v", err)
+	}
+	got, err = dbA.Get([]byte("local"))
+	if err != nil {
+		t.Fatalf("Machine A: Get local failed: %v", err)
+	}
+	if !bytes.Equal(got, []byte("from-c")) {
+		t.Errorf("Machine A: local = %q", got)
+	}
+}
+
+func TestE2E_KV_Offline(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+	dbName := "test-offline"
+
+	dbA, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()), kv.WithOffline(), kv.WithBackupThreshold(1))
+	if err != nil {
+		t.Fatalf("Machine A: Open failed: %v", err)
+	}
+	defer dbA.Close()
+	for i := 0; i < 3; i++ {
+		if err := dbA.Set([]byte(fmt.Sprintf("key-%d", i)), []byte("offline")); err != nil {
+			t.Fatalf("Machine A: Set failed: %v", err)
+		}
+	}
+
+	dbB, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Machine B: Open failed: %v", err)
+	}
+	defer dbB.Close()
+	if err := dbB.Sync(); err != nil {
+		t.Fatalf("Machine B: Sync failed: %v", err)
+	}
+	if _, err := dbB.Get([]byte("key-0")); err == nil {
+		t.Fatal("expected offline writes not to reach the cloud")
+	}
+
+	// Syncing flushes the offline writes and brings the store back online
+	if err := dbA.Sync(); err != nil {
+		t.Fatalf("Machine A: Sync failed: %v", err)
+	}
+	if dbA.IsOffline() {
+		t.Error("expected the store to be online after Sync")
+	}
+	if err := dbB.Sync(); err != nil {
+		t.Fatalf("Machine B: Sync failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		v, err := dbB.Get([]byte(fmt.Sprintf("key-%d", i)))
+		if err != nil || string(v) != "offline" {
+			t.Errorf("Machine B: Get(key-%d) = %q, %v", i, v, err)
+		}
+	}
+}
+
+func TestE2E_KV_SyncWithProgress(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	for _, incremental := range []bool{false, true} {
+		name := "snapshot"
+		opts := []kv.Option{}
+		if incremental {
+			name = "incremental"
+			opts = append(opts, kv.WithIncrementalSync())
+		}
+
+		t.Run(name, func(t *testing.T) {
+			dbName := "test-sync-progress-" + name
+
+			dbA, err := kv.Open(cl, dbName, append(opts, kv.WithPath(t.TempDir()))...)
+			if err != nil {
+				t.Fatalf("Machine A: Open failed: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if err := dbA.Set([]byte(fmt.Sprintf("key-%d", i)), []byte("value")); err != nil {
+					t.Fatalf("Machine A: Set failed: %v", err)
+				}
+				if err := dbA.Sync(); err != nil {
+					t.Fatalf("Machine A: Sync failed: %v", err)
+				}
+			}
+			dbA.Close()
+
+			dbB, err := kv.Open(cl, dbName, append(opts, kv.WithPath(t.TempDir()))...)
+			if err != nil {
+				t.Fatalf("Machine B: Open failed: %v", err)
+			}
+			defer dbB.Close()
+
+			var calls, lastDone, lastTotal int
+			var downloaded int64
+			err = dbB.SyncWithProgress(context.Background(), func(p kv.SyncProgress) {
+				if p.Done > p.Total {
+					t.Errorf("done %d exceeds total %d", p.Done, p.Total)
+				}
+				if p.BytesTransferred > p.TotalBytes {
+					t.Errorf("transferred %d exceeds total %d bytes", p.BytesTransferred, p.TotalBytes)
+				}
+				if p.Phase == kv.SyncDownloading {
+					downloaded += p.BytesTransferred
+				}
+				if incremental && p.Phase == kv.SyncApplying && p.OpsApplied == 0 {
+					t.Error("expected applying progress to count ops")
+				}
+				calls++
+				lastDone, lastTotal = p.Done, p.Total
+			})
+			if err != nil {
+				t.Fatalf("Machine B: SyncWithProgress failed: %v", err)
+			}
+			if calls == 0 {
+				t.Fatal("expected progress to be reported")
+			}
+			if lastDone != lastTotal || lastTotal == 0 {
+				t.Errorf("expected final progress to be complete, got %d/%d", lastDone, lastTotal)
+			}
+			if downloaded == 0 {
+				t.Error("expected downloaded bytes to be reported")
+			}
+
+			keys, err := dbB.Keys()
+			if err != nil {
+				t.Fatalf("Machine B: Keys failed: %v", err)
+			}
+			if len(keys) != 3 {
+				t.Errorf("Machine B: expected 3 keys, got %d", len(keys))
+			}
+		})
+	}
+}
+
+func TestE2E_KV_ListBackupsAndRestoreSeq(t *testing.T) {
+	cl := setupClient(t)
+	mustAuth(t, cl)
+
+	dbName := "test-restore-seq"
+	db, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Set([]byte("key"), []byte("good")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := db.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	good, err := db.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(good) == 0 {
+		t.Fatal("expected at least one backup")
+	}
+	goodSeq := good[len(good)-1]
+
+	if err := db.Set([]byte("key"), []byte("bad")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := db.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	backups, err := db.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) <= len(good) || backups[len(backups)-1] <= goodSeq {
+		t.Fatalf("expected a newer backup after second sync, got %v", backups)
+	}
+
+	if err := db.RestoreSeq(goodSeq); err != nil {
+		t.Fatalf("RestoreSeq failed: %v", err)
+	}
+	got, err := db.Get([]byte("key"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(got, []byte("good")) {
+		t.Errorf("expected 'good' after restore, got %q", got)
+	}
+
+	// The rollback survives a sync and reaches other machines
+	if err := db.Sync(); err != nil {
+		t.Fatalf("Sync after restore failed: %v", err)
+	}
+	if got, _ := db.Get([]byte("key")); !bytes.Equal(got, []byte("good")) {
+		t.Errorf("expected 'good' after sync, got %q", got)
+	}
+
+	other, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Open other failed: %v", err)
+	}
+	defer other.Close()
+	if err := other.Sync(); err != nil {
+		t.Fatalf("other Sync failed: %v", err)
+	}
+	if got, _ := other.Get([]byte("key")); !bytes.Equal(got, []byte("good")) {
+		t.Errorf("expected other machine to see 'good', got %q", got)
+	}
+
+	if err := db.RestoreSeq(999999); err == nil {
+		t.Error("expected error restoring a missing backup")
+	}
+}
+
+func TestE2E_KV_SyncDivergence(t *testing.T) {
+	cl, cfs := setupFS(t)
+	mustAuth(t, cl)
+
+	dbName := "test-sync-divergence"
+	db, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, v := range []string{"first", "second"} {
+		if err := db.Set([]byte("key"), []byte(v)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if err := db.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+	}
+	seqs, err := db.ListBackups()
+	if err != nil || len(seqs) < 2 {
+		t.Fatalf("ListBackups = %v, %v, want at least 2 backups", seqs, err)
+	}
+	pushed, remote := seqs[len(seqs)-1], seqs[len(seqs)-2]
+
+	// Roll the cloud back by removing the newest backup
+	des, err := cfs.ReadDir(dbName)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, de := range des {
+		if strings.HasPrefix(de.Name(), fmt.Sprintf("%d-", pushed)) {
+			if err := cfs.Remove(path.Join(dbName, de.Name())); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+		}
+	}
+
+	err = db.Sync()
+	var divErr *kv.ErrSyncDivergence
+	if !errors.As(err, &divErr) {
+		t.Fatalf("expected ErrSyncDivergence, got %v", err)
+	}
+	if divErr.LocalSeq != pushed || divErr.RemoteSeq != remote {
+		t.Errorf("ErrSyncDivergence = %d/%d, want %d/%d", divErr.LocalSeq, divErr.RemoteSeq, pushed, remote)
+	}
+
+	// Force-pushing resolves the divergence in favor of the local database
+	if err := db.ForcePush(); err != nil {
+		t.Fatalf("ForcePush failed: %v", err)
+	}
+	if err := db.Sync(); err != nil {
+		t.Fatalf("Sync after ForcePush failed: %v", err)
+	}
+
+	other, err := kv.Open(cl, dbName, kv.WithPath(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Open other failed: %v", err)
+	}
+	defer other.Close()
+	if err := other.Sync(); err != nil {
+		t.Fatalf("other Sync failed: %v", err)
+	}
+	if got, _ := other.Get([]byte("key")); !bytes.Equal(got, []byte("second")) {
+		t.Errorf("expected other machine to see 'second', got %q", got)
+	}
+}
+
 // =============================================================================
 // Isolation Tests (verify test isolation)
 // =============================================================================
@@ -0,0 +1,70 @@
+// ABOUTME: Background sync on a fixed interval
+// ABOUTME: Keeps long-running processes fresh without explicit Sync calls
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StartAutoSync starts a goroutine that syncs with the Charm Cloud every
+// interval until ctx is cancelled or the KV is closed. Close waits for an
+// in-progress sync to stop before closing the database.
+//
+// Sync errors are passed to the handler set with WithSyncErrorHandler, if
+// any. A tick that finds another sync already running, or the store offline,
+// is skipped silently.
+// StartAutoSync does nothing if interval isn't positive or the KV is closed.
+func (kv *KV) StartAutoSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	// Close can't start waiting for sync goroutines between the check and
+	// adding this one
+	kv.autoSyncMu.Lock()
+	defer kv.autoSyncMu.Unlock()
+	select {
+	case <-kv.shutdown:
+		return
+	default:
+	}
+	kv.autoSyncWG.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Cancel an in-progress sync as soon as Close is called
+	go func() {
+		select {
+		case <-kv.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer kv.autoSyncWG.Done()
+		defer cancel()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if kv.skipCloud() {
+				continue
+			}
+
+			err := kv.SyncWithContext(ctx)
+			if err == nil || errors.Is(err, ErrSyncLockHeld) || ctx.Err() != nil {
+				continue
+			}
+			if kv.syncErrorHandler != nil {
+				kv.syncErrorHandler(err)
+			}
+		}
+	}()
+}
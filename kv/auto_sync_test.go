@@ -0,0 +1,102 @@
+// ABOUTME: Tests for StartAutoSync background syncing.
+// ABOUTME: Verifies error reporting and that the goroutine stops on cancel and Close.
+package kv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartAutoSync_ReportsErrors(t *testing.T) {
+	kv := newTestKV(t)
+	errs := make(chan error, 10)
+	kv.syncErrorHandler = func(err error) { errs <- err }
+
+	// A closed database makes every sync fail before reaching the network
+	_ = kv.db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kv.StartAutoSync(ctx, 10*time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected a non-nil error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sync error was not reported")
+	}
+
+	cancel()
+	kv.autoSyncWG.Wait()
+}
+
+func TestStartAutoSync_SkipsHeldLock(t *testing.T) {
+	kv := newTestKV(t)
+	errs := make(chan error, 10)
+	kv.syncErrorHandler = func(err error) { errs <- err }
+
+	// Another process is syncing, so every tick should be skipped quietly
+	if _, err := kv.db.Exec("INSERT INTO sync_lock (id, holder, acquired_at, expires_at) VALUES (1, ?, ?, ?)",
+		"other", time.Now().Unix(), time.Now().Add(time.Minute).Unix()); err != nil {
+		t.Fatalf("failed to take sync lock: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	kv.StartAutoSync(ctx, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	kv.autoSyncWG.Wait()
+
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error reported: %v", err)
+	default:
+	}
+}
+
+func TestStartAutoSync_StopsOnClose(t *testing.T) {
+	kv := newTestKV(t)
+
+	kv.StartAutoSync(context.Background(), time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		_ = kv.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not stop the background sync")
+	}
+
+	// Starting after Close does nothing
+	kv.StartAutoSync(context.Background(), time.Millisecond)
+	kv.autoSyncWG.Wait()
+}
+
+func TestStartAutoSync_RacingClose(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		kv := newTestKV(t)
+		started := make(chan struct{})
+		go func() {
+			kv.StartAutoSync(context.Background(), time.Millisecond)
+			close(started)
+		}()
+		if err := kv.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		// Either Close waited for the goroutine or it was never started
+		<-started
+		kv.autoSyncWG.Wait()
+	}
+}
+
+func TestStartAutoSync_NonPositiveInterval(t *testing.T) {
+	kv := newTestKV(t)
+	kv.StartAutoSync(context.Background(), 0)
+	kv.autoSyncWG.Wait()
+}
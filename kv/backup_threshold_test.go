@@ -0,0 +1,66 @@
+// ABOUTME: Tests for the configurable automatic backup threshold.
+// ABOUTME: Verifies when syncAfterWrite triggers a backup for each setting.
+package kv
+
+import "testing"
+
+// pendingAfterWrites runs n writes through syncAfterWrite and returns the
+// pending write counter. A backup resets the counter to zero.
+func pendingAfterWrites(t *testing.T, threshold, n int) int {
+	t.Helper()
+	kv := newTestKV(t)
+	kv.backupThreshold = threshold
+	// With shutdown closed, triggered backups return without a cloud client
+	close(kv.shutdown)
+
+	for i := 0; i < n; i++ {
+		if err := kv.syncAfterWrite(); err != nil {
+			t.Fatalf("syncAfterWrite failed: %v", err)
+		}
+	}
+	return kv.pendingWrites
+}
+
+func TestBackupThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		writes    int
+		want      int
+	}{
+		{"default below threshold", backupWriteThreshold, backupWriteThreshold - 1, backupWriteThreshold - 1},
+		{"default at threshold", backupWriteThreshold, backupWriteThreshold, 0},
+		{"every write", 1, 5, 0},
+		{"large threshold", 100, 99, 99},
+		{"never", 0, 250, 250},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pendingAfterWrites(t, tt.threshold, tt.writes); got != tt.want {
+				t.Errorf("pendingWrites = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithBackupThreshold(t *testing.T) {
+	cfg := &Config{}
+	WithBackupThreshold(0)(cfg)
+	if !cfg.backupThresholdSet || cfg.backupThreshold != 0 {
+		t.Errorf("expected explicit threshold 0, got set=%v n=%d", cfg.backupThresholdSet, cfg.backupThreshold)
+	}
+
+	cfg = &Config{}
+	WithBackupThreshold(100)(cfg)
+	if cfg.backupThreshold != 100 {
+		t.Errorf("expected threshold 100, got %d", cfg.backupThreshold)
+	}
+}
+
+func TestWithManualBackup(t *testing.T) {
+	cfg := &Config{}
+	WithManualBackup()(cfg)
+	if !cfg.backupThresholdSet || cfg.backupThreshold != 0 {
+		t.Errorf("expected manual backups, got set=%v n=%d", cfg.backupThresholdSet, cfg.backupThreshold)
+	}
+}
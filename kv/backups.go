@@ -0,0 +1,86 @@
+// ABOUTME: Listing and restoring specific cloud backups
+// ABOUTME: Point-in-time recovery by rolling the store back to an earlier snapshot
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListBackups returns the sequence numbers of the full snapshot backups
+// available in the Charm Cloud, sorted ascending. Both content-addressed
+// backups ({seq}-{hash}) and old-format backups ({seq}) are included.
+func (kv *KV) ListBackups() ([]uint64, error) {
+	des, err := kv.fs.ReadDir(kv.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	seen := make(map[uint64]bool)
+	var seqs []uint64
+	for _, de := range des {
+		if de.IsDir() {
+			continue
+		}
+		name, _, _ := strings.Cut(de.Name(), "-")
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || seq == 0 {
+			// Skip manifest.json and anything else that isn't a backup
+			continue
+		}
+		if !seen[seq] {
+			seen[seq] = true
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// RestoreSeq rolls the database back to the snapshot backup with the given
+// sequence number, replacing the local database. The restored state is then
+// uploaded as the newest backup so the next Sync, here or on other machines,
+// doesn't bring back the data that was rolled back.
+//
+// Returns ErrNotSQLite if the backup isn't a valid SQLite database, and
+// ErrReadOnlyMode if the database is open in read-only mode. RestoreSeq is
+// not supported with WithIncrementalSync, since other machines would replay
+// the newer op batches on top of the restored snapshot.
+func (kv *KV) RestoreSeq(seq uint64) error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "restore backup"}
+	}
+	if kv.incremental {
+		return errors.New("restoring a backup is not supported with incremental sync")
+	}
+	if seq == 0 {
+		return errors.New("invalid backup seq 0")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return withSyncLock(kv.db, func() error {
+		if err := kv.restoreSeq(seq, nil); err != nil {
+			return fmt.Errorf("failed to restore backup %d: %w", seq, err)
+		}
+		// Writes made before the restore are gone with the old database
+		if err := clearPendingOps(kv.db); err != nil {
+			return fmt.Errorf("failed to clear pending ops: %w", err)
+		}
+		kv.backupMu.Lock()
+		kv.pendingWrites = 0
+		kv.backupMu.Unlock()
+
+		if err := kv.snapshotBackup(ctx); err != nil {
+			return fmt.Errorf("failed to upload restored backup: %w", err)
+		}
+		return kv.recordSyncTime()
+	})
+}
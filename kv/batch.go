@@ -0,0 +1,135 @@
+// ABOUTME: Batched writes for the KV store
+// ABOUTME: Groups Set/Delete calls into a single SQLite transaction
+
+package kv
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Batch accumulates Set and Delete operations and applies them in a single
+// transaction when committed. Each operation still gets its own op-log entry,
+// but the batch only counts as one write towards the backup threshold.
+//
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	kv   *KV
+	ops  []batchOp
+	done bool
+}
+
+// batchOp is a single queued write in a Batch.
+type batchOp struct {
+	opType    string // "set" or "delete"
+	key       []byte
+	value     []byte // plaintext, kept for change notifications
+	encValue  []byte // nil for deletes
+	expiresAt int64  // Unix milliseconds, 0 for no expiry
+}
+
+// Batch returns a new write batch for this KV store.
+func (kv *KV) Batch() *Batch {
+	return &Batch{kv: kv}
+}
+
+// Set queues a key and value to be written when the batch is committed.
+// The value is encrypted immediately so errors surface before Commit.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (b *Batch) Set(key, value []byte) error {
+	return b.set(key, value, 0)
+}
+
+// set queues a write that expires at expiresAt (Unix milliseconds), or
+// never if expiresAt is 0.
+func (b *Batch) set(key, value []byte, expiresAt int64) error {
+	if b.kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "set key"}
+	}
+	if b.done {
+		return ErrBatchDone
+	}
+	encValue, err := b.kv.encryptValue(value)
+	if err != nil {
+		return err
+	}
+	b.ops = append(b.ops, batchOp{opType: "set", key: bytes.Clone(key), value: bytes.Clone(value), encValue: encValue, expiresAt: expiresAt})
+	return nil
+}
+
+// Delete queues a key to be deleted when the batch is committed.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (b *Batch) Delete(key []byte) error {
+	if b.kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "delete key"}
+	}
+	if b.done {
+		return ErrBatchDone
+	}
+	b.ops = append(b.ops, batchOp{opType: "delete", key: bytes.Clone(key)})
+	return nil
+}
+
+// Len returns the number of operations queued in the batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Commit applies all queued operations in a single transaction. Either all
+// operations are applied or none are. The batch cannot be reused afterwards.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (b *Batch) Commit() error {
+	if b.kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "commit batch"}
+	}
+	if b.done {
+		return ErrBatchDone
+	}
+	b.done = true
+
+	ops := b.ops
+	b.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+
+	tx, err := b.kv.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, op := range ops {
+		switch op.opType {
+		case "set":
+			err = b.kv.setTx(tx, op.key, op.encValue, op.expiresAt)
+		case "delete":
+			err = b.kv.deleteTx(tx, op.key)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, op := range ops {
+		if op.opType == "set" {
+			b.kv.notify(KeyEvent{Key: op.key, Value: op.value, Type: KeySet})
+		} else {
+			b.kv.notify(KeyEvent{Key: op.key, Type: KeyDeleted})
+		}
+	}
+
+	// The whole batch counts as a single write towards the backup threshold
+	return b.kv.syncAfterWrite()
+}
+
+// Discard drops all queued operations without applying them.
+// The batch cannot be reused afterwards.
+func (b *Batch) Discard() {
+	b.ops = nil
+	b.done = true
+}
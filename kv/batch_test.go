@@ -0,0 +1,116 @@
+// ABOUTME: Tests for batched writes.
+// ABOUTME: Verifies atomic commit, op-log entries, discard, and read-only handling.
+package kv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatch_Commit(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.Set([]byte("old"), []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	b := kv.Batch()
+	for _, k := range []string{"a", "b", "c"} {
+		if err := b.Set([]byte(k), []byte("value-"+k)); err != nil {
+			t.Fatalf("batch Set failed: %v", err)
+		}
+	}
+	if err := b.Delete([]byte("old")); err != nil {
+		t.Fatalf("batch Delete failed: %v", err)
+	}
+	if b.Len() != 4 {
+		t.Errorf("expected 4 queued ops, got %d", b.Len())
+	}
+
+	// Nothing is written before Commit
+	if _, err := kv.Get([]byte("a")); err != ErrMissingKey {
+		t.Errorf("expected ErrMissingKey before commit, got %v", err)
+	}
+
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		v, err := kv.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("Get %q failed: %v", k, err)
+		}
+		if string(v) != "value-"+k {
+			t.Errorf("Get %q = %q", k, v)
+		}
+	}
+	if _, err := kv.Get([]byte("old")); err != ErrMissingKey {
+		t.Errorf("expected deleted key to be missing, got %v", err)
+	}
+
+	// One op-log entry per operation (plus the initial Set)
+	var opCount int
+	if err := kv.db.QueryRow("SELECT COUNT(*) FROM op_log").Scan(&opCount); err != nil {
+		t.Fatalf("failed to count op_log: %v", err)
+	}
+	if opCount != 5 {
+		t.Errorf("expected 5 op-log entries, got %d", opCount)
+	}
+
+	// The batch counts as a single write
+	if kv.pendingWrites != 2 {
+		t.Errorf("expected pendingWrites=2, got %d", kv.pendingWrites)
+	}
+}
+
+func TestBatch_Discard(t *testing.T) {
+	kv := newTestKV(t)
+
+	b := kv.Batch()
+	if err := b.Set([]byte("a"), []byte("value")); err != nil {
+		t.Fatalf("batch Set failed: %v", err)
+	}
+	b.Discard()
+
+	if _, err := kv.Get([]byte("a")); err != ErrMissingKey {
+		t.Errorf("expected discarded key to be missing, got %v", err)
+	}
+	if err := b.Set([]byte("b"), []byte("value")); !errors.Is(err, ErrBatchDone) {
+		t.Errorf("expected ErrBatchDone after Discard, got %v", err)
+	}
+	if err := b.Commit(); !errors.Is(err, ErrBatchDone) {
+		t.Errorf("expected ErrBatchDone on Commit after Discard, got %v", err)
+	}
+}
+
+func TestBatch_CommitTwice(t *testing.T) {
+	kv := newTestKV(t)
+
+	b := kv.Batch()
+	if err := b.Set([]byte("a"), []byte("value")); err != nil {
+		t.Fatalf("batch Set failed: %v", err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := b.Commit(); !errors.Is(err, ErrBatchDone) {
+		t.Errorf("expected ErrBatchDone on second Commit, got %v", err)
+	}
+}
+
+func TestBatch_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	b := kv.Batch()
+	if err := b.Set([]byte("a"), []byte("value")); !IsReadOnly(err) {
+		t.Errorf("expected read-only error from Set, got %v", err)
+	}
+	if err := b.Delete([]byte("a")); !IsReadOnly(err) {
+		t.Errorf("expected read-only error from Delete, got %v", err)
+	}
+	if err := b.Commit(); !IsReadOnly(err) {
+		t.Errorf("expected read-only error from Commit, got %v", err)
+	}
+}
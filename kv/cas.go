@@ -0,0 +1,97 @@
+// ABOUTME: Compare-and-swap operations for the KV store
+// ABOUTME: Atomic conditional writes for optimistic concurrency
+
+package kv
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+)
+
+// CompareAndSwap sets key to new only if its current value equals old,
+// reporting whether the swap happened. A nil old matches only a missing key,
+// so CompareAndSwap(key, nil, v) creates key if it doesn't exist.
+//
+// The read, comparison, and write happen in a single transaction holding the
+// database write lock, so concurrent writers from this or other processes
+// can't interleave. Like Set, the new value has no expiry.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) CompareAndSwap(key, old, new []byte) (bool, error) {
+	if kv.readOnly {
+		return false, &ErrReadOnlyMode{Operation: "compare and swap key"}
+	}
+	encValue, err := kv.encryptValue(new)
+	if err != nil {
+		return false, err
+	}
+
+	swapped, err := kv.compareAndWrite(key, old, func(tx *sql.Tx) error {
+		return kv.setTx(tx, key, encValue, 0)
+	})
+	if err != nil || !swapped {
+		return false, err
+	}
+	kv.notify(KeyEvent{Key: bytes.Clone(key), Value: bytes.Clone(new), Type: KeySet})
+	return true, kv.syncAfterWrite()
+}
+
+// CompareAndDelete deletes key only if its current value equals old,
+// reporting whether the delete happened. A missing key is never deleted.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) CompareAndDelete(key, old []byte) (bool, error) {
+	if kv.readOnly {
+		return false, &ErrReadOnlyMode{Operation: "compare and delete key"}
+	}
+	if old == nil {
+		return false, nil
+	}
+
+	deleted, err := kv.compareAndWrite(key, old, func(tx *sql.Tx) error {
+		return kv.deleteTx(tx, key)
+	})
+	if err != nil || !deleted {
+		return false, err
+	}
+	kv.notify(KeyEvent{Key: bytes.Clone(key), Type: KeyDeleted})
+	return true, kv.syncAfterWrite()
+}
+
+// compareAndWrite runs write in a write-locked transaction if the current
+// decrypted value of key equals old, or if old is nil and key is missing.
+// Returns whether write ran and was committed.
+func (kv *KV) compareAndWrite(key, old []byte, write func(tx *sql.Tx) error) (bool, error) {
+	tx, err := beginWriteTx(kv.db)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	encCurrent, err := sqliteGetTx(tx, key)
+	switch {
+	case err == ErrMissingKey:
+		if old != nil {
+			return false, nil
+		}
+	case err != nil:
+		return false, err
+	case old == nil:
+		return false, nil
+	default:
+		current, err := kv.decryptValue(encCurrent)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(current, old) {
+			return false, nil
+		}
+	}
+
+	if err := write(tx); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,139 @@
+// ABOUTME: Tests for CompareAndSwap and CompareAndDelete.
+// ABOUTME: Verifies conditional writes, op-log tracking, and atomicity under contention.
+package kv
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	kv := newTestKV(t)
+
+	// nil old creates a missing key
+	ok, err := kv.CompareAndSwap([]byte("k"), nil, []byte("v1"))
+	if err != nil || !ok {
+		t.Fatalf("expected create to succeed, got ok=%v err=%v", ok, err)
+	}
+
+	// nil old doesn't match an existing key
+	ok, err = kv.CompareAndSwap([]byte("k"), nil, []byte("other"))
+	if err != nil || ok {
+		t.Fatalf("expected create of existing key to fail, got ok=%v err=%v", ok, err)
+	}
+
+	// Mismatched old leaves the value alone
+	ok, err = kv.CompareAndSwap([]byte("k"), []byte("wrong"), []byte("v2"))
+	if err != nil || ok {
+		t.Fatalf("expected mismatched swap to fail, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = kv.CompareAndSwap([]byte("k"), []byte("v1"), []byte("v2"))
+	if err != nil || !ok {
+		t.Fatalf("expected swap to succeed, got ok=%v err=%v", ok, err)
+	}
+
+	v, err := kv.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "v2" {
+		t.Errorf("expected 'v2', got %q", v)
+	}
+
+	// Only the two successful swaps were recorded
+	ops, err := getUnsyncedOps(kv.db, 10)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Errorf("expected 2 ops, got %d", len(ops))
+	}
+	pending, err := hasPendingOps(kv.db)
+	if err != nil {
+		t.Fatalf("hasPendingOps failed: %v", err)
+	}
+	if !pending {
+		t.Error("expected pending ops after swap")
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	kv := newTestKV(t)
+
+	ok, err := kv.CompareAndDelete([]byte("k"), []byte("v"))
+	if err != nil || ok {
+		t.Fatalf("expected delete of missing key to fail, got ok=%v err=%v", ok, err)
+	}
+
+	if err := kv.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	ok, err = kv.CompareAndDelete([]byte("k"), []byte("wrong"))
+	if err != nil || ok {
+		t.Fatalf("expected mismatched delete to fail, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = kv.CompareAndDelete([]byte("k"), []byte("v"))
+	if err != nil || !ok {
+		t.Fatalf("expected delete to succeed, got ok=%v err=%v", ok, err)
+	}
+	if _, err := kv.Get([]byte("k")); err != ErrMissingKey {
+		t.Errorf("expected ErrMissingKey after delete, got %v", err)
+	}
+}
+
+func TestCompareAndSwap_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	if _, err := kv.CompareAndSwap([]byte("k"), nil, []byte("v")); !IsReadOnly(err) {
+		t.Errorf("expected read-only error from CompareAndSwap, got %v", err)
+	}
+	if _, err := kv.CompareAndDelete([]byte("k"), []byte("v")); !IsReadOnly(err) {
+		t.Errorf("expected read-only error from CompareAndDelete, got %v", err)
+	}
+}
+
+func TestCompareAndSwap_ConcurrentCounter(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("counter"), []byte("0")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	const workers, increments = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				cur, err := kv.Get([]byte("counter"))
+				if err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+				n, _ := strconv.Atoi(string(cur))
+				ok, err := kv.CompareAndSwap([]byte("counter"), cur, []byte(strconv.Itoa(n+1)))
+				if err != nil {
+					t.Errorf("CompareAndSwap failed: %v", err)
+					return
+				}
+				if ok {
+					i++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, err := kv.Get([]byte("counter"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != strconv.Itoa(workers*increments) {
+		t.Errorf("expected counter %d, got %s", workers*increments, v)
+	}
+}
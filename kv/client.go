@@ -1,7 +1,6 @@
 package kv
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -15,7 +14,7 @@ import (
 )
 
 type kvFile struct {
-	data *bytes.Buffer
+	data io.Reader
 	info *kvFileInfo
 }
 
@@ -69,12 +68,12 @@ func (kv *KV) seqStorageKey(seq uint64) string {
 	return strings.Join([]string{kv.name, fmt.Sprintf("%d", seq)}, "/")
 }
 
-func (kv *KV) backupSeq(from uint64, at uint64) error {
+func (kv *KV) backupSeq(from uint64, at uint64, progress *syncProgress) error {
 	// Use manifest-based backup for idempotent uploads
-	return kv.backupWithManifest(at)
+	return kv.backupWithManifest(at, progress)
 }
 
-func (kv *KV) restoreSeq(seq uint64) error {
+func (kv *KV) restoreSeq(seq uint64, progress *syncProgress) error {
 	// there is never a zero seq
 	if seq == 0 {
 		return nil
@@ -97,6 +96,7 @@ func (kv *KV) restoreSeq(seq uint64) error {
 	if err != nil {
 		return fmt.Errorf("failed to read backup: %w", err)
 	}
+	progress.transfer(SyncDownloading, int64(len(data)), int64(len(data)))
 
 	// Validate SQLite magic bytes before restoring.
 	// Old BadgerDB backups from before the SQLite migration will fail here.
@@ -106,6 +106,15 @@ func (kv *KV) restoreSeq(seq uint64) error {
 		return ErrNotSQLite
 	}
 
+	// Remember the current values so watchers can be told what changed
+	var before map[string][]byte
+	if kv.hasWatchers() {
+		before, err = sqliteSnapshotValues(kv.db)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Close current DB
 	if err := kv.db.Close(); err != nil {
 		return err
@@ -126,6 +135,20 @@ func (kv *KV) restoreSeq(seq uint64) error {
 		return err
 	}
 	kv.db = db
+
+	// A snapshot taken during Sync carries the creating machine's sync lock
+	// lease, which would lock this machine out until it expires.
+	if _, err := db.Exec("DELETE FROM sync_lock"); err != nil {
+		return fmt.Errorf("failed to clear restored sync lock: %w", err)
+	}
+
+	if before != nil {
+		after, err := sqliteSnapshotValues(db)
+		if err != nil {
+			return err
+		}
+		kv.notifyDiff(before, after)
+	}
 	return nil
 }
 
@@ -171,19 +194,28 @@ func (kv *KV) nextSeqWithContext(ctx context.Context, name string) (uint64, erro
 //
 // If old BadgerDB backups are found (from before the SQLite migration),
 // they are automatically cleaned up and skipped.
+//
+// Returns an *ErrSyncDivergence if the newest backup is older than the last
+// one uploaded from this machine.
 func (kv *KV) syncFromWithContext(ctx context.Context, mv uint64) error {
+	// Don't restore or upload over a cloud that was rolled back behind us
+	if err := kv.checkDivergence(); err != nil {
+		return err
+	}
+
 	// Try manifest-based sync first (new format)
 	manifest, manifestErr := kv.loadManifest()
+	progress := syncProgressFrom(ctx)
 	if manifestErr == nil && manifest.LatestSeq > mv {
-		return kv.syncFromManifest(manifest, mv)
+		return kv.syncFromManifest(manifest, mv, progress)
 	}
 
 	// Fall back to directory scan for backward compatibility with old backups
-	return kv.syncFromDirectoryScan(mv)
+	return kv.syncFromDirectoryScan(mv, progress)
 }
 
 // syncFromManifest syncs using the manifest file (new format).
-func (kv *KV) syncFromManifest(manifest *Manifest, mv uint64) error {
+func (kv *KV) syncFromManifest(manifest *Manifest, mv uint64, progress *syncProgress) error {
 	// Get the latest backup that's newer than our version
 	latest := manifest.LatestBackup()
 	if latest == nil || latest.Seq <= mv {
@@ -191,7 +223,8 @@ func (kv *KV) syncFromManifest(manifest *Manifest, mv uint64) error {
 	}
 
 	// Restore the latest backup
-	if err := kv.restoreSeq(latest.Seq); err != nil {
+	progress.steps(0, 1)
+	if err := kv.restoreSeq(latest.Seq, progress); err != nil {
 		if err == ErrNotSQLite {
 			// Corrupted backup in manifest - this shouldn't happen with new backups
 			// but handle it gracefully
@@ -199,6 +232,7 @@ func (kv *KV) syncFromManifest(manifest *Manifest, mv uint64) error {
 		}
 		return err
 	}
+	progress.steps(1, 1)
 
 	// Update max_version to reflect the sequence we restored
 	if err := kv.setMaxVersion(latest.Seq); err != nil {
@@ -209,7 +243,7 @@ func (kv *KV) syncFromManifest(manifest *Manifest, mv uint64) error {
 }
 
 // syncFromDirectoryScan syncs using directory listing (old format, backward compatible).
-func (kv *KV) syncFromDirectoryScan(mv uint64) error {
+func (kv *KV) syncFromDirectoryScan(mv uint64, progress *syncProgress) error {
 	seqDir, err := kv.fs.ReadDir(kv.name)
 	if err != nil {
 		return err
@@ -248,7 +282,8 @@ func (kv *KV) syncFromDirectoryScan(mv uint64) error {
 	}
 
 	// Restore only the latest backup
-	if err := kv.restoreSeq(maxSeq); err != nil {
+	progress.steps(0, 1)
+	if err := kv.restoreSeq(maxSeq, progress); err != nil {
 		// If this is an old BadgerDB backup, skip it and clean up all old backups
 		if err == ErrNotSQLite {
 			// Clean up remaining old backups
@@ -262,6 +297,7 @@ func (kv *KV) syncFromDirectoryScan(mv uint64) error {
 		}
 		return err
 	}
+	progress.steps(1, 1)
 
 	// Update max_version to reflect the sequence we restored
 	if err := kv.setMaxVersion(maxSeq); err != nil {
@@ -0,0 +1,138 @@
+// ABOUTME: Tests for database compaction.
+// ABOUTME: Verifies Compact reclaims free pages, respects the sync lock, and auto-compacts on Close.
+package kv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fillAndDelete writes n large values and deletes all but one, leaving free
+// pages in the database file. The op-log keeps its copy of each value, so
+// the file doesn't become mostly free.
+func fillAndDelete(t *testing.T, kv *KV, n int) {
+	t.Helper()
+	value := bytes.Repeat([]byte("x"), 16*1024)
+	for i := 0; i < n; i++ {
+		if err := kv.setWithOpLog(context.Background(), []byte(fmt.Sprintf("key-%d", i)), value, 0); err != nil {
+			t.Fatalf("setWithOpLog failed: %v", err)
+		}
+	}
+	for i := 1; i < n; i++ {
+		if err := kv.deleteWithOpLog(context.Background(), []byte(fmt.Sprintf("key-%d", i))); err != nil {
+			t.Fatalf("deleteWithOpLog failed: %v", err)
+		}
+	}
+	if err := sqliteCheckpoint(kv.db); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	kv := newTestKV(t)
+	fillAndDelete(t, kv, 50)
+
+	ratio, err := sqliteFreelistRatio(kv.db)
+	if err != nil {
+		t.Fatalf("sqliteFreelistRatio failed: %v", err)
+	}
+	if ratio < 0.1 {
+		t.Fatalf("expected free pages before compaction, got ratio %.2f", ratio)
+	}
+	before, err := kv.DiskSize()
+	if err != nil {
+		t.Fatalf("DiskSize failed: %v", err)
+	}
+
+	if err := kv.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	ratio, err = sqliteFreelistRatio(kv.db)
+	if err != nil {
+		t.Fatalf("sqliteFreelistRatio failed: %v", err)
+	}
+	if ratio != 0 {
+		t.Errorf("expected no free pages after compaction, got ratio %.2f", ratio)
+	}
+	after, err := kv.DiskSize()
+	if err != nil {
+		t.Fatalf("DiskSize failed: %v", err)
+	}
+	if after >= before {
+		t.Errorf("expected DiskSize to shrink, got %d -> %d", before, after)
+	}
+
+	if _, err := sqliteGet(kv.db, []byte("key-0")); err != nil {
+		t.Errorf("expected surviving key after compaction, got %v", err)
+	}
+}
+
+func TestCompact_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	fillAndDelete(t, kv, 20)
+	kv.readOnly = true
+
+	if err := kv.Compact(); !IsReadOnly(err) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+	ratio, err := sqliteFreelistRatio(kv.db)
+	if err != nil {
+		t.Fatalf("sqliteFreelistRatio failed: %v", err)
+	}
+	if ratio == 0 {
+		t.Error("expected read-only Compact to leave the database untouched")
+	}
+}
+
+func TestCompact_SyncLockHeld(t *testing.T) {
+	kv := newTestKV(t)
+
+	holder, err := acquireSyncLock(kv.db)
+	if err != nil {
+		t.Fatalf("acquireSyncLock failed: %v", err)
+	}
+	if err := kv.Compact(); err != ErrSyncLockHeld {
+		t.Errorf("expected ErrSyncLockHeld while syncing, got %v", err)
+	}
+
+	if err := releaseSyncLock(kv.db, holder); err != nil {
+		t.Fatalf("releaseSyncLock failed: %v", err)
+	}
+	if err := kv.Compact(); err != nil {
+		t.Errorf("Compact after sync failed: %v", err)
+	}
+}
+
+func TestAutoCompactOnClose(t *testing.T) {
+	kv := newTestKV(t)
+	kv.autoCompactThreshold = 0.25
+	fillAndDelete(t, kv, 50)
+
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err := openSQLite(kv.dbPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	ratio, err := sqliteFreelistRatio(db)
+	if err != nil {
+		t.Fatalf("sqliteFreelistRatio failed: %v", err)
+	}
+	if ratio != 0 {
+		t.Errorf("expected Close to compact, got free page ratio %.2f", ratio)
+	}
+}
+
+func TestWithAutoCompact(t *testing.T) {
+	cfg := &Config{}
+	WithAutoCompact(0.3)(cfg)
+	if cfg.autoCompactThreshold != 0.3 {
+		t.Errorf("expected threshold 0.3, got %v", cfg.autoCompactThreshold)
+	}
+}
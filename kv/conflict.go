@@ -0,0 +1,145 @@
+// ABOUTME: Conflict reporting and resolution for incremental sync
+// ABOUTME: Describes remote ops that collide with a different local value and merges them
+
+package kv
+
+import (
+	"bytes"
+	"context"
+)
+
+// ConflictWinner says which side of a conflict was kept.
+type ConflictWinner int
+
+const (
+	// LocalWins means the local value was newer and was kept.
+	LocalWins ConflictWinner = iota + 1
+
+	// RemoteWins means the remote op was newer and replaced the local value.
+	RemoteWins
+
+	// Merged means a ConflictResolver replaced both values with its own.
+	Merged
+)
+
+// String returns "local", "remote" or "merged".
+func (w ConflictWinner) String() string {
+	switch w {
+	case LocalWins:
+		return "local"
+	case RemoteWins:
+		return "remote"
+	case Merged:
+		return "merged"
+	default:
+		return "unknown"
+	}
+}
+
+// Conflict describes a remote op that targeted a key which already held a
+// different value locally. Last-write-wins by HLC timestamp, or the
+// ConflictResolver, has already been applied by the time a Conflict is
+// reported; Winner says which value was kept.
+type Conflict struct {
+	// Key is the key both sides wrote.
+	Key []byte
+
+	// LocalValue is the decrypted value held locally before the remote op.
+	LocalValue []byte
+
+	// RemoteValue is the decrypted value from the remote op. Nil for deletes.
+	RemoteValue []byte
+
+	// LocalHLC is the HLC timestamp of the latest local op for the key.
+	// Zero if the local value came from a snapshot with no op history.
+	LocalHLC int64
+
+	// RemoteHLC is the HLC timestamp of the remote op.
+	RemoteHLC int64
+
+	// Winner is the side whose value the key now holds, or Merged if it holds
+	// the value returned by a ConflictResolver.
+	Winner ConflictWinner
+}
+
+// ConflictHandler is called for every conflict found while applying remote
+// ops during Sync. It runs after the op's transaction has committed, so it
+// may safely read from or write to the KV store.
+type ConflictHandler func(c Conflict)
+
+// ConflictResolver returns the value a key should hold when a remote op
+// conflicts with its local value. local and remote are decrypted, and remote
+// is nil if the remote op is a delete; returning nil deletes the key. It runs
+// during Sync, after the op's transaction has committed, and must not call
+// Sync. Every machine resolves the conflicts it sees, so the resolver should
+// give the same result for the same values, such as the union of two sets.
+type ConflictResolver func(key, local, remote []byte) []byte
+
+// resolveConflict replaces the value last-write-wins kept for c with the one
+// returned by resolver, writing it with the remote op's expiry as a local op
+// so it's uploaded on the next Sync. It reports whether it wrote a value, in
+// which case c.Winner is set to Merged and watchers have been notified.
+// Nothing is written if the resolved value is the one already kept, or if
+// either value can't be decrypted.
+func (kv *KV) resolveConflict(resolver ConflictResolver, op *Op, c *Conflict) (bool, error) {
+	if resolver == nil || c == nil {
+		return false, nil
+	}
+	local, err := kv.decryptValue(c.LocalValue)
+	if err != nil {
+		return false, nil
+	}
+	var remote []byte
+	if c.RemoteValue != nil {
+		if remote, err = kv.decryptValue(c.RemoteValue); err != nil {
+			return false, nil
+		}
+	}
+	kept := local
+	if c.Winner == RemoteWins {
+		kept = remote
+	}
+
+	ctx := context.Background()
+	merged := resolver(c.Key, local, remote)
+	switch {
+	case merged == nil && kept == nil:
+		return false, nil
+	case merged == nil:
+		if err := kv.deleteWithOpLog(ctx, c.Key); err != nil {
+			return false, err
+		}
+		kv.notify(KeyEvent{Key: c.Key, Type: KeyDeleted})
+	case kept != nil && bytes.Equal(merged, kept):
+		return false, nil
+	default:
+		encValue, err := kv.encryptValue(merged)
+		if err != nil {
+			return false, err
+		}
+		var expiresAt int64
+		if op.OpType == "set" {
+			expiresAt = op.ExpiresAt
+		}
+		if err := kv.setWithOpLog(ctx, c.Key, encValue, expiresAt); err != nil {
+			return false, err
+		}
+		kv.notify(KeyEvent{Key: c.Key, Value: merged, Type: KeySet})
+	}
+	c.Winner = Merged
+	return true, nil
+}
+
+// reportConflict decrypts a conflict's values and passes it to handler.
+// Values that can't be decrypted are reported as nil.
+func (kv *KV) reportConflict(handler ConflictHandler, c *Conflict) {
+	if handler == nil || c == nil {
+		return
+	}
+	report := *c
+	report.LocalValue, _ = kv.decryptValue(c.LocalValue)
+	if c.RemoteValue != nil {
+		report.RemoteValue, _ = kv.decryptValue(c.RemoteValue)
+	}
+	handler(report)
+}
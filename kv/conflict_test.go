@@ -0,0 +1,302 @@
+// ABOUTME: Tests for conflict reporting and resolution while applying remote ops.
+// ABOUTME: Verifies which writes count as conflicts, which side wins, and merging.
+package kv
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// remoteSetOp builds a set op as another device would have written it.
+func remoteSetOp(t *testing.T, kv *KV, key, value string, hlc int64) *Op {
+	t.Helper()
+	enc, err := kv.encryptValue([]byte(value))
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+	return &Op{
+		OpID:         newOpID(),
+		OpType:       "set",
+		Key:          []byte(key),
+		Value:        enc,
+		HLCTimestamp: hlc,
+		DeviceID:     "remote-device",
+		Synced:       true,
+	}
+}
+
+func TestConflictHandler_RemoteWins(t *testing.T) {
+	kv := newTestKV(t)
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	if err := kv.Set([]byte("k"), []byte("local")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	localHLC, err := getLatestHLCForKey(kv.db, []byte("k"))
+	if err != nil {
+		t.Fatalf("getLatestHLCForKey failed: %v", err)
+	}
+
+	op := remoteSetOp(t, kv, "k", "remote", localHLC+1)
+	if err := kv.applyRemoteOp(op); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(got))
+	}
+	c := got[0]
+	if string(c.Key) != "k" || string(c.LocalValue) != "local" || string(c.RemoteValue) != "remote" {
+		t.Errorf("unexpected conflict values: %+v", c)
+	}
+	if c.LocalHLC != localHLC || c.RemoteHLC != localHLC+1 {
+		t.Errorf("unexpected conflict HLCs: local=%d remote=%d", c.LocalHLC, c.RemoteHLC)
+	}
+	if c.Winner != RemoteWins {
+		t.Errorf("expected remote to win, got %s", c.Winner)
+	}
+
+	v, err := kv.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "remote" {
+		t.Errorf("expected 'remote', got %q", v)
+	}
+}
+
+func TestConflictHandler_LocalWins(t *testing.T) {
+	kv := newTestKV(t)
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	if err := kv.Set([]byte("k"), []byte("local")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	op := remoteSetOp(t, kv, "k", "stale", 1)
+	if err := kv.applyRemoteOp(op); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Winner != LocalWins {
+		t.Fatalf("expected 1 conflict won by local, got %+v", got)
+	}
+
+	v, err := kv.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "local" {
+		t.Errorf("expected 'local', got %q", v)
+	}
+}
+
+func TestConflictHandler_RemoteDelete(t *testing.T) {
+	kv := newTestKV(t)
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	if err := kv.Set([]byte("k"), []byte("local")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	op := &Op{
+		OpID:         newOpID(),
+		OpType:       "delete",
+		Key:          []byte("k"),
+		HLCTimestamp: kv.hlc.Now(),
+		DeviceID:     "remote-device",
+		Synced:       true,
+	}
+	if err := kv.applyRemoteOp(op); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(got))
+	}
+	if got[0].RemoteValue != nil || got[0].Winner != RemoteWins {
+		t.Errorf("unexpected conflict for delete: %+v", got[0])
+	}
+}
+
+func TestConflictHandler_NoConflict(t *testing.T) {
+	kv := newTestKV(t)
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	// New key: nothing to conflict with
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "new", "v", kv.hlc.Now())); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	// Same value on both sides is not a conflict
+	if err := kv.Set([]byte("same"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "same", "v", kv.hlc.Now())); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Errorf("expected no conflicts, got %+v", got)
+	}
+}
+
+func TestConflictHandler_HandlerCanWrite(t *testing.T) {
+	kv := newTestKV(t)
+
+	// The handler runs outside the apply transaction, so writing from it
+	// must not deadlock
+	kv.conflictHandler = func(c Conflict) {
+		merged := append(append([]byte{}, c.LocalValue...), c.RemoteValue...)
+		if err := kv.Set(append([]byte("merged:"), c.Key...), merged); err != nil {
+			t.Errorf("Set from handler failed: %v", err)
+		}
+	}
+
+	if err := kv.Set([]byte("k"), []byte("a")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "k", "b", kv.hlc.Now())); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	v, err := kv.Get([]byte("merged:k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "ab" {
+		t.Errorf("expected 'ab', got %q", v)
+	}
+}
+
+func TestWithConflictHandler(t *testing.T) {
+	called := false
+	cfg := &Config{}
+	WithConflictHandler(func(Conflict) { called = true })(cfg)
+	if cfg.conflictHandler == nil {
+		t.Fatal("expected conflict handler to be set")
+	}
+	cfg.conflictHandler(Conflict{})
+	if !called {
+		t.Error("expected configured handler to be called")
+	}
+}
+
+// unionResolver merges comma-separated values, keeping each item once.
+func unionResolver(_, local, remote []byte) []byte {
+	seen := map[string]bool{}
+	var items []string
+	for _, v := range [][]byte{local, remote} {
+		for _, item := range strings.Split(string(v), ",") {
+			if item != "" && !seen[item] {
+				seen[item] = true
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Strings(items)
+	return []byte(strings.Join(items, ","))
+}
+
+func TestConflictResolver_Merges(t *testing.T) {
+	kv := newTestKV(t)
+	kv.conflictResolver = unionResolver
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	if err := kv.Set([]byte("k"), []byte("a,b")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := markOpsSynced(kv.db, mustUnsyncedOpIDs(t, kv)); err != nil {
+		t.Fatalf("markOpsSynced failed: %v", err)
+	}
+	remoteHLC := kv.hlc.Now()
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "k", "b,c", remoteHLC)); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	v, err := kv.Get([]byte("k"))
+	if err != nil || string(v) != "a,b,c" {
+		t.Errorf("Get = %q, %v, want %q", v, err, "a,b,c")
+	}
+	if len(got) != 1 || got[0].Winner != Merged {
+		t.Fatalf("expected 1 merged conflict, got %+v", got)
+	}
+
+	// The merged value is uploaded on the next Sync and beats the remote op
+	ops, err := getUnsyncedOps(kv.db, 10)
+	if err != nil || len(ops) != 1 {
+		t.Fatalf("getUnsyncedOps returned %d ops, %v, want 1", len(ops), err)
+	}
+	if ops[0].OpType != "set" || ops[0].HLCTimestamp <= remoteHLC {
+		t.Errorf("unexpected merged op: %+v", ops[0])
+	}
+}
+
+func TestConflictResolver_KeptValue(t *testing.T) {
+	kv := newTestKV(t)
+	kv.conflictResolver = unionResolver
+	var got []Conflict
+	kv.conflictHandler = func(c Conflict) { got = append(got, c) }
+
+	if err := kv.Set([]byte("k"), []byte("a")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := markOpsSynced(kv.db, mustUnsyncedOpIDs(t, kv)); err != nil {
+		t.Fatalf("markOpsSynced failed: %v", err)
+	}
+	// The remote already includes the local value, so nothing needs writing
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "k", "a,b", kv.hlc.Now())); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Winner != RemoteWins {
+		t.Fatalf("expected 1 conflict won by remote, got %+v", got)
+	}
+	ops, err := getUnsyncedOps(kv.db, 10)
+	if err != nil || len(ops) != 0 {
+		t.Errorf("getUnsyncedOps returned %d ops, %v, want 0", len(ops), err)
+	}
+}
+
+func TestConflictResolver_NilDeletes(t *testing.T) {
+	kv := newTestKV(t)
+	kv.conflictResolver = func(_, _, _ []byte) []byte { return nil }
+
+	if err := kv.Set([]byte("k"), []byte("local")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.applyRemoteOp(remoteSetOp(t, kv, "k", "remote", 1)); err != nil {
+		t.Fatalf("applyRemoteOp failed: %v", err)
+	}
+	if _, err := kv.Get([]byte("k")); err != ErrMissingKey {
+		t.Errorf("expected the key to be deleted, got %v", err)
+	}
+}
+
+func TestWithConflictResolver(t *testing.T) {
+	cfg := &Config{}
+	WithConflictResolver(unionResolver)(cfg)
+	if cfg.conflictResolver == nil {
+		t.Fatal("expected conflict resolver to be set")
+	}
+}
+
+// mustUnsyncedOpIDs returns the IDs of the store's unsynced ops.
+func mustUnsyncedOpIDs(t *testing.T, kv *KV) []string {
+	t.Helper()
+	ops, err := getUnsyncedOps(kv.db, -1)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+	ids := make([]string, len(ops))
+	for i, op := range ops {
+		ids[i] = op.OpID
+	}
+	return ids
+}
@@ -0,0 +1,73 @@
+// ABOUTME: Tests for the context-aware Get, Set, and Delete variants.
+// ABOUTME: Verifies they behave like the plain methods and honor cancellation.
+package kv
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestContextMethods(t *testing.T) {
+	kv := newTestKV(t)
+	ctx := context.Background()
+
+	if err := kv.SetContext(ctx, []byte("k"), []byte("v")); err != nil {
+		t.Fatalf("SetContext failed: %v", err)
+	}
+	v, err := kv.GetContext(ctx, []byte("k"))
+	if err != nil {
+		t.Fatalf("GetContext failed: %v", err)
+	}
+	if string(v) != "v" {
+		t.Errorf("expected %q, got %q", "v", v)
+	}
+	if err := kv.DeleteContext(ctx, []byte("k")); err != nil {
+		t.Fatalf("DeleteContext failed: %v", err)
+	}
+	if _, err := kv.GetContext(ctx, []byte("k")); err != ErrMissingKey {
+		t.Errorf("expected ErrMissingKey, got %v", err)
+	}
+}
+
+func TestContextMethods_Cancelled(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := kv.GetContext(ctx, []byte("k")); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetContext: expected context.Canceled, got %v", err)
+	}
+	if err := kv.SetContext(ctx, []byte("k"), []byte("new")); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetContext: expected context.Canceled, got %v", err)
+	}
+	if err := kv.DeleteContext(ctx, []byte("k")); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext: expected context.Canceled, got %v", err)
+	}
+
+	// Nothing was written
+	v, err := kv.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "v" {
+		t.Errorf("expected %q, got %q", "v", v)
+	}
+}
+
+func TestContextMethods_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	var roErr *ErrReadOnlyMode
+	if err := kv.SetContext(context.Background(), []byte("k"), []byte("v")); !errors.As(err, &roErr) {
+		t.Errorf("SetContext: expected ErrReadOnlyMode, got %v", err)
+	}
+	if err := kv.DeleteContext(context.Background(), []byte("k")); !errors.As(err, &roErr) {
+		t.Errorf("DeleteContext: expected ErrReadOnlyMode, got %v", err)
+	}
+}
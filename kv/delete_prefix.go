@@ -0,0 +1,76 @@
+// ABOUTME: Prefix deletes for the KV store
+// ABOUTME: Removes every key in a namespace with op-log entries so deletes sync
+
+package kv
+
+import "fmt"
+
+// deletePrefixChunk is how many keys DeletePrefix removes per transaction.
+const deletePrefixChunk = 1000
+
+// DeletePrefix deletes every key that starts with prefix and returns how
+// many were removed. An empty prefix deletes all keys. Each deletion is
+// recorded in the op-log, so it reaches other machines on the next Sync.
+//
+// Keys are deleted in transactions of up to 1000 keys so a large prefix
+// doesn't hold the write lock for long. If an error occurs partway through,
+// the keys in earlier chunks stay deleted and the count reflects them.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) DeletePrefix(prefix []byte) (int, error) {
+	if kv.readOnly {
+		return 0, &ErrReadOnlyMode{Operation: "delete prefix"}
+	}
+
+	total := 0
+	for {
+		n, err := kv.deletePrefixChunk(prefix)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < deletePrefixChunk {
+			break
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	// The whole prefix counts as a single write towards the backup threshold
+	return total, kv.syncAfterWrite()
+}
+
+// deletePrefixChunk deletes up to deletePrefixChunk keys starting with
+// prefix in a single transaction.
+func (kv *KV) deletePrefixChunk(prefix []byte) (int, error) {
+	// Take the write lock up front, as upgrading a read transaction fails
+	// at once if another writer got there first
+	tx, err := beginWriteTx(kv.db)
+	if err != nil {
+		return 0, err
+	}
+
+	keys, err := sqlitePrefixKeys(tx, prefix, deletePrefixChunk)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if len(keys) == 0 {
+		_ = tx.Rollback()
+		return 0, nil
+	}
+
+	for _, key := range keys {
+		if err := kv.deleteTx(tx, key); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, key := range keys {
+		kv.notify(KeyEvent{Key: key, Type: KeyDeleted})
+	}
+	return len(keys), nil
+}
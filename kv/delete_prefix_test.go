@@ -0,0 +1,193 @@
+// ABOUTME: Tests for DeletePrefix namespace deletes.
+// ABOUTME: Verifies matching, chunking, op-log entries, watch events, and read-only mode.
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	kv := newTestKV(t)
+	for _, k := range []string{"user:1:name", "user:1:email", "user:10:name", "user:2:name", "user:"} {
+		if err := kv.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	n, err := kv.DeletePrefix([]byte("user:1:"))
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeletePrefix removed %d keys, want 2", n)
+	}
+
+	keys, err := kv.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	got := map[string]bool{}
+	for _, k := range keys {
+		got[string(k)] = true
+	}
+	for _, k := range []string{"user:10:name", "user:2:name", "user:"} {
+		if !got[k] {
+			t.Errorf("expected %q to survive", k)
+		}
+	}
+	if len(keys) != 3 {
+		t.Errorf("expected 3 keys left, got %d", len(keys))
+	}
+
+	// Nothing left to delete
+	n, err = kv.DeletePrefix([]byte("user:1:"))
+	if err != nil || n != 0 {
+		t.Errorf("second DeletePrefix = (%d, %v), want (0, nil)", n, err)
+	}
+}
+
+func TestDeletePrefix_OpLogAndWatch(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("tmp:a"), []byte("1")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.Set([]byte("tmp:b"), []byte("2")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := kv.Watch(ctx, []byte("tmp:"))
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	if _, err := kv.DeletePrefix([]byte("tmp:")); err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+
+	for _, want := range []string{"tmp:a", "tmp:b"} {
+		ev := nextEvent(t, ch)
+		if string(ev.Key) != want || ev.Type != KeyDeleted {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	}
+
+	ops, err := getUnsyncedOps(kv.db, -1)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+	deletes := 0
+	for _, op := range ops {
+		if op.OpType == "delete" && bytes.HasPrefix(op.Key, []byte("tmp:")) {
+			deletes++
+		}
+	}
+	if deletes != 2 {
+		t.Errorf("expected 2 delete ops, got %d", deletes)
+	}
+}
+
+func TestDeletePrefix_Chunked(t *testing.T) {
+	kv := newTestKV(t)
+	b := kv.Batch()
+	total := deletePrefixChunk*2 + 5
+	for i := 0; i < total; i++ {
+		_ = b.Set([]byte(fmt.Sprintf("bulk:%05d", i)), []byte("v"))
+	}
+	_ = b.Set([]byte("keep"), []byte("v"))
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	n, err := kv.DeletePrefix([]byte("bulk:"))
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if n != total {
+		t.Errorf("DeletePrefix removed %d keys, want %d", n, total)
+	}
+	if count, _ := kv.Count(); count != 1 {
+		t.Errorf("expected 1 key left, got %d", count)
+	}
+}
+
+func TestDeletePrefix_WaitsForOtherWriters(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("user:1"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// Another process is writing when the prefix is deleted
+	other, err := openSQLite(kv.dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer other.Close() // nolint:errcheck
+	tx, err := beginWriteTx(other)
+	if err != nil {
+		t.Fatalf("beginWriteTx failed: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = tx.Exec("INSERT INTO kv (key, value) VALUES (?, ?)", []byte("other"), []byte("v"))
+		_ = tx.Commit()
+	}()
+
+	n, err := kv.DeletePrefix([]byte("user:"))
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 key deleted, got %d", n)
+	}
+}
+
+func TestDeletePrefix_Empty(t *testing.T) {
+	kv := newTestKV(t)
+	for _, k := range [][]byte{[]byte("a"), {0xff, 0xff}, {0x00}} {
+		if err := kv.Set(k, []byte("v")); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	n, err := kv.DeletePrefix(nil)
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("DeletePrefix removed %d keys, want 3", n)
+	}
+}
+
+func TestDeletePrefix_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	var roErr *ErrReadOnlyMode
+	if _, err := kv.DeletePrefix([]byte("x")); !errors.As(err, &roErr) {
+		t.Errorf("expected ErrReadOnlyMode, got %v", err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{nil, nil},
+		{[]byte("a"), []byte("b")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{0xff, 0xff}, nil},
+	}
+	for _, tt := range tests {
+		if got := prefixEnd(tt.prefix); !bytes.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("prefixEnd(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
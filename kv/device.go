@@ -0,0 +1,87 @@
+// ABOUTME: Per-machine device IDs for the op-log
+// ABOUTME: Derives a stable ID from the hostname and a random ID kept in the data directory
+
+package kv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deviceIDFile is the file in the kv data directory holding the random part
+// of this machine's device ID.
+const deviceIDFile = "device_id"
+
+// machineDeviceID returns a stable device ID for this machine, shared by
+// every database in dir. It hashes the hostname with a random ID stored in
+// dir, created on first use, so machines on the same account get different
+// IDs and a data directory copied to another machine doesn't keep its ID.
+func machineDeviceID(dir string) (string, error) {
+	secret, err := readOrCreateDeviceSecret(filepath.Join(dir, deviceIDFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to get device ID: %w", err)
+	}
+	host, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(host + "\n" + secret))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// readOrCreateDeviceSecret returns the random ID stored at p, storing a new
+// one if there is none yet. An empty file, left by a process that crashed
+// before this was written atomically, counts as none.
+func readOrCreateDeviceSecret(p string) (string, error) {
+	secret, err := readDeviceSecret(p)
+	if err != nil || secret != "" {
+		return secret, err
+	}
+
+	// Write the new ID aside and move it into place in one step, so no
+	// process ever reads a partly written file
+	f, err := os.CreateTemp(filepath.Dir(p), deviceIDFile+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name()) // nolint:errcheck
+	secret = newOpID()
+	if _, err := f.WriteString(secret + "\n"); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	err = os.Link(f.Name(), p)
+	if err == nil {
+		return secret, nil
+	}
+	if errors.Is(err, os.ErrExist) {
+		existing, err := readDeviceSecret(p)
+		if err != nil || existing != "" {
+			// Another process got there first
+			return existing, err
+		}
+	}
+	// Replace an empty file, or store the ID where hard links aren't
+	// supported, then read back whichever ID won
+	if err := os.Rename(f.Name(), p); err != nil {
+		return "", err
+	}
+	return readDeviceSecret(p)
+}
+
+// readDeviceSecret returns the random ID stored at p, or "" if there is none.
+func readDeviceSecret(p string) (string, error) {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
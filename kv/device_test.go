@@ -0,0 +1,91 @@
+// ABOUTME: Tests for per-machine device IDs.
+// ABOUTME: Verifies IDs are stable per data directory, distinct across them, and safe to create concurrently.
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMachineDeviceID_Stable(t *testing.T) {
+	dir := t.TempDir()
+	id, err := machineDeviceID(dir)
+	if err != nil {
+		t.Fatalf("machineDeviceID failed: %v", err)
+	}
+	if len(id) != 32 {
+		t.Errorf("expected a 32 character ID, got %q", id)
+	}
+	again, err := machineDeviceID(dir)
+	if err != nil {
+		t.Fatalf("machineDeviceID failed: %v", err)
+	}
+	if again != id {
+		t.Errorf("expected the same ID on reopen, got %q then %q", id, again)
+	}
+	if _, err := os.Stat(filepath.Join(dir, deviceIDFile)); err != nil {
+		t.Errorf("expected the device ID file to be stored: %v", err)
+	}
+}
+
+func TestMachineDeviceID_DiffersPerDataDir(t *testing.T) {
+	a, err := machineDeviceID(t.TempDir())
+	if err != nil {
+		t.Fatalf("machineDeviceID failed: %v", err)
+	}
+	b, err := machineDeviceID(t.TempDir())
+	if err != nil {
+		t.Fatalf("machineDeviceID failed: %v", err)
+	}
+	if a == b {
+		t.Errorf("expected different IDs for different data directories, got %q twice", a)
+	}
+}
+
+func TestMachineDeviceID_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	ids := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := machineDeviceID(dir)
+			if err != nil {
+				t.Errorf("machineDeviceID failed: %v", err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			t.Fatalf("expected every caller to get the same ID, got %q", ids)
+		}
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil || len(des) != 1 {
+		t.Errorf("expected only the device ID file to be left, got %v, %v", des, err)
+	}
+}
+
+func TestMachineDeviceID_EmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, deviceIDFile)
+	if err := os.WriteFile(p, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := readOrCreateDeviceSecret(p)
+	if err != nil {
+		t.Fatalf("readOrCreateDeviceSecret failed: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("expected an empty file to be replaced with a new ID")
+	}
+	again, err := readOrCreateDeviceSecret(p)
+	if err != nil || again != secret {
+		t.Errorf("readOrCreateDeviceSecret = %q, %v, want %q", again, err, secret)
+	}
+}
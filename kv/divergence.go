@@ -0,0 +1,84 @@
+// ABOUTME: Detection of cloud backups rolled back behind this machine
+// ABOUTME: Reports divergence on Sync and force-pushes the local database
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// pushedSeqMeta is the meta key holding the seq of the last snapshot backup
+// this machine uploaded.
+const pushedSeqMeta = "pushed_seq"
+
+// pushedSeq returns the seq of the last snapshot backup uploaded from here.
+func (kv *KV) pushedSeq() uint64 {
+	val, _ := sqliteGetMeta(kv.db, pushedSeqMeta)
+	return uint64(val)
+}
+
+// setPushedSeq records seq as the last snapshot backup uploaded from here.
+func (kv *KV) setPushedSeq(seq uint64) error {
+	return sqliteSetMeta(kv.db, pushedSeqMeta, int64(seq))
+}
+
+// checkDivergence returns an *ErrSyncDivergence if the newest snapshot backup
+// in the cloud is older than the last one this machine uploaded.
+func (kv *KV) checkDivergence() error {
+	pushed := kv.pushedSeq()
+	if pushed == 0 {
+		return nil
+	}
+	seqs, err := kv.ListBackups()
+	if err != nil {
+		return err
+	}
+	var remote uint64
+	if len(seqs) > 0 {
+		remote = seqs[len(seqs)-1]
+	}
+	if remote < pushed {
+		return &ErrSyncDivergence{LocalSeq: pushed, RemoteSeq: remote}
+	}
+	return nil
+}
+
+// ForcePush uploads the local database as the newest snapshot backup without
+// syncing from the cloud first, so other machines pick it up on their next
+// Sync. Use it to resolve an ErrSyncDivergence in favor of this machine;
+// RestoreSeq resolves it in favor of a cloud backup instead.
+//
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+// ForcePush is not supported with WithIncrementalSync.
+func (kv *KV) ForcePush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return kv.ForcePushWithContext(ctx)
+}
+
+// ForcePushWithContext is like ForcePush but gives up when ctx is done.
+func (kv *KV) ForcePushWithContext(ctx context.Context) error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "force push"}
+	}
+	if kv.incremental {
+		return errors.New("force push is not supported with incremental sync")
+	}
+
+	return withSyncLock(kv.db, func() error {
+		kv.backupMu.Lock()
+		kv.pendingWrites = 0
+		kv.backupMu.Unlock()
+
+		if err := kv.snapshotBackup(ctx); err != nil {
+			return fmt.Errorf("failed to upload backup: %w", err)
+		}
+		if err := clearPendingOps(kv.db); err != nil {
+			return fmt.Errorf("failed to clear pending ops: %w", err)
+		}
+		return kv.recordSyncTime()
+	})
+}
@@ -4,6 +4,7 @@
 package kv
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -174,3 +175,44 @@ func TestDoWithFallback(t *testing.T) {
 		t.Error("expected write access, got read-only")
 	}
 }
+
+// TestCheckpointOnClose verifies Close folds the WAL into the main database
+// file so a fresh handle sees the writes without it.
+func TestCheckpointOnClose(t *testing.T) {
+	kv := newTestKV(t)
+	kv.checkpointOnClose = true
+
+	// setWithOpLog skips the write counter, so Close won't try a cloud backup
+	if err := kv.setWithOpLog(context.Background(), []byte("key"), []byte("value"), 0); err != nil {
+		t.Fatalf("setWithOpLog failed: %v", err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if info, err := os.Stat(kv.dbPath + "-wal"); err == nil && info.Size() != 0 {
+		t.Errorf("expected WAL to be truncated, got %d bytes", info.Size())
+	}
+
+	db, err := openSQLite(kv.dbPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	v, err := sqliteGet(db, []byte("key"))
+	if err != nil {
+		t.Fatalf("Get after reopen failed: %v", err)
+	}
+	if string(v) != "value" {
+		t.Errorf("expected 'value', got %q", v)
+	}
+}
+
+// TestWithCheckpointOnClose verifies the option sets the config flag.
+func TestWithCheckpointOnClose(t *testing.T) {
+	cfg := &Config{}
+	WithCheckpointOnClose()(cfg)
+	if !cfg.checkpointOnClose {
+		t.Error("expected checkpointOnClose to be set")
+	}
+}
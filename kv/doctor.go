@@ -4,6 +4,7 @@
 package kv
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -11,44 +12,79 @@ import (
 )
 
 // DoctorResult contains the results of a health check on a KV database.
+// It marshals to JSON for consumption by monitoring tools.
 type DoctorResult struct {
 	// IntegrityOK is true if SQLite integrity_check passed.
-	IntegrityOK bool
+	IntegrityOK bool `json:"integrity_ok"`
 
 	// IntegrityDetails contains the raw output from PRAGMA integrity_check.
 	// Will be "ok" if healthy, or error details if corrupt.
-	IntegrityDetails string
+	IntegrityDetails string `json:"integrity_details"`
+
+	// JournalMode is the SQLite journal mode, normally "wal".
+	JournalMode string `json:"journal_mode"`
+
+	// PageCount is the number of pages in the database file.
+	PageCount int64 `json:"page_count"`
+
+	// FreelistCount is the number of unused pages that VACUUM would reclaim.
+	FreelistCount int64 `json:"freelist_count"`
 
 	// PendingOpsCount is the number of write operations waiting to be synced.
-	PendingOpsCount int64
+	PendingOpsCount int64 `json:"pending_ops"`
 
 	// OldestPendingOp is the timestamp of the oldest pending operation.
 	// Zero if no pending ops.
-	OldestPendingOp time.Time
+	OldestPendingOp time.Time `json:"oldest_pending_op"`
+
+	// UnsyncedOpsCount is the number of op-log entries not yet uploaded by
+	// incremental sync. Full snapshot sync doesn't upload the op-log, so
+	// without WithIncrementalSync this only grows; use PendingOpsCount instead.
+	UnsyncedOpsCount int64 `json:"unsynced_ops"`
 
 	// LocalSeq is the latest sequence number in the local database.
-	LocalSeq uint64
+	LocalSeq uint64 `json:"max_version"`
+
+	// LastSync is when the database last synced successfully. Zero if it
+	// never has, which is omitted from JSON.
+	LastSync time.Time `json:"last_sync,omitzero"`
 
 	// WALSize is the size of the WAL file in bytes, or -1 if not present.
-	WALSize int64
+	WALSize int64 `json:"wal_size"`
 
 	// SHMExists indicates if the shared memory file exists.
-	SHMExists bool
+	SHMExists bool `json:"shm_exists"`
 
 	// SyncLockHeld indicates if another process currently holds the sync lock.
-	SyncLockHeld bool
+	SyncLockHeld bool `json:"sync_lock_held"`
 
 	// SyncLockHolder is the holder ID if the lock is held.
-	SyncLockHolder string
+	SyncLockHolder string `json:"sync_lock_holder,omitempty"`
 
 	// SyncLockExpiresAt is when the current lock expires (if held).
-	SyncLockExpiresAt time.Time
+	SyncLockExpiresAt time.Time `json:"sync_lock_expires_at"`
 
 	// Errors contains any non-fatal errors encountered during the check.
-	Errors []string
+	Errors []string `json:"errors"`
 
 	// Warnings contains advisory messages that aren't errors.
-	Warnings []string
+	Warnings []string `json:"warnings"`
+}
+
+// DoctorReport is an alias for DoctorResult.
+type DoctorReport = DoctorResult
+
+// MarshalJSON serializes the result with an additional "healthy" field
+// reporting IsHealthy.
+func (r *DoctorResult) MarshalJSON() ([]byte, error) {
+	type resultAlias DoctorResult
+	return json.Marshal(struct {
+		Healthy bool `json:"healthy"`
+		*resultAlias
+	}{
+		Healthy:     r.IsHealthy(),
+		resultAlias: (*resultAlias)(r),
+	})
 }
 
 // IsHealthy returns true if the database appears healthy.
@@ -78,9 +114,29 @@ func (r *DoctorResult) String() string {
 		sb.WriteString(fmt.Sprintf("⚠ Pending ops: %d%s\n", r.PendingOpsCount, age))
 	}
 
+	// Unsynced op-log entries
+	if r.UnsyncedOpsCount > 0 {
+		sb.WriteString(fmt.Sprintf("✓ Unsynced op-log entries: %d\n", r.UnsyncedOpsCount))
+	}
+
 	// Local sequence
 	sb.WriteString(fmt.Sprintf("✓ Local seq: %d\n", r.LocalSeq))
 
+	// Last sync
+	if r.LastSync.IsZero() {
+		sb.WriteString("⚠ Last sync: never\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("✓ Last sync: %s ago\n", time.Since(r.LastSync).Round(time.Second)))
+	}
+
+	// Storage
+	if r.JournalMode != "" {
+		sb.WriteString(fmt.Sprintf("✓ Journal mode: %s\n", r.JournalMode))
+	}
+	if r.PageCount > 0 {
+		sb.WriteString(fmt.Sprintf("✓ Pages: %d (%d free)\n", r.PageCount, r.FreelistCount))
+	}
+
 	// WAL status
 	if r.WALSize >= 0 {
 		sb.WriteString(fmt.Sprintf("✓ WAL file: %d bytes\n", r.WALSize))
@@ -126,8 +182,19 @@ func (kv *KV) Doctor() (*DoctorResult, error) {
 		result.Errors = append(result.Errors, fmt.Sprintf("pending ops check failed: %v", err))
 	}
 
+	// Unsynced op-log entries
+	if err := kv.db.QueryRow("SELECT COUNT(*) FROM op_log WHERE synced = 0").Scan(&result.UnsyncedOpsCount); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("op-log check failed: %v", err))
+	}
+
 	// Local sequence
 	result.LocalSeq = kv.maxVersion()
+	result.LastSync = kv.LastSyncTime()
+
+	// Page and journal stats
+	if err := kv.checkStorage(result); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("storage check failed: %v", err))
+	}
 
 	// WAL file status
 	kv.checkWALStatus(result)
@@ -182,6 +249,17 @@ func (kv *KV) checkPendingOps(result *DoctorResult) error {
 	return nil
 }
 
+// checkStorage reads the journal mode and page counts.
+func (kv *KV) checkStorage(result *DoctorResult) error {
+	if err := kv.db.QueryRow("PRAGMA journal_mode").Scan(&result.JournalMode); err != nil {
+		return err
+	}
+	if err := kv.db.QueryRow("PRAGMA page_count").Scan(&result.PageCount); err != nil {
+		return err
+	}
+	return kv.db.QueryRow("PRAGMA freelist_count").Scan(&result.FreelistCount)
+}
+
 // checkWALStatus checks for WAL and SHM files.
 func (kv *KV) checkWALStatus(result *DoctorResult) {
 	walPath := kv.dbPath + "-wal"
@@ -2,8 +2,10 @@ package kv
 
 import (
 	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -333,6 +335,109 @@ func containsSubstringHelper(s, substr string) bool {
 	return false
 }
 
+func TestDoctor_StorageAndOpLogStats(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	result, err := kv.Doctor()
+	if err != nil {
+		t.Fatalf("Doctor() returned error: %v", err)
+	}
+
+	if result.JournalMode != "wal" {
+		t.Errorf("expected JournalMode=wal, got %q", result.JournalMode)
+	}
+	if result.PageCount <= 0 {
+		t.Errorf("expected positive PageCount, got %d", result.PageCount)
+	}
+	if result.FreelistCount < 0 {
+		t.Errorf("expected non-negative FreelistCount, got %d", result.FreelistCount)
+	}
+	if result.UnsyncedOpsCount != 2 {
+		t.Errorf("expected UnsyncedOpsCount=2, got %d", result.UnsyncedOpsCount)
+	}
+	if result.PendingOpsCount != 2 {
+		t.Errorf("expected PendingOpsCount=2, got %d", result.PendingOpsCount)
+	}
+}
+
+func TestDoctor_JSON(t *testing.T) {
+	kv := newTestKV(t)
+
+	var report *DoctorReport
+	report, err := kv.Doctor()
+	if err != nil {
+		t.Fatalf("Doctor() returned error: %v", err)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, field := range []string{"healthy", "integrity_ok", "journal_mode", "page_count",
+		"freelist_count", "pending_ops", "unsynced_ops", "max_version", "wal_size"} {
+		if _, ok := decoded[field]; !ok {
+			t.Errorf("expected field %q in JSON: %s", field, data)
+		}
+	}
+	if decoded["healthy"] != true {
+		t.Errorf("expected healthy=true, got %v", decoded["healthy"])
+	}
+
+	var roundtrip DoctorResult
+	if err := json.Unmarshal(data, &roundtrip); err != nil {
+		t.Fatalf("Unmarshal into DoctorResult failed: %v", err)
+	}
+	if roundtrip.JournalMode != report.JournalMode || roundtrip.PageCount != report.PageCount {
+		t.Errorf("roundtrip mismatch: %+v vs %+v", roundtrip, report)
+	}
+}
+
+func TestDoctor_LastSync(t *testing.T) {
+	kv := newTestKV(t)
+
+	report, err := kv.Doctor()
+	if err != nil {
+		t.Fatalf("Doctor() returned error: %v", err)
+	}
+	if !report.LastSync.IsZero() {
+		t.Errorf("expected zero LastSync before any sync, got %v", report.LastSync)
+	}
+	if !strings.Contains(report.String(), "Last sync: never") {
+		t.Errorf("expected String() to report no sync:\n%s", report.String())
+	}
+	data, _ := json.Marshal(report)
+	if strings.Contains(string(data), "last_sync") {
+		t.Errorf("expected last_sync to be omitted: %s", data)
+	}
+
+	if err := kv.recordSyncTime(); err != nil {
+		t.Fatalf("recordSyncTime failed: %v", err)
+	}
+	report, err = kv.Doctor()
+	if err != nil {
+		t.Fatalf("Doctor() returned error: %v", err)
+	}
+	if time.Since(report.LastSync) > time.Minute {
+		t.Errorf("expected recent LastSync, got %v", report.LastSync)
+	}
+	data, _ = json.Marshal(report)
+	if !strings.Contains(string(data), `"last_sync"`) {
+		t.Errorf("expected last_sync in JSON: %s", data)
+	}
+}
+
 // Verify KV has the necessary fields for Doctor
 func TestKV_HasDoctorRequirements(t *testing.T) {
 	// This test just verifies the KV struct has the fields Doctor needs
@@ -0,0 +1,101 @@
+// ABOUTME: Tests for the cached encryption key handling on KV.
+// ABOUTME: Verifies cache reuse, invalidation, and decryption across rotated keys.
+package kv
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func testEncryptKey(id string) *charm.EncryptKey {
+	return &charm.EncryptKey{ID: id, Key: strings.Repeat(id, 32)[:32]}
+}
+
+// newTestKV creates a KV backed by a temporary SQLite database with a cached
+// encryption key, so values can be encrypted without a Charm client.
+func newTestKV(t *testing.T) *KV {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return &KV{
+		db:         db,
+		dbPath:     dbPath,
+		name:       "test",
+		shutdown:   make(chan struct{}),
+		hlc:        NewHLC(),
+		localDevID: "test-device",
+		eks:        []*charm.EncryptKey{testEncryptKey("a")},
+	}
+}
+
+func TestEncryptKeys_UsesCache(t *testing.T) {
+	// No client is set, so any fetch would panic; the cache must be used.
+	kv := &KV{eks: []*charm.EncryptKey{testEncryptKey("a")}}
+
+	enc, err := kv.encryptValue([]byte("hello"))
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+	dec, err := kv.decryptValue(enc)
+	if err != nil {
+		t.Fatalf("decryptValue failed: %v", err)
+	}
+	if string(dec) != "hello" {
+		t.Errorf("expected 'hello', got %q", dec)
+	}
+}
+
+func TestEncryptKeys_DecryptsWithRotatedKey(t *testing.T) {
+	old := &KV{eks: []*charm.EncryptKey{testEncryptKey("a")}}
+	enc, err := old.encryptValue([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+
+	// After rotation the new key comes first, the old one is still available.
+	rotated := &KV{eks: []*charm.EncryptKey{testEncryptKey("b"), testEncryptKey("a")}}
+	dec, err := rotated.decryptValue(enc)
+	if err != nil {
+		t.Fatalf("decryptValue with rotated keys failed: %v", err)
+	}
+	if string(dec) != "secret" {
+		t.Errorf("expected 'secret', got %q", dec)
+	}
+}
+
+func TestRefreshEncryptKeys_ClearsCache(t *testing.T) {
+	kv := &KV{eks: []*charm.EncryptKey{testEncryptKey("a")}}
+
+	kv.RefreshEncryptKeys()
+
+	kv.eksMu.RLock()
+	defer kv.eksMu.RUnlock()
+	if kv.eks != nil {
+		t.Errorf("expected cache to be cleared, got %d keys", len(kv.eks))
+	}
+}
+
+func TestEncryptKeys_ConcurrentAccess(t *testing.T) {
+	kv := &KV{eks: []*charm.EncryptKey{testEncryptKey("a")}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := kv.encryptValue([]byte("value")); err != nil {
+				t.Errorf("encryptValue failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,5 +1,5 @@
 // ABOUTME: Error types and helpers for KV database operations.
-// ABOUTME: Includes lock detection and read-only mode error handling.
+// ABOUTME: Includes lock detection, read-only mode and sync divergence error handling.
 
 package kv
 
@@ -12,6 +12,16 @@ import (
 // ErrMissingKey is returned when a key is not found in the database.
 var ErrMissingKey = errors.New("key not found")
 
+// ErrClosed is returned when an operation is attempted on a closed KV store.
+var ErrClosed = errors.New("kv store is closed")
+
+// ErrBatchDone is returned when a Batch is used after Commit or Discard.
+var ErrBatchDone = errors.New("batch already committed or discarded")
+
+// ErrTxnDone is returned when a Txn is used after the function it was passed
+// to has returned.
+var ErrTxnDone = errors.New("transaction already finished")
+
 // ErrDatabaseLocked is returned when the database cannot be opened because
 // another process holds the lock.
 type ErrDatabaseLocked struct {
@@ -43,6 +53,27 @@ func (e *ErrReadOnlyMode) Error() string {
 		"To perform writes, stop the other process and reopen the database.", e.Operation)
 }
 
+// ErrSyncDivergence is returned by Sync when the newest snapshot backup in
+// the Charm Cloud is older than one this machine uploaded, because the cloud
+// was rolled back or its backups were removed. Nothing is synced until the
+// divergence is resolved.
+type ErrSyncDivergence struct {
+	// LocalSeq is the seq of the last backup uploaded from this machine.
+	LocalSeq uint64
+
+	// RemoteSeq is the seq of the newest backup in the cloud, or 0 if there
+	// are none.
+	RemoteSeq uint64
+}
+
+func (e *ErrSyncDivergence) Error() string {
+	return fmt.Sprintf("cloud backups diverged: this machine uploaded seq %d but the newest cloud backup is seq %d\n\n"+
+		"Syncing now could lose data. Options:\n"+
+		"  1. Use RestoreSeq() to roll back to a cloud backup\n"+
+		"  2. Use ForcePush() to upload the local database\n"+
+		"  3. Use Reset() to start over from the cloud", e.LocalSeq, e.RemoteSeq)
+}
+
 // IsLocked returns true if the error indicates the database is locked by
 // another process.
 func IsLocked(err error) bool {
@@ -79,3 +110,10 @@ func IsReadOnly(err error) bool {
 	var roErr *ErrReadOnlyMode
 	return errors.As(err, &roErr)
 }
+
+// IsSyncDivergence returns true if the error indicates the cloud backups are
+// older than this machine's.
+func IsSyncDivergence(err error) bool {
+	var divErr *ErrSyncDivergence
+	return errors.As(err, &divErr)
+}
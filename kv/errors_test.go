@@ -212,3 +212,27 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestErrSyncDivergence_Error(t *testing.T) {
+	err := &ErrSyncDivergence{LocalSeq: 7, RemoteSeq: 3}
+	msg := err.Error()
+
+	for _, want := range []string{"seq 7", "seq 3", "RestoreSeq()", "ForcePush()"} {
+		if !contains(msg, want) {
+			t.Errorf("error message should contain %q, got: %s", want, msg)
+		}
+	}
+}
+
+func TestIsSyncDivergence(t *testing.T) {
+	err := &ErrSyncDivergence{LocalSeq: 2, RemoteSeq: 1}
+	if !IsSyncDivergence(err) {
+		t.Error("IsSyncDivergence should return true for ErrSyncDivergence")
+	}
+	if !IsSyncDivergence(fmt.Errorf("sync failed: %w", err)) {
+		t.Error("IsSyncDivergence should return true for wrapped ErrSyncDivergence")
+	}
+	if IsSyncDivergence(errors.New("some error")) || IsSyncDivergence(nil) {
+		t.Error("IsSyncDivergence should return false for other errors")
+	}
+}
@@ -0,0 +1,113 @@
+// ABOUTME: Portable NDJSON export and import for the KV store
+// ABOUTME: Streams decrypted key/value pairs independent of encryption keys
+
+package kv
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// importBatchSize is how many records Import writes per transaction.
+const importBatchSize = 1000
+
+// exportRecord is a single line of an export stream. Keys and values are
+// base64 encoded by encoding/json.
+type exportRecord struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+
+	// ExpiresAt is when a TTL key expires, in Unix milliseconds.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// Export writes every key and its decrypted value to w as newline-delimited
+// JSON, one object per line with base64-encoded "key" and "value" fields.
+// Keys set with a TTL also carry "expires_at" in Unix milliseconds; expired
+// keys are skipped. Rows are streamed so the store is never held in memory.
+//
+// The output is plaintext. Unlike a cloud backup, it can be imported into a
+// store belonging to any account.
+func (kv *KV) Export(w io.Writer) error {
+	eks, err := kv.encryptKeys()
+	if err != nil {
+		return err
+	}
+
+	rows, err := kv.db.Query("SELECT key, value, expires_at FROM kv WHERE "+notExpired+" ORDER BY key", time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		var rec exportRecord
+		var encValue []byte
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&rec.Key, &encValue, &expiresAt); err != nil {
+			return fmt.Errorf("failed to scan key: %w", err)
+		}
+		rec.Value, err = decryptValueWithKeys(eks, encValue)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key %q: %w", rec.Key, err)
+		}
+		rec.ExpiresAt = expiresAt.Int64
+		if err := enc.Encode(&rec); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating keys: %w", err)
+	}
+	return nil
+}
+
+// Import reads a stream written by Export and sets each key, encrypting the
+// values with this store's keys. Existing keys are overwritten; keys not in
+// the stream are left alone. Records whose TTL has already passed are
+// skipped. Records are written in batches, so if Import fails partway the
+// batches before the failure remain applied.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) Import(r io.Reader) error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "import"}
+	}
+
+	dec := json.NewDecoder(r)
+	b := kv.Batch()
+	for line := 1; ; line++ {
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			b.Discard()
+			return fmt.Errorf("failed to read record %d: %w", line, err)
+		}
+		if rec.Key == nil {
+			b.Discard()
+			return fmt.Errorf("record %d has no key", line)
+		}
+		if rec.ExpiresAt != 0 && rec.ExpiresAt <= time.Now().UnixMilli() {
+			continue
+		}
+
+		if err := b.set(rec.Key, rec.Value, rec.ExpiresAt); err != nil {
+			b.Discard()
+			return err
+		}
+		if b.Len() >= importBatchSize {
+			if err := b.Commit(); err != nil {
+				return err
+			}
+			b = kv.Batch()
+		}
+	}
+	return b.Commit()
+}
@@ -0,0 +1,101 @@
+// ABOUTME: Tests for NDJSON export and import.
+// ABOUTME: Verifies round-tripping between stores with different encryption keys.
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestExportImport_Roundtrip(t *testing.T) {
+	src := newTestKV(t)
+	if err := src.Set([]byte("a"), []byte("alpha")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.Set([]byte("b"), []byte{0x00, 0xff, 0x10}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := src.SetWithTTL([]byte("ttl"), []byte("soon"), time.Hour); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %s", len(lines), buf.String())
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if first["key"] != "YQ==" || first["value"] != "YWxwaGE=" {
+		t.Errorf("expected base64 plaintext key and value, got %v", first)
+	}
+
+	// A store with a different encryption key can import the export
+	dst := newTestKV(t)
+	dst.eks = []*charm.EncryptKey{testEncryptKey("other")}
+	if err := dst.Import(&buf); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for k, want := range map[string][]byte{"a": []byte("alpha"), "b": {0x00, 0xff, 0x10}, "ttl": []byte("soon")} {
+		got, err := dst.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", k, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Get(%q) = %q, want %q", k, got, want)
+		}
+	}
+
+	var expiresAt int64
+	if err := dst.db.QueryRow("SELECT expires_at FROM kv WHERE key = ?", []byte("ttl")).Scan(&expiresAt); err != nil {
+		t.Fatalf("failed to read expires_at: %v", err)
+	}
+	if expiresAt <= time.Now().UnixMilli() {
+		t.Errorf("expected imported TTL to be preserved, got %d", expiresAt)
+	}
+}
+
+func TestImport_SkipsExpiredAndRejectsBadInput(t *testing.T) {
+	kv := newTestKV(t)
+
+	stream := `{"key":"YQ==","value":"MQ==","expires_at":1}
+{"key":"Yg==","value":"Mg=="}
+`
+	if err := kv.Import(strings.NewReader(stream)); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if _, err := kv.Get([]byte("a")); err != ErrMissingKey {
+		t.Errorf("expected expired record to be skipped, got %v", err)
+	}
+	if v, err := kv.Get([]byte("b")); err != nil || string(v) != "2" {
+		t.Errorf("expected b=2, got %q, %v", v, err)
+	}
+
+	if err := kv.Import(strings.NewReader(`{"value":"MQ=="}`)); err == nil {
+		t.Error("expected error for record without key")
+	}
+	if err := kv.Import(strings.NewReader("not json")); err == nil {
+		t.Error("expected error for malformed stream")
+	}
+}
+
+func TestImport_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	if err := kv.Import(strings.NewReader("")); !IsReadOnly(err) {
+		t.Errorf("expected read-only error, got %v", err)
+	}
+}
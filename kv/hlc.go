@@ -51,6 +51,14 @@ func (h *HLC) Now() int64 {
 	return h.pack()
 }
 
+// Last returns the latest timestamp the clock has given out or seen, without
+// advancing it, or 0 if there is none yet.
+func (h *HLC) Last() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pack()
+}
+
 // Update updates the HLC based on a received timestamp.
 // Used when receiving events from other devices.
 // Returns the updated timestamp.
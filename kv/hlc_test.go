@@ -19,6 +19,31 @@ func TestHLC_Now_Monotonic(t *testing.T) {
 	}
 }
 
+func TestHLC_Last(t *testing.T) {
+	hlc := NewHLC()
+	if last := hlc.Last(); last != 0 {
+		t.Errorf("Last = %d before any timestamp, want 0", last)
+	}
+
+	ts := hlc.Now()
+	if last := hlc.Last(); last != ts {
+		t.Errorf("Last = %d, want %d", last, ts)
+	}
+	// Reading doesn't advance the clock
+	if last := hlc.Last(); last != ts {
+		t.Errorf("Last = %d after reading, want %d", last, ts)
+	}
+	if next := hlc.Now(); next <= ts {
+		t.Errorf("Now = %d after Last, want more than %d", next, ts)
+	}
+
+	remote := hlc.Now() + 1000<<16
+	hlc.Update(remote)
+	if last := hlc.Last(); last <= remote {
+		t.Errorf("Last = %d, want more than the received %d", last, remote)
+	}
+}
+
 func TestHLC_Now_CloseToPhysical(t *testing.T) {
 	hlc := NewHLC()
 
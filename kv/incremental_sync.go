@@ -0,0 +1,344 @@
+// ABOUTME: Incremental op-log sync for the KV store
+// ABOUTME: Uploads unsynced ops as batches and applies remote batches with LWW
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// opBatchLimit is the maximum number of ops uploaded in a single batch.
+const opBatchLimit = 1000
+
+// OpBatch is a group of op-log entries uploaded to the cloud together.
+type OpBatch struct {
+	// Seq is the cloud sequence number assigned to this batch.
+	Seq uint64 `json:"seq"`
+
+	// DeviceID identifies which device uploaded this batch.
+	DeviceID string `json:"device_id,omitempty"`
+
+	// CreatedAt is when this batch was uploaded.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Ops are the operations in the batch, in local sequence order.
+	Ops []Op `json:"ops"`
+}
+
+// opsDirKey returns the storage directory holding op batches.
+func opsDirKey(name string) string {
+	return fmt.Sprintf("%s/ops", name)
+}
+
+// opBatchKey returns the storage path for the op batch with the given seq.
+func opBatchKey(name string, seq uint64) string {
+	return fmt.Sprintf("%s/ops/%d", name, seq)
+}
+
+// recordOpBatch marks an op batch as present locally.
+func recordOpBatch(db *sql.DB, seq uint64) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO op_batches (seq, applied_at) VALUES (?, ?)", seq, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to record op batch: %w", err)
+	}
+	return nil
+}
+
+// appliedOpBatches returns the set of op batch seqs already present locally.
+func appliedOpBatches(db *sql.DB) (map[uint64]bool, error) {
+	rows, err := db.Query("SELECT seq FROM op_batches")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query op batches: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[uint64]bool)
+	for rows.Next() {
+		var seq uint64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, fmt.Errorf("failed to scan op batch: %w", err)
+		}
+		applied[seq] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating op batches: %w", err)
+	}
+	return applied, nil
+}
+
+// remoteOpBatchSeqs returns the seqs of all op batches in the cloud,
+// sorted ascending. Returns an empty slice if the remote has no op-log.
+func (kv *KV) remoteOpBatchSeqs() ([]uint64, error) {
+	des, err := kv.fs.ReadDir(opsDirKey(kv.name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list op batches: %w", err)
+	}
+
+	seqs := make([]uint64, 0, len(des))
+	for _, de := range des {
+		seq, err := strconv.ParseUint(de.Name(), 10, 64)
+		if err != nil {
+			// Skip files that aren't op batches
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// incrementalBackup pulls remote ops and then pushes local unsynced ops.
+func (kv *KV) incrementalBackup(ctx context.Context) error {
+	if err := kv.pullOps(ctx); err != nil {
+		return err
+	}
+	return kv.pushOps(ctx)
+}
+
+// pushOps uploads all unsynced local ops as one or more op batches.
+// If the remote has no op-log yet, a full snapshot is uploaded first so that
+// data written before incremental sync was enabled isn't lost.
+func (kv *KV) pushOps(ctx context.Context) error {
+	remote, err := kv.remoteOpBatchSeqs()
+	if err != nil {
+		return err
+	}
+	if len(remote) == 0 {
+		if err := kv.snapshotBackup(ctx); err != nil {
+			return fmt.Errorf("failed to upload baseline snapshot: %w", err)
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ops, err := getUnsyncedOps(kv.db, opBatchLimit)
+		if err != nil {
+			return err
+		}
+		if len(ops) == 0 {
+			return nil
+		}
+
+		seq, err := kv.nextSeqWithContext(ctx, kv.name)
+		if err != nil {
+			return err
+		}
+
+		batch := &OpBatch{
+			Seq:       seq,
+			DeviceID:  kv.localDevID,
+			CreatedAt: time.Now().UTC(),
+			Ops:       ops,
+		}
+		if err := kv.uploadOpBatch(batch, syncProgressFrom(ctx)); err != nil {
+			return err
+		}
+
+		opIDs := make([]string, len(ops))
+		for i, op := range ops {
+			opIDs[i] = op.OpID
+		}
+		if err := markOpsSynced(kv.db, opIDs); err != nil {
+			return err
+		}
+		// Our own batch never needs to be downloaded again
+		if err := recordOpBatch(kv.db, seq); err != nil {
+			return err
+		}
+		if seq > kv.maxVersion() {
+			if err := kv.setMaxVersion(seq); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// uploadOpBatch writes an op batch to cloud storage.
+func (kv *KV) uploadOpBatch(batch *OpBatch, progress *syncProgress) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to serialize op batch: %w", err)
+	}
+
+	key := opBatchKey(kv.name, batch.Seq)
+	size := int64(len(data))
+	src := &kvFile{
+		data: progress.reader(SyncUploading, bytes.NewReader(data), size),
+		info: &kvFileInfo{
+			name:    key,
+			size:    size,
+			mode:    fs.FileMode(0o660),
+			modTime: time.Now(),
+		},
+	}
+	if err := kv.fs.WriteFile(key, src); err != nil {
+		return fmt.Errorf("failed to upload op batch: %w", err)
+	}
+	return nil
+}
+
+// downloadOpBatch reads an op batch from cloud storage.
+func (kv *KV) downloadOpBatch(seq uint64, progress *syncProgress) (*OpBatch, error) {
+	data, err := kv.fs.ReadFile(opBatchKey(kv.name, seq))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download op batch %d: %w", seq, err)
+	}
+	progress.transfer(SyncDownloading, int64(len(data)), int64(len(data)))
+	var batch OpBatch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to parse op batch %d: %w", seq, err)
+	}
+	return &batch, nil
+}
+
+// pullOps downloads and applies every remote op batch not yet present
+// locally. Ops are applied with last-write-wins conflict resolution.
+// On a machine that has never synced, the latest full snapshot is restored
+// first and only the batches it doesn't already include are applied.
+func (kv *KV) pullOps(ctx context.Context) error {
+	progress := syncProgressFrom(ctx)
+
+	applied, err := appliedOpBatches(kv.db)
+	if err != nil {
+		return err
+	}
+	remote, err := kv.remoteOpBatchSeqs()
+	if err != nil {
+		return err
+	}
+
+	done := 0
+	if len(applied) == 0 && kv.maxVersion() == 0 {
+		// Count the snapshot as one step; the batches it includes aren't
+		// known until it has been restored
+		progress.steps(0, len(remote)+1)
+		if err := kv.bootstrapFromSnapshot(withSyncProgress(ctx, nil)); err != nil {
+			return fmt.Errorf("failed to bootstrap from snapshot: %w", err)
+		}
+		// The snapshot records which batches it already includes
+		applied, err = appliedOpBatches(kv.db)
+		if err != nil {
+			return err
+		}
+		done = 1
+	}
+
+	var pending []uint64
+	for _, seq := range remote {
+		if !applied[seq] {
+			pending = append(pending, seq)
+		}
+	}
+	total := done + len(pending)
+	if total > 0 {
+		progress.steps(done, total)
+	}
+
+	for _, seq := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := kv.downloadOpBatch(seq, progress)
+		if err != nil {
+			return err
+		}
+		for i := range batch.Ops {
+			op := &batch.Ops[i]
+			// Remote ops are already in the cloud, don't push them back
+			op.Synced = true
+			kv.hlc.Update(op.HLCTimestamp)
+			if err := kv.applyRemoteOp(op); err != nil {
+				return err
+			}
+		}
+		progress.applied(len(batch.Ops))
+		if err := recordOpBatch(kv.db, seq); err != nil {
+			return err
+		}
+		if seq > kv.maxVersion() {
+			if err := kv.setMaxVersion(seq); err != nil {
+				return err
+			}
+		}
+		done++
+		progress.steps(done, total)
+	}
+	return nil
+}
+
+// bootstrapFromSnapshot restores the latest full snapshot on a machine that
+// has never synced, so data written before incremental sync was enabled is
+// included. Local ops that haven't been uploaded yet are replayed on top of
+// the snapshot so they aren't lost.
+func (kv *KV) bootstrapFromSnapshot(ctx context.Context) error {
+	local, err := getUnsyncedOps(kv.db, -1)
+	if err != nil {
+		return err
+	}
+
+	if err := kv.syncFromWithContext(ctx, 0); err != nil {
+		return err
+	}
+	if kv.maxVersion() == 0 {
+		// No snapshot in the cloud, the local database is unchanged
+		return nil
+	}
+
+	// Everything in the snapshot is already in the cloud
+	if _, err := kv.db.Exec("UPDATE op_log SET synced = 1 WHERE synced = 0"); err != nil {
+		return fmt.Errorf("failed to mark snapshot ops synced: %w", err)
+	}
+
+	for i := range local {
+		op := &local[i]
+		kv.hlc.Update(op.HLCTimestamp)
+		// These are our own writes, not conflicts with another machine
+		if err := kv.applyOpAndNotify(op, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRemoteOp applies an op with last-write-wins conflict resolution, or
+// the configured resolver, reports any conflict to the configured handler,
+// and notifies watchers if it changed the store.
+func (kv *KV) applyRemoteOp(op *Op) error {
+	return kv.applyOpAndNotify(op, kv.conflictHandler, kv.conflictResolver)
+}
+
+// applyOpAndNotify applies an op, resolving any conflict with resolver and
+// reporting it to handler once the write transaction has committed.
+func (kv *KV) applyOpAndNotify(op *Op, handler ConflictHandler, resolver ConflictResolver) error {
+	applied, conflict, err := applyOp(kv.db, op)
+	if err != nil {
+		return fmt.Errorf("failed to apply op %s: %w", op.OpID, err)
+	}
+	resolved, err := kv.resolveConflict(resolver, op, conflict)
+	if err != nil {
+		return fmt.Errorf("failed to resolve conflict on op %s: %w", op.OpID, err)
+	}
+	kv.reportConflict(handler, conflict)
+	if !applied || resolved {
+		// A resolved value has already been written and announced
+		return nil
+	}
+	if op.OpType == "set" {
+		kv.notifyEncrypted(op.Key, op.Value, KeySet)
+	} else {
+		kv.notify(KeyEvent{Key: op.Key, Type: KeyDeleted})
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// ABOUTME: Tests for incremental op-log sync helpers.
+// ABOUTME: Covers op batch bookkeeping, serialization, and storage keys.
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOpBatchKeys(t *testing.T) {
+	if got := opsDirKey("mydb"); got != "mydb/ops" {
+		t.Errorf("opsDirKey = %q, want %q", got, "mydb/ops")
+	}
+	if got := opBatchKey("mydb", 42); got != "mydb/ops/42" {
+		t.Errorf("opBatchKey = %q, want %q", got, "mydb/ops/42")
+	}
+}
+
+func TestRecordOpBatch(t *testing.T) {
+	kv := newTestKV(t)
+
+	applied, err := appliedOpBatches(kv.db)
+	if err != nil {
+		t.Fatalf("appliedOpBatches failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no applied batches, got %v", applied)
+	}
+
+	for _, seq := range []uint64{3, 7, 3} {
+		if err := recordOpBatch(kv.db, seq); err != nil {
+			t.Fatalf("recordOpBatch(%d) failed: %v", seq, err)
+		}
+	}
+
+	applied, err = appliedOpBatches(kv.db)
+	if err != nil {
+		t.Fatalf("appliedOpBatches failed: %v", err)
+	}
+	if len(applied) != 2 || !applied[3] || !applied[7] {
+		t.Errorf("expected batches 3 and 7, got %v", applied)
+	}
+}
+
+func TestOpBatch_JSONRoundtrip(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.Set([]byte("a"), []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.SetWithTTL([]byte("b"), []byte("value"), time.Hour); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := kv.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	ops, err := getUnsyncedOps(kv.db, opBatchLimit)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+
+	batch := &OpBatch{Seq: 5, DeviceID: "dev", CreatedAt: time.Now().UTC(), Ops: ops}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var got OpBatch
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Seq != 5 || len(got.Ops) != 3 {
+		t.Fatalf("unexpected batch: seq=%d ops=%d", got.Seq, len(got.Ops))
+	}
+	for i := range ops {
+		if got.Ops[i].OpID != ops[i].OpID || !bytes.Equal(got.Ops[i].Key, ops[i].Key) ||
+			!bytes.Equal(got.Ops[i].Value, ops[i].Value) || got.Ops[i].ExpiresAt != ops[i].ExpiresAt {
+			t.Errorf("op %d mismatch after roundtrip: %+v vs %+v", i, got.Ops[i], ops[i])
+		}
+	}
+}
+
+func TestApplyRemoteBatchToSecondStore(t *testing.T) {
+	src := newTestKV(t)
+	dst := newTestKV(t)
+
+	if err := src.Set([]byte("a"), []byte("from-src")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	ops, err := getUnsyncedOps(src.db, opBatchLimit)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+
+	// Apply the way pullOps does
+	for i := range ops {
+		ops[i].Synced = true
+		if _, _, err := applyOp(dst.db, &ops[i]); err != nil {
+			t.Fatalf("applyOp failed: %v", err)
+		}
+	}
+
+	v, err := dst.Get([]byte("a"))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(v) != "from-src" {
+		t.Errorf("expected 'from-src', got %q", v)
+	}
+
+	// Applied remote ops must not be pushed back
+	unsynced, err := getUnsyncedOps(dst.db, opBatchLimit)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+	if len(unsynced) != 0 {
+		t.Errorf("expected no unsynced ops on destination, got %d", len(unsynced))
+	}
+}
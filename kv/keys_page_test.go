@@ -0,0 +1,88 @@
+// ABOUTME: Tests for KeysPage cursor pagination.
+// ABOUTME: Verifies ordering, cursors, the last page, expired keys, and invalid limits.
+package kv
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeysPage(t *testing.T) {
+	kv := newTestKV(t)
+	// Set out of order to check the pages come back sorted
+	for _, i := range []int{4, 1, 3, 0, 2} {
+		if err := kv.Set([]byte(fmt.Sprintf("key-%d", i)), []byte("v")); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	var got []string
+	var pages int
+	var after []byte
+	for {
+		keys, next, err := kv.KeysPage(after, 2)
+		if err != nil {
+			t.Fatalf("KeysPage failed: %v", err)
+		}
+		pages++
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+
+	want := "[key-0 key-1 key-2 key-3 key-4]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("KeysPage returned %v, want %s", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+}
+
+func TestKeysPage_ExactLastPage(t *testing.T) {
+	kv := newTestKV(t)
+	for _, k := range []string{"a", "b"} {
+		if err := kv.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	keys, next, err := kv.KeysPage(nil, 2)
+	if err != nil {
+		t.Fatalf("KeysPage failed: %v", err)
+	}
+	if len(keys) != 2 || next != nil {
+		t.Errorf("KeysPage = %q, %q, want 2 keys and no cursor", keys, next)
+	}
+}
+
+func TestKeysPage_SkipsExpired(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("a"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.SetWithTTL([]byte("b"), []byte("v"), time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	keys, next, err := kv.KeysPage(nil, 10)
+	if err != nil {
+		t.Fatalf("KeysPage failed: %v", err)
+	}
+	if len(keys) != 1 || string(keys[0]) != "a" || next != nil {
+		t.Errorf("KeysPage = %q, %q, want only %q", keys, next, "a")
+	}
+}
+
+func TestKeysPage_InvalidLimit(t *testing.T) {
+	kv := newTestKV(t)
+	if _, _, err := kv.KeysPage(nil, 0); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
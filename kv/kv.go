@@ -2,6 +2,7 @@
 package kv
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/hex"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/charm/client"
@@ -33,14 +35,48 @@ type KV struct {
 	readOnly bool
 
 	// Backup batching state
-	backupMu      sync.Mutex
-	pendingWrites int
-	shutdown      chan struct{}
-	shutdownOnce  sync.Once
+	backupMu        sync.Mutex
+	pendingWrites   int
+	backupThreshold int // Writes between automatic backups, 0 = never
+
+	// Background sync started by StartAutoSync. autoSyncMu orders starting
+	// a sync goroutine against Close signalling shutdown.
+	autoSyncMu       sync.Mutex
+	autoSyncWG       sync.WaitGroup
+	syncErrorHandler func(error)
+	shutdown         chan struct{}
+	shutdownOnce     sync.Once
 
 	// Op-log state for Phase 3 incremental sync
-	hlc        *HLC   // Hybrid logical clock for ordering
-	localDevID string // Stable device identifier
+	hlc         *HLC   // Hybrid logical clock for ordering
+	localDevID  string // Stable device identifier
+	incremental bool   // Sync via op-log batches instead of full snapshots
+
+	// Called for conflicts found while applying remote ops
+	conflictHandler ConflictHandler
+
+	// Merges conflicting values instead of last-write-wins, if set
+	conflictResolver ConflictResolver
+
+	// Checkpoint the WAL into the main database file on Close
+	checkpointOnClose bool
+
+	// Compact on Close when the free page ratio exceeds this (0 = never)
+	autoCompactThreshold float64
+
+	// Encryption key cache, populated on first use
+	eksMu sync.RWMutex
+	eks   []*charm.EncryptKey
+
+	// Change notification subscribers
+	watchMu  sync.Mutex
+	watchers map[*watcher]struct{}
+
+	// Skip all cloud interaction until a Sync succeeds, see WithOffline
+	offline atomic.Bool
+	// When the Charm Cloud was last found unreachable, in Unix nanoseconds,
+	// or 0 if it was reachable
+	unreachableAt atomic.Int64
 }
 
 // Config holds optional configuration for opening a KV store.
@@ -52,6 +88,22 @@ type Config struct {
 	writeRetryBaseDelay time.Duration // Initial delay between retries
 	writeRetryMaxDelay  time.Duration // Maximum delay cap
 	retryConfigured     bool          // True if retry was explicitly configured
+
+	incrementalSync  bool             // Sync via op-log batches instead of full snapshots
+	conflictHandler  ConflictHandler  // Called for conflicts found during Sync
+	conflictResolver ConflictResolver // Merges conflicting values during Sync
+
+	checkpointOnClose    bool    // Checkpoint the WAL into the main database file on Close
+	autoCompactThreshold float64 // Compact on Close above this free page ratio
+
+	backupThreshold    int  // Writes between automatic backups
+	backupThresholdSet bool // True if the backup threshold was explicitly configured
+
+	syncErrorHandler func(error) // Called with errors from background syncs
+
+	offline bool // Start without any cloud interaction
+
+	deviceID string // Overrides the device ID recorded with ops and backups
 }
 
 // Default retry settings
@@ -63,7 +115,8 @@ const (
 
 // Backup strategy constants
 const (
-	// Backup after this many writes have accumulated
+	// Backup after this many writes have accumulated, unless overridden
+	// with WithBackupThreshold
 	backupWriteThreshold = 10
 )
 
@@ -111,48 +164,133 @@ func WithNoWriteRetry() Option {
 	}
 }
 
-// applyRetryDefaults sets default retry values if not explicitly configured.
-func applyRetryDefaults(cfg *Config) {
-	if !cfg.retryConfigured {
-		cfg.writeRetryAttempts = DefaultWriteRetryAttempts
-		cfg.writeRetryBaseDelay = DefaultWriteRetryBaseDelay
-		cfg.writeRetryMaxDelay = DefaultWriteRetryMaxDelay
+// WithIncrementalSync makes Sync upload only the operations written since the
+// last sync, and apply operations from other machines, instead of uploading
+// a full database snapshot every time. The first sync on a fresh machine
+// restores the latest full snapshot and switches to incremental afterwards.
+// All machines sharing a store should use the same sync mode.
+func WithIncrementalSync() Option {
+	return func(c *Config) {
+		c.incrementalSync = true
 	}
 }
 
-// getOrCreateDeviceID returns a stable device identifier for this database.
-// Priority:
-// 1. Charm user ID (if authenticated)
-// 2. Previously stored device ID in meta_str table
-// 3. Generate and store a new UUID
-func getOrCreateDeviceID(db *sql.DB, cc *client.Client) (string, error) {
-	// Try to get Charm user ID first (provides cross-device user identity)
-	if cc != nil {
-		if id, err := cc.ID(); err == nil && id != "" {
-			return id, nil
-		}
+// WithConflictHandler sets a function to call whenever Sync applies a remote
+// op to a key that already holds a different local value. Conflicts are still
+// resolved with last-write-wins; the handler is told which side won so it can
+// log or merge. Conflicts are only detected with WithIncrementalSync, since a
+// full snapshot restore replaces the database wholesale.
+func WithConflictHandler(fn ConflictHandler) Option {
+	return func(c *Config) {
+		c.conflictHandler = fn
 	}
+}
 
-	// Check for stored device ID in meta_str table
-	var devID string
-	err := db.QueryRow("SELECT value FROM meta_str WHERE name = 'device_id'").Scan(&devID)
-	if err == nil && devID != "" {
-		return devID, nil
+// WithConflictResolver sets a function that picks the value a key should hold
+// whenever Sync applies a remote op to a key that already holds a different
+// local value, instead of keeping the last write. The resolved value is
+// written as a new local op, so it reaches other machines on the next Sync.
+// Without a resolver, or for values that can't be decrypted, conflicts are
+// resolved with last-write-wins. Like WithConflictHandler, it only applies
+// with WithIncrementalSync.
+func WithConflictResolver(fn ConflictResolver) Option {
+	return func(c *Config) {
+		c.conflictResolver = fn
 	}
-	if err != nil && err != sql.ErrNoRows {
-		return "", fmt.Errorf("failed to query device ID: %w", err)
+}
+
+// WithCheckpointOnClose makes Close checkpoint the write-ahead log into the
+// main database file, truncating the WAL, before closing. Committed writes
+// are always visible to a new handle through the WAL; this additionally
+// makes the main database file complete on its own once Close returns, for
+// example before copying it or handing it to a process that opens it
+// without the WAL.
+func WithCheckpointOnClose() Option {
+	return func(c *Config) {
+		c.checkpointOnClose = true
 	}
+}
 
-	// Generate a new device ID
-	devID = newOpID() // Uses UUID
+// WithAutoCompact makes Close compact the database when the fraction of
+// free pages exceeds threshold, for example 0.25 to compact once a quarter of
+// the file is unused. A threshold of 0 disables auto-compaction.
+func WithAutoCompact(threshold float64) Option {
+	return func(c *Config) {
+		c.autoCompactThreshold = threshold
+	}
+}
 
-	// Store it for future use
-	_, err = db.Exec("INSERT OR REPLACE INTO meta_str (name, value) VALUES ('device_id', ?)", devID)
-	if err != nil {
-		return "", fmt.Errorf("failed to store device ID: %w", err)
+// WithBackupThreshold sets how many writes accumulate before they are
+// automatically backed up to the Charm Cloud. The default is 10.
+//
+// Writes are always durable in the local database immediately; the
+// threshold only controls how much could be lost if the local disk is lost
+// before the next backup, and how often writers pay for an upload. A value
+// of 1 backs up after every write, which is safest but makes every write
+// wait on the network. Larger values make bursts of writes much faster. A
+// value of 0 disables automatic backups entirely, so writes only reach the
+// cloud on an explicit Sync or on Close.
+func WithBackupThreshold(n int) Option {
+	return func(c *Config) {
+		c.backupThreshold = n
+		c.backupThresholdSet = true
+	}
+}
+
+// WithManualBackup disables automatic backups, so writes only reach the
+// cloud on an explicit Sync or on Close. It is the same as
+// WithBackupThreshold(0) and suits bulk imports.
+func WithManualBackup() Option {
+	return WithBackupThreshold(0)
+}
+
+// WithSyncErrorHandler sets a function to call when a background sync
+// started by StartAutoSync fails. Without a handler those errors are dropped.
+// The handler is called from the background sync goroutine.
+func WithSyncErrorHandler(fn func(error)) Option {
+	return func(c *Config) {
+		c.syncErrorHandler = fn
+	}
+}
+
+// WithDeviceID sets the device ID recorded with this machine's ops and
+// backups, which breaks ties between ops written at the same time on
+// different machines. By default each machine derives its own ID from its
+// hostname and a random ID kept in the data directory; override it only with
+// an ID no other machine uses.
+func WithDeviceID(id string) Option {
+	return func(c *Config) {
+		c.deviceID = id
+	}
+}
+
+// WithOffline opens the store offline, for use without network access.
+// Writes only touch the local database, where they're recorded as pending
+// until a Sync uploads them: no automatic backups are made, Close doesn't
+// flush, SyncIfStale and StartAutoSync do nothing, and the Charm ID isn't
+// looked up when opening. A Sync that succeeds brings the store back online;
+// SetOffline switches modes directly.
+//
+// A store opened without WithOffline goes offline by itself when a backup or
+// sync fails to reach the Charm Cloud, and tries again every
+// OfflineProbeInterval until it succeeds.
+//
+// Values are still encrypted with the client's encrypt keys, which are
+// fetched from the Charm Cloud when the store is opened, so open the store
+// before losing connectivity.
+func WithOffline() Option {
+	return func(c *Config) {
+		c.offline = true
 	}
+}
 
-	return devID, nil
+// applyRetryDefaults sets default retry values if not explicitly configured.
+func applyRetryDefaults(cfg *Config) {
+	if !cfg.retryConfigured {
+		cfg.writeRetryAttempts = DefaultWriteRetryAttempts
+		cfg.writeRetryBaseDelay = DefaultWriteRetryBaseDelay
+		cfg.writeRetryMaxDelay = DefaultWriteRetryMaxDelay
+	}
 }
 
 // openKV opens a KV store with the given client, name, read-only mode, and options.
@@ -195,24 +333,38 @@ func openKV(cc *client.Client, name string, readOnly bool, opts ...Option) (*KV,
 		return nil, err
 	}
 
-	// Get device ID for op-log (use charm user ID if available, otherwise generate stable UUID)
-	devID, err := getOrCreateDeviceID(db, cc)
-	if err != nil {
-		_ = db.Close()
-		return nil, fmt.Errorf("failed to get device ID: %w", err)
+	// Get the device ID recorded with ops and backups
+	devID := cfg.deviceID
+	if devID == "" {
+		devID, err = machineDeviceID(kvDir)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
 	}
 
 	kv := &KV{
-		db:         db,
-		dbPath:     dbPath,
-		name:       name,
-		cc:         cc,
-		fs:         cfs,
-		readOnly:   readOnly,
-		shutdown:   make(chan struct{}),
-		hlc:        NewHLC(),
-		localDevID: devID,
-	}
+		db:                   db,
+		dbPath:               dbPath,
+		name:                 name,
+		cc:                   cc,
+		fs:                   cfs,
+		readOnly:             readOnly,
+		shutdown:             make(chan struct{}),
+		hlc:                  NewHLC(),
+		localDevID:           devID,
+		incremental:          cfg.incrementalSync,
+		conflictHandler:      cfg.conflictHandler,
+		conflictResolver:     cfg.conflictResolver,
+		checkpointOnClose:    cfg.checkpointOnClose,
+		autoCompactThreshold: cfg.autoCompactThreshold,
+		backupThreshold:      backupWriteThreshold,
+		syncErrorHandler:     cfg.syncErrorHandler,
+	}
+	if cfg.backupThresholdSet {
+		kv.backupThreshold = cfg.backupThreshold
+	}
+	kv.offline.Store(cfg.offline)
 
 	return kv, nil
 }
@@ -335,7 +487,12 @@ func OpenWithDefaultsFallback(name string, opts ...Option) (*KV, error) {
 }
 
 // Sync synchronizes the local database with any updates from the Charm Cloud.
-// This also flushes any pending writes to ensure they're backed up.
+// This also flushes any pending writes to ensure they're backed up. A store
+// opened WithOffline is back online once Sync succeeds.
+//
+// Without WithIncrementalSync, Sync returns an *ErrSyncDivergence, and
+// neither downloads nor uploads anything, if the newest backup in the cloud
+// is older than one this machine uploaded.
 func (kv *KV) Sync() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -346,15 +503,47 @@ func (kv *KV) Sync() error {
 // This also flushes any pending writes to ensure they're backed up.
 // Uses a sync lock to prevent concurrent Sync() calls from racing.
 func (kv *KV) SyncWithContext(ctx context.Context) error {
+	return kv.SyncWithProgress(ctx, nil)
+}
+
+// SyncWithProgress is like SyncWithContext but calls fn as changes are
+// downloaded from the cloud, applied, and uploaded, so callers can show a
+// progress bar during large restores. fn is not called if there is nothing
+// to download or upload.
+func (kv *KV) SyncWithProgress(ctx context.Context, fn SyncProgressFunc) error {
+	if fn != nil {
+		ctx = withSyncProgress(ctx, fn)
+	}
+
 	// Acquire sync lock to prevent concurrent sync operations.
 	// This is important for cross-process safety.
 	return withSyncLock(kv.db, func() error {
-		return kv.syncWithContextLocked(ctx)
+		return kv.syncLocked(ctx)
 	})
 }
 
+// syncLocked syncs like SyncWithContext, bringing the store back online if
+// it succeeds. It must be called with the sync lock held.
+func (kv *KV) syncLocked(ctx context.Context) error {
+	err := kv.syncWithContextLocked(ctx)
+	kv.noteReachability(err)
+	if err != nil {
+		return err
+	}
+	kv.offline.Store(false)
+	return nil
+}
+
 // syncWithContextLocked performs the actual sync work (must be called with sync lock held).
 func (kv *KV) syncWithContextLocked(ctx context.Context) error {
+	// Lazily remove expired TTL keys so the deletions are included in the
+	// backup below and reach other machines.
+	if !kv.readOnly {
+		if _, err := kv.deleteExpired(); err != nil {
+			return fmt.Errorf("failed to delete expired keys: %w", err)
+		}
+	}
+
 	// Check both in-memory counter and durable pending_ops table.
 	// In-memory catches writes from this session, pending_ops catches any
 	// that might have survived a crash.
@@ -384,7 +573,11 @@ func (kv *KV) syncWithContextLocked(ctx context.Context) error {
 	}
 
 	// Then sync from cloud
-	if err := kv.syncFromWithContext(ctx, kv.maxVersion()); err != nil {
+	if kv.incremental {
+		if err := kv.pullOps(ctx); err != nil {
+			return err
+		}
+	} else if err := kv.syncFromWithContext(ctx, kv.maxVersion()); err != nil {
 		return err
 	}
 
@@ -424,10 +617,11 @@ func (kv *KV) IsStale(threshold time.Duration) bool {
 }
 
 // SyncIfStale syncs with the server if the last sync was longer ago than threshold.
-// Returns nil if no sync was needed or if sync succeeded.
+// Returns nil if no sync was needed or if sync succeeded. An offline store
+// doesn't sync, other than to check if an unreachable Charm Cloud is back.
 // This is useful for ensuring fresh data on read operations.
 func (kv *KV) SyncIfStale(threshold time.Duration) error {
-	if !kv.IsStale(threshold) {
+	if kv.skipCloud() || !kv.IsStale(threshold) {
 		return nil
 	}
 	return kv.Sync()
@@ -435,29 +629,45 @@ func (kv *KV) SyncIfStale(threshold time.Duration) error {
 
 // syncAfterWrite tracks writes and triggers backup when threshold is reached.
 // Instead of backing up on every write, this batches writes and only syncs
-// when backupWriteThreshold is reached. This dramatically improves write
+// when the backup threshold is reached. This dramatically improves write
 // performance while maintaining safety through explicit Sync() calls.
 func (kv *KV) syncAfterWrite() error {
+	return kv.syncAfterWriteWithContext(context.Background())
+}
+
+// syncAfterWriteWithContext is like syncAfterWrite but cancels the backup,
+// if one is due, when ctx is done.
+func (kv *KV) syncAfterWriteWithContext(ctx context.Context) error {
 	kv.backupMu.Lock()
 	kv.pendingWrites++
-	shouldBackup := kv.pendingWrites >= backupWriteThreshold
+	// Offline writes keep counting, so the first write back online backs up
+	pending := kv.pendingWrites
+	shouldBackup := !kv.skipCloud() && kv.backupThreshold > 0 && pending >= kv.backupThreshold
 	if shouldBackup {
 		kv.pendingWrites = 0
 	}
 	kv.backupMu.Unlock()
 
 	// Backup synchronously when threshold is reached
-	if shouldBackup {
-		return kv.performBackup()
+	if !shouldBackup {
+		return nil
 	}
-
-	return nil
+	err := kv.performBackup(ctx)
+	if kv.noteReachability(err) {
+		// The write is kept locally and uploaded once the cloud is back
+		kv.backupMu.Lock()
+		kv.pendingWrites += pending
+		kv.backupMu.Unlock()
+		return nil
+	}
+	return err
 }
 
-// performBackup executes the actual backup operation.
+// performBackup executes the actual backup operation, giving up after 60
+// seconds or when ctx is done.
 // This syncs from cloud, gets a new sequence number, and backs up the database.
-func (kv *KV) performBackup() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+func (kv *KV) performBackup(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 	return kv.performBackupWithContext(ctx)
 }
@@ -480,6 +690,10 @@ func (kv *KV) doBackup(ctx context.Context, checkShutdown bool) error {
 		}
 	}
 
+	if kv.incremental {
+		return kv.incrementalBackup(ctx)
+	}
+
 	// First sync any remote changes
 	mv := kv.maxVersion()
 	err := kv.syncFromWithContext(ctx, mv)
@@ -487,6 +701,12 @@ func (kv *KV) doBackup(ctx context.Context, checkShutdown bool) error {
 		return err
 	}
 
+	return kv.snapshotBackup(ctx)
+}
+
+// snapshotBackup uploads a full snapshot of the database under a new
+// sequence number.
+func (kv *KV) snapshotBackup(ctx context.Context) error {
 	// Get next sequence number
 	seq, err := kv.nextSeqWithContext(ctx, kv.name)
 	if err != nil {
@@ -499,7 +719,10 @@ func (kv *KV) doBackup(ctx context.Context, checkShutdown bool) error {
 	}
 
 	// Do the full backup
-	return kv.backupSeq(0, seq)
+	if err := kv.backupSeq(0, seq, syncProgressFrom(ctx)); err != nil {
+		return err
+	}
+	return kv.setPushedSeq(seq)
 }
 
 // maxVersion returns the current max version from the meta table.
@@ -516,9 +739,15 @@ func (kv *KV) setMaxVersion(v uint64) error {
 // Close flushes any pending backups and closes the underlying database.
 func (kv *KV) Close() error {
 	// Signal shutdown FIRST to prevent any new backups from starting
+	kv.autoSyncMu.Lock()
 	kv.shutdownOnce.Do(func() {
 		close(kv.shutdown)
 	})
+	kv.autoSyncMu.Unlock()
+	kv.closeWatchers()
+
+	// Let any background sync finish before touching the database
+	kv.autoSyncWG.Wait()
 
 	// Check if there are pending writes to flush
 	kv.backupMu.Lock()
@@ -528,20 +757,47 @@ func (kv *KV) Close() error {
 
 	// If there are pending writes, flush them now before closing
 	// Use doBackup with checkShutdown=false since we intentionally want to flush
-	if pendingWrites > 0 && !kv.readOnly {
+	if pendingWrites > 0 && !kv.readOnly && !kv.skipCloud() {
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 		_ = kv.doBackup(ctx, false) // Best effort - ignore errors during close
 		cancel()
 	}
 
+	if kv.autoCompactThreshold > 0 && !kv.readOnly {
+		// Best effort - a store that can't be compacted still closes cleanly
+		if ratio, err := sqliteFreelistRatio(kv.db); err == nil && ratio > kv.autoCompactThreshold {
+			_ = kv.Compact()
+		}
+	}
+
+	if kv.checkpointOnClose && !kv.readOnly {
+		if err := sqliteCheckpoint(kv.db); err != nil {
+			_ = kv.db.Close()
+			return err
+		}
+	}
+
 	return kv.db.Close()
 }
 
-// encryptValue encrypts a value using the client's encryption keys.
-// Uses deterministic SIV encryption to ensure the same value always encrypts
-// to the same ciphertext, matching BadgerDB's security model.
-func (kv *KV) encryptValue(value []byte) ([]byte, error) {
-	// Get encryption keys from client
+// encryptKeys returns the client's encryption keys. The keys are fetched on
+// first use and cached until RefreshEncryptKeys is called.
+func (kv *KV) encryptKeys() ([]*charm.EncryptKey, error) {
+	kv.eksMu.RLock()
+	eks := kv.eks
+	kv.eksMu.RUnlock()
+	if len(eks) > 0 {
+		return eks, nil
+	}
+
+	kv.eksMu.Lock()
+	defer kv.eksMu.Unlock()
+
+	// Another caller may have populated the cache while we waited
+	if len(kv.eks) > 0 {
+		return kv.eks, nil
+	}
+
 	eks, err := kv.cc.EncryptKeys()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get encryption keys: %w", err)
@@ -549,6 +805,26 @@ func (kv *KV) encryptValue(value []byte) ([]byte, error) {
 	if len(eks) == 0 {
 		return nil, fmt.Errorf("no encryption keys available")
 	}
+	kv.eks = eks
+	return eks, nil
+}
+
+// RefreshEncryptKeys invalidates the cached encryption keys so the next read
+// or write fetches them from the client again. Call this after rotating keys.
+func (kv *KV) RefreshEncryptKeys() {
+	kv.eksMu.Lock()
+	kv.eks = nil
+	kv.eksMu.Unlock()
+}
+
+// encryptValue encrypts a value using the client's encryption keys.
+// Uses deterministic SIV encryption to ensure the same value always encrypts
+// to the same ciphertext, matching BadgerDB's security model.
+func (kv *KV) encryptValue(value []byte) ([]byte, error) {
+	eks, err := kv.encryptKeys()
+	if err != nil {
+		return nil, err
+	}
 
 	// Use first key for encryption (same as crypt package)
 	var key *charm.EncryptKey
@@ -570,11 +846,16 @@ func (kv *KV) encryptValue(value []byte) ([]byte, error) {
 // decryptValue decrypts a value using the client's encryption keys.
 // Tries all available keys to handle key rotation.
 func (kv *KV) decryptValue(encValue []byte) ([]byte, error) {
-	// Get encryption keys from client
-	eks, err := kv.cc.EncryptKeys()
+	eks, err := kv.encryptKeys()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get encryption keys: %w", err)
+		return nil, err
 	}
+	return decryptValueWithKeys(eks, encValue)
+}
+
+// decryptValueWithKeys decrypts a value using the given encryption keys.
+// Tries all keys to handle key rotation.
+func decryptValueWithKeys(eks []*charm.EncryptKey, encValue []byte) ([]byte, error) {
 	if len(eks) == 0 {
 		return nil, fmt.Errorf("no encryption keys available")
 	}
@@ -607,6 +888,13 @@ func (kv *KV) decryptValue(encValue []byte) ([]byte, error) {
 // Set is a convenience method for setting a key and value.
 // Returns ErrReadOnlyMode if the database is open in read-only mode.
 func (kv *KV) Set(key []byte, value []byte) error {
+	return kv.SetContext(context.Background(), key, value)
+}
+
+// SetContext is like Set but stops waiting on the database, and cancels any
+// automatic backup the write triggers, when ctx is done. A write that has
+// already committed stays committed even if the backup is cancelled.
+func (kv *KV) SetContext(ctx context.Context, key []byte, value []byte) error {
 	if kv.readOnly {
 		return &ErrReadOnlyMode{Operation: "set key"}
 	}
@@ -616,51 +904,45 @@ func (kv *KV) Set(key []byte, value []byte) error {
 		return err
 	}
 	// Use transactional set that records pending op and op-log entry
-	if err := kv.setWithOpLog(key, encValue); err != nil {
+	if err := kv.setWithOpLog(ctx, key, encValue, 0); err != nil {
 		return err
 	}
-	return kv.syncAfterWrite()
+	kv.notify(KeyEvent{Key: bytes.Clone(key), Value: bytes.Clone(value), Type: KeySet})
+	return kv.syncAfterWriteWithContext(ctx)
 }
 
-// setWithOpLog stores a key-value pair with both pending_ops and op_log tracking.
-func (kv *KV) setWithOpLog(key, encValue []byte) error {
-	tx, err := kv.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// SetWithTTL sets a key and value that expires after ttl. Once expired, the
+// key is treated as absent by Get, GetMulti, and Keys, and is deleted during
+// the next Sync so the deletion propagates to other machines.
+// Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "set key"}
 	}
-
-	// Store the key-value pair
-	_, err = tx.Exec("INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)", key, encValue)
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl %s: must be positive", ttl)
+	}
+	encValue, err := kv.encryptValue(value)
 	if err != nil {
-		_ = tx.Rollback()
-		return fmt.Errorf("failed to set key: %w", err)
+		return err
 	}
-
-	// Record pending op (for current full-backup sync)
-	if err := recordPendingOp(tx, "set", key, encValue); err != nil {
-		_ = tx.Rollback()
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	if err := kv.setWithOpLog(context.Background(), key, encValue, expiresAt); err != nil {
 		return err
 	}
+	kv.notify(KeyEvent{Key: bytes.Clone(key), Value: bytes.Clone(value), Type: KeySet})
+	return kv.syncAfterWrite()
+}
 
-	// Record op-log entry (for future incremental sync)
-	// IMPORTANT: Use getNextSeqTx within the transaction to avoid race conditions
-	seq, err := getNextSeqTx(tx)
+// setWithOpLog stores a key-value pair with both pending_ops and op_log tracking.
+// expiresAt is the expiry time in Unix milliseconds, or 0 for no expiry.
+func (kv *KV) setWithOpLog(ctx context.Context, key, encValue []byte, expiresAt int64) error {
+	tx, err := kv.db.BeginTx(ctx, nil)
 	if err != nil {
-		_ = tx.Rollback()
-		return fmt.Errorf("failed to get next seq: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	op := &Op{
-		OpID:         newOpID(),
-		Seq:          seq,
-		OpType:       "set",
-		Key:          key,
-		Value:        encValue,
-		HLCTimestamp: kv.hlc.Now(),
-		DeviceID:     kv.localDevID,
-		Synced:       false,
-	}
-	if err := logOp(tx, op); err != nil {
+	if err := kv.setTx(tx, key, encValue, expiresAt); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
@@ -671,6 +953,24 @@ func (kv *KV) setWithOpLog(key, encValue []byte) error {
 	return nil
 }
 
+// setTx stores a key-value pair and records the pending op and op-log entry
+// within the given transaction. expiresAt is the expiry time in Unix
+// milliseconds, or 0 for no expiry.
+func (kv *KV) setTx(tx *sql.Tx, key, encValue []byte, expiresAt int64) error {
+	// Store the key-value pair
+	_, err := tx.Exec("INSERT OR REPLACE INTO kv (key, value, expires_at) VALUES (?, ?, ?)", key, encValue, nullableInt(expiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to set key: %w", err)
+	}
+
+	// Record pending op (for current full-backup sync)
+	if err := recordPendingOp(tx, "set", key, encValue); err != nil {
+		return err
+	}
+
+	return kv.logOpTx(tx, "set", key, encValue, expiresAt)
+}
+
 // SetReader is a convenience method to set the value for a key to the data
 // read from the provided io.Reader.
 func (kv *KV) SetReader(key []byte, value io.Reader) error {
@@ -683,7 +983,12 @@ func (kv *KV) SetReader(key []byte, value io.Reader) error {
 
 // Get is a convenience method for getting a value from the key value store.
 func (kv *KV) Get(key []byte) ([]byte, error) {
-	encValue, err := sqliteGet(kv.db, key)
+	return kv.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but stops waiting on the database when ctx is done.
+func (kv *KV) GetContext(ctx context.Context, key []byte) ([]byte, error) {
+	encValue, err := sqliteGetContext(ctx, kv.db, key)
 	if err != nil {
 		return nil, err
 	}
@@ -691,66 +996,147 @@ func (kv *KV) Get(key []byte) ([]byte, error) {
 	return kv.decryptValue(encValue)
 }
 
+// GetMulti fetches the values for several keys at once. The values are read
+// with a single query and the encryption keys are fetched only once. Keys
+// that don't exist are absent from the returned map, which is keyed by the
+// string form of each key.
+func (kv *KV) GetMulti(keys [][]byte) (map[string][]byte, error) {
+	encValues, err := sqliteGetMulti(kv.db, keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(encValues))
+	if len(encValues) == 0 {
+		return values, nil
+	}
+
+	eks, err := kv.encryptKeys()
+	if err != nil {
+		return nil, err
+	}
+	for k, encValue := range encValues {
+		v, err := decryptValueWithKeys(eks, encValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key %q: %w", k, err)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
 // Delete is a convenience method for deleting a value from the key value store.
 // Returns ErrReadOnlyMode if the database is open in read-only mode.
 func (kv *KV) Delete(key []byte) error {
+	return kv.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but stops waiting on the database, and cancels
+// any automatic backup the delete triggers, when ctx is done.
+func (kv *KV) DeleteContext(ctx context.Context, key []byte) error {
 	if kv.readOnly {
 		return &ErrReadOnlyMode{Operation: "delete key"}
 	}
 	// Use transactional delete that records pending op and op-log entry
-	if err := kv.deleteWithOpLog(key); err != nil {
+	if err := kv.deleteWithOpLog(ctx, key); err != nil {
 		return err
 	}
-	return kv.syncAfterWrite()
+	kv.notify(KeyEvent{Key: bytes.Clone(key), Type: KeyDeleted})
+	return kv.syncAfterWriteWithContext(ctx)
 }
 
 // deleteWithOpLog removes a key with both pending_ops and op_log tracking.
-func (kv *KV) deleteWithOpLog(key []byte) error {
-	tx, err := kv.db.Begin()
+func (kv *KV) deleteWithOpLog(ctx context.Context, key []byte) error {
+	tx, err := kv.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
+	if err := kv.deleteTx(tx, key); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// deleteTx removes a key and records the pending op and op-log entry within
+// the given transaction.
+func (kv *KV) deleteTx(tx *sql.Tx, key []byte) error {
 	// Delete the key
-	_, err = tx.Exec("DELETE FROM kv WHERE key = ?", key)
+	_, err := tx.Exec("DELETE FROM kv WHERE key = ?", key)
 	if err != nil {
-		_ = tx.Rollback()
 		return fmt.Errorf("failed to delete key: %w", err)
 	}
 
 	// Record pending op (for current full-backup sync)
 	if err := recordPendingOp(tx, "delete", key, nil); err != nil {
-		_ = tx.Rollback()
 		return err
 	}
 
+	return kv.logOpTx(tx, "delete", key, nil, 0)
+}
+
+// logOpTx records a local op-log entry within the given transaction.
+func (kv *KV) logOpTx(tx *sql.Tx, opType string, key, encValue []byte, expiresAt int64) error {
 	// Record op-log entry (for future incremental sync)
 	// IMPORTANT: Use getNextSeqTx within the transaction to avoid race conditions
 	seq, err := getNextSeqTx(tx)
 	if err != nil {
-		_ = tx.Rollback()
 		return fmt.Errorf("failed to get next seq: %w", err)
 	}
 
 	op := &Op{
 		OpID:         newOpID(),
 		Seq:          seq,
-		OpType:       "delete",
+		OpType:       opType,
 		Key:          key,
-		Value:        nil,
+		Value:        encValue,
+		ExpiresAt:    expiresAt,
 		HLCTimestamp: kv.hlc.Now(),
 		DeviceID:     kv.localDevID,
 		Synced:       false,
 	}
-	if err := logOp(tx, op); err != nil {
+	return logOp(tx, op)
+}
+
+// deleteExpired removes all keys whose TTL has passed. Each removal is
+// recorded as a regular delete so it is backed up and replicated.
+// Returns the number of keys removed.
+func (kv *KV) deleteExpired() (int, error) {
+	// Take the write lock up front, as upgrading a read transaction fails
+	// at once if another writer got there first
+	tx, err := beginWriteTx(kv.db)
+	if err != nil {
+		return 0, err
+	}
+
+	keys, err := sqliteExpiredKeys(tx, time.Now().UnixMilli())
+	if err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
+	}
+	if len(keys) == 0 {
+		_ = tx.Rollback()
+		return 0, nil
+	}
+
+	for _, key := range keys {
+		if err := kv.deleteTx(tx, key); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	return nil
+	for _, key := range keys {
+		kv.notify(KeyEvent{Key: key, Type: KeyDeleted})
+	}
+	return len(keys), nil
 }
 
 // Keys returns a list of all keys for this key value store.
@@ -758,11 +1144,93 @@ func (kv *KV) Keys() ([][]byte, error) {
 	return sqliteKeys(kv.db)
 }
 
+// KeysPage returns up to limit keys greater than after, in lexicographic
+// order, and the cursor to pass as after to get the next page. A nil after
+// starts from the first key, and a nil cursor means there are no more keys.
+// Use it instead of Keys to page through a large store without loading every
+// key at once.
+func (kv *KV) KeysPage(after []byte, limit int) ([][]byte, []byte, error) {
+	if limit < 1 {
+		return nil, nil, fmt.Errorf("invalid limit %d", limit)
+	}
+	// Fetch one extra key to know whether there's another page
+	keys, err := sqliteKeysPage(kv.db, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(keys) <= limit {
+		return keys, nil, nil
+	}
+	keys = keys[:limit]
+	return keys, keys[limit-1], nil
+}
+
+// Count returns the number of keys in the store without loading them.
+// Expired keys are not counted.
+func (kv *KV) Count() (int64, error) {
+	return sqliteCount(kv.db)
+}
+
+// DiskSize returns the approximate on-disk size of the local database in
+// bytes: the main database file plus the write-ahead log, if present.
+func (kv *KV) DiskSize() (int64, error) {
+	size, err := sqliteFileSize(kv.db)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(kv.dbPath + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to stat WAL file: %w", err)
+	}
+	if err == nil {
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// Compact reclaims the space left by deleted keys. It checkpoints the
+// write-ahead log, runs VACUUM to rebuild the database file without its
+// free pages, then checkpoints again so the WAL written by VACUUM is
+// truncated. It rewrites the whole database file, so it can take a while on
+// large stores and blocks other writers while it runs.
+//
+// Compact holds the sync lock and returns ErrSyncLockHeld if a Sync is in
+// progress. Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) Compact() error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "compact"}
+	}
+	return withSyncLock(kv.db, func() error {
+		if err := sqliteCheckpoint(kv.db); err != nil {
+			return err
+		}
+		if err := sqliteVacuum(kv.db); err != nil {
+			return err
+		}
+		return sqliteCheckpoint(kv.db)
+	})
+}
+
 // Client returns the underlying *client.Client.
 func (kv *KV) Client() *client.Client {
 	return kv.cc
 }
 
+// DeviceID returns the device ID recorded with this machine's ops and
+// backups. See WithDeviceID.
+func (kv *KV) DeviceID() string {
+	return kv.localDevID
+}
+
+// HLCNow returns the current timestamp of the hybrid logical clock that
+// orders this machine's ops: the latest it has given out or seen in ops
+// pulled from other machines, or 0 if there is none yet. Reading it doesn't
+// advance the clock, so it helps when debugging the order Sync applies ops
+// in without changing it.
+func (kv *KV) HLCNow() int64 {
+	return kv.hlc.Last()
+}
+
 // Reset deletes the local database and rebuilds with a fresh sync
 // from the Charm Cloud.
 func (kv *KV) Reset() error {
@@ -800,6 +1268,11 @@ func (kv *KV) Reset() error {
 //
 // The lock is only held for the duration of fn, allowing other processes to
 // write between calls.
+//
+// Writes made in fn are committed to the local database before Do returns,
+// so a later Do or DoReadOnly call always reads them, even if the cloud
+// backup started by Close fails. Pass WithCheckpointOnClose to also fold
+// the WAL into the main database file before Do returns.
 func Do(name string, fn func(*KV) error, opts ...Option) (err error) {
 	kv, err := OpenWithDefaults(name, opts...)
 	if err != nil {
@@ -177,7 +177,7 @@ func (kv *KV) saveManifest(m *Manifest) error {
 
 // backupWithManifest creates a content-addressed backup and updates the manifest.
 // This is idempotent - uploading the same content twice is safe.
-func (kv *KV) backupWithManifest(seq uint64) error {
+func (kv *KV) backupWithManifest(seq uint64, progress *syncProgress) error {
 	// Create the backup
 	buf := bytes.NewBuffer(nil)
 	if err := sqliteBackup(kv.dbPath, buf); err != nil {
@@ -192,17 +192,18 @@ func (kv *KV) backupWithManifest(seq uint64) error {
 		Seq:       seq,
 		Hash:      hash,
 		CreatedAt: time.Now().UTC(),
-		DeviceID:  kv.deviceID(),
+		DeviceID:  kv.localDevID,
 	}
 
 	// Upload backup with content-addressed key
 	// This is idempotent - same content = same key
 	backupKey := entry.StorageKey(kv.name)
+	size := int64(len(backupData))
 	src := &kvFile{
-		data: bytes.NewBuffer(backupData),
+		data: progress.reader(SyncUploading, bytes.NewReader(backupData), size),
 		info: &kvFileInfo{
 			name:    backupKey,
-			size:    int64(len(backupData)),
+			size:    size,
 			mode:    fs.FileMode(0o660),
 			modTime: time.Now(),
 		},
@@ -227,15 +228,3 @@ func (kv *KV) backupWithManifest(seq uint64) error {
 
 	return nil
 }
-
-// deviceID returns a stable identifier for this device/instance.
-// Used for debugging which device created a backup.
-func (kv *KV) deviceID() string {
-	// Use the charm user ID if available, otherwise empty
-	if kv.cc != nil {
-		if user, err := kv.cc.Auth(); err == nil {
-			return user.ID
-		}
-	}
-	return ""
-}
@@ -0,0 +1,63 @@
+// ABOUTME: Offline mode that keeps writes local until the next Sync
+// ABOUTME: Lets a store be used without network access, see WithOffline
+
+package kv
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// OfflineProbeInterval is how long a store that found the Charm Cloud
+// unreachable waits before trying it again. Until then it makes no automatic
+// backups or syncs, as if it had been taken offline.
+const OfflineProbeInterval = 30 * time.Second
+
+// IsOffline reports whether the store is offline, making no automatic
+// backups or syncs, either because it was taken offline or because it found
+// the Charm Cloud unreachable. See WithOffline.
+func (kv *KV) IsOffline() bool {
+	return kv.offline.Load() || kv.unreachableAt.Load() != 0
+}
+
+// SetOffline takes the store offline or brings it back online, such as when
+// the application detects it has lost or regained network access. Writes
+// made while offline are uploaded by the next Sync, or by the next automatic
+// backup once online.
+func (kv *KV) SetOffline(offline bool) {
+	kv.offline.Store(offline)
+	kv.unreachableAt.Store(0)
+}
+
+// skipCloud reports whether automatic backups and syncs should be skipped:
+// the store was taken offline, or found the Charm Cloud unreachable less
+// than OfflineProbeInterval ago.
+func (kv *KV) skipCloud() bool {
+	if kv.offline.Load() {
+		return true
+	}
+	at := kv.unreachableAt.Load()
+	return at != 0 && time.Since(time.Unix(0, at)) < OfflineProbeInterval
+}
+
+// noteReachability records whether a backup or sync that returned err
+// reached the Charm Cloud, reporting whether it failed for lack of network.
+func (kv *KV) noteReachability(err error) bool {
+	if err == nil {
+		kv.unreachableAt.Store(0)
+		return false
+	}
+	if !isNetworkError(err) {
+		return false
+	}
+	kv.unreachableAt.Store(time.Now().UnixNano())
+	return true
+}
+
+// isNetworkError reports whether err comes from failing to reach a server,
+// such as a DNS lookup, dial or timeout failure.
+func isNetworkError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,137 @@
+// ABOUTME: Tests for offline mode.
+// ABOUTME: Verifies offline stores keep writes local and skip all cloud syncs.
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOffline_WritesStayLocal(t *testing.T) {
+	// No client is set, so any backup or sync would fail or panic
+	kv := newTestKV(t)
+	kv.backupThreshold = 1
+	kv.SetOffline(true)
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := kv.Set([]byte(k), []byte("value "+k)); err != nil {
+			t.Fatalf("Set(%q) failed: %v", k, err)
+		}
+	}
+	if err := kv.Delete([]byte("c")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	v, err := kv.Get([]byte("a"))
+	if err != nil || string(v) != "value a" {
+		t.Errorf("Get = %q, %v, want %q", v, err, "value a")
+	}
+
+	if kv.pendingWrites != 4 {
+		t.Errorf("pendingWrites = %d, want 4", kv.pendingWrites)
+	}
+	n, err := countPendingOps(kv.db)
+	if err != nil || n != 4 {
+		t.Errorf("countPendingOps = %d, %v, want 4", n, err)
+	}
+	ops, err := getUnsyncedOps(kv.db, 10)
+	if err != nil || len(ops) != 4 {
+		t.Errorf("getUnsyncedOps returned %d ops, %v, want 4", len(ops), err)
+	}
+
+	if err := kv.SyncIfStale(time.Nanosecond); err != nil {
+		t.Errorf("SyncIfStale failed offline: %v", err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestOffline_SetOffline(t *testing.T) {
+	kv := newTestKV(t)
+	if kv.IsOffline() {
+		t.Error("expected a new store to be online")
+	}
+	kv.SetOffline(true)
+	if !kv.IsOffline() {
+		t.Error("expected the store to be offline")
+	}
+	kv.SetOffline(false)
+	if kv.IsOffline() {
+		t.Error("expected the store to be back online")
+	}
+
+	cfg := &Config{}
+	WithOffline()(cfg)
+	if !cfg.offline {
+		t.Error("expected WithOffline to set offline")
+	}
+}
+
+func TestOffline_SkipsAutoSync(t *testing.T) {
+	kv := newTestKV(t)
+	kv.SetOffline(true)
+	errs := make(chan error, 10)
+	kv.syncErrorHandler = func(err error) { errs <- err }
+
+	// A closed database would make every sync fail
+	_ = kv.db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	kv.StartAutoSync(ctx, 10*time.Millisecond)
+	select {
+	case err := <-errs:
+		t.Errorf("expected no sync while offline, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	kv.autoSyncWG.Wait()
+}
+
+func TestOffline_DetectsUnreachableCloud(t *testing.T) {
+	kv := newTestKV(t)
+	kv.backupThreshold = 1
+
+	if kv.noteReachability(errors.New("server error: 500")) || kv.IsOffline() {
+		t.Fatal("expected a server error not to take the store offline")
+	}
+	dnsErr := &net.DNSError{Err: "no such host", Name: "charm.example.com", IsNotFound: true}
+	if !kv.noteReachability(fmt.Errorf("failed to upload backup: %w", dnsErr)) {
+		t.Fatal("expected a DNS failure to count as a network error")
+	}
+	if !kv.IsOffline() || !kv.skipCloud() {
+		t.Fatal("expected the store to go offline")
+	}
+
+	// No client is set, so a backup attempt would panic
+	for _, k := range []string{"a", "b"} {
+		if err := kv.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatalf("Set(%q) failed: %v", k, err)
+		}
+	}
+	if kv.pendingWrites != 2 {
+		t.Errorf("pendingWrites = %d, want 2", kv.pendingWrites)
+	}
+
+	// Once the probe interval has passed the cloud is tried again
+	kv.unreachableAt.Store(time.Now().Add(-OfflineProbeInterval).UnixNano())
+	if kv.skipCloud() {
+		t.Error("expected the cloud to be tried again after the probe interval")
+	}
+	if !kv.IsOffline() {
+		t.Error("expected the store to stay offline until the cloud is reached")
+	}
+	kv.noteReachability(nil)
+	if kv.IsOffline() {
+		t.Error("expected reaching the cloud to bring the store back online")
+	}
+
+	kv.noteReachability(dnsErr)
+	kv.SetOffline(false)
+	if kv.IsOffline() || kv.skipCloud() {
+		t.Error("expected SetOffline(false) to bring the store back online")
+	}
+}
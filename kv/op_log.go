@@ -4,6 +4,7 @@
 package kv
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
 
@@ -28,6 +29,10 @@ type Op struct {
 	// Value is the new value (nil for delete operations).
 	Value []byte `json:"value,omitempty"`
 
+	// ExpiresAt is when a set value expires, in Unix milliseconds.
+	// Zero means the value never expires.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
 	// HLCTimestamp is the hybrid logical clock timestamp.
 	// Used for ordering and conflict resolution.
 	HLCTimestamp int64 `json:"hlc_timestamp"`
@@ -42,9 +47,9 @@ type Op struct {
 // logOp records an operation in the op_log table.
 func logOp(tx *sql.Tx, op *Op) error {
 	_, err := tx.Exec(`
-		INSERT INTO op_log (op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, op.OpID, op.Seq, op.OpType, op.Key, op.Value, op.HLCTimestamp, op.DeviceID, boolToInt(op.Synced))
+		INSERT INTO op_log (op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, op.OpID, op.Seq, op.OpType, op.Key, op.Value, op.HLCTimestamp, op.DeviceID, boolToInt(op.Synced), nullableInt(op.ExpiresAt))
 	if err != nil {
 		return fmt.Errorf("failed to log op: %w", err)
 	}
@@ -53,8 +58,6 @@ func logOp(tx *sql.Tx, op *Op) error {
 
 // hasOp checks if an operation with the given ID already exists.
 // Used for idempotency checks.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
 func hasOp(db *sql.DB, opID string) (bool, error) {
 	var exists int
 	err := db.QueryRow("SELECT 1 FROM op_log WHERE op_id = ?", opID).Scan(&exists)
@@ -69,11 +72,9 @@ func hasOp(db *sql.DB, opID string) (bool, error) {
 
 // getUnsyncedOps returns all ops from op_log that haven't been synced yet.
 // Ops are returned in sequence order.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
 func getUnsyncedOps(db *sql.DB, limit int) ([]Op, error) {
 	rows, err := db.Query(`
-		SELECT op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced
+		SELECT op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced, expires_at
 		FROM op_log
 		WHERE synced = 0
 		ORDER BY seq ASC
@@ -93,7 +94,7 @@ func getUnsyncedOps(db *sql.DB, limit int) ([]Op, error) {
 //nolint:unused // Reserved for Phase 3 incremental sync implementation
 func getOpsAfter(db *sql.DB, afterSeq int64, limit int) ([]Op, error) {
 	rows, err := db.Query(`
-		SELECT op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced
+		SELECT op_id, seq, op_type, key, value, hlc_timestamp, device_id, synced, expires_at
 		FROM op_log
 		WHERE seq > ?
 		ORDER BY seq ASC
@@ -108,8 +109,6 @@ func getOpsAfter(db *sql.DB, afterSeq int64, limit int) ([]Op, error) {
 }
 
 // markOpsSynced marks the given ops as synced.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
 func markOpsSynced(db *sql.DB, opIDs []string) error {
 	if len(opIDs) == 0 {
 		return nil
@@ -142,23 +141,28 @@ func markOpsSynced(db *sql.DB, opIDs []string) error {
 
 // getLatestHLCForKey returns the latest HLC timestamp for a key.
 // Returns 0 if no ops exist for the key.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
 func getLatestHLCForKey(db *sql.DB, key []byte) (int64, error) {
-	var hlc sql.NullInt64
+	hlc, _, err := getLatestOpForKey(db, key)
+	return hlc, err
+}
+
+// getLatestOpForKey returns the HLC timestamp and device ID of the op that
+// wins for a key: the one with the latest timestamp, with ties going to the
+// greater device ID. Returns 0 and "" if no ops exist for the key.
+func getLatestOpForKey(db *sql.DB, key []byte) (int64, string, error) {
+	var hlc int64
+	var devID string
 	err := db.QueryRow(`
-		SELECT MAX(hlc_timestamp) FROM op_log WHERE key = ?
-	`, key).Scan(&hlc)
+		SELECT hlc_timestamp, device_id FROM op_log WHERE key = ?
+		ORDER BY hlc_timestamp DESC, device_id DESC LIMIT 1
+	`, key).Scan(&hlc, &devID)
 	if err == sql.ErrNoRows {
-		return 0, nil
+		return 0, "", nil
 	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get latest HLC: %w", err)
+		return 0, "", fmt.Errorf("failed to get latest HLC: %w", err)
 	}
-	if !hlc.Valid {
-		return 0, nil
-	}
-	return hlc.Int64, nil
+	return hlc, devID, nil
 }
 
 // getNextSeqTx returns the next local sequence number within a transaction.
@@ -193,59 +197,88 @@ func getNextSeq(db *sql.DB) (int64, error) {
 }
 
 // applyOp applies a remote operation to the local database.
-// Uses last-write-wins conflict resolution based on HLC timestamp.
+// Uses last-write-wins conflict resolution based on HLC timestamp, with the
+// device ID breaking ties.
 // Returns true if the operation was applied, false if it was superseded.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
-func applyOp(db *sql.DB, op *Op) (bool, error) {
+// If the op targets a key that already holds a different value, the returned
+// Conflict describes it, with values still encrypted.
+func applyOp(db *sql.DB, op *Op) (bool, *Conflict, error) {
 	// Check if we already have this op (idempotency)
 	exists, err := hasOp(db, op.OpID)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
 	if exists {
-		return false, nil // Already applied, no-op
+		return false, nil, nil // Already applied, no-op
 	}
 
-	// Check if there's a newer op for this key
-	latestHLC, err := getLatestHLCForKey(db, op.Key)
+	// Check if there's a newer op for this key. Ops written at the same time
+	// on different devices are ordered by device ID, so every machine picks
+	// the same winner.
+	latestHLC, latestDevID, err := getLatestOpForKey(db, op.Key)
 	if err != nil {
-		return false, err
+		return false, nil, err
+	}
+	newer := latestHLC == 0 || op.HLCTimestamp > latestHLC ||
+		(op.HLCTimestamp == latestHLC && op.DeviceID > latestDevID)
+
+	// Capture the current value to detect conflicts
+	localValue, err := sqliteGet(db, op.Key)
+	if err != nil && err != ErrMissingKey {
+		return false, nil, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		return false, fmt.Errorf("failed to begin transaction: %w", err)
+		return false, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	// Always log the op for history
 	if err := logOp(tx, op); err != nil {
 		_ = tx.Rollback()
-		return false, err
+		return false, nil, err
 	}
 
 	// Only apply if this op is newer than existing
-	if op.HLCTimestamp > latestHLC || latestHLC == 0 {
+	if newer {
 		// Apply the operation
 		if op.OpType == "set" {
-			if _, err := tx.Exec("INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)", op.Key, op.Value); err != nil {
+			if _, err := tx.Exec("INSERT OR REPLACE INTO kv (key, value, expires_at) VALUES (?, ?, ?)", op.Key, op.Value, nullableInt(op.ExpiresAt)); err != nil {
 				_ = tx.Rollback()
-				return false, fmt.Errorf("failed to apply set: %w", err)
+				return false, nil, fmt.Errorf("failed to apply set: %w", err)
 			}
 		} else if op.OpType == "delete" {
 			if _, err := tx.Exec("DELETE FROM kv WHERE key = ?", op.Key); err != nil {
 				_ = tx.Rollback()
-				return false, fmt.Errorf("failed to apply delete: %w", err)
+				return false, nil, fmt.Errorf("failed to apply delete: %w", err)
 			}
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return false, fmt.Errorf("failed to commit: %w", err)
+		return false, nil, fmt.Errorf("failed to commit: %w", err)
 	}
 
 	// Return true if we actually modified the KV store
-	return op.HLCTimestamp > latestHLC || latestHLC == 0, nil
+	applied := newer
+
+	var conflict *Conflict
+	if localValue != nil && (op.OpType == "delete" || !bytes.Equal(localValue, op.Value)) {
+		conflict = &Conflict{
+			Key:        op.Key,
+			LocalValue: localValue,
+			LocalHLC:   latestHLC,
+			RemoteHLC:  op.HLCTimestamp,
+			Winner:     LocalWins,
+		}
+		if op.OpType == "set" {
+			conflict.RemoteValue = op.Value
+		}
+		if applied {
+			conflict.Winner = RemoteWins
+		}
+	}
+	return applied, conflict, nil
 }
 
 // newOpID generates a new unique operation ID.
@@ -254,17 +287,17 @@ func newOpID() string {
 }
 
 // scanOps scans rows into Op structs.
-//
-//nolint:unused // Reserved for Phase 3 incremental sync implementation
 func scanOps(rows *sql.Rows) ([]Op, error) {
 	var ops []Op
 	for rows.Next() {
 		var op Op
 		var syncedInt int
-		if err := rows.Scan(&op.OpID, &op.Seq, &op.OpType, &op.Key, &op.Value, &op.HLCTimestamp, &op.DeviceID, &syncedInt); err != nil {
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&op.OpID, &op.Seq, &op.OpType, &op.Key, &op.Value, &op.HLCTimestamp, &op.DeviceID, &syncedInt, &expiresAt); err != nil {
 			return nil, fmt.Errorf("failed to scan op: %w", err)
 		}
 		op.Synced = syncedInt == 1
+		op.ExpiresAt = expiresAt.Int64
 		ops = append(ops, op)
 	}
 	if err := rows.Err(); err != nil {
@@ -280,3 +313,11 @@ func boolToInt(b bool) int {
 	}
 	return 0
 }
+
+// nullableInt converts zero to NULL for optional SQLite integer columns.
+func nullableInt(v int64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
@@ -278,7 +278,7 @@ func TestApplyOp_NewOp(t *testing.T) {
 		Synced:       true,
 	}
 
-	applied, err := applyOp(db, op)
+	applied, _, err := applyOp(db, op)
 	if err != nil {
 		t.Fatalf("applyOp failed: %v", err)
 	}
@@ -318,7 +318,7 @@ func TestApplyOp_Idempotent(t *testing.T) {
 	}
 
 	// Apply first time
-	applied1, err := applyOp(db, op)
+	applied1, _, err := applyOp(db, op)
 	if err != nil {
 		t.Fatalf("first applyOp failed: %v", err)
 	}
@@ -327,7 +327,7 @@ func TestApplyOp_Idempotent(t *testing.T) {
 	}
 
 	// Apply second time (should be no-op)
-	applied2, err := applyOp(db, op)
+	applied2, _, err := applyOp(db, op)
 	if err != nil {
 		t.Fatalf("second applyOp failed: %v", err)
 	}
@@ -357,7 +357,7 @@ func TestApplyOp_ConflictResolution(t *testing.T) {
 		DeviceID:     "device-a",
 		Synced:       true,
 	}
-	applied1, err := applyOp(db, newerOp)
+	applied1, _, err := applyOp(db, newerOp)
 	if err != nil {
 		t.Fatalf("applyOp newer failed: %v", err)
 	}
@@ -376,7 +376,7 @@ func TestApplyOp_ConflictResolution(t *testing.T) {
 		DeviceID:     "device-b",
 		Synced:       true,
 	}
-	applied2, err := applyOp(db, olderOp)
+	applied2, _, err := applyOp(db, olderOp)
 	if err != nil {
 		t.Fatalf("applyOp older failed: %v", err)
 	}
@@ -427,7 +427,7 @@ func TestApplyOp_Delete(t *testing.T) {
 		Synced:       true,
 	}
 
-	applied, err := applyOp(db, op)
+	applied, _, err := applyOp(db, op)
 	if err != nil {
 		t.Fatalf("applyOp failed: %v", err)
 	}
@@ -452,3 +452,65 @@ func TestNewOpID_Unique(t *testing.T) {
 		seen[id] = true
 	}
 }
+
+func TestKV_DeviceIDAndHLCNow(t *testing.T) {
+	kv := newTestKV(t)
+	if got := kv.DeviceID(); got != "test-device" {
+		t.Errorf("DeviceID = %q, want %q", got, "test-device")
+	}
+
+	if err := kv.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	ops, err := getUnsyncedOps(kv.db, -1)
+	if err != nil || len(ops) != 1 {
+		t.Fatalf("getUnsyncedOps returned %d ops, %v, want 1", len(ops), err)
+	}
+	if ops[0].DeviceID != "test-device" {
+		t.Errorf("op DeviceID = %q, want %q", ops[0].DeviceID, "test-device")
+	}
+	// Reading the clock doesn't advance it
+	for i := 0; i < 2; i++ {
+		if now := kv.HLCNow(); now != ops[0].HLCTimestamp {
+			t.Errorf("HLCNow = %d, want the last op's %d", now, ops[0].HLCTimestamp)
+		}
+	}
+}
+
+func TestWithDeviceID(t *testing.T) {
+	cfg := &Config{}
+	WithDeviceID("laptop")(cfg)
+	if cfg.deviceID != "laptop" {
+		t.Errorf("deviceID = %q, want %q", cfg.deviceID, "laptop")
+	}
+}
+
+func TestApplyOp_TieBreaksByDeviceID(t *testing.T) {
+	// Ops with the same timestamp must resolve the same way on every machine,
+	// whichever order they arrive in
+	for _, order := range [][]string{{"device-a", "device-b"}, {"device-b", "device-a"}} {
+		db, err := openSQLite(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		for _, dev := range order {
+			op := &Op{
+				OpID:         newOpID(),
+				OpType:       "set",
+				Key:          []byte("k"),
+				Value:        []byte(dev),
+				HLCTimestamp: 1000,
+				DeviceID:     dev,
+				Synced:       true,
+			}
+			if _, _, err := applyOp(db, op); err != nil {
+				t.Fatalf("applyOp failed: %v", err)
+			}
+		}
+		v, err := sqliteGet(db, []byte("k"))
+		if err != nil || string(v) != "device-b" {
+			t.Errorf("order %v: Get = %q, %v, want %q", order, v, err, "device-b")
+		}
+		_ = db.Close()
+	}
+}
@@ -0,0 +1,133 @@
+// ABOUTME: Progress reporting for Sync
+// ABOUTME: Carries an optional progress callback through the sync context
+
+package kv
+
+import (
+	"context"
+	"io"
+)
+
+// SyncPhase identifies what a Sync is doing when it reports progress.
+type SyncPhase string
+
+const (
+	// SyncDownloading is reported as snapshots and op batches are downloaded.
+	SyncDownloading SyncPhase = "downloading remote"
+	// SyncApplying is reported as downloaded op batches are applied.
+	SyncApplying SyncPhase = "applying ops"
+	// SyncUploading is reported as local changes are uploaded.
+	SyncUploading SyncPhase = "uploading backup"
+)
+
+// SyncProgress describes how far a Sync has got.
+type SyncProgress struct {
+	// Phase is the stage the sync is in.
+	Phase SyncPhase
+
+	// BytesTransferred and TotalBytes cover the file currently being
+	// downloaded or uploaded.
+	BytesTransferred int64
+	TotalBytes       int64
+
+	// OpsApplied is the number of remote ops applied so far. Only
+	// incremental sync applies ops; a snapshot restore replaces the
+	// database in one step.
+	OpsApplied int
+
+	// Done and Total count download steps, where a step is one restored
+	// snapshot or one applied op batch. Total may be revised while syncing
+	// as more is learned about what needs to be applied.
+	Done  int
+	Total int
+}
+
+// SyncProgressFunc receives progress while Sync downloads, applies, and
+// uploads changes. It is called from the goroutine running Sync.
+type SyncProgressFunc func(p SyncProgress)
+
+// syncProgress accumulates progress for a single Sync and reports each
+// update. A nil *syncProgress ignores updates.
+type syncProgress struct {
+	fn SyncProgressFunc
+	p  SyncProgress
+}
+
+// steps records how many download steps are done out of total.
+func (sp *syncProgress) steps(done, total int) {
+	if sp == nil {
+		return
+	}
+	if sp.p.Phase == "" {
+		sp.p.Phase = SyncDownloading
+	}
+	sp.p.Done, sp.p.Total = done, total
+	sp.fn(sp.p)
+}
+
+// transfer records n of total bytes moved in the given phase.
+func (sp *syncProgress) transfer(phase SyncPhase, n, total int64) {
+	if sp == nil {
+		return
+	}
+	sp.p.Phase = phase
+	sp.p.BytesTransferred, sp.p.TotalBytes = n, total
+	sp.fn(sp.p)
+}
+
+// reader returns r, which holds total bytes, recording the bytes read from
+// it as transferred in the given phase. A nil *syncProgress returns r.
+func (sp *syncProgress) reader(phase SyncPhase, r io.Reader, total int64) io.Reader {
+	if sp == nil {
+		return r
+	}
+	sp.transfer(phase, 0, total)
+	return &progressReader{r: r, sp: sp, phase: phase, total: total}
+}
+
+// progressReader records how much of r has been read.
+type progressReader struct {
+	r     io.Reader
+	sp    *syncProgress
+	phase SyncPhase
+	read  int64
+	total int64
+}
+
+// Read implements io.Reader.
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.read += int64(n)
+		pr.sp.transfer(pr.phase, pr.read, pr.total)
+	}
+	return n, err
+}
+
+// applied records n more remote ops applied.
+func (sp *syncProgress) applied(n int) {
+	if sp == nil {
+		return
+	}
+	sp.p.Phase = SyncApplying
+	sp.p.OpsApplied += n
+	sp.fn(sp.p)
+}
+
+type syncProgressKey struct{}
+
+// withSyncProgress returns a context carrying a progress callback. A nil fn
+// hides progress from everything run with the returned context.
+func withSyncProgress(ctx context.Context, fn SyncProgressFunc) context.Context {
+	var sp *syncProgress
+	if fn != nil {
+		sp = &syncProgress{fn: fn}
+	}
+	return context.WithValue(ctx, syncProgressKey{}, sp)
+}
+
+// syncProgressFrom returns the progress tracker carried by ctx, or nil.
+func syncProgressFrom(ctx context.Context) *syncProgress {
+	sp, _ := ctx.Value(syncProgressKey{}).(*syncProgress)
+	return sp
+}
@@ -0,0 +1,84 @@
+// ABOUTME: Tests for Sync progress reporting helpers.
+// ABOUTME: Verifies the progress tracker accumulates state and survives the sync context.
+package kv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSyncProgressContext(t *testing.T) {
+	if sp := syncProgressFrom(context.Background()); sp != nil {
+		t.Error("expected no progress tracker on a plain context")
+	}
+
+	var got []SyncProgress
+	ctx := withSyncProgress(context.Background(), func(p SyncProgress) {
+		got = append(got, p)
+	})
+	sp := syncProgressFrom(ctx)
+	sp.steps(0, 2)
+	sp.transfer(SyncDownloading, 100, 100)
+	sp.applied(3)
+	sp.applied(2)
+	sp.steps(2, 2)
+	sp.transfer(SyncUploading, 0, 50)
+
+	if len(got) != 6 {
+		t.Fatalf("expected 6 progress calls, got %d", len(got))
+	}
+	if got[0].Phase != SyncDownloading || got[0].Total != 2 {
+		t.Errorf("unexpected first progress: %+v", got[0])
+	}
+	if p := got[3]; p.Phase != SyncApplying || p.OpsApplied != 5 {
+		t.Errorf("expected 5 ops applied, got %+v", p)
+	}
+	last := got[5]
+	if last.Phase != SyncUploading || last.BytesTransferred != 0 || last.TotalBytes != 50 {
+		t.Errorf("unexpected upload progress: %+v", last)
+	}
+	// Earlier counters carry over into later phases
+	if last.Done != 2 || last.OpsApplied != 5 {
+		t.Errorf("expected counters to carry over, got %+v", last)
+	}
+
+	// Hiding progress for a nested step must not panic
+	cleared := syncProgressFrom(withSyncProgress(ctx, nil))
+	cleared.steps(1, 1)
+	cleared.transfer(SyncUploading, 1, 1)
+	cleared.applied(1)
+	if len(got) != 6 {
+		t.Errorf("expected hidden progress not to be reported, got %d calls", len(got))
+	}
+}
+
+func TestSyncProgressReader(t *testing.T) {
+	var got []SyncProgress
+	sp := syncProgressFrom(withSyncProgress(context.Background(), func(p SyncProgress) {
+		got = append(got, p)
+	}))
+	data := []byte("uploaded a byte at a time")
+	r := sp.reader(SyncUploading, iotest.OneByteReader(bytes.NewReader(data)), int64(len(data)))
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	// The start and then every byte read
+	if len(got) != len(data)+1 {
+		t.Fatalf("expected %d progress calls, got %d", len(data)+1, len(got))
+	}
+	for i, p := range got {
+		if p.Phase != SyncUploading || p.BytesTransferred != int64(i) || p.TotalBytes != int64(len(data)) {
+			t.Fatalf("unexpected progress %d: %+v", i, p)
+		}
+	}
+
+	var hidden *syncProgress
+	src := bytes.NewReader(data)
+	if r := hidden.reader(SyncUploading, src, 1); r != io.Reader(src) {
+		t.Error("expected hidden progress to return the reader itself")
+	}
+}
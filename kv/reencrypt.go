@@ -0,0 +1,128 @@
+// ABOUTME: Re-encryption of every KV value after an encrypt key rotation
+// ABOUTME: Rewrites values under the default key in chunks, then syncs them up
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/charm/fs"
+)
+
+// reEncryptChunk is how many values ReEncryptAll rewrites per transaction.
+const reEncryptChunk = 1000
+
+// ReEncryptAll rewrites every value in the store so it's encrypted with the
+// current default encrypt key, then syncs. Run it after
+// client.RotateEncryptKey so old keys no longer protect any value.
+//
+// It first syncs to pick up remote changes, then decrypts each value with
+// whichever key it was written with and stores it again, recording a regular
+// write so the new ciphertext reaches other machines. Values are rewritten
+// in transactions of up to 1000 keys; if an error occurs partway through,
+// earlier chunks stay rewritten and it's safe to run again. Keys and
+// backup names are encrypted with the account's oldest key and don't change.
+//
+// This is heavy: it touches every value and uploads a full backup. Run it
+// offline, while no other machine is writing to the store. With
+// WithIncrementalSync, op batches uploaded before the rotation stay
+// encrypted with the old key until the store is compacted into a snapshot.
+// It holds the sync lock and returns ErrSyncLockHeld if a Sync is in
+// progress. Returns ErrReadOnlyMode if the database is open in read-only mode.
+func (kv *KV) ReEncryptAll() error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "re-encrypt"}
+	}
+
+	// Hold the sync lock throughout, so no sync runs with the FS being
+	// replaced or sees values half rewritten
+	return withSyncLock(kv.db, func() error {
+		// Pick up the new default key for values and backups
+		kv.RefreshEncryptKeys()
+		cfs, err := fs.NewFSWithClient(kv.cc)
+		if err != nil {
+			return err
+		}
+		kv.fs = cfs
+
+		if err := kv.syncWithTimeout(); err != nil {
+			return err
+		}
+
+		var after []byte
+		total := 0
+		for {
+			n, last, err := kv.reEncryptChunk(after)
+			if err != nil {
+				return err
+			}
+			total += n
+			if last == nil {
+				break
+			}
+			after = last
+		}
+		if total == 0 {
+			return nil
+		}
+		return kv.syncWithTimeout()
+	})
+}
+
+// syncWithTimeout syncs like Sync while the sync lock is held.
+func (kv *KV) syncWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return kv.syncLocked(ctx)
+}
+
+// reEncryptChunk rewrites up to reEncryptChunk values with keys sorting after
+// the given key in a single transaction. It returns how many values changed
+// and the last key it looked at, or nil once there are no keys left.
+func (kv *KV) reEncryptChunk(after []byte) (int, []byte, error) {
+	tx, err := kv.db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rows, err := sqliteValuesAfter(tx, after, reEncryptChunk)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, nil, err
+	}
+	if len(rows) == 0 {
+		_ = tx.Rollback()
+		return 0, nil, nil
+	}
+
+	n := 0
+	for _, r := range rows {
+		pt, err := kv.decryptValue(r.value)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, nil, fmt.Errorf("failed to decrypt key %q: %w", r.key, err)
+		}
+		enc, err := kv.encryptValue(pt)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, nil, err
+		}
+		// Values already under the default key encrypt to the same bytes
+		if bytes.Equal(enc, r.value) {
+			continue
+		}
+		if err := kv.setTx(tx, r.key, enc, r.expiresAt); err != nil {
+			_ = tx.Rollback()
+			return 0, nil, err
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return n, rows[len(rows)-1].key, nil
+}
@@ -0,0 +1,65 @@
+// ABOUTME: Tests for re-encrypting KV values after an encrypt key rotation.
+// ABOUTME: Exercises the chunked rewrite directly, without a Charm Cloud server.
+package kv
+
+import (
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestReEncryptChunk(t *testing.T) {
+	kv := newTestKV(t)
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for k, v := range want {
+		if err := kv.Set([]byte(k), []byte(v)); err != nil {
+			t.Fatalf("Set(%q) failed: %v", k, err)
+		}
+	}
+	if err := clearPendingOps(kv.db); err != nil {
+		t.Fatalf("clearPendingOps failed: %v", err)
+	}
+
+	// Rotate: the new key is the default, the old one is kept for decryption
+	kv.eks = []*charm.EncryptKey{testEncryptKey("n"), testEncryptKey("a")}
+
+	n, last, err := kv.reEncryptChunk(nil)
+	if err != nil {
+		t.Fatalf("reEncryptChunk failed: %v", err)
+	}
+	if n != 3 || string(last) != "c" {
+		t.Errorf("expected 3 values rewritten up to %q, got %d up to %q", "c", n, last)
+	}
+	if n, last, err := kv.reEncryptChunk(last); err != nil || n != 0 || last != nil {
+		t.Errorf("expected the end of the store, got %d, %q, %v", n, last, err)
+	}
+	pending, err := hasPendingOps(kv.db)
+	if err != nil || !pending {
+		t.Errorf("expected the rewrites to be pending a backup, got %v, %v", pending, err)
+	}
+
+	// Every value must now decrypt with the new key alone
+	kv.eks = []*charm.EncryptKey{testEncryptKey("n")}
+	for k, v := range want {
+		got, err := kv.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("Get(%q) failed after re-encryption: %v", k, err)
+		}
+		if string(got) != v {
+			t.Errorf("Get(%q) = %q, want %q", k, got, v)
+		}
+	}
+
+	// Running again changes nothing
+	if n, _, err := kv.reEncryptChunk(nil); err != nil || n != 0 {
+		t.Errorf("expected no values to change on a second run, got %d, %v", n, err)
+	}
+}
+
+func TestReEncryptAll_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+	if err := kv.ReEncryptAll(); !IsReadOnly(err) {
+		t.Errorf("expected ErrReadOnlyMode, got %v", err)
+	}
+}
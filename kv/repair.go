@@ -128,9 +128,8 @@ func Repair(name string, force bool, opts ...Option) (*RepairResult, error) {
 
 	// Attempt WAL checkpoint
 	if _, err := os.Stat(walPath); err == nil {
-		_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
-		if err != nil {
-			result.Error = fmt.Errorf("WAL checkpoint failed: %w", err)
+		if err := sqliteCheckpoint(db); err != nil {
+			result.Error = err
 		} else {
 			result.WalCheckpointed = true
 		}
@@ -188,8 +187,8 @@ func Repair(name string, force bool, opts ...Option) (*RepairResult, error) {
 	result.IntegrityOK = true
 
 	// Step 4: Vacuum
-	if _, err := db.Exec("VACUUM"); err != nil {
-		result.Error = fmt.Errorf("vacuum failed: %w", err)
+	if err := sqliteVacuum(db); err != nil {
+		result.Error = err
 	} else {
 		result.Vacuumed = true
 	}
@@ -4,12 +4,15 @@
 package kv
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -185,21 +188,110 @@ func openSQLiteCore(path string, allowRecovery bool) (*sql.DB, error) {
 
 		CREATE INDEX IF NOT EXISTS idx_op_log_synced ON op_log(synced, seq);
 		CREATE INDEX IF NOT EXISTS idx_op_log_key ON op_log(key, hlc_timestamp DESC);
+
+		-- Op batches table: records which cloud op-log batches are already
+		-- present locally (applied from the cloud or uploaded from here), so
+		-- each batch is downloaded at most once even if uploads land out of order.
+		CREATE TABLE IF NOT EXISTS op_batches (
+			seq        INTEGER PRIMARY KEY,
+			applied_at INTEGER NOT NULL
+		);
 	`
 	if _, err := db.Exec(schema); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	if err := migrateSchema(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
 	return db, nil
 }
 
-// sqliteGet retrieves a value by key. Returns ErrMissingKey if not found.
+// migrateSchema adds columns introduced after the initial schema to databases
+// created by older versions (including restored cloud backups).
+func migrateSchema(db *sql.DB) error {
+	// expires_at holds the expiry time for TTL keys in Unix milliseconds.
+	// NULL means the key never expires.
+	if err := addColumnIfMissing(db, "kv", "expires_at", "INTEGER"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "op_log", "expires_at", "INTEGER")
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists.
+// table, column, and decl must be trusted constants since SQLite doesn't
+// support parameter binding for identifiers.
+func addColumnIfMissing(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating columns: %w", err)
+	}
+	_ = rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
+	if err != nil {
+		// Another connection may have added the column concurrently
+		if strings.Contains(err.Error(), "duplicate column") {
+			return nil
+		}
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// notExpired is the WHERE clause fragment that excludes expired TTL keys.
+// It takes the current time in Unix milliseconds as its only parameter.
+const notExpired = "(expires_at IS NULL OR expires_at > ?)"
+
+// sqliteGet retrieves a value by key. Returns ErrMissingKey if not found
+// or if the key has expired.
 //
 //nolint:unused // Will be used in kv.go integration
 func sqliteGet(db *sql.DB, key []byte) ([]byte, error) {
+	return sqliteGetContext(context.Background(), db, key)
+}
+
+// sqliteGetContext is like sqliteGet but gives up when ctx is done.
+func sqliteGetContext(ctx context.Context, db *sql.DB, key []byte) ([]byte, error) {
+	var value []byte
+	err := db.QueryRowContext(ctx, "SELECT value FROM kv WHERE key = ? AND "+notExpired, key, time.Now().UnixMilli()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrMissingKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	return value, nil
+}
+
+// sqliteGetTx is like sqliteGet but reads within the given transaction.
+func sqliteGetTx(tx *sql.Tx, key []byte) ([]byte, error) {
 	var value []byte
-	err := db.QueryRow("SELECT value FROM kv WHERE key = ?", key).Scan(&value)
+	err := tx.QueryRow("SELECT value FROM kv WHERE key = ? AND "+notExpired, key, time.Now().UnixMilli()).Scan(&value)
 	if err == sql.ErrNoRows {
 		return nil, ErrMissingKey
 	}
@@ -209,6 +301,67 @@ func sqliteGet(db *sql.DB, key []byte) ([]byte, error) {
 	return value, nil
 }
 
+// beginWriteTx starts a transaction that holds the database write lock from
+// the start, like BEGIN IMMEDIATE. Use it for read-modify-write transactions,
+// where a deferred transaction could read a value another writer is about to
+// change and then fail to upgrade to a write lock.
+func beginWriteTx(db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// The busy timeout set at open only applies to one pooled connection,
+	// so set it on the connection this transaction runs on too
+	if _, err := tx.Exec("PRAGMA busy_timeout=5000"); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
+	}
+	// A write statement takes the write lock even if it changes nothing,
+	// waiting up to the busy timeout for other writers to finish
+	if _, err := tx.Exec("UPDATE kv SET value = value WHERE 0"); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to acquire write lock: %w", err)
+	}
+	return tx, nil
+}
+
+// sqliteGetMulti retrieves the values for several keys in a single query.
+// Keys that don't exist or have expired are absent from the returned map,
+// which is keyed by the string form of each key.
+func sqliteGetMulti(db *sql.DB, keys [][]byte) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, 0, len(keys)+1)
+	for i, k := range keys {
+		placeholders[i] = "?"
+		args = append(args, k)
+	}
+	args = append(args, time.Now().UnixMilli())
+
+	query := fmt.Sprintf("SELECT key, value FROM kv WHERE key IN (%s) AND %s", strings.Join(placeholders, ", "), notExpired)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		values[string(key)] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating values: %w", err)
+	}
+	return values, nil
+}
+
 // sqliteSet stores a key-value pair, overwriting if exists.
 //
 //nolint:unused // Will be used in kv.go integration
@@ -289,12 +442,40 @@ func sqliteDeleteWithPendingOp(db *sql.DB, key []byte) error {
 	return nil
 }
 
-// sqliteKeys returns all keys in the database.
+// sqliteKeys returns all unexpired keys in the database.
 // Returns an empty slice (not nil) if no keys exist.
 //
 //nolint:unused // Will be used in kv.go integration
 func sqliteKeys(db *sql.DB) ([][]byte, error) {
-	rows, err := db.Query("SELECT key FROM kv")
+	rows, err := db.Query("SELECT key FROM kv WHERE "+notExpired, time.Now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make([][]byte, 0)
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating keys: %w", err)
+	}
+	return keys, nil
+}
+
+// sqliteKeysPage returns up to limit unexpired keys greater than after, in
+// key order.
+func sqliteKeysPage(db *sql.DB, after []byte, limit int) ([][]byte, error) {
+	if after == nil {
+		// NULL compares as unknown, an empty blob sorts before every key
+		after = []byte{}
+	}
+	rows, err := db.Query("SELECT key FROM kv WHERE key > ? AND "+notExpired+" ORDER BY key LIMIT ?",
+		after, time.Now().UnixMilli(), limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query keys: %w", err)
 	}
@@ -314,6 +495,174 @@ func sqliteKeys(db *sql.DB) ([][]byte, error) {
 	return keys, nil
 }
 
+// sqliteCount returns the number of unexpired keys.
+func sqliteCount(db *sql.DB) (int64, error) {
+	var n int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM kv WHERE "+notExpired, time.Now().UnixMilli()).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count keys: %w", err)
+	}
+	return n, nil
+}
+
+// sqliteFileSize returns the size of the main database file as reported by
+// SQLite, page_count * page_size.
+func sqliteFileSize(db *sql.DB) (int64, error) {
+	var pageCount, pageSize int64
+	if err := db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to get page size: %w", err)
+	}
+	return pageCount * pageSize, nil
+}
+
+// sqliteVacuum rebuilds the database file, reclaiming free pages.
+func sqliteVacuum(db *sql.DB) error {
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum failed: %w", err)
+	}
+	return nil
+}
+
+// sqliteCheckpoint copies the WAL into the main database file and truncates
+// the WAL to zero bytes.
+func sqliteCheckpoint(db *sql.DB) error {
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("WAL checkpoint failed: %w", err)
+	}
+	return nil
+}
+
+// sqliteFreelistRatio returns the fraction of database pages that are free.
+func sqliteFreelistRatio(db *sql.DB) (float64, error) {
+	var pageCount, freelistCount int64
+	if err := db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA freelist_count").Scan(&freelistCount); err != nil {
+		return 0, fmt.Errorf("failed to get freelist count: %w", err)
+	}
+	if pageCount == 0 {
+		return 0, nil
+	}
+	return float64(freelistCount) / float64(pageCount), nil
+}
+
+// sqlitePrefixKeys returns up to limit unexpired keys starting with prefix,
+// in key order. The prefix is matched as a key range so the primary key
+// index is used.
+func sqlitePrefixKeys(tx *sql.Tx, prefix []byte, limit int) ([][]byte, error) {
+	query := "SELECT key FROM kv WHERE " + notExpired
+	args := []interface{}{time.Now().UnixMilli()}
+	if len(prefix) > 0 {
+		query += " AND key >= ?"
+		args = append(args, prefix)
+	}
+	if end := prefixEnd(prefix); end != nil {
+		query += " AND key < ?"
+		args = append(args, end)
+	}
+	query += " ORDER BY key LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys [][]byte
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating keys: %w", err)
+	}
+	return keys, nil
+}
+
+// storedValue is a raw row of the kv table.
+type storedValue struct {
+	key       []byte
+	value     []byte
+	expiresAt int64 // Unix milliseconds, 0 for no expiry
+}
+
+// sqliteValuesAfter returns up to limit raw rows with keys sorting after the
+// given key, in key order. A nil key starts from the first row.
+func sqliteValuesAfter(tx *sql.Tx, after []byte, limit int) ([]storedValue, error) {
+	query := "SELECT key, value, expires_at FROM kv"
+	var args []interface{}
+	if after != nil {
+		query += " WHERE key > ?"
+		args = append(args, after)
+	}
+	query += " ORDER BY key LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query values: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var vals []storedValue
+	for rows.Next() {
+		var v storedValue
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&v.key, &v.value, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		v.expiresAt = expiresAt.Int64
+		vals = append(vals, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating values: %w", err)
+	}
+	return vals, nil
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or nil if there is none (prefix is empty or all 0xff bytes).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// sqliteExpiredKeys returns all keys whose TTL has passed as of now
+// (Unix milliseconds).
+func sqliteExpiredKeys(tx *sql.Tx, now int64) ([][]byte, error) {
+	rows, err := tx.Query("SELECT key FROM kv WHERE expires_at IS NOT NULL AND expires_at <= ?", now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys [][]byte
+	for rows.Next() {
+		var key []byte
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expired keys: %w", err)
+	}
+	return keys, nil
+}
+
 // sqliteGetMeta retrieves a metadata value. Returns 0 if not found.
 //
 //nolint:unused // Will be used in kv.go integration
@@ -5,6 +5,7 @@ package kv
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -147,6 +148,118 @@ func TestSQLiteKeysEmpty(t *testing.T) {
 	}
 }
 
+func TestSQLiteGetMulti(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := sqliteSet(db, []byte(k), []byte("value-"+k)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	got, err := sqliteGetMulti(db, [][]byte{[]byte("a"), []byte("c"), []byte("missing")})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("GetMulti returned %d values, want 2", len(got))
+	}
+	if string(got["a"]) != "value-a" || string(got["c"]) != "value-c" {
+		t.Errorf("GetMulti returned wrong values: %q", got)
+	}
+	if _, ok := got["missing"]; ok {
+		t.Error("GetMulti should omit missing keys")
+	}
+
+	// No keys should return an empty map without querying
+	got, err = sqliteGetMulti(db, nil)
+	if err != nil {
+		t.Fatalf("GetMulti with no keys failed: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("GetMulti with no keys returned %v, want empty map", got)
+	}
+}
+
+func TestSQLiteCount(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	n, err := sqliteCount(db)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Count on empty db = %d, want 0", n)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := sqliteSet(db, []byte(k), []byte("value-"+k)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	// Expired keys aren't counted
+	if _, err := db.Exec("UPDATE kv SET expires_at = 1 WHERE key = ?", []byte("c")); err != nil {
+		t.Fatalf("failed to expire key: %v", err)
+	}
+
+	n, err = sqliteCount(db)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
+
+func TestKVDiskSize(t *testing.T) {
+	kv := newTestKV(t)
+
+	before, err := kv.DiskSize()
+	if err != nil {
+		t.Fatalf("DiskSize failed: %v", err)
+	}
+	if before <= 0 {
+		t.Errorf("expected positive DiskSize, got %d", before)
+	}
+
+	value := bytes.Repeat([]byte("x"), 64*1024)
+	for i := 0; i < 8; i++ {
+		if err := kv.setWithOpLog(context.Background(), []byte(fmt.Sprintf("key-%d", i)), value, 0); err != nil {
+			t.Fatalf("setWithOpLog failed: %v", err)
+		}
+	}
+
+	after, err := kv.DiskSize()
+	if err != nil {
+		t.Fatalf("DiskSize failed: %v", err)
+	}
+	if after <= before {
+		t.Errorf("expected DiskSize to grow after writes, got %d -> %d", before, after)
+	}
+
+	count, err := kv.Count()
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 8 {
+		t.Errorf("Count = %d, want 8", count)
+	}
+}
+
 func TestSQLiteMeta(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "test.db")
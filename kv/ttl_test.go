@@ -0,0 +1,232 @@
+// ABOUTME: Tests for per-key TTL support.
+// ABOUTME: Verifies expiry on reads, lazy deletion, op-log metadata, and schema migration.
+package kv
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetWithTTL_ExpiresOnRead(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.SetWithTTL([]byte("session"), []byte("token"), 50*time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := kv.Set([]byte("permanent"), []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	v, err := kv.Get([]byte("session"))
+	if err != nil {
+		t.Fatalf("Get before expiry failed: %v", err)
+	}
+	if string(v) != "token" {
+		t.Errorf("expected 'token', got %q", v)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := kv.Get([]byte("session")); err != ErrMissingKey {
+		t.Errorf("expected ErrMissingKey after expiry, got %v", err)
+	}
+
+	keys, err := kv.Keys()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if len(keys) != 1 || string(keys[0]) != "permanent" {
+		t.Errorf("expected only 'permanent' in Keys, got %q", keys)
+	}
+
+	values, err := kv.GetMulti([][]byte{[]byte("session"), []byte("permanent")})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if _, ok := values["session"]; ok {
+		t.Error("expected expired key to be absent from GetMulti")
+	}
+}
+
+func TestSetWithTTL_SetClearsExpiry(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.SetWithTTL([]byte("key"), []byte("v1"), 50*time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := kv.Set([]byte("key"), []byte("v2")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	v, err := kv.Get([]byte("key"))
+	if err != nil {
+		t.Fatalf("expected key to survive after plain Set, got %v", err)
+	}
+	if string(v) != "v2" {
+		t.Errorf("expected 'v2', got %q", v)
+	}
+}
+
+func TestSetWithTTL_InvalidTTL(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.SetWithTTL([]byte("key"), []byte("value"), 0); err == nil {
+		t.Error("expected error for zero TTL")
+	}
+	if err := kv.SetWithTTL([]byte("key"), []byte("value"), -time.Second); err == nil {
+		t.Error("expected error for negative TTL")
+	}
+}
+
+func TestSetWithTTL_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	err := kv.SetWithTTL([]byte("key"), []byte("value"), time.Minute)
+	if !IsReadOnly(err) {
+		t.Errorf("expected read-only error, got %v", err)
+	}
+}
+
+func TestSetWithTTL_RecordsExpiryInOpLog(t *testing.T) {
+	kv := newTestKV(t)
+
+	before := time.Now().Add(time.Minute).UnixMilli()
+	if err := kv.SetWithTTL([]byte("key"), []byte("value"), time.Minute); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+
+	ops, err := getUnsyncedOps(kv.db, 10)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+	if len(ops) != 1 {
+		t.Fatalf("expected 1 op, got %d", len(ops))
+	}
+	if ops[0].ExpiresAt < before {
+		t.Errorf("expected op ExpiresAt >= %d, got %d", before, ops[0].ExpiresAt)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	kv := newTestKV(t)
+
+	if err := kv.SetWithTTL([]byte("a"), []byte("value"), 10*time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := kv.SetWithTTL([]byte("b"), []byte("value"), time.Hour); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	n, err := kv.deleteExpired()
+	if err != nil {
+		t.Fatalf("deleteExpired failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired key deleted, got %d", n)
+	}
+
+	var rows int
+	if err := kv.db.QueryRow("SELECT COUNT(*) FROM kv").Scan(&rows); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("expected 1 row left, got %d", rows)
+	}
+
+	// The deletion is recorded in the op-log so it replicates
+	var deletes int
+	err = kv.db.QueryRow("SELECT COUNT(*) FROM op_log WHERE op_type = 'delete' AND key = ?", []byte("a")).Scan(&deletes)
+	if err != nil {
+		t.Fatalf("failed to count delete ops: %v", err)
+	}
+	if deletes != 1 {
+		t.Errorf("expected 1 delete op for expired key, got %d", deletes)
+	}
+
+	// Nothing left to delete
+	n, err = kv.deleteExpired()
+	if err != nil {
+		t.Fatalf("second deleteExpired failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 keys deleted on second pass, got %d", n)
+	}
+}
+
+func TestDeleteExpired_WaitsForOtherWriters(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.SetWithTTL([]byte("a"), []byte("value"), time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// Another process is writing when the expired keys are deleted
+	other, err := openSQLite(kv.dbPath)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer other.Close() // nolint:errcheck
+	tx, err := beginWriteTx(other)
+	if err != nil {
+		t.Fatalf("beginWriteTx failed: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = tx.Exec("INSERT INTO kv (key, value) VALUES (?, ?)", []byte("b"), []byte("value"))
+		_ = tx.Commit()
+	}()
+
+	n, err := kv.deleteExpired()
+	if err != nil {
+		t.Fatalf("deleteExpired failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired key deleted, got %d", n)
+	}
+}
+
+func TestMigrateSchema_AddsExpiresAt(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// Create a database with the pre-TTL schema
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	_, err = old.Exec(`
+		CREATE TABLE kv (key BLOB PRIMARY KEY, value BLOB NOT NULL) WITHOUT ROWID;
+		INSERT INTO kv (key, value) VALUES (x'6b', x'76');
+	`)
+	if err != nil {
+		t.Fatalf("failed to create old schema: %v", err)
+	}
+	_ = old.Close()
+
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		t.Fatalf("openSQLite failed: %v", err)
+	}
+	defer db.Close()
+
+	var expiresAt sql.NullInt64
+	if err := db.QueryRow("SELECT expires_at FROM kv WHERE key = x'6b'").Scan(&expiresAt); err != nil {
+		t.Fatalf("expected expires_at column after migration: %v", err)
+	}
+	if expiresAt.Valid {
+		t.Errorf("expected NULL expires_at for existing row, got %d", expiresAt.Int64)
+	}
+
+	// Reopening must be a no-op
+	db2, err := openSQLite(dbPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	_ = db2.Close()
+}
@@ -0,0 +1,138 @@
+// ABOUTME: Read-modify-write transactions for the KV store
+// ABOUTME: Runs Get/Has/Set/Delete calls in one SQLite transaction that commits atomically
+
+package kv
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+)
+
+// Txn is a transaction on a KV store, passed to the function given to Update
+// or View. Its reads see the transaction's own writes, and its writes are
+// only visible to others once the transaction commits. Each write still gets
+// its own op-log entry.
+//
+// A Txn is only valid inside the function it was passed to, and is not safe
+// for concurrent use.
+type Txn struct {
+	kv       *KV
+	tx       *sql.Tx
+	writable bool
+	done     bool
+	events   []KeyEvent
+}
+
+// Update runs fn in a read-write transaction, so several keys can be read,
+// compared and written atomically. The transaction holds the database write
+// lock from the start, so no other writer, in this process or another, can
+// interleave. If fn returns nil the transaction commits, otherwise it rolls
+// back and fn's error is returned. Like a Batch, the whole transaction counts
+// as one write towards the backup threshold.
+//
+// fn must not call the store's own methods, such as Set, which would wait
+// on the write lock Update holds. Returns ErrReadOnlyMode if the database is
+// open in read-only mode.
+func (kv *KV) Update(fn func(txn *Txn) error) error {
+	if kv.readOnly {
+		return &ErrReadOnlyMode{Operation: "update"}
+	}
+	tx, err := beginWriteTx(kv.db)
+	if err != nil {
+		return err
+	}
+	txn := &Txn{kv: kv, tx: tx, writable: true}
+	defer func() {
+		txn.done = true
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(txn); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if len(txn.events) == 0 {
+		return nil
+	}
+	for _, e := range txn.events {
+		kv.notify(e)
+	}
+	return kv.syncAfterWrite()
+}
+
+// View runs fn in a read-only transaction, so several keys can be read from
+// a consistent snapshot of the store. Set and Delete fail with
+// ErrReadOnlyMode. fn's error is returned.
+func (kv *KV) View(fn func(txn *Txn) error) error {
+	tx, err := kv.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	txn := &Txn{kv: kv, tx: tx}
+	defer func() {
+		txn.done = true
+		_ = tx.Rollback()
+	}()
+	return fn(txn)
+}
+
+// Get returns the value of key, or ErrMissingKey if it doesn't exist.
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if txn.done {
+		return nil, ErrTxnDone
+	}
+	encValue, err := sqliteGetTx(txn.tx, key)
+	if err != nil {
+		return nil, err
+	}
+	return txn.kv.decryptValue(encValue)
+}
+
+// Has reports whether key exists.
+func (txn *Txn) Has(key []byte) (bool, error) {
+	if txn.done {
+		return false, ErrTxnDone
+	}
+	_, err := sqliteGetTx(txn.tx, key)
+	if err == ErrMissingKey {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Set sets key to value. Like KV.Set, the value has no expiry.
+func (txn *Txn) Set(key, value []byte) error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	if !txn.writable {
+		return &ErrReadOnlyMode{Operation: "set key"}
+	}
+	encValue, err := txn.kv.encryptValue(value)
+	if err != nil {
+		return err
+	}
+	if err := txn.kv.setTx(txn.tx, key, encValue, 0); err != nil {
+		return err
+	}
+	txn.events = append(txn.events, KeyEvent{Key: bytes.Clone(key), Value: bytes.Clone(value), Type: KeySet})
+	return nil
+}
+
+// Delete deletes key. Deleting a missing key isn't an error.
+func (txn *Txn) Delete(key []byte) error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	if !txn.writable {
+		return &ErrReadOnlyMode{Operation: "delete key"}
+	}
+	if err := txn.kv.deleteTx(txn.tx, key); err != nil {
+		return err
+	}
+	txn.events = append(txn.events, KeyEvent{Key: bytes.Clone(key), Type: KeyDeleted})
+	return nil
+}
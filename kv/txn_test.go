@@ -0,0 +1,149 @@
+// ABOUTME: Tests for Update and View transactions.
+// ABOUTME: Verifies atomic commit, rollback on error, op-log entries, and read-only handling.
+package kv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdate_Commit(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("balance:a"), []byte("10")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	err := kv.Update(func(txn *Txn) error {
+		v, err := txn.Get([]byte("balance:a"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "10" {
+			t.Errorf("Get = %q, want %q", v, "10")
+		}
+		if err := txn.Set([]byte("balance:b"), v); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte("balance:a")); err != nil {
+			return err
+		}
+
+		// Reads see the transaction's own writes
+		if ok, err := txn.Has([]byte("balance:a")); err != nil || ok {
+			t.Errorf("Has deleted key = %v, %v, want false", ok, err)
+		}
+		if v, err := txn.Get([]byte("balance:b")); err != nil || string(v) != "10" {
+			t.Errorf("Get new key = %q, %v, want %q", v, err, "10")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if _, err := kv.Get([]byte("balance:a")); err != ErrMissingKey {
+		t.Errorf("expected the deleted key to be missing, got %v", err)
+	}
+	if v, err := kv.Get([]byte("balance:b")); err != nil || string(v) != "10" {
+		t.Errorf("Get = %q, %v, want %q", v, err, "10")
+	}
+
+	// One op-log entry per write, plus the initial Set
+	var opCount int
+	if err := kv.db.QueryRow("SELECT COUNT(*) FROM op_log").Scan(&opCount); err != nil {
+		t.Fatalf("failed to count op_log: %v", err)
+	}
+	if opCount != 3 {
+		t.Errorf("expected 3 op-log entries, got %d", opCount)
+	}
+}
+
+func TestUpdate_RollsBackOnError(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("a"), []byte("before")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := kv.Update(func(txn *Txn) error {
+		if err := txn.Set([]byte("a"), []byte("after")); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte("b"), []byte("new")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("expected the function's error, got %v", err)
+	}
+
+	if v, err := kv.Get([]byte("a")); err != nil || string(v) != "before" {
+		t.Errorf("Get = %q, %v, want %q", v, err, "before")
+	}
+	if _, err := kv.Get([]byte("b")); err != ErrMissingKey {
+		t.Errorf("expected the rolled back key to be missing, got %v", err)
+	}
+	n, err := countPendingOps(kv.db)
+	if err != nil || n != 1 {
+		t.Errorf("countPendingOps = %d, %v, want 1", n, err)
+	}
+}
+
+func TestUpdate_TxnDoneAfterReturn(t *testing.T) {
+	kv := newTestKV(t)
+	var leaked *Txn
+	if err := kv.Update(func(txn *Txn) error {
+		leaked = txn
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := leaked.Set([]byte("a"), []byte("v")); err != ErrTxnDone {
+		t.Errorf("expected ErrTxnDone, got %v", err)
+	}
+	if _, err := leaked.Get([]byte("a")); err != ErrTxnDone {
+		t.Errorf("expected ErrTxnDone, got %v", err)
+	}
+}
+
+func TestView(t *testing.T) {
+	kv := newTestKV(t)
+	if err := kv.Set([]byte("a"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	err := kv.View(func(txn *Txn) error {
+		if v, err := txn.Get([]byte("a")); err != nil || string(v) != "v" {
+			t.Errorf("Get = %q, %v, want %q", v, err, "v")
+		}
+		if ok, err := txn.Has([]byte("missing")); err != nil || ok {
+			t.Errorf("Has missing key = %v, %v, want false", ok, err)
+		}
+		var rerr *ErrReadOnlyMode
+		if err := txn.Set([]byte("b"), []byte("v")); !errors.As(err, &rerr) {
+			t.Errorf("expected ErrReadOnlyMode from Set, got %v", err)
+		}
+		if err := txn.Delete([]byte("a")); !errors.As(err, &rerr) {
+			t.Errorf("expected ErrReadOnlyMode from Delete, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+}
+
+func TestUpdate_ReadOnly(t *testing.T) {
+	kv := newTestKV(t)
+	kv.readOnly = true
+
+	err := kv.Update(func(txn *Txn) error {
+		t.Error("expected fn not to run")
+		return nil
+	})
+	var rerr *ErrReadOnlyMode
+	if !errors.As(err, &rerr) {
+		t.Errorf("expected ErrReadOnlyMode, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// ABOUTME: Change notifications for the KV store
+// ABOUTME: Delivers set/delete events from local writes and cloud syncs to watchers
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// watchBufferSize is how many events a watcher can fall behind by before
+// further events are dropped for it.
+const watchBufferSize = 256
+
+// KeyEventType describes what happened to a key.
+type KeyEventType int
+
+const (
+	// KeySet means the key was created or its value changed.
+	KeySet KeyEventType = iota + 1
+
+	// KeyDeleted means the key was removed.
+	KeyDeleted
+)
+
+// String returns the op type name for the event type.
+func (t KeyEventType) String() string {
+	switch t {
+	case KeySet:
+		return "set"
+	case KeyDeleted:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyEvent describes a change to a single key.
+type KeyEvent struct {
+	// Key is the key that changed.
+	Key []byte
+
+	// Value is the new decrypted value. Nil for deletes.
+	Value []byte
+
+	// Type is whether the key was set or deleted.
+	Type KeyEventType
+}
+
+// KVEvent is an alias for KeyEvent.
+type KVEvent = KeyEvent
+
+// watcher is a single Watch subscription.
+type watcher struct {
+	prefix []byte
+	ch     chan KeyEvent
+}
+
+// Watch returns a channel that receives an event whenever a key starting
+// with prefix is set or deleted, whether by this KV instance or by changes
+// pulled from the cloud during Sync. An empty prefix watches all keys.
+//
+// The channel is closed when ctx is done or the KV is closed. Events are
+// buffered; if the receiver falls too far behind, further events are dropped
+// rather than blocking writers.
+func (kv *KV) Watch(ctx context.Context, prefix []byte) (<-chan KeyEvent, error) {
+	select {
+	case <-kv.shutdown:
+		return nil, ErrClosed
+	default:
+	}
+
+	w := &watcher{
+		prefix: bytes.Clone(prefix),
+		ch:     make(chan KeyEvent, watchBufferSize),
+	}
+
+	kv.watchMu.Lock()
+	if kv.watchers == nil {
+		kv.watchers = make(map[*watcher]struct{})
+	}
+	kv.watchers[w] = struct{}{}
+	kv.watchMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-kv.shutdown:
+		}
+		kv.unwatch(w)
+	}()
+
+	return w.ch, nil
+}
+
+// unwatch removes a watcher and closes its channel if still registered.
+func (kv *KV) unwatch(w *watcher) {
+	kv.watchMu.Lock()
+	defer kv.watchMu.Unlock()
+	if _, ok := kv.watchers[w]; ok {
+		delete(kv.watchers, w)
+		close(w.ch)
+	}
+}
+
+// closeWatchers removes all watchers and closes their channels.
+func (kv *KV) closeWatchers() {
+	kv.watchMu.Lock()
+	defer kv.watchMu.Unlock()
+	for w := range kv.watchers {
+		delete(kv.watchers, w)
+		close(w.ch)
+	}
+}
+
+// hasWatchers reports whether anyone is watching for changes.
+func (kv *KV) hasWatchers() bool {
+	kv.watchMu.Lock()
+	defer kv.watchMu.Unlock()
+	return len(kv.watchers) > 0
+}
+
+// notify delivers events to every watcher whose prefix matches.
+func (kv *KV) notify(events ...KeyEvent) {
+	kv.watchMu.Lock()
+	defer kv.watchMu.Unlock()
+	for _, ev := range events {
+		for w := range kv.watchers {
+			if !bytes.HasPrefix(ev.Key, w.prefix) {
+				continue
+			}
+			select {
+			case w.ch <- ev:
+			default:
+				// Receiver is too slow, drop the event rather than block
+			}
+		}
+	}
+}
+
+// notifyEncrypted delivers an event for a value that is still encrypted,
+// such as one applied from a remote op. If the value can't be decrypted the
+// event is delivered without a value.
+func (kv *KV) notifyEncrypted(key, encValue []byte, typ KeyEventType) {
+	if !kv.hasWatchers() {
+		return
+	}
+	ev := KeyEvent{Key: key, Type: typ}
+	if typ == KeySet {
+		if v, err := kv.decryptValue(encValue); err == nil {
+			ev.Value = v
+		}
+	}
+	kv.notify(ev)
+}
+
+// sqliteSnapshotValues returns every unexpired key and its encrypted value.
+// Used to diff the database across a full restore.
+func sqliteSnapshotValues(db *sql.DB) (map[string][]byte, error) {
+	rows, err := db.Query("SELECT key, value FROM kv WHERE "+notExpired, time.Now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query values: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	values := make(map[string][]byte)
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		values[string(key)] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating values: %w", err)
+	}
+	return values, nil
+}
+
+// notifyDiff emits events for every difference between two snapshots of
+// encrypted values. Since encryption is deterministic, equal ciphertexts
+// mean equal values.
+func (kv *KV) notifyDiff(before, after map[string][]byte) {
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !bytes.Equal(old, v) {
+			kv.notifyEncrypted([]byte(k), v, KeySet)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			kv.notify(KeyEvent{Key: []byte(k), Type: KeyDeleted})
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// ABOUTME: Tests for the Watch change notification API.
+// ABOUTME: Verifies prefix filtering, event delivery for all write paths, and unsubscribe.
+package kv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextEvent waits briefly for an event on ch.
+func nextEvent(t *testing.T, ch <-chan KeyEvent) KeyEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed unexpectedly")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return KeyEvent{}
+}
+
+// expectNoEvent verifies nothing is pending on ch.
+func expectNoEvent(t *testing.T, ch <-chan KeyEvent) {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event: %+v", ev)
+	default:
+	}
+}
+
+func TestWatch_LocalWrites(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := kv.Watch(ctx, []byte("user:"))
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	if err := kv.Set([]byte("user:1"), []byte("alice")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.Set([]byte("other"), []byte("ignored")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.Delete([]byte("user:1")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	ev := nextEvent(t, ch)
+	if string(ev.Key) != "user:1" || string(ev.Value) != "alice" || ev.Type != KeySet {
+		t.Errorf("unexpected set event: %+v", ev)
+	}
+	ev = nextEvent(t, ch)
+	if string(ev.Key) != "user:1" || ev.Value != nil || ev.Type != KeyDeleted {
+		t.Errorf("unexpected delete event: %+v", ev)
+	}
+	expectNoEvent(t, ch)
+}
+
+func TestWatch_EventsOwnTheirBytes(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := kv.Watch(ctx, nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// Callers may reuse their buffers as soon as a write returns
+	key, value := []byte("k1"), []byte("v1")
+	if err := kv.Set(key, value); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	copy(key, "xx")
+	copy(value, "yy")
+	key = []byte("k2")
+	if err := kv.Delete(key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	copy(key, "zz")
+
+	if ev := nextEvent(t, ch); string(ev.Key) != "k1" || string(ev.Value) != "v1" {
+		t.Errorf("unexpected set event: %+v", ev)
+	}
+	if ev := nextEvent(t, ch); string(ev.Key) != "k2" || ev.Type != KeyDeleted {
+		t.Errorf("unexpected delete event: %+v", ev)
+	}
+}
+
+func TestWatch_BatchAndExpiry(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := kv.Watch(ctx, nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	b := kv.Batch()
+	_ = b.Set([]byte("a"), []byte("1"))
+	_ = b.Delete([]byte("b"))
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	ev := nextEvent(t, ch)
+	if string(ev.Key) != "a" || string(ev.Value) != "1" || ev.Type != KeySet {
+		t.Errorf("unexpected batch set event: %+v", ev)
+	}
+	ev = nextEvent(t, ch)
+	if string(ev.Key) != "b" || ev.Type != KeyDeleted {
+		t.Errorf("unexpected batch delete event: %+v", ev)
+	}
+
+	if err := kv.SetWithTTL([]byte("ttl"), []byte("x"), 10*time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	nextEvent(t, ch)
+	time.Sleep(50 * time.Millisecond)
+	if _, err := kv.deleteExpired(); err != nil {
+		t.Fatalf("deleteExpired failed: %v", err)
+	}
+	ev = nextEvent(t, ch)
+	if string(ev.Key) != "ttl" || ev.Type != KeyDeleted {
+		t.Errorf("unexpected expiry event: %+v", ev)
+	}
+}
+
+func TestWatch_RemoteOps(t *testing.T) {
+	src := newTestKV(t)
+	dst := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := src.Set([]byte("k"), []byte("remote")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	ops, err := getUnsyncedOps(src.db, opBatchLimit)
+	if err != nil {
+		t.Fatalf("getUnsyncedOps failed: %v", err)
+	}
+
+	ch, err := dst.Watch(ctx, nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	for i := range ops {
+		if err := dst.applyRemoteOp(&ops[i]); err != nil {
+			t.Fatalf("applyRemoteOp failed: %v", err)
+		}
+	}
+
+	ev := nextEvent(t, ch)
+	if string(ev.Key) != "k" || string(ev.Value) != "remote" || ev.Type != KeySet {
+		t.Errorf("unexpected remote event: %+v", ev)
+	}
+
+	// Re-applying the same op is a no-op and emits nothing
+	if err := dst.applyRemoteOp(&ops[0]); err != nil {
+		t.Fatalf("applyRemoteOp (again) failed: %v", err)
+	}
+	expectNoEvent(t, ch)
+}
+
+func TestWatch_NotifyDiff(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	same, _ := kv.encryptValue([]byte("same"))
+	oldV, _ := kv.encryptValue([]byte("old"))
+	newV, _ := kv.encryptValue([]byte("new"))
+
+	ch, err := kv.Watch(ctx, nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	kv.notifyDiff(
+		map[string][]byte{"same": same, "changed": oldV, "gone": oldV},
+		map[string][]byte{"same": same, "changed": newV, "added": newV},
+	)
+
+	got := map[string]KeyEvent{}
+	for i := 0; i < 3; i++ {
+		ev := nextEvent(t, ch)
+		got[string(ev.Key)] = ev
+	}
+	expectNoEvent(t, ch)
+
+	if ev := got["changed"]; ev.Type != KeySet || string(ev.Value) != "new" {
+		t.Errorf("unexpected event for changed key: %+v", ev)
+	}
+	if ev := got["added"]; ev.Type != KeySet || string(ev.Value) != "new" {
+		t.Errorf("unexpected event for added key: %+v", ev)
+	}
+	if ev := got["gone"]; ev.Type != KeyDeleted {
+		t.Errorf("unexpected event for removed key: %+v", ev)
+	}
+}
+
+func TestWatch_CancelClosesChannel(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := kv.Watch(ctx, nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	if kv.hasWatchers() {
+		t.Error("expected watcher to be removed after cancel")
+	}
+}
+
+func TestWatch_AfterClose(t *testing.T) {
+	kv := newTestKV(t)
+
+	ch, err := kv.Watch(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if _, ok := <-ch; ok {
+		t.Error("expected channel to be closed by Close")
+	}
+	if _, err := kv.Watch(context.Background(), nil); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWatch_KVEventAlias(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ch <-chan KVEvent
+	ch, err := kv.Watch(ctx, []byte("k"))
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if err := kv.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	var ev KVEvent = nextEvent(t, ch)
+	if ev.Type.String() != "set" || string(ev.Value) != "v" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
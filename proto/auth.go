@@ -11,8 +11,11 @@ type Auth struct {
 }
 
 // Keys is the response returned when the user queries for the keys linked
-// to their account.
+// to their account. ActiveKey is the index in Keys of the key in use, or -1
+// if it isn't in Keys. When only a page of the keys is asked for, Total is
+// the number of keys linked to the account.
 type Keys struct {
 	ActiveKey int          `json:"active_key"`
 	Keys      []*PublicKey `json:"keys"`
+	Total     int          `json:"total,omitempty"`
 }
@@ -21,6 +21,20 @@ var ErrNameInvalid = errors.New("invalid name")
 // ErrCouldNotUnlinkKey is used when a key can't be deleted.
 var ErrCouldNotUnlinkKey = errors.New("could not unlink key")
 
+// ErrUnlinkActiveKey is used when unlinking the key the client is using, which
+// would lock the client out of the account.
+var ErrUnlinkActiveKey = errors.New("cannot unlink the key in use")
+
+// ErrLinkedKeys is used when deleting an account that other keys are still
+// linked to, without forcing it.
+var ErrLinkedKeys = errors.New("other keys are still linked to the account")
+
+// ErrMissingKey is used when a public key isn't linked to the user's account.
+var ErrMissingKey = errors.New("key not found")
+
+// ErrKeyLabelInvalid is used when a key label is too long.
+var ErrKeyLabelInvalid = errors.New("invalid key label")
+
 // ErrMissingUser is used when no user record is found.
 var ErrMissingUser = errors.New("no user found")
 
@@ -31,6 +45,16 @@ var ErrUserExists = errors.New("user already exists for that key")
 // ErrPageOutOfBounds is an error for an invalid page number.
 var ErrPageOutOfBounds = errors.New("page must be a value of 1 or greater")
 
+// ErrMissingEncryptKey is used when an encrypt key isn't found for a user.
+var ErrMissingEncryptKey = errors.New("encrypt key not found")
+
+// ErrLastEncryptKey is used when deleting a user's only encrypt key, which
+// would leave their encrypted data unreadable.
+var ErrLastEncryptKey = errors.New("cannot delete the last encrypt key")
+
+// ErrMissingNews is used when a news item isn't found.
+var ErrMissingNews = errors.New("news not found")
+
 // ErrTokenExists is used when attempting to create a token that already exists.
 var ErrTokenExists = errors.New("token already exists")
 
@@ -15,6 +15,33 @@ type FileInfo struct {
 	Files   []FileInfo  `json:"files,omitempty"`
 }
 
+// FSUsage is the storage used by a user's files, and the most they may use.
+// A Limit of 0 means there's no limit.
+type FSUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// FSStats is the number of files a user has stored and their total size in
+// bytes.
+type FSStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Upload is a file being uploaded in parts, so an interrupted upload can be
+// resumed. Path is the encrypted path the file is stored at once all Size
+// bytes have been received.
+type Upload struct {
+	ID        string      `json:"id"`
+	Path      string      `json:"path"`
+	Mode      fs.FileMode `json:"mode"`
+	Size      int64       `json:"size"`
+	ChunkSize int64       `json:"chunk_size"`
+	Received  int64       `json:"received"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
 // Add execute permissions to an fs.FileMode to mirror read permissions.
 func AddExecPermsForMkDir(mode fs.FileMode) fs.FileMode {
 	if mode.IsDir() {
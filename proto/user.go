@@ -22,9 +22,20 @@ type PublicKey struct {
 	ID        int        `json:"id"`
 	UserID    int        `json:"user_id,omitempty"`
 	Key       string     `json:"key"`
+	Label     string     `json:"label,omitempty"`
 	CreatedAt *time.Time `json:"created_at"`
 }
 
+// MaxKeyLabelLength is the longest label a public key can be given.
+const MaxKeyLabelLength = 100
+
+// KeyLabelRequest is the message for labeling one of the user's public keys.
+// An empty label removes the key's label.
+type KeyLabelRequest struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
 // Sha returns the SHA for the public key in hex format.
 func (pk *PublicKey) Sha() string {
 	return PublicKeySha(pk.Key)
@@ -0,0 +1,53 @@
+// ABOUTME: Integration tests for the /v1/admin/users endpoint
+// ABOUTME: Covers the admin key allowlist, pagination and non-admin rejection
+package server_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/charm/testserver"
+	"github.com/charmbracelet/keygen"
+)
+
+func TestAdminListUsers(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "admin_ed25519")
+	kp, err := keygen.New(keyPath, keygen.WithKeyType(keygen.Ed25519), keygen.WithWrite())
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	t.Setenv("CHARM_IDENTITY_KEY", keyPath)
+	t.Setenv("CHARM_SERVER_ADMIN_KEYS", kp.AuthorizedKey()+" admin@example.com")
+	cl := testserver.SetupTestServer(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+
+	us, err := cl.AdminListUsers(1)
+	if err != nil {
+		t.Fatalf("AdminListUsers failed: %v", err)
+	}
+	if len(us) != 1 || us[0].CharmID != id {
+		t.Errorf("expected only the admin user %s, got %v", id, us)
+	}
+	us, err = cl.AdminListUsers(2)
+	if err != nil {
+		t.Fatalf("AdminListUsers failed: %v", err)
+	}
+	if len(us) != 0 {
+		t.Errorf("expected an empty second page, got %v", us)
+	}
+	if _, err := cl.AdminListUsers(0); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected a bad request error, got %v", err)
+	}
+}
+
+func TestAdminListUsersForbidden(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	_, err := cl.AdminListUsers(1)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/log"
 
@@ -22,6 +25,8 @@ func (me *SSHServer) sshMiddleware() wish.Middleware {
 					me.handleAPIAuth(s)
 				case "api-keys":
 					me.handleAPIKeys(s)
+				case "api-key-label":
+					me.handleAPIKeyLabel(s)
 				case "api-link":
 					me.handleAPILink(s)
 				case "api-unlink":
@@ -41,23 +46,27 @@ func (me *SSHServer) handleAPIAuth(s ssh.Session) {
 	key, err := keyText(s)
 	if err != nil {
 		me.errorLog.Print(err)
+		me.config.Stats.APIAuthFailed()
 		return
 	}
 	u, err := me.db.UserForKey(key, true)
 	if err != nil {
 		me.errorLog.Print(err)
+		me.config.Stats.APIAuthFailed()
 		return
 	}
 	log.Debug("JWT for user", "id", u.CharmID)
 	j, err := me.newJWT(u.CharmID, "charm")
 	if err != nil {
 		me.errorLog.Printf("Error making JWT: %s\n", err)
+		me.config.Stats.APIAuthFailed()
 		return
 	}
 
 	eks, err := me.db.EncryptKeysForPublicKey(u.PublicKey)
 	if err != nil {
 		me.errorLog.Printf("Error fetching encrypt keys: %s\n", err)
+		me.config.Stats.APIAuthFailed()
 		return
 	}
 	httpScheme := me.config.httpURL().Scheme
@@ -85,12 +94,26 @@ func (me *SSHServer) handleAPIKeys(s ssh.Session) {
 		return
 	}
 	log.Debug("API keys for user", "id", u.CharmID)
-	keys, err := me.db.KeysForUser(u)
+	offset, limit, err := keysPageRange(s.Command()[1:])
+	if err != nil {
+		_ = me.sendAPIMessage(s, fmt.Sprintf("API keys error: %s", err))
+		return
+	}
+	keys, err := me.db.KeysForUserPage(u, offset, limit)
 	if err != nil {
 		me.errorLog.Print(err)
 		_ = me.sendAPIMessage(s, "There was a problem fetching your keys")
 		return
 	}
+	var total int
+	if len(s.Command()) > 1 {
+		total, err = me.db.KeyCountForUser(u)
+		if err != nil {
+			me.errorLog.Print(err)
+			_ = me.sendAPIMessage(s, "There was a problem fetching your keys")
+			return
+		}
+	}
 
 	// Find index of the key currently in use
 	activeKey := -1
@@ -104,10 +127,64 @@ func (me *SSHServer) handleAPIKeys(s ssh.Session) {
 	_ = me.sendJSON(s, charm.Keys{
 		ActiveKey: activeKey,
 		Keys:      keys,
+		Total:     total,
 	})
 	me.config.Stats.APIKeys()
 }
 
+// keysPageRange returns the page of keys asked for with the api-keys
+// arguments, an offset and a limit. Without arguments every key is listed. A
+// negative limit means no limit.
+func keysPageRange(args []string) (offset int, limit int, err error) {
+	limit = -1
+	if len(args) == 0 {
+		return 0, limit, nil
+	}
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("expected an offset and a limit")
+	}
+	offset, err = strconv.Atoi(args[0])
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset: %s", args[0])
+	}
+	limit, err = strconv.Atoi(args[1])
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("invalid limit: %s", args[1])
+	}
+	return offset, limit, nil
+}
+
+func (me *SSHServer) handleAPIKeyLabel(s ssh.Session) {
+	key, err := keyText(s)
+	if err != nil {
+		me.errorLog.Print(err)
+		_ = me.sendAPIMessage(s, "Missing key")
+		return
+	}
+	u, err := me.db.UserForKey(key, true)
+	if err != nil {
+		me.errorLog.Print(err)
+		_ = me.sendAPIMessage(s, fmt.Sprintf("API key label error: %s", err))
+		return
+	}
+	var lr charm.KeyLabelRequest
+	if err := json.NewDecoder(s).Decode(&lr); err != nil {
+		log.Error("Error decoding key label", "err", err)
+		_ = me.sendAPIMessage(s, fmt.Sprintf("Error labeling key: %s", err))
+		return
+	}
+	if utf8.RuneCountInString(lr.Label) > charm.MaxKeyLabelLength {
+		_ = me.sendAPIMessage(s, charm.ErrKeyLabelInvalid.Error())
+		return
+	}
+	log.Debug("API key label for user", "id", u.CharmID, "key", lr.ID)
+	if err := me.db.SetPublicKeyLabel(u, lr.ID, lr.Label); err != nil {
+		log.Error("Error labeling key", "err", err)
+		_ = me.sendAPIMessage(s, err.Error())
+		return
+	}
+}
+
 func (me *SSHServer) handleID(s ssh.Session) {
 	key, err := keyText(s)
 	if err != nil {
@@ -1,9 +1,13 @@
 package server_test
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 
+	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/testserver"
+	"github.com/charmbracelet/keygen"
 )
 
 func TestSSHAuthMiddleware(t *testing.T) {
@@ -25,3 +29,65 @@ func TestSSHAuthMiddleware(t *testing.T) {
 	// 	t.Fatal("auth error, missing EncryptKeys")
 	// }
 }
+
+func TestAPIKeysPage(t *testing.T) {
+	cl, srv := setupTestServerWithDB(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	u, err := srv.Config.DB.GetUserWithID(id)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	for i := range 4 {
+		kp, err := keygen.New(filepath.Join(t.TempDir(), fmt.Sprintf("other_%d", i)), keygen.WithKeyType(keygen.Ed25519))
+		if err != nil {
+			t.Fatalf("keygen error: %s", err)
+		}
+		if err := srv.Config.DB.LinkUserKey(u, kp.AuthorizedKey()); err != nil {
+			t.Fatalf("failed to link key: %v", err)
+		}
+	}
+
+	all, err := cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata failed: %v", err)
+	}
+	if len(all.Keys) != 5 {
+		t.Fatalf("expected 5 keys, got %d", len(all.Keys))
+	}
+
+	var paged []*charm.PublicKey
+	for offset := 0; ; offset += 2 {
+		page, err := cl.AuthorizedKeysPage(offset, 2)
+		if err != nil {
+			t.Fatalf("AuthorizedKeysPage(%d, 2) failed: %v", offset, err)
+		}
+		if page.Total != 5 {
+			t.Errorf("expected a total of 5, got %d", page.Total)
+		}
+		if offset == 0 && page.ActiveKey != 0 {
+			t.Errorf("expected the active key first, got %d", page.ActiveKey)
+		}
+		if offset > 0 && page.ActiveKey != -1 {
+			t.Errorf("expected no active key at offset %d, got %d", offset, page.ActiveKey)
+		}
+		paged = append(paged, page.Keys...)
+		if len(page.Keys) < 2 {
+			break
+		}
+	}
+	if len(paged) != len(all.Keys) {
+		t.Fatalf("expected %d keys across pages, got %d", len(all.Keys), len(paged))
+	}
+	for i, k := range paged {
+		if k.ID != all.Keys[i].ID {
+			t.Errorf("key %d: expected ID %d, got %d", i, all.Keys[i].ID, k.ID)
+		}
+	}
+
+	if _, err := cl.AuthorizedKeysPage(0, 0); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
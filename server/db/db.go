@@ -13,19 +13,30 @@ type DB interface {
 	LinkUserKey(user *charm.User, key string) error
 	UnlinkUserKey(user *charm.User, key string) error
 	KeysForUser(user *charm.User) ([]*charm.PublicKey, error)
+	KeysForUserPage(user *charm.User, offset int, limit int) ([]*charm.PublicKey, error)
+	KeyCountForUser(user *charm.User) (int, error)
+	SetPublicKeyLabel(user *charm.User, keyID int, label string) error
 	MergeUsers(userID1 int, userID2 int) error
+	MergeUsersByCharmID(keep string, remove string) error
+	DeleteUser(user *charm.User) error
 	EncryptKeysForPublicKey(pk *charm.PublicKey) ([]*charm.EncryptKey, error)
 	AddEncryptKeyForPublicKey(user *charm.User, publicKey string, globalID string, encryptedKey string, createdAt *time.Time) error
+	SetDefaultEncryptKey(user *charm.User, globalID string) error
+	DeleteEncryptKey(user *charm.User, globalID string) error
 	GetUserWithID(charmID string) (*charm.User, error)
 	GetUserWithName(name string) (*charm.User, error)
 	SetUserName(charmID string, name string) (*charm.User, error)
 	UserCount() (int, error)
+	GetUserList(offset int) ([]*charm.User, error)
 	UserNameCount() (int, error)
 	NextSeq(user *charm.User, name string) (uint64, error)
 	GetSeq(user *charm.User, name string) (uint64, error)
+	HasSeq(user *charm.User, name string) (bool, error)
 	PostNews(subject string, body string, tags []string) error
 	GetNews(id string) (*charm.News, error)
-	GetNewsList(tag string, page int) ([]*charm.News, error)
+	GetNewsList(tags []string, page int) ([]*charm.News, error)
+	NewsCount(tags []string) (int, error)
+	DeleteNews(id string) error
 	SetToken(token charm.Token) error
 	DeleteToken(token charm.Token) error
 	Close() error
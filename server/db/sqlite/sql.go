@@ -36,6 +36,26 @@ const (
                                 ON UPDATE CASCADE
                             )`
 
+	sqlCreateDefaultEncryptKeyTable = `CREATE TABLE IF NOT EXISTS default_encrypt_key(
+                                     user_id integer NOT NULL PRIMARY KEY,
+                                     global_id uuid NOT NULL,
+                                     CONSTRAINT user_id_fk
+                                       FOREIGN KEY (user_id)
+                                       REFERENCES charm_user (id)
+                                       ON DELETE CASCADE
+                                       ON UPDATE CASCADE
+                                   )`
+
+	sqlCreatePublicKeyLabelTable = `CREATE TABLE IF NOT EXISTS public_key_label(
+                                  public_key_id integer NOT NULL PRIMARY KEY,
+                                  label varchar(100) NOT NULL,
+                                  CONSTRAINT public_key_id_fk
+                                    FOREIGN KEY (public_key_id)
+                                    REFERENCES public_key (id)
+                                    ON DELETE CASCADE
+                                    ON UPDATE CASCADE
+                                )`
+
 	sqlCreateNamedSeqTable = `CREATE TABLE IF NOT EXISTS named_seq(
                             id INTEGER NOT NULL PRIMARY KEY,
                             user_id integer NOT NULL,
@@ -76,12 +96,32 @@ const (
 	sqlSelectUserWithName         = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE name like ?`
 	sqlSelectUserWithCharmID      = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE charm_id = ?`
 	sqlSelectUserWithID           = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE id = ?`
-	sqlSelectUserPublicKeys       = `SELECT id, public_key, created_at FROM public_key WHERE user_id = ?`
+	sqlSelectUserList             = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user ORDER BY id LIMIT 50 OFFSET ?`
 	sqlSelectNumberUserPublicKeys = `SELECT count(*) FROM public_key WHERE user_id = ?`
 	sqlSelectPublicKey            = `SELECT id, user_id, public_key FROM public_key WHERE public_key = ?`
-	sqlSelectEncryptKey           = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = ? AND global_id = ?`
-	sqlSelectEncryptKeys          = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = ? ORDER BY created_at ASC`
 	sqlSelectNamedSeq             = `SELECT seq FROM named_seq WHERE user_id = ? AND name = ?`
+	sqlSelectEncryptKey           = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = ? AND global_id = ?`
+	sqlSelectEncryptKeys          = `SELECT ek.global_id, ek.encrypted_key, ek.created_at FROM encrypt_key AS ek
+	                                 INNER JOIN public_key AS pk ON pk.id = ek.public_key_id
+	                                 LEFT JOIN default_encrypt_key AS d ON d.user_id = pk.user_id AND d.global_id = ek.global_id
+	                                 WHERE ek.public_key_id = ?
+	                                 ORDER BY d.global_id IS NULL, ek.created_at ASC`
+
+	sqlSelectUserPublicKeys = `SELECT pk.id, pk.public_key, pk.created_at, COALESCE(l.label, '') FROM public_key AS pk
+	                           LEFT JOIN public_key_label AS l ON l.public_key_id = pk.id
+	                           WHERE pk.user_id = ?
+	                           ORDER BY pk.created_at ASC, pk.id ASC
+	                           LIMIT ? OFFSET ?`
+
+	sqlSelectUserHasPublicKey = `SELECT EXISTS (SELECT 1 FROM public_key WHERE user_id = ? AND id = ?)`
+
+	sqlSelectUserHasEncryptKey = `SELECT EXISTS (SELECT 1 FROM encrypt_key AS ek
+	                              INNER JOIN public_key AS pk ON pk.id = ek.public_key_id
+	                              WHERE pk.user_id = ? AND ek.global_id = ?)`
+
+	sqlCountUserEncryptKeys = `SELECT COUNT(DISTINCT ek.global_id) FROM encrypt_key AS ek
+	                           INNER JOIN public_key AS pk ON pk.id = ek.public_key_id
+	                           WHERE pk.user_id = ?`
 
 	sqlInsertUser = `INSERT INTO charm_user (charm_id) VALUES (?)`
 
@@ -100,23 +140,45 @@ const (
 	sqlInsertEncryptKey         = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id) VALUES (?, ?, ?)`
 	sqlInsertEncryptKeyWithDate = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id, created_at) VALUES (?, ?, ?, ?)`
 
+	sqlUpsertDefaultEncryptKey = `INSERT INTO default_encrypt_key (user_id, global_id) VALUES (?, ?)
+                                ON CONFLICT (user_id) DO UPDATE SET
+                                global_id = excluded.global_id`
+
+	sqlUpsertPublicKeyLabel = `INSERT INTO public_key_label (public_key_id, label) VALUES (?, ?)
+                             ON CONFLICT (public_key_id) DO UPDATE SET
+                             label = excluded.label`
+
 	sqlInsertToken = `INSERT INTO token (pin) VALUES (?)`
 
 	sqlUpdateUser            = `UPDATE charm_user SET name = ? WHERE charm_id = ?`
 	sqlUpdateMergePublicKeys = `UPDATE public_key SET user_id = ? WHERE user_id = ?`
+	sqlMergeNamedSeqs        = `INSERT INTO named_seq (user_id, name, seq)
+                              SELECT ?, name, seq FROM named_seq WHERE user_id = ?
+                              ON CONFLICT (user_id, name) DO UPDATE SET
+                              seq = MAX(seq, excluded.seq)`
 
-	sqlDeleteUserPublicKey = `DELETE FROM public_key WHERE user_id = ? AND public_key = ?`
-	sqlDeleteUser          = `DELETE FROM charm_user WHERE id = ?`
+	sqlDeleteUserPublicKey  = `DELETE FROM public_key WHERE user_id = ? AND public_key = ?`
+	sqlDeletePublicKeyLabel = `DELETE FROM public_key_label WHERE public_key_id = ?`
+	sqlDeleteUser           = `DELETE FROM charm_user WHERE id = ?`
+
+	sqlDeleteEncryptKey = `DELETE FROM encrypt_key WHERE global_id = ?
+	                       AND public_key_id IN (SELECT id FROM public_key WHERE user_id = ?)`
+	sqlDeleteDefaultEncryptKey = `DELETE FROM default_encrypt_key WHERE user_id = ? AND global_id = ?`
 
 	sqlDeleteToken = `DELETE FROM token WHERE pin = ?`
 
+	sqlDeleteNews = `DELETE FROM news WHERE id = ?`
+
 	sqlCountUsers     = `SELECT COUNT(*) FROM charm_user`
 	sqlCountUserNames = `SELECT COUNT(*) FROM charm_user WHERE name <> ''`
 
 	sqlSelectNews     = `SELECT id, subject, body, created_at FROM news WHERE id = ?`
-	sqlSelectNewsList = `SELECT n.id, n.subject, n.created_at FROM news AS n
+	sqlSelectNewsList = `SELECT DISTINCT n.id, n.subject, n.created_at FROM news AS n
 	                     INNER JOIN news_tag AS t ON t.news_id = n.id
-	                     WHERE t.tag = ?
+	                     WHERE t.tag IN (%s)
 	                     ORDER BY n.created_at desc
 	                     LIMIT 50 OFFSET ?`
+	sqlCountNewsList = `SELECT COUNT(DISTINCT n.id) FROM news AS n
+	                    INNER JOIN news_tag AS t ON t.news_id = n.id
+	                    WHERE t.tag IN (%s)`
 )
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -73,6 +74,27 @@ func (me *DB) GetUserWithID(charmID string) (*charm.User, error) {
 	return u, nil
 }
 
+// GetUserList returns up to 50 users starting at offset, oldest first.
+func (me *DB) GetUserList(offset int) ([]*charm.User, error) {
+	var us []*charm.User
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := me.selectUserList(tx, offset)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			u, err := me.scanUser(rs)
+			if err != nil {
+				return err
+			}
+			us = append(us, u)
+		}
+		return rs.Err()
+	})
+	return us, err
+}
+
 // GetUserWithName returns the user for the given name.
 func (me *DB) GetUserWithName(name string) (*charm.User, error) {
 	r := me.db.QueryRow(sqlSelectUserWithName, name)
@@ -226,6 +248,71 @@ func (me *DB) EncryptKeysForPublicKey(pk *charm.PublicKey) ([]*charm.EncryptKey,
 	return ks, nil
 }
 
+// SetDefaultEncryptKey makes the encrypt key with the given global ID the
+// user's default, listed first by EncryptKeysForPublicKey. It returns
+// charm.ErrMissingEncryptKey if none of the user's public keys has the key.
+func (me *DB) SetDefaultEncryptKey(u *charm.User, gid string) error {
+	log.Debug("Setting default encrypt key", "key", gid, "id", u.CharmID)
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		var ok bool
+		if err := me.selectUserHasEncryptKey(tx, u.ID, gid).Scan(&ok); err != nil {
+			return err
+		}
+		if !ok {
+			return charm.ErrMissingEncryptKey
+		}
+		return me.upsertDefaultEncryptKey(tx, u.ID, gid)
+	})
+}
+
+// DeleteEncryptKey deletes the encrypt key with the given global ID from all
+// of the user's public keys. It returns charm.ErrMissingEncryptKey if none of
+// the user's public keys has the key, and charm.ErrLastEncryptKey if it's the
+// user's only encrypt key, as deleting it would leave their data unreadable.
+func (me *DB) DeleteEncryptKey(u *charm.User, gid string) error {
+	log.Debug("Deleting encrypt key", "key", gid, "id", u.CharmID)
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		var ok bool
+		if err := me.selectUserHasEncryptKey(tx, u.ID, gid).Scan(&ok); err != nil {
+			return err
+		}
+		if !ok {
+			return charm.ErrMissingEncryptKey
+		}
+		var n int
+		if err := me.countUserEncryptKeys(tx, u.ID).Scan(&n); err != nil {
+			return err
+		}
+		if n <= 1 {
+			return charm.ErrLastEncryptKey
+		}
+		if err := me.deleteDefaultEncryptKey(tx, u.ID, gid); err != nil {
+			return err
+		}
+		return me.deleteEncryptKey(tx, u.ID, gid)
+	})
+}
+
+// SetPublicKeyLabel sets the label of the user's public key with the given
+// ID. An empty label removes it. It returns charm.ErrMissingKey if the key
+// isn't linked to the user.
+func (me *DB) SetPublicKeyLabel(u *charm.User, keyID int, label string) error {
+	log.Debug("Setting key label", "key", keyID, "id", u.CharmID)
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		var ok bool
+		if err := me.selectUserHasPublicKey(tx, u.ID, keyID).Scan(&ok); err != nil {
+			return err
+		}
+		if !ok {
+			return charm.ErrMissingKey
+		}
+		if label == "" {
+			return me.deletePublicKeyLabel(tx, keyID)
+		}
+		return me.upsertPublicKeyLabel(tx, keyID, label)
+	})
+}
+
 // LinkUserKey links a user to a key.
 func (me *DB) LinkUserKey(user *charm.User, key string) error {
 	ks := charm.PublicKeySha(key)
@@ -264,12 +351,18 @@ func (me *DB) UnlinkUserKey(user *charm.User, key string) error {
 	})
 }
 
-// KeysForUser returns all user's public keys.
+// KeysForUser returns all user's public keys, oldest first.
 func (me *DB) KeysForUser(user *charm.User) ([]*charm.PublicKey, error) {
+	return me.KeysForUserPage(user, 0, -1)
+}
+
+// KeysForUserPage returns up to limit of the user's public keys starting at
+// offset, oldest first. A negative limit means no limit.
+func (me *DB) KeysForUserPage(user *charm.User, offset int, limit int) ([]*charm.PublicKey, error) {
 	var keys []*charm.PublicKey
-	log.Debug("Getting keys for user", "id", user.CharmID)
+	log.Debug("Getting keys for user", "id", user.CharmID, "offset", offset, "limit", limit)
 	err := me.WrapTransaction(func(tx *sql.Tx) error {
-		rs, err := me.selectUserPublicKeys(tx, user.ID)
+		rs, err := me.selectUserPublicKeys(tx, user.ID, offset, limit)
 		if err != nil {
 			return err
 		}
@@ -277,7 +370,7 @@ func (me *DB) KeysForUser(user *charm.User) ([]*charm.PublicKey, error) {
 
 		for rs.Next() {
 			k := &charm.PublicKey{}
-			err := rs.Scan(&k.ID, &k.Key, &k.CreatedAt)
+			err := rs.Scan(&k.ID, &k.Key, &k.CreatedAt, &k.Label)
 			if err != nil {
 				return err
 			}
@@ -296,6 +389,15 @@ func (me *DB) KeysForUser(user *charm.User) ([]*charm.PublicKey, error) {
 	return keys, nil
 }
 
+// KeyCountForUser returns the number of public keys linked to the user.
+func (me *DB) KeyCountForUser(user *charm.User) (int, error) {
+	var count int
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		return me.selectNumberUserPublicKeys(tx, user.ID).Scan(&count)
+	})
+	return count, err
+}
+
 // GetSeq returns the named sequence.
 func (me *DB) GetSeq(u *charm.User, name string) (uint64, error) {
 	var seq uint64
@@ -313,6 +415,19 @@ func (me *DB) GetSeq(u *charm.User, name string) (uint64, error) {
 	return seq, nil
 }
 
+// HasSeq returns whether the named sequence exists, without creating it.
+func (me *DB) HasSeq(u *charm.User, name string) (bool, error) {
+	var seq uint64
+	err := me.db.QueryRow(sqlSelectNamedSeq, u.ID, name).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // NextSeq increments the sequence and returns.
 func (me *DB) NextSeq(u *charm.User, name string) (uint64, error) {
 	var seq uint64
@@ -347,11 +462,15 @@ func (me *DB) GetNews(id string) (*charm.News, error) {
 	return n, nil
 }
 
-// GetNewsList returns the list of server news.
-func (me *DB) GetNewsList(tag string, page int) ([]*charm.News, error) {
+// GetNewsList returns the list of server news tagged with any of the given
+// tags.
+func (me *DB) GetNewsList(tags []string, page int) ([]*charm.News, error) {
 	var ns []*charm.News
+	if len(tags) == 0 {
+		return ns, nil
+	}
 	err := me.WrapTransaction(func(tx *sql.Tx) error {
-		rs, err := me.selectNewsList(tx, tag, page)
+		rs, err := me.selectNewsList(tx, tags, page)
 		if err != nil {
 			return err
 		}
@@ -372,6 +491,20 @@ func (me *DB) GetNewsList(tag string, page int) ([]*charm.News, error) {
 	return ns, err
 }
 
+// NewsCount returns the number of server news tagged with any of the given
+// tags.
+func (me *DB) NewsCount(tags []string) (int, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	var c int
+	r := me.db.QueryRow(fmt.Sprintf(sqlCountNewsList, tagPlaceholders(tags)), tagArgs(tags)...)
+	if err := r.Scan(&c); err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
 // PostNews publish news to the server.
 func (me *DB) PostNews(subject string, body string, tags []string) error {
 	return me.WrapTransaction(func(tx *sql.Tx) error {
@@ -379,7 +512,29 @@ func (me *DB) PostNews(subject string, body string, tags []string) error {
 	})
 }
 
+// DeleteNews removes a news item along with its tags. It returns
+// charm.ErrMissingNews if there's no news with the given ID.
+func (me *DB) DeleteNews(id string) error {
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return charm.ErrMissingNews
+	}
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		n, err := me.deleteNews(tx, i)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return charm.ErrMissingNews
+		}
+		return nil
+	})
+}
+
 // MergeUsers merge two users into a single one.
+//
+// Deprecated: use MergeUsersByCharmID, which identifies users by their stable
+// Charm IDs.
 func (me *DB) MergeUsers(userID1 int, userID2 int) error {
 	return me.WrapTransaction(func(tx *sql.Tx) error {
 		err := me.updateMergePublicKeys(tx, userID1, userID2)
@@ -391,6 +546,50 @@ func (me *DB) MergeUsers(userID1 int, userID2 int) error {
 	})
 }
 
+// MergeUsersByCharmID moves the public keys of the user with the Charm ID
+// remove, along with their encrypt keys and named sequences, to the user with
+// the Charm ID keep, then deletes the removed user. A sequence both users
+// have keeps the higher value. It returns charm.ErrMissingUser if either user
+// doesn't exist. Files are kept by the FileStore, so moving them is up to the
+// caller.
+func (me *DB) MergeUsersByCharmID(keep string, remove string) error {
+	if keep == remove {
+		return fmt.Errorf("cannot merge user %s with itself", keep)
+	}
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		ku, err := me.scanUser(me.selectUserWithCharmID(tx, keep))
+		if err == sql.ErrNoRows {
+			return charm.ErrMissingUser
+		}
+		if err != nil {
+			return err
+		}
+		ru, err := me.scanUser(me.selectUserWithCharmID(tx, remove))
+		if err == sql.ErrNoRows {
+			return charm.ErrMissingUser
+		}
+		if err != nil {
+			return err
+		}
+		if err := me.updateMergePublicKeys(tx, ku.ID, ru.ID); err != nil {
+			return err
+		}
+		if err := me.mergeNamedSeqs(tx, ku.ID, ru.ID); err != nil {
+			return err
+		}
+		return me.deleteUser(tx, ru.ID)
+	})
+}
+
+// DeleteUser deletes the user along with their public keys, encrypt keys and
+// sequences.
+func (me *DB) DeleteUser(user *charm.User) error {
+	log.Debug("Deleting user", "id", user.CharmID)
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		return me.deleteUser(tx, user.ID)
+	})
+}
+
 // SetToken creates the given token.
 func (me *DB) SetToken(token charm.Token) error {
 	return me.WrapTransaction(func(tx *sql.Tx) error {
@@ -423,6 +622,10 @@ func (me *DB) CreateDB() error {
 		if err != nil {
 			return err
 		}
+		err = me.createPublicKeyLabelTable(tx)
+		if err != nil {
+			return err
+		}
 		err = me.createNamedSeqTable(tx)
 		if err != nil {
 			return err
@@ -431,6 +634,10 @@ func (me *DB) CreateDB() error {
 		if err != nil {
 			return err
 		}
+		err = me.createDefaultEncryptKeyTable(tx)
+		if err != nil {
+			return err
+		}
 		err = me.createNewsTable(tx)
 		if err != nil {
 			return err
@@ -487,6 +694,16 @@ func (me *DB) insertEncryptKey(tx *sql.Tx, key string, globalID string, publicKe
 	return err
 }
 
+func (me *DB) upsertDefaultEncryptKey(tx *sql.Tx, userID int, globalID string) error {
+	_, err := tx.Exec(sqlUpsertDefaultEncryptKey, userID, globalID)
+	return err
+}
+
+func (me *DB) upsertPublicKeyLabel(tx *sql.Tx, publicKeyID int, label string) error {
+	_, err := tx.Exec(sqlUpsertPublicKeyLabel, publicKeyID, label)
+	return err
+}
+
 func (me *DB) insertNews(tx *sql.Tx, subject string, body string, tags []string) error {
 	r, err := tx.Exec(sqlInsertNews, subject, body)
 	if err != nil {
@@ -544,8 +761,12 @@ func (me *DB) selectUserWithID(tx *sql.Tx, userID int) *sql.Row {
 	return tx.QueryRow(sqlSelectUserWithID, userID)
 }
 
-func (me *DB) selectUserPublicKeys(tx *sql.Tx, userID int) (*sql.Rows, error) {
-	return tx.Query(sqlSelectUserPublicKeys, userID)
+func (me *DB) selectUserList(tx *sql.Tx, offset int) (*sql.Rows, error) {
+	return tx.Query(sqlSelectUserList, offset)
+}
+
+func (me *DB) selectUserPublicKeys(tx *sql.Tx, userID int, offset int, limit int) (*sql.Rows, error) {
+	return tx.Query(sqlSelectUserPublicKeys, userID, limit, offset)
 }
 
 func (me *DB) selectNumberUserPublicKeys(tx *sql.Tx, userID int) *sql.Row {
@@ -564,12 +785,40 @@ func (me *DB) selectEncryptKeys(tx *sql.Tx, publicKeyID int) (*sql.Rows, error)
 	return tx.Query(sqlSelectEncryptKeys, publicKeyID)
 }
 
+func (me *DB) selectUserHasPublicKey(tx *sql.Tx, userID int, publicKeyID int) *sql.Row {
+	return tx.QueryRow(sqlSelectUserHasPublicKey, userID, publicKeyID)
+}
+
+func (me *DB) selectUserHasEncryptKey(tx *sql.Tx, userID int, globalID string) *sql.Row {
+	return tx.QueryRow(sqlSelectUserHasEncryptKey, userID, globalID)
+}
+
+func (me *DB) countUserEncryptKeys(tx *sql.Tx, userID int) *sql.Row {
+	return tx.QueryRow(sqlCountUserEncryptKeys, userID)
+}
+
 func (me *DB) selectNews(tx *sql.Tx, id int) *sql.Row {
 	return tx.QueryRow(sqlSelectNews, id)
 }
 
-func (me *DB) selectNewsList(tx *sql.Tx, tag string, offset int) (*sql.Rows, error) {
-	return tx.Query(sqlSelectNewsList, tag, offset)
+func (me *DB) selectNewsList(tx *sql.Tx, tags []string, offset int) (*sql.Rows, error) {
+	args := tagArgs(tags)
+	args = append(args, offset)
+	return tx.Query(fmt.Sprintf(sqlSelectNewsList, tagPlaceholders(tags)), args...)
+}
+
+// tagArgs returns tags as query arguments.
+func tagArgs(tags []string) []any {
+	args := make([]any, 0, len(tags)+1)
+	for _, t := range tags {
+		args = append(args, t)
+	}
+	return args
+}
+
+// tagPlaceholders returns the placeholders for tags in an IN clause.
+func tagPlaceholders(tags []string) string {
+	return strings.TrimSuffix(strings.Repeat("?,", len(tags)), ",")
 }
 
 func (me *DB) deleteUserPublicKey(tx *sql.Tx, userID int, publicKey string) error {
@@ -577,11 +826,36 @@ func (me *DB) deleteUserPublicKey(tx *sql.Tx, userID int, publicKey string) erro
 	return err
 }
 
+func (me *DB) deletePublicKeyLabel(tx *sql.Tx, publicKeyID int) error {
+	_, err := tx.Exec(sqlDeletePublicKeyLabel, publicKeyID)
+	return err
+}
+
 func (me *DB) deleteUser(tx *sql.Tx, userID int) error {
 	_, err := tx.Exec(sqlDeleteUser, userID)
 	return err
 }
 
+func (me *DB) deleteEncryptKey(tx *sql.Tx, userID int, globalID string) error {
+	_, err := tx.Exec(sqlDeleteEncryptKey, globalID, userID)
+	return err
+}
+
+func (me *DB) deleteDefaultEncryptKey(tx *sql.Tx, userID int, globalID string) error {
+	_, err := tx.Exec(sqlDeleteDefaultEncryptKey, userID, globalID)
+	return err
+}
+
+// deleteNews deletes the news with the given ID, returning how many were
+// deleted. Its tags are deleted by the news_tag foreign key.
+func (me *DB) deleteNews(tx *sql.Tx, id int) (int64, error) {
+	r, err := tx.Exec(sqlDeleteNews, id)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
 func (me *DB) deleteToken(tx *sql.Tx, token string) error {
 	_, err := tx.Exec(sqlDeleteToken, token)
 	return err
@@ -592,6 +866,11 @@ func (me *DB) updateMergePublicKeys(tx *sql.Tx, userID1 int, userID2 int) error
 	return err
 }
 
+func (me *DB) mergeNamedSeqs(tx *sql.Tx, userID1 int, userID2 int) error {
+	_, err := tx.Exec(sqlMergeNamedSeqs, userID1, userID2)
+	return err
+}
+
 func (me *DB) createUserTable(tx *sql.Tx) error {
 	_, err := tx.Exec(sqlCreateUserTable)
 	return err
@@ -602,11 +881,21 @@ func (me *DB) createPublicKeyTable(tx *sql.Tx) error {
 	return err
 }
 
+func (me *DB) createPublicKeyLabelTable(tx *sql.Tx) error {
+	_, err := tx.Exec(sqlCreatePublicKeyLabelTable)
+	return err
+}
+
 func (me *DB) createEncryptKeyTable(tx *sql.Tx) error {
 	_, err := tx.Exec(sqlCreateEncryptKeyTable)
 	return err
 }
 
+func (me *DB) createDefaultEncryptKeyTable(tx *sql.Tx) error {
+	_, err := tx.Exec(sqlCreateDefaultEncryptKeyTable)
+	return err
+}
+
 func (me *DB) createNamedSeqTable(tx *sql.Tx) error {
 	_, err := tx.Exec(sqlCreateNamedSeqTable)
 	return err
@@ -627,7 +916,12 @@ func (me *DB) createTokenTable(tx *sql.Tx) error {
 	return err
 }
 
-func (me *DB) scanUser(r *sql.Row) (*charm.User, error) {
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (me *DB) scanUser(r rowScanner) (*charm.User, error) {
 	u := &charm.User{}
 	var un, ue, ub sql.NullString
 	var ca sql.NullTime
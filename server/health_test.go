@@ -0,0 +1,58 @@
+// ABOUTME: Integration tests for the health check endpoint
+// ABOUTME: Verifies component status reporting and 503s on storage failures
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+type healthReport struct {
+	DB      string `json:"db"`
+	Storage string `json:"storage"`
+}
+
+func getHealth(t *testing.T, port int) (int, healthReport) {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d", port))
+	if err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	var hr healthReport
+	if err := json.NewDecoder(resp.Body).Decode(&hr); err != nil {
+		t.Fatalf("cannot decode health report: %v", err)
+	}
+	return resp.StatusCode, hr
+}
+
+// failingFileStore fails every write.
+type failingFileStore struct {
+	storage.FileStore
+}
+
+func (failingFileStore) Put(string, string, io.Reader, fs.FileMode) error {
+	return errors.New("disk full")
+}
+
+func TestHealthCheck(t *testing.T) {
+	_, srv := setupTestServerWithDB(t)
+
+	status, hr := getHealth(t, srv.Config.HealthPort)
+	if status != http.StatusOK || hr.DB != "ok" || hr.Storage != "ok" {
+		t.Errorf("expected a healthy report, got %d %+v", status, hr)
+	}
+
+	srv.Config.FileStore = failingFileStore{srv.Config.FileStore}
+	status, hr = getHealth(t, srv.Config.HealthPort)
+	if status != http.StatusServiceUnavailable || hr.DB != "ok" || hr.Storage != "error" {
+		t.Errorf("expected a storage failure, got %d %+v", status, hr)
+	}
+}
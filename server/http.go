@@ -1,7 +1,9 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,10 +21,11 @@ import (
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/server/db"
 	"github.com/charmbracelet/charm/server/storage"
-	"github.com/meowgorithm/babylogger"
+	"github.com/google/uuid"
 	"goji.io"
 	"goji.io/pat"
 	"goji.io/pattern"
+	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/go-jose/go-jose.v2"
 )
@@ -37,6 +40,8 @@ type HTTPServer struct {
 	server     *http.Server
 	health     *http.Server
 	httpScheme string
+	adminKeys  map[string]bool
+	uploads    *uploadStore
 }
 
 type providerJSON struct {
@@ -51,22 +56,26 @@ type providerJSON struct {
 // NewHTTPServer returns a new *HTTPServer with the specified Config.
 func NewHTTPServer(cfg *Config) (*HTTPServer, error) {
 	healthMux := http.NewServeMux()
-	// No auth health check endpoint
-	healthMux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "We live!")
-	}))
 	health := &http.Server{
 		Addr:              fmt.Sprintf("%s:%d", cfg.BindAddr, cfg.HealthPort),
 		Handler:           healthMux,
 		ErrorLog:          cfg.errorLog,
 		ReadHeaderTimeout: time.Minute,
 	}
+	adminKeys, err := parseAdminKeys(cfg.AdminKeys)
+	if err != nil {
+		return nil, err
+	}
 	mux := goji.NewMux()
 	s := &HTTPServer{
 		cfg:        cfg,
 		health:     health,
 		httpScheme: "http",
+		adminKeys:  adminKeys,
+		uploads:    newUploadStore(filepath.Join(cfg.DataDir, "uploads")),
 	}
+	// No auth health check endpoint
+	healthMux.HandleFunc("/", s.handleHealth)
 	s.server = &http.Server{
 		Addr:              fmt.Sprintf("%s:%d", s.cfg.BindAddr, s.cfg.HTTPPort),
 		Handler:           mux,
@@ -88,22 +97,38 @@ func NewHTTPServer(cfg *Config) (*HTTPServer, error) {
 		return nil, err
 	}
 
-	mux.Use(babylogger.Middleware)
+	if cfg.LogRequests {
+		mux.Use(LoggingMiddleware(log.Default(), log.ParseLevel(cfg.RequestLogLevel)))
+	}
+	mux.Use(StatsMiddleware(cfg.Stats))
 	mux.Use(PublicPrefixesMiddleware([]string{"/v1/public/", "/.well-known/"}))
 	mux.Use(jwtMiddleware)
 	mux.Use(CharmUserMiddleware(s))
-	mux.Use(RequestLimitMiddleware())
+	mux.Use(RequestLimitMiddleware(cfg.MaxRequestBytes, cfg.MaxFSRequestBytes))
+	mux.Use(RateLimitMiddleware(cfg.RateLimit, cfg.FSWriteRateLimit))
 	mux.HandleFunc(pat.Get("/v1/id/:id"), s.handleGetUserByID)
+	mux.HandleFunc(pat.Get("/v1/admin/users"), s.handleGetAdminUsers)
+	mux.HandleFunc(pat.Delete("/v1/account"), s.handleDeleteAccount)
 	mux.HandleFunc(pat.Get("/v1/bio/:name"), s.handleGetUser)
 	mux.HandleFunc(pat.Post("/v1/bio"), s.handlePostUser)
 	mux.HandleFunc(pat.Post("/v1/encrypt-key"), s.handlePostEncryptKey)
+	mux.HandleFunc(pat.Post("/v1/encrypt-key/default"), s.handlePostDefaultEncryptKey)
+	mux.HandleFunc(pat.Delete("/v1/encrypt-key/:id"), s.handleDeleteEncryptKey)
+	mux.HandleFunc(pat.Get("/v1/fs/usage"), s.handleGetFSUsage)
+	mux.HandleFunc(pat.Get("/v1/fs/stats"), s.handleGetFSStats)
 	mux.HandleFunc(pat.Get("/v1/fs/*"), s.handleGetFile)
 	mux.HandleFunc(pat.Post("/v1/fs/*"), s.handlePostFile)
 	mux.HandleFunc(pat.Delete("/v1/fs/*"), s.handleDeleteFile)
+	mux.HandleFunc(pat.Post("/v1/uploads"), s.handlePostUpload)
+	mux.HandleFunc(pat.Get("/v1/uploads/:id"), s.handleGetUpload)
+	mux.HandleFunc(pat.Put("/v1/uploads/:id"), s.handlePutUpload)
+	mux.HandleFunc(pat.Post("/v1/uploads/:id/complete"), s.handlePostUploadComplete)
+	mux.HandleFunc(pat.Delete("/v1/uploads/:id"), s.handleDeleteUpload)
 	mux.HandleFunc(pat.Get("/v1/seq/:name"), s.handleGetSeq)
 	mux.HandleFunc(pat.Post("/v1/seq/:name"), s.handlePostSeq)
 	mux.HandleFunc(pat.Get("/v1/news"), s.handleGetNewsList)
 	mux.HandleFunc(pat.Get("/v1/news/:id"), s.handleGetNews)
+	mux.HandleFunc(pat.Delete("/v1/news/:id"), s.handleDeleteNews)
 	mux.HandleFunc(pat.Get("/v1/public/jwks"), s.handleJWKS)
 	mux.HandleFunc(pat.Get("/.well-known/openid-configuration"), s.handleOpenIDConfig)
 	s.db = cfg.DB
@@ -169,6 +194,58 @@ func (s *HTTPServer) renderCustomError(w http.ResponseWriter, msg string, status
 	_ = json.NewEncoder(w).Encode(charm.Message{Message: msg})
 }
 
+// healthCheckID is the Charm ID the health check stores its probe files
+// under. It isn't a UUID, so it can't belong to a user.
+const healthCheckID = "health-check"
+
+// healthStatus is the health check's report on each component.
+type healthStatus struct {
+	DB      string `json:"db"`
+	Storage string `json:"storage"`
+	Version string `json:"version,omitempty"`
+}
+
+func (s *HTTPServer) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	hs := healthStatus{DB: "ok", Storage: "ok", Version: s.cfg.Version}
+	status := http.StatusOK
+	if _, err := s.cfg.DB.UserCount(); err != nil {
+		log.Error("health check: cannot query the database", "err", err)
+		hs.DB = "error"
+		status = http.StatusServiceUnavailable
+	}
+	if err := s.probeStorage(); err != nil {
+		log.Error("health check: cannot use the file store", "err", err)
+		hs.Storage = "error"
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(hs)
+}
+
+// probeStorage writes a file to the file store, reads it back and deletes it.
+func (s *HTTPServer) probeStorage() error {
+	path := "/" + uuid.NewString()
+	data := []byte("ok")
+	if err := s.cfg.FileStore.Put(healthCheckID, path, bytes.NewReader(data), 0o600); err != nil {
+		return err
+	}
+	defer s.cfg.FileStore.Delete(healthCheckID, path) // nolint:errcheck
+	f, err := s.cfg.FileStore.Get(healthCheckID, path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	got, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, data) {
+		return fmt.Errorf("read back %q, wrote %q", got, data)
+	}
+	return nil
+}
+
 func (s *HTTPServer) handleJWKS(w http.ResponseWriter, _ *http.Request) {
 	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{s.cfg.jwtKeyPair.JWK.Public()}}
 	w.Header().Set("Content-Type", "application/json")
@@ -224,15 +301,127 @@ func (s *HTTPServer) handlePostUser(w http.ResponseWriter, r *http.Request) {
 	nu, err := s.db.SetUserName(id, u.Name)
 	if err == charm.ErrNameTaken {
 		s.renderCustomError(w, fmt.Sprintf("username '%s' already taken", u.Name), http.StatusConflict)
+		return
+	} else if err == charm.ErrMissingUser {
+		s.renderCustomError(w, fmt.Sprintf("missing user for id '%s'", id), http.StatusNotFound)
+		return
 	} else if err != nil {
 		log.Error("cannot set user name", "err", err)
 		s.renderError(w)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(nu)
 	s.cfg.Stats.SetUserName()
 }
 
+// handleDeleteAccount deletes the user's files and account. Unless the force
+// query parameter is true, it refuses while other keys are linked.
+func (s *HTTPServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if !force {
+		keys, err := s.db.KeysForUser(u)
+		if err != nil {
+			log.Error("cannot get user keys", "err", err)
+			s.renderError(w)
+			return
+		}
+		if len(keys) > 1 {
+			s.renderCustomError(w, charm.ErrLinkedKeys.Error(), http.StatusConflict)
+			return
+		}
+	}
+	// Files go first: if deleting them fails the account is still there to
+	// retry with
+	if err := s.cfg.FileStore.DeleteAll(u.CharmID); err != nil {
+		log.Error("cannot delete user files", "err", err)
+		s.renderError(w)
+		return
+	}
+	if err := s.uploads.removeAll(u.CharmID); err != nil {
+		log.Error("cannot delete user uploads", "err", err)
+		s.renderError(w)
+		return
+	}
+	if err := s.db.DeleteUser(u); err != nil {
+		log.Error("cannot delete user", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
+func (s *HTTPServer) handleGetAdminUsers(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	admin, err := s.isAdmin(u)
+	if err != nil {
+		log.Error("cannot get user keys", "err", err)
+		s.renderError(w)
+		return
+	}
+	if !admin {
+		s.renderCustomError(w, "admin access required", http.StatusForbidden)
+		return
+	}
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			s.renderCustomError(w, fmt.Sprintf("invalid page: %s", p), http.StatusBadRequest)
+			return
+		}
+	}
+	us, err := s.db.GetUserList((page - 1) * resultsPerPage)
+	if err != nil {
+		log.Error("cannot get users", "err", err)
+		s.renderError(w)
+		return
+	}
+	total, err := s.db.UserCount()
+	if err != nil {
+		log.Error("cannot count users", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	_ = json.NewEncoder(w).Encode(us)
+}
+
+// isAdmin reports whether any of the user's public keys is an admin key.
+func (s *HTTPServer) isAdmin(u *charm.User) (bool, error) {
+	if len(s.adminKeys) == 0 {
+		return false, nil
+	}
+	keys, err := s.db.KeysForUser(u)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range keys {
+		if s.adminKeys[k.Key] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseAdminKeys returns the set of admin public keys in the form they're
+// stored in the DB, without options or comments.
+func parseAdminKeys(keys []string) (map[string]bool, error) {
+	aks := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if strings.TrimSpace(k) == "" {
+			continue
+		}
+		pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid admin key %q: %w", k, err)
+		}
+		aks[fmt.Sprintf("%s %s", pk.Type(), base64.StdEncoding.EncodeToString(pk.Marshal()))] = true
+	}
+	return aks, nil
+}
+
 func (s *HTTPServer) handlePostEncryptKey(w http.ResponseWriter, r *http.Request) {
 	u := s.charmUserFromRequest(w, r)
 	ek := &charm.EncryptKey{}
@@ -257,6 +446,46 @@ func (s *HTTPServer) handlePostEncryptKey(w http.ResponseWriter, r *http.Request
 	s.cfg.Stats.SetUserName()
 }
 
+func (s *HTTPServer) handlePostDefaultEncryptKey(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	ek := &charm.EncryptKey{}
+	if err := json.NewDecoder(r.Body).Decode(ek); err != nil {
+		log.Error("cannot decode encrypt key json", "err", err)
+		s.renderCustomError(w, "invalid encrypt key", http.StatusBadRequest)
+		return
+	}
+	err := s.db.SetDefaultEncryptKey(u, ek.ID)
+	if errors.Is(err, charm.ErrMissingEncryptKey) {
+		s.renderCustomError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot set default encrypt key", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
+// handleDeleteEncryptKey deletes one of the user's encrypt keys, refusing to
+// delete the last one.
+func (s *HTTPServer) handleDeleteEncryptKey(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	err := s.db.DeleteEncryptKey(u, pat.Param(r, "id"))
+	if errors.Is(err, charm.ErrMissingEncryptKey) {
+		s.renderCustomError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, charm.ErrLastEncryptKey) {
+		s.renderCustomError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error("cannot delete encrypt key", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
 func (s *HTTPServer) handleGetSeq(w http.ResponseWriter, r *http.Request) {
 	u := s.charmUserFromRequest(w, r)
 	name := pat.Param(r, "name")
@@ -300,29 +529,127 @@ func (s *HTTPServer) handlePostFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer f.Close() // nolint:errcheck
-	if s.cfg.UserMaxStorage > 0 {
-		stat, err := s.cfg.FileStore.Stat(u.CharmID, "")
-		if err != nil {
-			log.Error("cannot stat user storage", "err", err)
-			s.renderError(w)
-			return
-		}
-		if stat.Size()+fh.Size > s.cfg.UserMaxStorage {
-			s.renderCustomError(w, "user storage limit exceeded", http.StatusForbidden)
-			return
-		}
+	exceeded, err := s.storageLimitExceeded(u.CharmID, path, fh.Size)
+	if err != nil {
+		log.Error("cannot get user storage usage", "err", err)
+		s.renderError(w)
+		return
+	}
+	if exceeded {
+		s.renderCustomError(w, "user storage limit exceeded", http.StatusInsufficientStorage)
+		return
 	}
 	if err := s.cfg.FileStore.Put(u.CharmID, path, f, fs.FileMode(m)); err != nil {
 		log.Error("cannot post file", "err", err)
 		s.renderError(w)
 		return
 	}
-	s.cfg.Stats.FSFileWritten(u.CharmID, fh.Size)
+	s.recordFileWritten(u, path, fh.Size)
+}
+
+// recordFileWritten reports a written file to the stats. Files in a directory
+// named like one of the user's sequences are kv uploads, since kv keeps its
+// backups under a directory named for the database and its sequence.
+func (s *HTTPServer) recordFileWritten(u *charm.User, path string, size int64) {
+	s.cfg.Stats.FSFileWritten(u.CharmID, size)
+	dir, _, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return
+	}
+	kv, err := s.db.HasSeq(u, dir)
+	if err != nil {
+		log.Error("cannot check for kv sequence", "err", err)
+		return
+	}
+	if kv {
+		s.cfg.Stats.KVBackupWritten(size)
+	}
+}
+
+func (s *HTTPServer) handleGetFSUsage(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	used, err := s.cfg.FileStore.Usage(u.CharmID)
+	if err != nil {
+		log.Error("cannot get user storage usage", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&charm.FSUsage{Used: used, Limit: s.cfg.UserMaxStorage})
+}
+
+func (s *HTTPServer) handleGetFSStats(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	files, size, err := s.cfg.FileStore.Stats(u.CharmID)
+	if err != nil {
+		log.Error("cannot get user storage stats", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&charm.FSStats{Files: files, Bytes: size})
+}
+
+// storageLimitExceeded reports whether storing size bytes at path would take
+// the user past UserMaxStorage. The size of a file being replaced isn't
+// counted, so overwriting a file with one of the same size always fits.
+func (s *HTTPServer) storageLimitExceeded(charmID string, path string, size int64) (bool, error) {
+	if s.cfg.UserMaxStorage <= 0 {
+		return false, nil
+	}
+	used, err := s.cfg.FileStore.Usage(charmID)
+	if err != nil {
+		return false, err
+	}
+	if info, err := s.cfg.FileStore.Stat(charmID, path); err == nil && !info.IsDir() {
+		used -= info.Size()
+	}
+	return used+size > s.cfg.UserMaxStorage, nil
+}
+
+// listingRange returns the window of a directory listing asked for with the
+// offset and limit query parameters. A negative limit means no limit.
+func listingRange(r *http.Request) (offset int, limit int, err error) {
+	q := r.URL.Query()
+	limit = -1
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", v)
+		}
+	}
+	return offset, limit, nil
+}
+
+// writeListingPage writes the window of the directory listing read from f
+// starting at offset and holding up to limit entries.
+func writeListingPage(w io.Writer, f io.Reader, offset int, limit int) error {
+	var dir charm.FileInfo
+	if err := json.NewDecoder(f).Decode(&dir); err != nil {
+		return err
+	}
+	files := dir.Files[min(offset, len(dir.Files)):]
+	if limit >= 0 && limit < len(files) {
+		files = files[:limit]
+	}
+	dir.Files = files
+	return json.NewEncoder(w).Encode(dir)
 }
 
 func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 	u := s.charmUserFromRequest(w, r)
 	path := filepath.Clean(pattern.Path(r.Context()))
+	offset, limit, err := listingRange(r)
+	if err != nil {
+		s.renderCustomError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	f, err := s.cfg.FileStore.Get(u.CharmID, path)
 	if errors.Is(err, fs.ErrNotExist) {
 		s.renderCustomError(w, "file not found", http.StatusNotFound)
@@ -344,18 +671,36 @@ func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 	switch f.(type) {
 	case *charmfs.DirFile:
 		w.Header().Set("Content-Type", "application/json")
+		if offset > 0 || limit >= 0 {
+			w.Header().Set("X-File-Mode", fmt.Sprintf("%d", fi.Mode()))
+			if err := writeListingPage(w, f, offset, limit); err != nil {
+				log.Error("cannot page directory listing", "err", err)
+				s.renderError(w)
+			}
+			return
+		}
 	default:
 		w.Header().Set("Content-Type", "application/octet-stream")
 		w.Header().Set("Last-Modified", fi.ModTime().Format(http.TimeFormat))
-		s.cfg.Stats.FSFileRead(u.CharmID, fi.Size())
+		// Files decompressed as they're sent have no known size
+		if fi.Size() >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(fi.Size(), 10))
+		}
 	}
 	w.Header().Set("X-File-Mode", fmt.Sprintf("%d", fi.Mode()))
-	_, err = io.Copy(w, f)
+	// A HEAD request only wants the headers, such as to stat a file
+	if r.Method == http.MethodHead {
+		return
+	}
+	n, err := io.Copy(w, f)
 	if err != nil {
 		log.Error("cannot copy file", "err", err)
 		s.renderError(w)
 		return
 	}
+	if _, ok := f.(*charmfs.DirFile); !ok {
+		s.cfg.Stats.FSFileRead(u.CharmID, n)
+	}
 }
 
 func (s *HTTPServer) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
@@ -369,30 +714,70 @@ func (s *HTTPServer) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *HTTPServer) handleGetNewsList(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// newsTags returns the tags to filter news by. Tags are given as repeated tag
+// parameters; the comma separated tags parameter sent by older clients is also
+// accepted. News is filtered by the server tag if no tags are given.
+func newsTags(r *http.Request) []string {
+	var tags []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	for _, t := range r.Form["tag"] {
+		add(t)
+	}
+	for _, ts := range r.Form["tags"] {
+		for _, t := range strings.Split(ts, ",") {
+			add(t)
+		}
+	}
+	if len(tags) == 0 {
+		return []string{"server"}
+	}
+	return tags
+}
+
+// newsPage returns the page of news asked for with the page parameter. A
+// missing page, or one below 1, is the first page.
+func newsPage(r *http.Request) (int, error) {
 	p := r.FormValue("page")
 	if p == "" {
-		p = "1"
+		return 1, nil
 	}
 	page, err := strconv.Atoi(p)
 	if err != nil {
-		log.Error("page not a number", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		return 0, fmt.Errorf("invalid page: %s", p)
+	}
+	return max(page, 1), nil
+}
+
+func (s *HTTPServer) handleGetNewsList(w http.ResponseWriter, r *http.Request) {
+	page, err := newsPage(r)
+	if err != nil {
+		s.renderCustomError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 
 	offset := (page - 1) * resultsPerPage
-	tag := r.FormValue("tag")
-	if tag == "" {
-		tag = "server"
-	}
-	ns, err := s.db.GetNewsList(tag, offset)
+	tags := newsTags(r)
+	ns, err := s.db.GetNewsList(tags, offset)
 	if err != nil {
 		log.Error("cannot get news", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	total, err := s.db.NewsCount(tags)
+	if err != nil {
+		log.Error("cannot count news", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	_ = json.NewEncoder(w).Encode(ns)
 	s.cfg.Stats.GetNews()
 }
@@ -410,6 +795,32 @@ func (s *HTTPServer) handleGetNews(w http.ResponseWriter, r *http.Request) {
 	s.cfg.Stats.GetNews()
 }
 
+// handleDeleteNews removes a published news item. Only admins can delete
+// news.
+func (s *HTTPServer) handleDeleteNews(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	admin, err := s.isAdmin(u)
+	if err != nil {
+		log.Error("cannot get user keys", "err", err)
+		s.renderError(w)
+		return
+	}
+	if !admin {
+		s.renderCustomError(w, "admin access required", http.StatusForbidden)
+		return
+	}
+	err = s.db.DeleteNews(pat.Param(r, "id"))
+	if errors.Is(err, charm.ErrMissingNews) {
+		s.renderCustomError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot delete news", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
 func (s *HTTPServer) charmUserFromRequest(w http.ResponseWriter, r *http.Request) *charm.User {
 	u, ok := r.Context().Value(ctxUserKey).(*charm.User)
 	if !ok {
@@ -1,8 +1,15 @@
 package server_test
 
 import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	charmfs "github.com/charmbracelet/charm/fs"
 	"github.com/charmbracelet/charm/testserver"
 )
 
@@ -18,3 +25,82 @@ func TestHTTPAccess(t *testing.T) {
 		t.Fatalf("expected access error, got nil")
 	}
 }
+
+func TestHTTPInvalidListingRange(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	_, err := cl.Auth()
+	if err != nil {
+		t.Fatalf("auth error: %s", err)
+	}
+
+	for _, q := range []string{"offset=-1", "offset=x", "limit=0", "limit=x"} {
+		_, err = cl.AuthedRawRequest("GET", "/v1/fs/dir?"+q)
+		if err == nil || !strings.Contains(err.Error(), "400") {
+			t.Errorf("%s: expected a bad request error, got %v", q, err)
+		}
+	}
+}
+
+func TestHTTPGetCompressedFile(t *testing.T) {
+	t.Setenv("CHARM_SERVER_COMPRESS_FILES", "true")
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatalf("NewFSWithClient failed: %v", err)
+	}
+	data := bytes.Repeat([]byte("compress me "), 1000)
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint:errcheck
+	if err := cfs.WriteFile("dir/file", f); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	ep, err := cfs.EncryptPath("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The size of the stored, compressed file isn't that of the body
+	resp, err := cl.AuthedRawRequest(http.MethodHead, "/v1/fs/"+ep)
+	if err != nil {
+		t.Fatalf("HEAD failed: %v", err)
+	}
+	resp.Body.Close() // nolint:errcheck
+	if resp.ContentLength != -1 {
+		t.Errorf("HEAD Content-Length = %d, want none", resp.ContentLength)
+	}
+
+	resp, err = cl.AuthedRawRequest(http.MethodGet, "/v1/fs/"+ep)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the body failed: %v", err)
+	}
+	if resp.ContentLength != -1 && resp.ContentLength != int64(len(body)) {
+		t.Errorf("GET Content-Length = %d, body is %d bytes", resp.ContentLength, len(body))
+	}
+
+	got, err := cfs.ReadFile("dir/file")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("ReadFile returned %d bytes, want %d", len(got), len(data))
+	}
+	fi, err := cfs.Stat("dir/file")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if fi.Size() <= 0 || fi.Name() != "file" {
+		t.Errorf("Stat = %q, %d bytes, want file with a size", fi.Name(), fi.Size())
+	}
+}
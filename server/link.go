@@ -189,7 +189,7 @@ func (me *SSHServer) LinkGen(lt charm.LinkTransport) error {
 			} else {
 				// Link requester's key is linked to another acccount, merge
 				log.Debug("Key is already linked to different account", "id", lu.CharmID)
-				err = me.db.MergeUsers(u.ID, lu.ID)
+				err = mergeUsers(me.db, me.config.FileStore, u.CharmID, lu.CharmID)
 				if err != nil {
 					l.Status = charm.LinkStatusError
 					me.linkQueue.SendLinkRequest(lt, linkRequest, l)
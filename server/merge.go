@@ -0,0 +1,105 @@
+// ABOUTME: Merging one Charm account into another
+// ABOUTME: Moves the removed account's files along with its keys and sequences
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/charmbracelet/charm/server/storage"
+	"github.com/charmbracelet/log"
+)
+
+// errMergeConflict is returned when both users of a merge have a file at the
+// same path.
+var errMergeConflict = errors.New("both users have a file at the same path")
+
+// MergeUsers merges the user with the Charm ID remove into the user with the
+// Charm ID keep, as happens when a key already linked to one account is
+// linked to another. The removed user's keys and sequences move to the kept
+// user, and so do their files, which stay readable with the moved encrypt
+// keys. Nothing is merged if both users have a file at the same path.
+func (srv *Server) MergeUsers(keep string, remove string) error {
+	return mergeUsers(srv.Config.DB, srv.Config.FileStore, keep, remove)
+}
+
+func mergeUsers(d db.DB, fstore storage.FileStore, keep string, remove string) error {
+	files, err := listFiles(fstore, remove, "/")
+	if err != nil {
+		return fmt.Errorf("cannot list files of %s: %w", remove, err)
+	}
+	for _, fi := range files {
+		if fi.IsDir {
+			continue
+		}
+		_, err := fstore.Stat(keep, fi.Name)
+		if err == nil {
+			return fmt.Errorf("%w: %s", errMergeConflict, fi.Name)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := d.MergeUsersByCharmID(keep, remove); err != nil {
+		return err
+	}
+	// The removed user is gone, so a file that fails to move is left where
+	// it is rather than deleted
+	for _, fi := range files {
+		if err := copyFile(fstore, remove, keep, fi); err != nil {
+			log.Error("cannot move merged user's file", "id", remove, "path", fi.Name, "err", err)
+			return err
+		}
+	}
+	return fstore.DeleteAll(remove)
+}
+
+// listFiles returns the files and directories under dir of the user with the
+// given Charm ID, directories before the files in them. Names are full paths.
+func listFiles(fstore storage.FileStore, charmID string, dir string) ([]charm.FileInfo, error) {
+	f, err := fstore.Get(charmID, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	var listing charm.FileInfo
+	if err := json.NewDecoder(f).Decode(&listing); err != nil {
+		return nil, err
+	}
+	var files []charm.FileInfo
+	for _, fi := range listing.Files {
+		fi.Name = path.Join(dir, fi.Name)
+		files = append(files, fi)
+		if !fi.IsDir {
+			continue
+		}
+		sub, err := listFiles(fstore, charmID, fi.Name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// copyFile copies the file or directory fi from one user to another.
+func copyFile(fstore storage.FileStore, from string, to string, fi charm.FileInfo) error {
+	if fi.IsDir {
+		return fstore.Put(to, fi.Name, nil, fi.Mode)
+	}
+	f, err := fstore.Get(from, fi.Name)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	return fstore.Put(to, fi.Name, f, fi.Mode)
+}
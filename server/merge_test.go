@@ -0,0 +1,157 @@
+// ABOUTME: Tests for merging user accounts in the server database
+// ABOUTME: Covers moving keys, sequences and files, and rejecting unknown users
+package server_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestMergeUsersByCharmID(t *testing.T) {
+	_, srv := setupTestServerWithDB(t)
+	db := srv.Config.DB
+
+	keep, err := db.UserForKey("ssh-ed25519 keep", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	remove, err := db.UserForKey("ssh-ed25519 remove", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := db.AddEncryptKeyForPublicKey(remove, "ssh-ed25519 remove", "gid", "encrypted", nil); err != nil {
+		t.Fatalf("failed to add encrypt key: %v", err)
+	}
+
+	if err := db.MergeUsersByCharmID(keep.CharmID, remove.CharmID); err != nil {
+		t.Fatalf("MergeUsersByCharmID failed: %v", err)
+	}
+
+	u, err := db.UserForKey("ssh-ed25519 remove", false)
+	if err != nil {
+		t.Fatalf("expected the moved key to find a user: %v", err)
+	}
+	if u.CharmID != keep.CharmID {
+		t.Errorf("expected the key to belong to %s, got %s", keep.CharmID, u.CharmID)
+	}
+	eks, err := db.EncryptKeysForPublicKey(u.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to get encrypt keys: %v", err)
+	}
+	if len(eks) != 1 || eks[0].ID != "gid" {
+		t.Errorf("expected the encrypt key to move with its public key, got %v", eks)
+	}
+	if _, err := db.GetUserWithID(remove.CharmID); !errors.Is(err, charm.ErrMissingUser) {
+		t.Errorf("expected the removed user to be gone, got %v", err)
+	}
+}
+
+func TestMergeUsersByCharmIDMissingUser(t *testing.T) {
+	_, srv := setupTestServerWithDB(t)
+	db := srv.Config.DB
+
+	keep, err := db.UserForKey("ssh-ed25519 keep", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := db.MergeUsersByCharmID(keep.CharmID, "missing"); !errors.Is(err, charm.ErrMissingUser) {
+		t.Errorf("expected ErrMissingUser, got %v", err)
+	}
+	if err := db.MergeUsersByCharmID(keep.CharmID, keep.CharmID); err == nil {
+		t.Error("expected an error merging a user with itself")
+	}
+	if _, err := db.GetUserWithID(keep.CharmID); err != nil {
+		t.Errorf("expected the user to be kept, got %v", err)
+	}
+}
+
+func TestMergeUsersMovesData(t *testing.T) {
+	_, srv := setupTestServerWithDB(t)
+	db := srv.Config.DB
+	fstore := srv.Config.FileStore
+
+	keep, err := db.UserForKey("ssh-ed25519 keep", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	remove, err := db.UserForKey("ssh-ed25519 remove", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	for _, u := range []*charm.User{keep, remove, remove} {
+		if _, err := db.NextSeq(u, "kv"); err != nil {
+			t.Fatalf("NextSeq failed: %v", err)
+		}
+	}
+	if _, err := db.NextSeq(remove, "other"); err != nil {
+		t.Fatalf("NextSeq failed: %v", err)
+	}
+	if err := fstore.Put(keep.CharmID, "/kept.txt", strings.NewReader("kept"), 0o644); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+	if err := fstore.Put(remove.CharmID, "/dir/backup", strings.NewReader("backup"), 0o600); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+
+	if err := srv.MergeUsers(keep.CharmID, remove.CharmID); err != nil {
+		t.Fatalf("MergeUsers failed: %v", err)
+	}
+
+	for p, want := range map[string]string{"/kept.txt": "kept", "/dir/backup": "backup"} {
+		f, err := fstore.Get(keep.CharmID, p)
+		if err != nil {
+			t.Fatalf("expected %s to be reachable under the kept user: %v", p, err)
+		}
+		got, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", p, got, err, want)
+		}
+	}
+	fi, err := fstore.Stat(keep.CharmID, "/dir/backup")
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected the moved file to keep its mode, got %v, %v", fi, err)
+	}
+	if _, err := fstore.Get(remove.CharmID, "/dir/backup"); err == nil {
+		t.Error("expected the removed user's files to be gone")
+	}
+	for name, want := range map[string]uint64{"kv": 2, "other": 1} {
+		if seq, err := db.GetSeq(keep, name); err != nil || seq != want {
+			t.Errorf("seq %s = %d, %v, want %d", name, seq, err, want)
+		}
+	}
+}
+
+func TestMergeUsersConflict(t *testing.T) {
+	_, srv := setupTestServerWithDB(t)
+	db := srv.Config.DB
+	fstore := srv.Config.FileStore
+
+	keep, err := db.UserForKey("ssh-ed25519 keep", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	remove, err := db.UserForKey("ssh-ed25519 remove", true)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	for _, u := range []*charm.User{keep, remove} {
+		if err := fstore.Put(u.CharmID, "/same.txt", strings.NewReader(u.CharmID), 0o644); err != nil {
+			t.Fatalf("failed to put file: %v", err)
+		}
+	}
+
+	if err := srv.MergeUsers(keep.CharmID, remove.CharmID); err == nil {
+		t.Fatal("expected merging users with the same file to fail")
+	}
+	if _, err := db.GetUserWithID(remove.CharmID); err != nil {
+		t.Errorf("expected the user not to be merged, got %v", err)
+	}
+	if _, err := fstore.Stat(remove.CharmID, "/same.txt"); err != nil {
+		t.Errorf("expected the user's file to be kept, got %v", err)
+	}
+}
@@ -3,15 +3,21 @@ package server
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"golang.org/x/time/rate"
 	"gopkg.in/go-jose/go-jose.v2"
 
 	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
 	"github.com/auth0/go-jwt-middleware/v2/validator"
 	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/stats"
 )
 
 type contextKey string
@@ -19,20 +25,33 @@ type contextKey string
 var (
 	ctxUserKey   contextKey = "charmUser"
 	ctxPublicKey contextKey = "public"
+	ctxLogKey    contextKey = "requestLog"
 )
 
-// MaxFSRequestSize is the maximum size of a request body for fs endpoints.
+// MaxRequestSize is the default maximum size of a request body for endpoints
+// other than the fs and uploads ones.
+const MaxRequestSize int64 = 1024 * 1024 // 1MB
+
+// MaxFSRequestSize is the default maximum size of a request body for fs and
+// uploads endpoints, and of a file uploaded in parts.
+//
+// Deprecated: set Config.MaxFSRequestBytes instead. This is only used when
+// it's 0 or less.
 var MaxFSRequestSize int64 = 1024 * 1024 * 1024 // 1GB
 
-// RequestLimitMiddleware limits the request body size to the specified limit.
-func RequestLimitMiddleware() func(http.Handler) http.Handler {
+// RequestLimitMiddleware limits the request body size to maxBytes, or to
+// maxFSBytes for the fs and uploads endpoints. A limit of 0 or less uses
+// MaxRequestSize or MaxFSRequestSize.
+func RequestLimitMiddleware(maxBytes int64, maxFSBytes int64) func(http.Handler) http.Handler {
+	if maxBytes <= 0 {
+		maxBytes = MaxRequestSize
+	}
+	maxFSBytes = fsRequestLimit(maxFSBytes)
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var maxRequestSize int64
-			if strings.HasPrefix(r.URL.Path, "/v1/fs") {
-				maxRequestSize = MaxFSRequestSize
-			} else {
-				maxRequestSize = 1024 * 1024 // limit request size to 1MB for other endpoints
+			maxRequestSize := maxBytes
+			if isFSPath(r.URL.Path) {
+				maxRequestSize = maxFSBytes
 			}
 			// Check if the request body is too large using Content-Length
 			if r.ContentLength > maxRequestSize {
@@ -46,6 +65,202 @@ func RequestLimitMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// fsRequestLimit returns the maximum size of a request body for the fs and
+// uploads endpoints given the configured limit n.
+func fsRequestLimit(n int64) int64 {
+	if n <= 0 {
+		return MaxFSRequestSize
+	}
+	return n
+}
+
+// isFSPath reports whether p is one of the fs or uploads endpoints, which
+// take file data and get the larger request size limit.
+func isFSPath(p string) bool {
+	for _, prefix := range []string{"/v1/fs", "/v1/uploads"} {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoggingMiddleware logs every request to logger at level, with its method,
+// path, status, response size, latency and, once CharmUserMiddleware has
+// found them, the Charm ID of the user making it. It should come first in
+// the middleware chain so rejected requests are logged too.
+func LoggingMiddleware(logger *log.Logger, level log.Level) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rl := &requestLog{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rl, r.WithContext(context.WithValue(r.Context(), ctxLogKey, rl)))
+			kv := []interface{}{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rl.status,
+				"bytes", rl.bytes,
+				"latency", time.Since(start),
+			}
+			if rl.charmID != "" {
+				kv = append(kv, "id", rl.charmID)
+			}
+			switch level {
+			case log.DebugLevel:
+				logger.Debug("request", kv...)
+			case log.WarnLevel:
+				logger.Warn("request", kv...)
+			case log.ErrorLevel:
+				logger.Error("request", kv...)
+			default:
+				logger.Info("request", kv...)
+			}
+		})
+	}
+}
+
+// StatsMiddleware reports the status of every response to st. Like
+// LoggingMiddleware, it should come early in the middleware chain so rejected
+// requests are counted too.
+func StatsMiddleware(st stats.Stats) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl := &requestLog{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rl, r)
+			st.HTTPResponse(rl.status)
+		})
+	}
+}
+
+// requestLog records the response to a request for LoggingMiddleware.
+type requestLog struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	charmID     string
+}
+
+// WriteHeader records the status and sends it.
+func (rl *requestLog) WriteHeader(status int) {
+	if !rl.wroteHeader {
+		rl.status = status
+		rl.wroteHeader = true
+	}
+	rl.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written.
+func (rl *requestLog) Write(b []byte) (int, error) {
+	rl.wroteHeader = true
+	n, err := rl.ResponseWriter.Write(b)
+	rl.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (rl *requestLog) Unwrap() http.ResponseWriter {
+	return rl.ResponseWriter
+}
+
+// logCharmID adds the Charm ID of the user making r to its request log, if
+// it's being logged.
+func logCharmID(r *http.Request, charmID string) {
+	if rl, ok := r.Context().Value(ctxLogKey).(*requestLog); ok {
+		rl.charmID = charmID
+	}
+}
+
+// RateLimitMiddleware limits how often each Charm user may make requests,
+// with a token bucket per user allowing perMinute requests a minute in
+// bursts of up to perMinute. File uploads, including each request of a
+// resumable upload, are limited separately to fsWritesPerMinute, so large
+// uploads can be throttled harder. A limit of 0
+// or less disables it. Requests over the limit get a 429 with a Retry-After
+// header. Public requests aren't limited; the middleware must run after
+// CharmUserMiddleware to find the user.
+func RateLimitMiddleware(perMinute int, fsWritesPerMinute int) func(http.Handler) http.Handler {
+	requests := newRateLimiter(perMinute)
+	writes := newRateLimiter(fsWritesPerMinute)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, ok := r.Context().Value(ctxUserKey).(*charm.User)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			rl := requests
+			if (r.Method == http.MethodPost || r.Method == http.MethodPut) && isFSPath(r.URL.Path) {
+				rl = writes
+			}
+			if d := rl.wait(u.CharmID, time.Now()); d > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimiter keeps a token bucket per key.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	limiters  map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing perMinute events a minute
+// per key, or nil if perMinute is 0 or less.
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limit:    rate.Limit(float64(perMinute) / 60),
+		burst:    perMinute,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// wait takes a token for key, returning 0 if one was available and
+// otherwise how long until one will be. A nil rateLimiter never limits.
+func (rl *rateLimiter) wait(key string, now time.Time) time.Duration {
+	if rl == nil {
+		return 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.sweep(now)
+	l, ok := rl.limiters[key]
+	if !ok {
+		l = rate.NewLimiter(rl.limit, rl.burst)
+		rl.limiters[key] = l
+	}
+	res := l.ReserveN(now, 1)
+	d := res.DelayFrom(now)
+	if d > 0 {
+		res.CancelAt(now)
+	}
+	return d
+}
+
+// sweep forgets the buckets that have refilled, at most once a minute, so
+// idle users don't take up memory. A full bucket is the same as a new one.
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+	for k, l := range rl.limiters {
+		if l.TokensAt(now) >= float64(rl.burst) {
+			delete(rl.limiters, k)
+		}
+	}
+}
+
 // PublicPrefixesMiddleware allows for the specification of non-authed URL
 // prefixes. These won't be checked for JWT bearers or Charm user accounts.
 func PublicPrefixesMiddleware(prefixes []string) func(http.Handler) http.Handler {
@@ -104,6 +319,8 @@ func CharmUserMiddleware(s *HTTPServer) func(http.Handler) http.Handler {
 					s.renderError(w)
 					return
 				}
+				logCharmID(r, u.CharmID)
+				s.cfg.Stats.UserActive(u.CharmID)
 				ctx := context.WithValue(r.Context(), ctxUserKey, u)
 				h.ServeHTTP(w, r.WithContext(ctx))
 			}
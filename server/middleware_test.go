@@ -1,20 +1,26 @@
 // ABOUTME: Unit tests for HTTP middleware functions.
-// ABOUTME: Tests request size limits for standard and filesystem endpoints.
+// ABOUTME: Tests request logging, stats, and size and rate limits for standard and filesystem endpoints.
 package server
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/stats/noop"
+	"github.com/charmbracelet/log"
 )
 
 // TestRequestLimitMiddleware_NonFSEndpoint_ExceedsLimit tests that non-FS endpoints
 // reject requests with Content-Length > 1MB (413 status).
 func TestRequestLimitMiddleware_NonFSEndpoint_ExceedsLimit(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a simple handler that would normally succeed
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -45,7 +51,7 @@ func TestRequestLimitMiddleware_NonFSEndpoint_ExceedsLimit(t *testing.T) {
 // TestRequestLimitMiddleware_FSEndpoint_1MBAllowed tests that FS endpoints
 // allow requests with Content-Length > 1MB but < 1GB.
 func TestRequestLimitMiddleware_FSEndpoint_1MBAllowed(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a handler that reads and returns success
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +86,7 @@ func TestRequestLimitMiddleware_FSEndpoint_1MBAllowed(t *testing.T) {
 // TestRequestLimitMiddleware_FSEndpoint_ExceedsLimit tests that FS endpoints
 // reject requests with Content-Length > 1GB (413 status).
 func TestRequestLimitMiddleware_FSEndpoint_ExceedsLimit(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a simple handler that would normally succeed
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -112,7 +118,7 @@ func TestRequestLimitMiddleware_FSEndpoint_ExceedsLimit(t *testing.T) {
 // a request body exceeds the limit without Content-Length header, the middleware
 // triggers 413 when the handler attempts to read beyond the limit.
 func TestRequestLimitMiddleware_BodyExceedsLimit_NoContentLength(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a handler that tries to read the body
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -149,7 +155,7 @@ func TestRequestLimitMiddleware_BodyExceedsLimit_NoContentLength(t *testing.T) {
 // TestRequestLimitMiddleware_NonFSEndpoint_WithinLimit tests that non-FS endpoints
 // allow requests with Content-Length <= 1MB.
 func TestRequestLimitMiddleware_NonFSEndpoint_WithinLimit(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a handler that reads and returns success
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -179,7 +185,7 @@ func TestRequestLimitMiddleware_NonFSEndpoint_WithinLimit(t *testing.T) {
 // TestRequestLimitMiddleware_FSEndpoint_WithinLimit tests that FS endpoints
 // allow requests with Content-Length <= 1GB.
 func TestRequestLimitMiddleware_FSEndpoint_WithinLimit(t *testing.T) {
-	middleware := RequestLimitMiddleware()
+	middleware := RequestLimitMiddleware(0, 0)
 
 	// Create a handler that reads and returns success
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -217,14 +223,17 @@ func TestRequestLimitMiddleware_FSEndpoint_PathVariations(t *testing.T) {
 		{"/v1/fs/download", true},
 		{"/v1/fs", true},
 		{"/v1/fs/nested/path", true},
-		{"/v1/fsomething", true}, // HasPrefix matches this
+		{"/v1/fsomething", false},
+		{"/v1/uploads", true},
+		{"/v1/uploads/abc", true},
+		{"/v1/uploadsx", false},
 		{"/v1/api/fs", false},
 		{"/v2/fs/upload", false},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.path, func(t *testing.T) {
-			middleware := RequestLimitMiddleware()
+			middleware := RequestLimitMiddleware(0, 0)
 
 			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
@@ -254,3 +263,203 @@ func TestRequestLimitMiddleware_FSEndpoint_PathVariations(t *testing.T) {
 		})
 	}
 }
+
+// TestRequestLimitMiddleware_ConfiguredLimits tests that the limits passed to
+// the middleware replace the defaults.
+func TestRequestLimitMiddleware_ConfiguredLimits(t *testing.T) {
+	middleware := RequestLimitMiddleware(1024, 4096)
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		path string
+		size int
+		code int
+	}{
+		{"/v1/bio", 1024, http.StatusOK},
+		{"/v1/bio", 1025, http.StatusRequestEntityTooLarge},
+		{"/v1/fs/file", 4096, http.StatusOK},
+		{"/v1/fs/file", 4097, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("POST", tc.path, bytes.NewReader(make([]byte, tc.size)))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.code {
+			t.Errorf("%d bytes to %s: expected status %d, got %d", tc.size, tc.path, tc.code, rr.Code)
+		}
+	}
+}
+
+// rateLimitedRequest sends a request for the given user, if any, through h
+// and returns the response status and Retry-After header.
+func rateLimitedRequest(h http.Handler, method, path, charmID string) (int, string) {
+	req := httptest.NewRequest(method, path, nil)
+	if charmID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), ctxUserKey, &charm.User{CharmID: charmID}))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr.Code, rr.Header().Get("Retry-After")
+}
+
+// TestRateLimitMiddleware tests that each user gets their own bucket and
+// requests over the limit get a 429 with a Retry-After header.
+func TestRateLimitMiddleware(t *testing.T) {
+	h := RateLimitMiddleware(2, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if code, _ := rateLimitedRequest(h, "GET", "/v1/bio", "a"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	code, retry := rateLimitedRequest(h, "GET", "/v1/bio", "a")
+	if code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+	if retry != "30" {
+		t.Errorf("expected Retry-After 30, got %q", retry)
+	}
+	if code, _ := rateLimitedRequest(h, "GET", "/v1/bio", "b"); code != http.StatusOK {
+		t.Errorf("expected another user to be allowed, got %d", code)
+	}
+	if code, _ := rateLimitedRequest(h, "GET", "/v1/public/jwks", ""); code != http.StatusOK {
+		t.Errorf("expected public requests not to be limited, got %d", code)
+	}
+
+	// Uploads have their own, lower limit
+	if code, _ := rateLimitedRequest(h, "POST", "/v1/fs/a.txt", "a"); code != http.StatusOK {
+		t.Errorf("expected the first upload to be allowed, got %d", code)
+	}
+	code, retry = rateLimitedRequest(h, "POST", "/v1/fs/b.txt", "a")
+	if code != http.StatusTooManyRequests || retry != "60" {
+		t.Errorf("expected 429 with Retry-After 60, got %d and %q", code, retry)
+	}
+}
+
+// TestRateLimitMiddleware_Uploads tests that every request of a resumable
+// upload counts towards the upload limit.
+func TestRateLimitMiddleware_Uploads(t *testing.T) {
+	h := RateLimitMiddleware(100, 3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, req := range []struct{ method, path string }{
+		{"POST", "/v1/uploads"},
+		{"PUT", "/v1/uploads/id"},
+		{"POST", "/v1/uploads/id/complete"},
+	} {
+		if code, _ := rateLimitedRequest(h, req.method, req.path, "a"); code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d", req.method, req.path, code)
+		}
+	}
+	if code, _ := rateLimitedRequest(h, "PUT", "/v1/uploads/id", "a"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the upload limit is used up, got %d", code)
+	}
+	if code, _ := rateLimitedRequest(h, "GET", "/v1/uploads/id", "a"); code != http.StatusOK {
+		t.Errorf("expected reads not to count as uploads, got %d", code)
+	}
+}
+
+// TestRateLimitMiddleware_Disabled tests that a limit of 0 allows everything.
+func TestRateLimitMiddleware_Disabled(t *testing.T) {
+	h := RateLimitMiddleware(0, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 100; i++ {
+		if code, _ := rateLimitedRequest(h, "POST", "/v1/fs/a.txt", "a"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, code)
+		}
+	}
+}
+
+// TestRateLimiterSweep tests that refilled buckets are forgotten.
+func TestRateLimiterSweep(t *testing.T) {
+	rl := newRateLimiter(60)
+	now := time.Now()
+	rl.wait("a", now)
+	rl.wait("b", now.Add(2*time.Minute-500*time.Millisecond))
+	rl.wait("c", now.Add(2*time.Minute))
+	if _, ok := rl.limiters["a"]; ok {
+		t.Error("expected the refilled bucket to be forgotten")
+	}
+	if len(rl.limiters) != 2 {
+		t.Errorf("expected 2 buckets, got %d", len(rl.limiters))
+	}
+}
+
+// TestLoggingMiddleware tests that requests are logged with their status,
+// size and the user making them.
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf)
+	h := LoggingMiddleware(logger, log.InfoLevel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logCharmID(r, "charm-id")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("not here"))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/fs/missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected the handler's status, got %d", rr.Code)
+	}
+	out := buf.String()
+	for _, want := range []string{"method=GET", "path=/v1/fs/missing", "status=404", "bytes=8", "latency=", "id=charm-id"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the log, got %q", want, out)
+		}
+	}
+}
+
+// TestLoggingMiddleware_Level tests that requests are logged at the given
+// level.
+func TestLoggingMiddleware_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf)
+	h := LoggingMiddleware(logger, log.DebugLevel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if buf.Len() != 0 {
+		t.Errorf("expected debug logs to be filtered out, got %q", buf.String())
+	}
+	logger.SetLevel(log.DebugLevel)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(buf.String(), "status=200") {
+		t.Errorf("expected the request to be logged, got %q", buf.String())
+	}
+}
+
+// statusStats records the statuses reported to it.
+type statusStats struct {
+	noop.Stats
+	statuses []int
+}
+
+func (st *statusStats) HTTPResponse(status int) {
+	st.statuses = append(st.statuses, status)
+}
+
+// TestStatsMiddleware tests that the status of every response is reported,
+// including the default 200.
+func TestStatsMiddleware(t *testing.T) {
+	st := &statusStats{}
+	h := StatsMiddleware(st)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if len(st.statuses) != 2 || st.statuses[0] != http.StatusNotFound || st.statuses[1] != http.StatusOK {
+		t.Errorf("expected statuses [404 200], got %v", st.statuses)
+	}
+}
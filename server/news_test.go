@@ -113,9 +113,7 @@ func TestNewsIntegration(t *testing.T) {
 		t.Fatalf("failed to post custom tag news: %s", err)
 	}
 
-	// Retrieve news list with "server" tag via client
-	// NOTE: Client sends "tags" parameter but server reads "tag" parameter
-	// So we need to use raw request to test properly
+	// Retrieve news list with "server" tag
 	resp, err := cl.AuthedRawRequest("GET", "/v1/news?page=1&tag=server")
 	if err != nil {
 		t.Fatalf("failed to get news list: %s", err)
@@ -164,37 +162,126 @@ func TestNewsIntegration(t *testing.T) {
 	}
 }
 
-// TestNewsListClientServerTagMismatch documents the mismatch between client and server
-// The client uses 'tags' query parameter but the server expects 'tag' (singular)
-func TestNewsListClientServerTagMismatch(t *testing.T) {
-	cl := testserver.SetupTestServer(t)
+// TestNewsListFiltersByTags tests that the client's tags filter the news list,
+// matching news tagged with any of them
+func TestNewsListFiltersByTags(t *testing.T) {
+	cl, srv := setupTestServerWithDB(t)
 
 	_, err := cl.Auth()
 	if err != nil {
 		t.Fatalf("auth error: %s", err)
 	}
 
-	// The client.NewsList() method uses 'tags' parameter (plural)
-	// But server handleGetNewsList() expects 'tag' parameter (singular)
-	// This test documents this behavior
+	posts := []struct {
+		subject string
+		tags    []string
+	}{
+		{"Server News", []string{"server"}},
+		{"Custom News", []string{"custom-tag"}},
+		{"Other News", []string{"other-tag"}},
+		{"Both News", []string{"custom-tag", "other-tag"}},
+	}
+	for _, p := range posts {
+		if err := srv.Config.DB.PostNews(p.subject, "body", p.tags); err != nil {
+			t.Fatalf("failed to post news: %s", err)
+		}
+	}
 
-	// Client sends: /v1/news?page=1&tags=server
-	// Server reads: r.FormValue("tag")
-	// This means the server will use default "server" tag instead of what client sends
+	subjects := func(ns []*charm.News) map[string]bool {
+		m := map[string]bool{}
+		for _, n := range ns {
+			m[n.Subject] = true
+		}
+		return m
+	}
 
 	newsList, err := cl.NewsList([]string{"custom-tag"}, 1)
 	if err != nil {
 		t.Fatalf("failed to get news list: %s", err)
 	}
+	got := subjects(newsList)
+	if len(newsList) != 2 || !got["Custom News"] || !got["Both News"] {
+		t.Errorf("expected custom-tag news only, got %v", got)
+	}
+
+	newsList, err = cl.NewsList([]string{"custom-tag", "other-tag"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list: %s", err)
+	}
+	got = subjects(newsList)
+	if len(newsList) != 3 || !got["Custom News"] || !got["Other News"] || !got["Both News"] {
+		t.Errorf("expected news with either tag listed once, got %d items: %v", len(newsList), got)
+	}
 
-	// This will succeed but won't filter by "custom-tag" due to the mismatch
-	// The server ignores the "tags" parameter and uses "tag" parameter
-	t.Logf("News list retrieved with %d items (tag mismatch means filtering may not work)", len(newsList))
+	// Older clients send a comma separated tags parameter or a single tag
+	for _, q := range []string{"tags=custom-tag,other-tag", "tag=custom-tag&tag=other-tag"} {
+		resp, err := cl.AuthedRawRequest("GET", "/v1/news?page=1&"+q)
+		if err != nil {
+			t.Fatalf("failed to get news list: %s", err)
+		}
+		var ns []*charm.News
+		err = json.NewDecoder(resp.Body).Decode(&ns)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to decode news list: %s", err)
+		}
+		if len(ns) != 3 {
+			t.Errorf("%s: expected 3 news items, got %d", q, len(ns))
+		}
+	}
 }
 
-// TestNewsListPageZero tests what happens when page=0 is requested
-// According to server code: offset = (page - 1) * resultsPerPage
-// With page=0: offset = (0 - 1) * 50 = -50
+// TestNewsListWithCount tests that the total count covers every page and
+// follows the tag filter
+func TestNewsListWithCount(t *testing.T) {
+	cl, srv := setupTestServerWithDB(t)
+
+	_, err := cl.Auth()
+	if err != nil {
+		t.Fatalf("auth error: %s", err)
+	}
+
+	for i := 0; i < 55; i++ {
+		if err := srv.Config.DB.PostNews(fmt.Sprintf("News %d", i), "body", []string{"counted"}); err != nil {
+			t.Fatalf("failed to post news: %s", err)
+		}
+	}
+	if err := srv.Config.DB.PostNews("Tagged twice", "body", []string{"counted", "other"}); err != nil {
+		t.Fatalf("failed to post news: %s", err)
+	}
+	if err := srv.Config.DB.PostNews("Other", "body", []string{"other"}); err != nil {
+		t.Fatalf("failed to post news: %s", err)
+	}
+
+	newsList, total, err := cl.NewsListWithCount([]string{"counted"}, 2)
+	if err != nil {
+		t.Fatalf("failed to get news list with count: %s", err)
+	}
+	if total != 56 {
+		t.Errorf("expected a total of 56, got %d", total)
+	}
+	if len(newsList) != 6 {
+		t.Errorf("expected 6 news items on page 2, got %d", len(newsList))
+	}
+
+	_, total, err = cl.NewsListWithCount([]string{"counted", "other"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list with count: %s", err)
+	}
+	if total != 57 {
+		t.Errorf("expected news tagged twice to be counted once, got a total of %d", total)
+	}
+
+	_, total, err = cl.NewsListWithCount([]string{"missing"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list with count: %s", err)
+	}
+	if total != 0 {
+		t.Errorf("expected a total of 0, got %d", total)
+	}
+}
+
+// TestNewsListPageZero tests that page=0 is treated as the first page
 func TestNewsListPageZero(t *testing.T) {
 	cl := testserver.SetupTestServer(t)
 
@@ -209,11 +296,6 @@ func TestNewsListPageZero(t *testing.T) {
 		t.Fatalf("failed to get news list with page=0: %s", err)
 	}
 
-	// SQLite LIMIT with negative OFFSET behavior:
-	// OFFSET -50 is treated as OFFSET 0 in SQLite
-	// So page=0 should return the same results as page=1
-	t.Logf("Page 0 returned %d items (negative offset treated as 0)", len(newsList))
-
 	// Verify it returns same as page 1
 	newsListPage1, err := cl.NewsList([]string{"server"}, 1)
 	if err != nil {
@@ -225,7 +307,8 @@ func TestNewsListPageZero(t *testing.T) {
 	}
 }
 
-// TestNewsListInvalidPage tests server behavior with invalid page parameter
+// TestNewsListInvalidPage tests that a page that isn't a number is rejected
+// as a bad request
 func TestNewsListInvalidPage(t *testing.T) {
 	cl := testserver.SetupTestServer(t)
 
@@ -234,34 +317,23 @@ func TestNewsListInvalidPage(t *testing.T) {
 		t.Fatalf("auth error: %s", err)
 	}
 
-	// Make a raw request with invalid page parameter
-	// The client doesn't expose this, so we need to use AuthedRawRequest
-	// Note: AuthedRawRequest returns an error for non-200 status codes
-	// So we expect the error to occur, which indicates the server properly rejected the request
-
+	// The client doesn't expose this, so we need to use AuthedRawRequest,
+	// which returns an error for non-2xx status codes
 	resp, err := cl.AuthedRawRequest("GET", "/v1/news?page=abc")
-
-	// Server should return 500 (Internal Server Error) based on handleGetNewsList code
-	// Lines 379-382 in http.go show strconv.Atoi error returns 500
-	// The client wraps this as an error
 	if err == nil {
-		t.Error("expected error for invalid page parameter, got nil")
-		if resp != nil {
-			resp.Body.Close()
-		}
-		return
+		resp.Body.Close()
+		t.Fatal("expected error for invalid page parameter, got nil")
 	}
-
-	// Verify the error message indicates server error
-	errStr := fmt.Sprintf("%s", err)
-	if errStr == "" {
-		t.Error("error should have a message")
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "invalid page: abc") {
+		t.Errorf("expected the error to name the page, got %v", err)
 	}
-
-	t.Logf("Invalid page error (expected): %s", err)
 }
 
-// TestNewsListInvalidPageNegative tests server behavior with negative page numbers
+// TestNewsListInvalidPageNegative tests that a negative page is treated as
+// the first page
 func TestNewsListInvalidPageNegative(t *testing.T) {
 	cl := testserver.SetupTestServer(t)
 
@@ -270,15 +342,17 @@ func TestNewsListInvalidPageNegative(t *testing.T) {
 		t.Fatalf("auth error: %s", err)
 	}
 
-	// Test with negative page number
 	newsList, err := cl.NewsList([]string{"server"}, -5)
 	if err != nil {
 		t.Fatalf("failed to get news list with page=-5: %s", err)
 	}
-
-	// Server calculates: offset = (-5 - 1) * 50 = -300
-	// SQLite treats negative OFFSET as 0
-	t.Logf("Page -5 returned %d items (negative offset treated as 0)", len(newsList))
+	newsListPage1, err := cl.NewsList([]string{"server"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list with page=1: %s", err)
+	}
+	if len(newsList) != len(newsListPage1) {
+		t.Errorf("page=-5 returned %d items but page=1 returned %d items", len(newsList), len(newsListPage1))
+	}
 }
 
 // TestNewsListPagination tests basic pagination behavior
@@ -524,3 +598,73 @@ func TestNewsURLEncoding(t *testing.T) {
 
 	t.Logf("URL encoding test error: %s", err)
 }
+
+// TestNewsDelete tests that admins can delete news, removing it from the list
+func TestNewsDelete(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "admin_ed25519")
+	kp, err := keygen.New(keyPath, keygen.WithKeyType(keygen.Ed25519), keygen.WithWrite())
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	t.Setenv("CHARM_IDENTITY_KEY", keyPath)
+	t.Setenv("CHARM_SERVER_ADMIN_KEYS", kp.AuthorizedKey())
+	cl, srv := setupTestServerWithDB(t)
+
+	if err := srv.Config.DB.PostNews("Mistake", "body", []string{"deleted"}); err != nil {
+		t.Fatalf("failed to post news: %s", err)
+	}
+	newsList, err := cl.NewsList([]string{"deleted"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list: %s", err)
+	}
+	if len(newsList) != 1 {
+		t.Fatalf("expected 1 news item, got %d", len(newsList))
+	}
+
+	resp, err := cl.AuthedRawRequest("DELETE", "/v1/news/"+newsList[0].ID)
+	if err != nil {
+		t.Fatalf("failed to delete news: %s", err)
+	}
+	resp.Body.Close()
+	newsList, err = cl.NewsList([]string{"deleted"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list: %s", err)
+	}
+	if len(newsList) != 0 {
+		t.Errorf("expected deleted news to be gone, got %d items", len(newsList))
+	}
+
+	// Deleting it again, or news that never existed, is not found
+	for _, id := range []string{"1", "nonexistent"} {
+		resp, err := cl.AuthedRawRequest("DELETE", "/v1/news/"+id)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("expected a not found error deleting news %s, got %v", id, err)
+		}
+	}
+}
+
+// TestNewsDeleteForbidden tests that only admins can delete news
+func TestNewsDeleteForbidden(t *testing.T) {
+	cl, srv := setupTestServerWithDB(t)
+
+	if err := srv.Config.DB.PostNews("Keep me", "body", []string{"kept"}); err != nil {
+		t.Fatalf("failed to post news: %s", err)
+	}
+	resp, err := cl.AuthedRawRequest("DELETE", "/v1/news/1")
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+	newsList, err := cl.NewsList([]string{"kept"}, 1)
+	if err != nil {
+		t.Fatalf("failed to get news list: %s", err)
+	}
+	if len(newsList) != 1 {
+		t.Errorf("expected news to be kept, got %d items", len(newsList))
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/charmbracelet/charm/server/stats/prometheus"
 	"github.com/charmbracelet/charm/server/storage"
 	lfs "github.com/charmbracelet/charm/server/storage/local"
+	s3storage "github.com/charmbracelet/charm/server/storage/s3"
 	"github.com/charmbracelet/log"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/sync/errgroup"
@@ -26,29 +27,42 @@ import (
 
 // Config is the configuration for the Charm server.
 type Config struct {
-	BindAddr       string `env:"CHARM_SERVER_BIND_ADDRESS" envDefault:""`
-	Host           string `env:"CHARM_SERVER_HOST" envDefault:"localhost"`
-	SSHPort        int    `env:"CHARM_SERVER_SSH_PORT" envDefault:"35353"`
-	HTTPPort       int    `env:"CHARM_SERVER_HTTP_PORT" envDefault:"35354"`
-	StatsPort      int    `env:"CHARM_SERVER_STATS_PORT" envDefault:"35355"`
-	HealthPort     int    `env:"CHARM_SERVER_HEALTH_PORT" envDefault:"35356"`
-	DataDir        string `env:"CHARM_SERVER_DATA_DIR" envDefault:"data"`
-	UseTLS         bool   `env:"CHARM_SERVER_USE_TLS" envDefault:"false"`
-	TLSKeyFile     string `env:"CHARM_SERVER_TLS_KEY_FILE"`
-	TLSCertFile    string `env:"CHARM_SERVER_TLS_CERT_FILE"`
-	PublicURL      string `env:"CHARM_SERVER_PUBLIC_URL"`
-	EnableMetrics  bool   `env:"CHARM_SERVER_ENABLE_METRICS" envDefault:"false"`
-	UserMaxStorage int64  `env:"CHARM_SERVER_USER_MAX_STORAGE" envDefault:"0"`
-	errorLog       *glog.Logger
-	PublicKey      []byte
-	PrivateKey     []byte
-	DB             db.DB
-	FileStore      storage.FileStore
-	Stats          stats.Stats
-	linkQueue      charm.LinkQueue
-	tlsConfig      *tls.Config
-	jwtKeyPair     JSONWebKeyPair
-	httpScheme     string
+	BindAddr          string   `env:"CHARM_SERVER_BIND_ADDRESS" envDefault:""`
+	Host              string   `env:"CHARM_SERVER_HOST" envDefault:"localhost"`
+	SSHPort           int      `env:"CHARM_SERVER_SSH_PORT" envDefault:"35353"`
+	HTTPPort          int      `env:"CHARM_SERVER_HTTP_PORT" envDefault:"35354"`
+	StatsPort         int      `env:"CHARM_SERVER_STATS_PORT" envDefault:"35355"`
+	HealthPort        int      `env:"CHARM_SERVER_HEALTH_PORT" envDefault:"35356"`
+	DataDir           string   `env:"CHARM_SERVER_DATA_DIR" envDefault:"data"`
+	UseTLS            bool     `env:"CHARM_SERVER_USE_TLS" envDefault:"false"`
+	TLSKeyFile        string   `env:"CHARM_SERVER_TLS_KEY_FILE"`
+	TLSCertFile       string   `env:"CHARM_SERVER_TLS_CERT_FILE"`
+	PublicURL         string   `env:"CHARM_SERVER_PUBLIC_URL"`
+	EnableMetrics     bool     `env:"CHARM_SERVER_ENABLE_METRICS" envDefault:"false"`
+	UserMaxStorage    int64    `env:"CHARM_SERVER_USER_MAX_STORAGE" envDefault:"0"`
+	S3Bucket          string   `env:"CHARM_SERVER_S3_BUCKET"`
+	S3Region          string   `env:"CHARM_SERVER_S3_REGION"`
+	S3Endpoint        string   `env:"CHARM_SERVER_S3_ENDPOINT"`
+	S3PathStyle       bool     `env:"CHARM_SERVER_S3_PATH_STYLE" envDefault:"false"`
+	CompressFiles     bool     `env:"CHARM_SERVER_COMPRESS_FILES" envDefault:"false"`
+	RateLimit         int      `env:"CHARM_SERVER_RATE_LIMIT" envDefault:"0"`
+	FSWriteRateLimit  int      `env:"CHARM_SERVER_FS_WRITE_RATE_LIMIT" envDefault:"0"`
+	AdminKeys         []string `env:"CHARM_SERVER_ADMIN_KEYS" envSeparator:","`
+	LogRequests       bool     `env:"CHARM_SERVER_LOG_REQUESTS" envDefault:"true"`
+	RequestLogLevel   string   `env:"CHARM_SERVER_REQUEST_LOG_LEVEL" envDefault:"info"`
+	MaxRequestBytes   int64    `env:"CHARM_SERVER_MAX_REQUEST_BYTES" envDefault:"1048576"`
+	MaxFSRequestBytes int64    `env:"CHARM_SERVER_MAX_FS_REQUEST_BYTES" envDefault:"1073741824"`
+	errorLog          *glog.Logger
+	Version           string
+	PublicKey         []byte
+	PrivateKey        []byte
+	DB                db.DB
+	FileStore         storage.FileStore
+	Stats             stats.Stats
+	linkQueue         charm.LinkQueue
+	tlsConfig         *tls.Config
+	jwtKeyPair        JSONWebKeyPair
+	httpScheme        string
 }
 
 // Server contains the SSH and HTTP servers required to host the Charm Cloud.
@@ -211,6 +225,20 @@ func (srv *Server) init(cfg *Config) {
 		}
 		srv.Config = cfg.WithDB(db)
 	}
+	if cfg.FileStore == nil && cfg.S3Bucket != "" {
+		// Credentials come from the default AWS chain, such as the
+		// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables
+		fs, err := s3storage.NewS3FileStore(context.Background(), s3storage.Config{
+			Bucket:       cfg.S3Bucket,
+			Region:       cfg.S3Region,
+			Endpoint:     cfg.S3Endpoint,
+			UsePathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			log.Fatal("could not init s3 storage", "err", err)
+		}
+		srv.Config = cfg.WithFileStore(fs)
+	}
 	if cfg.FileStore == nil {
 		fs, err := lfs.NewLocalFileStore(filepath.Join(cfg.DataDir, "files"))
 		if err != nil {
@@ -218,6 +246,9 @@ func (srv *Server) init(cfg *Config) {
 		}
 		srv.Config = cfg.WithFileStore(fs)
 	}
+	if _, ok := cfg.FileStore.(*storage.CompressingFileStore); cfg.CompressFiles && !ok {
+		srv.Config = cfg.WithFileStore(storage.NewCompressingFileStore(cfg.FileStore))
+	}
 	if cfg.Stats == nil {
 		srv.Config = cfg.WithStats(getStatsImpl(cfg))
 	}
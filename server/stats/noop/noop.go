@@ -17,6 +17,7 @@ func (Stats) APILinkGen()                      {}
 func (Stats) APILinkRequest()                  {}
 func (Stats) APIUnlink()                       {}
 func (Stats) APIAuth()                         {}
+func (Stats) APIAuthFailed()                   {}
 func (Stats) APIKeys()                         {}
 func (Stats) LinkGen()                         {}
 func (Stats) LinkRequest()                     {}
@@ -31,6 +32,9 @@ func (Stats) GetNews()                         {}
 func (Stats) PostNews()                        {}
 func (Stats) FSFileRead(_ string, _ int64)     {}
 func (Stats) FSFileWritten(_ string, _ int64)  {}
+func (Stats) KVBackupWritten(_ int64)          {}
+func (Stats) HTTPResponse(_ int)               {}
+func (Stats) UserActive(_ string)              {}
 func (Stats) Start() error                     { return nil }
 func (Stats) Close() error                     { return nil }
 func (Stats) Shutdown(_ context.Context) error { return nil }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/charm/server/db"
@@ -13,12 +15,17 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// activeUserWindow is how recently a user must have made a request to count
+// as active.
+const activeUserWindow = 24 * time.Hour
+
 // Stats contains all of the calls to track metrics.
 type Stats struct {
 	apiLinkGenCalls     prometheus.Counter
 	apiLinkRequestCalls prometheus.Counter
 	apiUnlinkCalls      prometheus.Counter
 	apiAuthCalls        prometheus.Counter
+	apiAuthFailures     prometheus.Counter
 	apiKeysCalls        prometheus.Counter
 	linkGenCalls        prometheus.Counter
 	linkRequestCalls    prometheus.Counter
@@ -35,8 +42,13 @@ type Stats struct {
 	fsBytesWritten      *prometheus.CounterVec
 	fsReads             *prometheus.CounterVec
 	fsWritten           *prometheus.CounterVec
+	kvBackupBytes       prometheus.Histogram
+	httpResponses       *prometheus.CounterVec
 	users               prometheus.Gauge
 	userNames           prometheus.Gauge
+	activeUsers         prometheus.Gauge
+	lastSeenMu          sync.Mutex
+	lastSeen            map[string]time.Time
 	db                  db.DB
 	port                int
 	server              *http.Server
@@ -55,6 +67,7 @@ func (ps *Stats) Start() error {
 			if err == nil {
 				ps.userNames.Set(float64(c))
 			}
+			ps.activeUsers.Set(float64(ps.countActiveUsers(time.Now())))
 
 			time.Sleep(time.Minute)
 		}
@@ -96,6 +109,7 @@ func NewStats(db db.DB, port int) *Stats {
 		apiLinkRequestCalls: newCounter("charm_id_api_link_request_total", "Total api link request calls"),
 		apiUnlinkCalls:      newCounter("charm_id_api_unlink_total", "Total api unlink calls"),
 		apiAuthCalls:        newCounter("charm_id_api_auth_total", "Total api auth calls"),
+		apiAuthFailures:     newCounter("charm_id_api_auth_failed_total", "Total failed api auth calls"),
 		apiKeysCalls:        newCounter("charm_id_api_keys_total", "Total api keys calls"),
 		linkGenCalls:        newCounter("charm_id_link_gen_total", "Total link gen calls"),
 		linkRequestCalls:    newCounter("charm_id_link_request_total", "Total link request calls"),
@@ -112,8 +126,12 @@ func NewStats(db db.DB, port int) *Stats {
 		fsBytesWritten:      newCounterWithLabels("charm_fs_bytes_written_total", "Total bytes written", fsLabels),
 		fsReads:             newCounterWithLabels("charm_fs_files_read_total", "Total files read", fsLabels),
 		fsWritten:           newCounterWithLabels("charm_fs_files_written_total", "Total files read", fsLabels),
+		kvBackupBytes:       newHistogram("charm_kv_backup_bytes", "Size of uploaded kv backups", prometheus.ExponentialBuckets(1<<10, 4, 10)),
+		httpResponses:       newCounterWithLabels("charm_http_responses_total", "Total http responses", []string{"code"}),
 		users:               newGauge("charm_bio_users", "Total users"),
 		userNames:           newGauge("charm_bio_users_names", "Total usernames"),
+		activeUsers:         newGauge("charm_bio_active_users", "Users who made a request in the last 24 hours"),
+		lastSeen:            make(map[string]time.Time),
 		db:                  db,
 		port:                port,
 		server:              s,
@@ -140,6 +158,11 @@ func (ps *Stats) APIAuth() {
 	ps.apiAuthCalls.Inc()
 }
 
+// APIAuthFailed increments the number of failed api-auth calls.
+func (ps *Stats) APIAuthFailed() {
+	ps.apiAuthFailures.Inc()
+}
+
 // APIKeys increments the number of api-keys calls.
 func (ps *Stats) APIKeys() {
 	ps.apiKeysCalls.Inc()
@@ -212,6 +235,36 @@ func (ps *Stats) FSFileWritten(id string, size int64) {
 	ps.fsBytesWritten.WithLabelValues(id).Add(float64(size))
 }
 
+// KVBackupWritten reports the size of an uploaded kv backup.
+func (ps *Stats) KVBackupWritten(size int64) {
+	ps.kvBackupBytes.Observe(float64(size))
+}
+
+// HTTPResponse increments the number of http responses with the given status.
+func (ps *Stats) HTTPResponse(status int) {
+	ps.httpResponses.WithLabelValues(strconv.Itoa(status)).Inc()
+}
+
+// UserActive records a request by a given charm_id for the active users gauge.
+func (ps *Stats) UserActive(id string) {
+	ps.lastSeenMu.Lock()
+	defer ps.lastSeenMu.Unlock()
+	ps.lastSeen[id] = time.Now()
+}
+
+// countActiveUsers returns the number of users seen within activeUserWindow
+// of now, forgetting the others.
+func (ps *Stats) countActiveUsers(now time.Time) int {
+	ps.lastSeenMu.Lock()
+	defer ps.lastSeenMu.Unlock()
+	for id, t := range ps.lastSeen {
+		if now.Sub(t) > activeUserWindow {
+			delete(ps.lastSeen, id)
+		}
+	}
+	return len(ps.lastSeen)
+}
+
 func newCounter(name string, help string) prometheus.Counter {
 	return promauto.NewCounter(prometheus.CounterOpts{
 		Name: name,
@@ -232,3 +285,11 @@ func newGauge(name string, help string) prometheus.Gauge {
 		Help: help,
 	})
 }
+
+func newHistogram(name string, help string, buckets []float64) prometheus.Histogram {
+	return promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+	})
+}
@@ -11,6 +11,7 @@ type Stats interface {
 	APILinkRequest()
 	APIUnlink()
 	APIAuth()
+	APIAuthFailed()
 	APIKeys()
 	LinkGen()
 	LinkRequest()
@@ -25,5 +26,8 @@ type Stats interface {
 	PostNews()
 	FSFileRead(id string, size int64)
 	FSFileWritten(id string, size int64)
+	KVBackupWritten(size int64)
+	HTTPResponse(status int)
+	UserActive(id string)
 	Close() error
 }
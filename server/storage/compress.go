@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+)
+
+// gzipMarker starts every file stored by a CompressingFileStore, so files
+// stored before compression was enabled can still be told apart and read.
+var gzipMarker = []byte("\x00charm-gzip\x00")
+
+// CompressingFileStore is a FileStore that gzip-compresses files before
+// storing them in the wrapped FileStore, and decompresses them when they're
+// read. Files stored without compression are read as they are, so it can be
+// enabled on a store that already holds files.
+//
+// Files reach the server encrypted, and encrypted data barely compresses:
+// only the encryption header shrinks, which is worthwhile for lots of small
+// files and little else. Sizes reported by Stat, Usage and Stats are of the
+// stored, compressed files. The size of a compressed file isn't known until
+// it has been read, so files returned by Get report a size of -1.
+type CompressingFileStore struct {
+	FileStore
+}
+
+// NewCompressingFileStore returns a CompressingFileStore wrapping fs.
+func NewCompressingFileStore(fs FileStore) *CompressingFileStore {
+	return &CompressingFileStore{FileStore: fs}
+}
+
+// Put compresses the data read from r while streaming it to the wrapped
+// FileStore.
+func (cfs *CompressingFileStore) Put(charmID string, path string, r io.Reader, mode fs.FileMode) error {
+	if mode.IsDir() {
+		return cfs.FileStore.Put(charmID, path, r, mode)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(compress(pw, r))
+	}()
+	err := cfs.FileStore.Put(charmID, path, pr, mode)
+	// Stop the compressing goroutine if the wrapped FileStore gave up early
+	pr.CloseWithError(io.ErrClosedPipe)
+	return err
+}
+
+// compress writes the marker and the gzip-compressed data read from r to w.
+func compress(w io.Writer, r io.Reader) error {
+	if _, err := w.Write(gzipMarker); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if _, err := io.Copy(zw, r); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Get returns the file from the wrapped FileStore, decompressing it as it's
+// read if it was stored compressed, in which case its Stat reports a size of
+// -1. Directory listings are returned as they are.
+func (cfs *CompressingFileStore) Get(charmID string, path string) (fs.File, error) {
+	f, err := cfs.FileStore.Get(charmID, path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close() // nolint:errcheck
+		return nil, err
+	}
+	if info.IsDir() {
+		return f, nil
+	}
+	br := bufio.NewReader(f)
+	head, err := br.Peek(len(gzipMarker))
+	if err != nil && err != io.EOF {
+		f.Close() // nolint:errcheck
+		return nil, err
+	}
+	if !bytes.Equal(head, gzipMarker) {
+		return &storedFile{File: f, r: br}, nil
+	}
+	if _, err := br.Discard(len(gzipMarker)); err != nil {
+		f.Close() // nolint:errcheck
+		return nil, err
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		f.Close() // nolint:errcheck
+		return nil, err
+	}
+	return &storedFile{File: f, r: zr, zr: zr}, nil
+}
+
+// storedFile is a file from the wrapped FileStore read through r.
+type storedFile struct {
+	fs.File
+	r  io.Reader
+	zr *gzip.Reader
+}
+
+// Read reads the file's contents.
+func (f *storedFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+// Stat returns the file's info. The size of a compressed file is -1, as it
+// isn't known until the file has been decompressed.
+func (f *storedFile) Stat() (fs.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil || f.zr == nil {
+		return fi, err
+	}
+	return unknownSizeInfo{fi}, nil
+}
+
+// Close closes the file.
+func (f *storedFile) Close() error {
+	if f.zr != nil {
+		_ = f.zr.Close()
+	}
+	return f.File.Close()
+}
+
+// unknownSizeInfo is the FileInfo of a file whose size isn't known.
+type unknownSizeInfo struct {
+	fs.FileInfo
+}
+
+// Size returns -1.
+func (unknownSizeInfo) Size() int64 {
+	return -1
+}
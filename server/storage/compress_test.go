@@ -0,0 +1,125 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/charm/server/storage"
+	localstorage "github.com/charmbracelet/charm/server/storage/local"
+	"github.com/google/uuid"
+)
+
+func newCompressingStore(t *testing.T) (*storage.CompressingFileStore, *localstorage.LocalFileStore, string) {
+	t.Helper()
+	lfs, err := localstorage.NewLocalFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.NewCompressingFileStore(lfs), lfs, uuid.New().String()
+}
+
+func readAll(t *testing.T, fsys storage.FileStore, charmID, path string) []byte {
+	t.Helper()
+	f, err := fsys.Get(charmID, path)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", path, err)
+	}
+	defer f.Close() // nolint:errcheck
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestCompressingFileStoreRoundTrip(t *testing.T) {
+	cfs, lfs, charmID := newCompressingStore(t)
+	content := strings.Repeat("compress me ", 1000)
+
+	if err := cfs.Put(charmID, "/a.txt", strings.NewReader(content), 0o644); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+	if got := readAll(t, cfs, charmID, "/a.txt"); string(got) != content {
+		t.Errorf("expected the original contents back, got %d bytes", len(got))
+	}
+	stored := readAll(t, lfs, charmID, "/a.txt")
+	if len(stored) >= len(content) {
+		t.Errorf("expected the stored file to be compressed, got %d bytes for %d", len(stored), len(content))
+	}
+	f, err := cfs.Get(charmID, "/a.txt")
+	if err != nil {
+		t.Fatalf("failed to get file: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	if fi, err := f.Stat(); err != nil || fi.Size() != -1 {
+		t.Errorf("expected an unknown size for a compressed file, got %v, %v", fi, err)
+	}
+
+	if err := cfs.Put(charmID, "/empty.txt", bytes.NewReader(nil), 0o644); err != nil {
+		t.Fatalf("failed to put empty file: %v", err)
+	}
+	if got := readAll(t, cfs, charmID, "/empty.txt"); len(got) != 0 {
+		t.Errorf("expected an empty file, got %q", got)
+	}
+}
+
+func TestCompressingFileStoreReadsUncompressed(t *testing.T) {
+	cfs, lfs, charmID := newCompressingStore(t)
+	for name, content := range map[string]string{"/old.txt": "stored before compression", "/short": "x"} {
+		if err := lfs.Put(charmID, name, strings.NewReader(content), 0o644); err != nil {
+			t.Fatalf("failed to put file: %v", err)
+		}
+		if got := readAll(t, cfs, charmID, name); string(got) != content {
+			t.Errorf("expected %q, got %q", content, got)
+		}
+	}
+}
+
+func TestCompressingFileStoreDirectories(t *testing.T) {
+	cfs, _, charmID := newCompressingStore(t)
+	if err := cfs.Put(charmID, "/dir/a.txt", strings.NewReader("a"), 0o644); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+	if err := cfs.Put(charmID, "/empty", bytes.NewReader(nil), fs.ModeDir|0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	f, err := cfs.Get(charmID, "/dir")
+	if err != nil {
+		t.Fatalf("failed to get directory: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+	info, err := f.Stat()
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected a directory, got %v, %v", info, err)
+	}
+	if data, _ := io.ReadAll(f); !bytes.Contains(data, []byte(`"a.txt"`)) {
+		t.Errorf("expected the directory listing, got %s", data)
+	}
+	if info, err := cfs.Stat(charmID, "/empty"); err != nil || !info.IsDir() {
+		t.Errorf("expected an empty directory, got %v, %v", info, err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestCompressingFileStoreFailedPut(t *testing.T) {
+	cfs, lfs, charmID := newCompressingStore(t)
+	err := cfs.Put(charmID, "/a.txt", io.MultiReader(strings.NewReader("partial"), failingReader{}), 0o644)
+	if err == nil {
+		t.Fatal("expected an error when the reader fails")
+	}
+	if _, err := cfs.Stat(charmID, "/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected no file after a failed put, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(lfs.Path, charmID, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no stored file after a failed put, got %v", err)
+	}
+}
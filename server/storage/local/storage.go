@@ -15,6 +15,10 @@ import (
 	"github.com/charmbracelet/charm/server/storage"
 )
 
+// stagingDir holds uploads in progress, relative to the store's root. It
+// can't clash with a user directory, which is named after a Charm ID.
+const stagingDir = ".staging"
+
 // LocalFileStore is a FileStore implementation that stores files locally in a
 // folder.
 type LocalFileStore struct {
@@ -70,6 +74,50 @@ func (lfs *LocalFileStore) validatePath(charmID, path string) (string, error) {
 	return fullPath, nil
 }
 
+// DeleteAll deletes every file stored for the Charm ID. It's not an error if
+// there are none.
+func (lfs *LocalFileStore) DeleteAll(charmID string) error {
+	if err := validateCharmID(charmID); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(lfs.Path, charmID))
+}
+
+// Usage returns the total size in bytes of the files stored for the Charm
+// ID.
+func (lfs *LocalFileStore) Usage(charmID string) (int64, error) {
+	if err := validateCharmID(charmID); err != nil {
+		return 0, err
+	}
+	size, err := dirSize(filepath.Join(lfs.Path, charmID))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// Stats returns the number of files stored for the Charm ID and their total
+// size in bytes. Directories aren't counted.
+func (lfs *LocalFileStore) Stats(charmID string) (int, int64, error) {
+	if err := validateCharmID(charmID); err != nil {
+		return 0, 0, err
+	}
+	files, size, err := dirStats(filepath.Join(lfs.Path, charmID))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	return files, size, err
+}
+
+// validateCharmID checks that the Charm ID names a user directory. Anything
+// but a plain directory name could reach outside the user's files.
+func validateCharmID(charmID string) error {
+	if charmID == "" || charmID == "." || charmID == ".." || charmID == stagingDir || filepath.Base(charmID) != charmID {
+		return fmt.Errorf("invalid charm id specified: %s", charmID)
+	}
+	return nil
+}
+
 // Stat returns the FileInfo for the given Charm ID and path.
 func (lfs *LocalFileStore) Stat(charmID, path string) (fs.FileInfo, error) {
 	fp, err := lfs.validatePath(charmID, path)
@@ -94,25 +142,55 @@ func (lfs *LocalFileStore) Stat(charmID, path string) (fs.FileInfo, error) {
 	}
 	// Get the actual size of the files in a directory
 	if i.IsDir() {
-		in.FileInfo.Size = 0
-		if err = filepath.Walk(fp, func(path string, info fs.FileInfo, err error) error {
-			if info.IsDir() {
-				return nil
-			}
-			in.FileInfo.Size += info.Size()
-			return nil
-		}); err != nil {
+		in.FileInfo.Size, err = dirSize(fp)
+		if err != nil {
 			return nil, err
 		}
 	}
 	return in, nil
 }
 
-// Get returns an fs.File for the given Charm ID and path.
+// dirSize returns the total size of the files under the directory fp.
+func dirSize(fp string) (int64, error) {
+	_, size, err := dirStats(fp)
+	return size, err
+}
+
+// dirStats returns the number and total size of the files under the
+// directory fp.
+func dirStats(fp string) (int, int64, error) {
+	var files int
+	var size int64
+	err := filepath.WalkDir(fp, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// Get returns an fs.File for the given Charm ID and path. The root path
+// returns a listing of the top level of the user's files.
 func (lfs *LocalFileStore) Get(charmID string, path string) (fs.File, error) {
-	fp, err := lfs.validatePath(charmID, path)
-	if err != nil {
-		return nil, err
+	var fp string
+	if cleaned := filepath.Clean(path); cleaned == string(os.PathSeparator) || cleaned == "." {
+		fp = filepath.Join(lfs.Path, charmID)
+	} else {
+		var err error
+		fp, err = lfs.validatePath(charmID, path)
+		if err != nil {
+			return nil, err
+		}
 	}
 	info, err := os.Stat(fp)
 	if os.IsNotExist(err) {
@@ -131,6 +209,8 @@ func (lfs *LocalFileStore) Get(charmID string, path string) (fs.File, error) {
 		if err != nil {
 			return nil, err
 		}
+		// Directories report the total size of the files in them
+		var size int64
 		fis := make([]charm.FileInfo, 0)
 		for _, v := range rds {
 			fi, err := v.Info()
@@ -144,12 +224,19 @@ func (lfs *LocalFileStore) Get(charmID string, path string) (fs.File, error) {
 				ModTime: fi.ModTime(),
 				Mode:    fi.Mode(),
 			}
+			if fi.IsDir() {
+				fin.Size, err = dirSize(filepath.Join(fp, v.Name()))
+				if err != nil {
+					return nil, err
+				}
+			}
+			size += fin.Size
 			fis = append(fis, fin)
 		}
 		dir := charm.FileInfo{
 			Name:    info.Name(),
 			IsDir:   true,
-			Size:    0,
+			Size:    size,
 			ModTime: info.ModTime(),
 			Mode:    info.Mode(),
 			Files:   fis,
@@ -182,19 +269,33 @@ func (lfs *LocalFileStore) Put(charmID string, path string, r io.Reader, mode fs
 	if err != nil {
 		return err
 	}
-	f, err := os.Create(fp)
-	if err != nil {
+	// Upload into a staging file and rename it into place, so a failed or
+	// interrupted upload never leaves a partial file behind. Staging files
+	// live outside the user directories so they never show up in listings.
+	tmpDir := filepath.Join(lfs.Path, stagingDir)
+	if err := storage.EnsureDir(tmpDir, 0o700); err != nil {
 		return err
 	}
-	defer f.Close() // nolint:errcheck
-	_, err = io.Copy(f, r)
+	f, err := os.CreateTemp(tmpDir, "upload-*")
 	if err != nil {
 		return err
 	}
-	if mode != 0 {
-		return f.Chmod(mode)
+	defer os.Remove(f.Name()) // nolint:errcheck
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close() // nolint:errcheck
+		return err
 	}
-	return nil
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fp)
 }
 
 // Delete deletes the file at the given path for the provided Charm ID.
@@ -442,3 +442,211 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+// failingReader returns some data and then an error, like a dropped upload.
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.sent = true
+	return copy(p, "partial"), nil
+}
+
+func TestPutFailedUploadLeavesNoFile(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lfs.Put(charmID, "/new.txt", &failingReader{}, 0o644); err == nil {
+		t.Fatal("expected an error from a failed upload")
+	}
+	if _, err := os.Stat(filepath.Join(tdir, charmID, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no file after a failed upload, got %v", err)
+	}
+
+	// A failed overwrite keeps the previous content
+	if err := lfs.Put(charmID, "/old.txt", bytes.NewBufferString("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lfs.Put(charmID, "/old.txt", &failingReader{}, 0o644); err == nil {
+		t.Fatal("expected an error from a failed upload")
+	}
+	data, err := os.ReadFile(filepath.Join(tdir, charmID, "old.txt"))
+	if err != nil || string(data) != "original" {
+		t.Errorf("expected original content to survive, got %q, %v", data, err)
+	}
+
+	// Staging files are cleaned up
+	staged, err := os.ReadDir(filepath.Join(tdir, stagingDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 0 {
+		t.Errorf("expected no leftover staging files, got %d", len(staged))
+	}
+}
+
+func TestGetRoot(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A user without files has no root yet
+	if _, err := lfs.Get(charmID, "/"); err != fs.ErrNotExist {
+		t.Fatalf("expected fs.ErrNotExist for an empty root, got %v", err)
+	}
+
+	for _, p := range []string{"/a.txt", "/dir/b.txt"} {
+		if err := lfs.Put(charmID, p, bytes.NewBufferString("x"), fs.FileMode(0o644)); err != nil {
+			t.Fatalf("failed to put %s: %v", p, err)
+		}
+	}
+	for _, root := range []string{"/", "."} {
+		file, err := lfs.Get(charmID, root)
+		if err != nil {
+			t.Fatalf("expected no error when getting %q, got %v", root, err)
+		}
+		var dirInfo charm.FileInfo
+		if err := json.NewDecoder(file).Decode(&dirInfo); err != nil {
+			t.Fatalf("failed to decode root listing: %v", err)
+		}
+		_ = file.Close()
+		if !dirInfo.IsDir || len(dirInfo.Files) != 2 {
+			t.Errorf("expected a listing of 2 entries for %q, got %+v", root, dirInfo)
+		}
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	other := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{charmID, other} {
+		if err := lfs.Put(id, "/dir/a.txt", bytes.NewBufferString("x"), fs.FileMode(0o644)); err != nil {
+			t.Fatalf("failed to put file: %v", err)
+		}
+	}
+
+	if err := lfs.DeleteAll(charmID); err != nil {
+		t.Fatalf("expected no error deleting all files, got %v", err)
+	}
+	if _, err := lfs.Get(charmID, "/"); err != fs.ErrNotExist {
+		t.Errorf("expected fs.ErrNotExist after deleting all files, got %v", err)
+	}
+	if _, err := lfs.Get(other, "/dir/a.txt"); err != nil {
+		t.Errorf("expected other users' files to be kept, got %v", err)
+	}
+	// Nothing left to delete is fine
+	if err := lfs.DeleteAll(charmID); err != nil {
+		t.Errorf("expected no error deleting all files again, got %v", err)
+	}
+
+	for _, id := range []string{"", ".", "..", stagingDir, other + "/dir"} {
+		if err := lfs.DeleteAll(id); err == nil {
+			t.Errorf("expected an error deleting all files for %q", id)
+		}
+	}
+}
+
+func TestGetDirectorySize(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"/testdir/file1.txt":       "hello",
+		"/testdir/sub/file2.txt":   "world!",
+		"/testdir/sub/deep/f3.txt": "charm",
+	}
+	for p, c := range files {
+		if err := lfs.Put(charmID, p, bytes.NewBufferString(c), fs.FileMode(0o644)); err != nil {
+			t.Fatalf("failed to put %s: %v", p, err)
+		}
+	}
+
+	file, err := lfs.Get(charmID, "/testdir")
+	if err != nil {
+		t.Fatalf("expected no error when getting directory, got %v", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	var dirInfo charm.FileInfo
+	if err := json.NewDecoder(file).Decode(&dirInfo); err != nil {
+		t.Fatalf("failed to decode directory listing: %v", err)
+	}
+	if dirInfo.Size != 16 {
+		t.Errorf("expected directory size 16, got %d", dirInfo.Size)
+	}
+	for _, fi := range dirInfo.Files {
+		var want int64
+		switch fi.Name {
+		case "file1.txt":
+			want = 5
+		case "sub":
+			want = 11
+		default:
+			t.Fatalf("unexpected entry %s", fi.Name)
+		}
+		if fi.Size != want {
+			t.Errorf("expected %s size %d, got %d", fi.Name, want, fi.Size)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used, err := lfs.Usage(charmID)
+	if err != nil || used != 0 {
+		t.Fatalf("expected no usage for a new user, got %d, %v", used, err)
+	}
+	if err := lfs.Put(charmID, "/a.txt", bytes.NewBufferString("hello"), fs.FileMode(0o644)); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+	if err := lfs.Put(charmID, "/dir/b.txt", bytes.NewBufferString("world!"), fs.FileMode(0o644)); err != nil {
+		t.Fatalf("failed to put file: %v", err)
+	}
+	used, err = lfs.Usage(charmID)
+	if err != nil {
+		t.Fatalf("expected no error getting usage, got %v", err)
+	}
+	if used != 11 {
+		t.Errorf("expected usage 11, got %d", used)
+	}
+	files, size, err := lfs.Stats(charmID)
+	if err != nil || files != 2 || size != 11 {
+		t.Errorf("expected 2 files of 11 bytes, got %d files of %d bytes, %v", files, size, err)
+	}
+	if files, size, err := lfs.Stats(uuid.New().String()); err != nil || files != 0 || size != 0 {
+		t.Errorf("expected no files for a new user, got %d files of %d bytes, %v", files, size, err)
+	}
+
+	for _, id := range []string{"", "..", stagingDir} {
+		if _, err := lfs.Usage(id); err == nil {
+			t.Errorf("expected an error getting usage for %q", id)
+		}
+		if _, _, err := lfs.Stats(id); err == nil {
+			t.Errorf("expected an error getting stats for %q", id)
+		}
+	}
+}
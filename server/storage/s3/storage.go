@@ -0,0 +1,430 @@
+// Package s3storage provides a FileStore that keeps files in an S3 bucket, so
+// several Charm servers can share the same storage.
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	charmfs "github.com/charmbracelet/charm/fs"
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// modeMetadata is the object metadata key holding a file's fs.FileMode.
+const modeMetadata = "charm-mode"
+
+// Modes reported for entries in directory listings. Listing a bucket doesn't
+// return object metadata, so the stored modes are only reported by Stat and
+// Get on the files themselves.
+const (
+	listedFileMode = fs.FileMode(0o644)
+	listedDirMode  = fs.ModeDir | 0o755
+)
+
+// Config is the configuration for an S3FileStore.
+type Config struct {
+	Bucket string
+	Region string
+	// Endpoint overrides the S3 endpoint, to use an S3 compatible service.
+	Endpoint string
+	// AccessKeyID and SecretAccessKey are static credentials. If they're
+	// empty, the default AWS credential chain is used.
+	AccessKeyID     string
+	SecretAccessKey string
+	// UsePathStyle puts the bucket name in the URL path rather than the host
+	// name, as some S3 compatible services require.
+	UsePathStyle bool
+}
+
+// s3API is the part of the S3 client the store uses.
+type s3API interface {
+	transfermanager.S3APIClient
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(context.Context, *s3.DeleteObjectsInput, ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// S3FileStore is a FileStore implementation that stores files in an S3
+// bucket, keyed by Charm ID and path. Directories have no objects of their
+// own, except for empty ones created explicitly; listings are built from the
+// keys sharing the directory's prefix.
+type S3FileStore struct {
+	bucket   string
+	client   s3API
+	uploader *transfermanager.Client
+}
+
+// NewS3FileStore creates a FileStore in the configured S3 bucket. Files will
+// be encrypted client-side and stored as objects.
+func NewS3FileStore(ctx context.Context, cfg Config) (*S3FileStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("missing s3 bucket")
+	}
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+	return newS3FileStore(client, cfg.Bucket), nil
+}
+
+func newS3FileStore(client s3API, bucket string) *S3FileStore {
+	return &S3FileStore{
+		bucket:   bucket,
+		client:   client,
+		uploader: transfermanager.New(client),
+	}
+}
+
+// key returns the object key for the user-provided path. Cleaning the path as
+// an absolute one resolves any "../" before it's joined to the Charm ID, so it
+// can't escape the user's prefix.
+func (s *S3FileStore) key(charmID, p string) (string, error) {
+	if err := validateCharmID(charmID); err != nil {
+		return "", err
+	}
+	cleaned := path.Clean("/" + p)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid path specified: %s", p)
+	}
+	return charmID + cleaned, nil
+}
+
+// validateCharmID checks that the Charm ID names a single key segment.
+func validateCharmID(charmID string) error {
+	if charmID == "" || charmID == "." || charmID == ".." || strings.Contains(charmID, "/") {
+		return fmt.Errorf("invalid charm id specified: %s", charmID)
+	}
+	return nil
+}
+
+// Stat returns the FileInfo for the given Charm ID and path.
+func (s *S3FileStore) Stat(charmID, p string) (fs.FileInfo, error) {
+	ctx := context.Background()
+	k, err := s.key(charmID, p)
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(k),
+	})
+	if err == nil {
+		return objectInfo(path.Base(k), head.ContentLength, head.LastModified, head.Metadata), nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	dir, err := s.listDir(ctx, k+"/", path.Base(k))
+	if err != nil {
+		return nil, err
+	}
+	dir.Files = nil
+	return &charmfs.FileInfo{FileInfo: *dir}, nil
+}
+
+// Get returns an fs.File for the given Charm ID and path. The root path
+// returns a listing of the top level of the user's files.
+func (s *S3FileStore) Get(charmID string, p string) (fs.File, error) {
+	ctx := context.Background()
+	var prefix, name string
+	if cleaned := path.Clean(p); cleaned == "/" || cleaned == "." {
+		if err := validateCharmID(charmID); err != nil {
+			return nil, err
+		}
+		prefix, name = charmID+"/", charmID
+	} else {
+		k, err := s.key(charmID, p)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(k),
+		})
+		if err == nil {
+			return &object{
+				ReadCloser: obj.Body,
+				info:       objectInfo(path.Base(k), obj.ContentLength, obj.LastModified, obj.Metadata),
+			}, nil
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+		prefix, name = k+"/", path.Base(k)
+	}
+	dir, err := s.listDir(ctx, prefix, name)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(dir); err != nil {
+		return nil, err
+	}
+	info := *dir
+	info.Files = nil
+	return &charmfs.DirFile{
+		Buffer:   buf,
+		FileInfo: &charmfs.FileInfo{FileInfo: info},
+	}, nil
+}
+
+// Put reads from the provided io.Reader and stores the data with the Charm ID
+// and path. The data is streamed to the bucket in parts, so large files are
+// never held in memory in full, and a failed upload leaves no object behind.
+func (s *S3FileStore) Put(charmID string, p string, r io.Reader, mode fs.FileMode) error {
+	ctx := context.Background()
+	k, err := s.key(charmID, p)
+	if err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0o644
+	}
+	meta := map[string]string{modeMetadata: strconv.FormatUint(uint64(mode), 10)}
+	// An empty directory is kept as a marker object named after its prefix
+	if mode.IsDir() {
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(k + "/"),
+			Body:     bytes.NewReader(nil),
+			Metadata: meta,
+		})
+		return err
+	}
+	_, err = s.uploader.UploadObject(ctx, &transfermanager.UploadObjectInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(k),
+		Body:     r,
+		Metadata: meta,
+	})
+	return err
+}
+
+// Delete deletes the file at the given path for the provided Charm ID. A
+// directory is deleted along with everything in it.
+func (s *S3FileStore) Delete(charmID string, p string) error {
+	ctx := context.Background()
+	k, err := s.key(charmID, p)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(k),
+	}); err != nil {
+		return err
+	}
+	return s.deletePrefix(ctx, k+"/")
+}
+
+// DeleteAll deletes every file stored for the Charm ID. It's not an error if
+// there are none.
+func (s *S3FileStore) DeleteAll(charmID string) error {
+	if err := validateCharmID(charmID); err != nil {
+		return err
+	}
+	return s.deletePrefix(context.Background(), charmID+"/")
+}
+
+// Usage returns the total size in bytes of the files stored for the Charm
+// ID.
+func (s *S3FileStore) Usage(charmID string) (int64, error) {
+	if err := validateCharmID(charmID); err != nil {
+		return 0, err
+	}
+	var size int64
+	err := s.eachObject(context.Background(), charmID+"/", func(objs []types.Object) error {
+		for _, o := range objs {
+			size += aws.ToInt64(o.Size)
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Stats returns the number of files stored for the Charm ID and their total
+// size in bytes. Directory markers aren't counted.
+func (s *S3FileStore) Stats(charmID string) (int, int64, error) {
+	if err := validateCharmID(charmID); err != nil {
+		return 0, 0, err
+	}
+	var files int
+	var size int64
+	err := s.eachObject(context.Background(), charmID+"/", func(objs []types.Object) error {
+		for _, o := range objs {
+			if strings.HasSuffix(aws.ToString(o.Key), "/") {
+				continue
+			}
+			files++
+			size += aws.ToInt64(o.Size)
+		}
+		return nil
+	})
+	return files, size, err
+}
+
+// listDir lists the directory whose objects share prefix. Files in nested
+// directories count towards the size of the top level directory holding
+// them. It returns fs.ErrNotExist if there are no objects under prefix.
+func (s *S3FileStore) listDir(ctx context.Context, prefix string, name string) (*charm.FileInfo, error) {
+	dir := &charm.FileInfo{
+		Name:  name,
+		IsDir: true,
+		Mode:  listedDirMode,
+		Files: make([]charm.FileInfo, 0),
+	}
+	found := false
+	entries := map[string]*charm.FileInfo{}
+	err := s.eachObject(ctx, prefix, func(objs []types.Object) error {
+		for _, o := range objs {
+			found = true
+			size, modTime := aws.ToInt64(o.Size), aws.ToTime(o.LastModified)
+			dir.Size += size
+			if modTime.After(dir.ModTime) {
+				dir.ModTime = modTime
+			}
+			rel := strings.TrimPrefix(aws.ToString(o.Key), prefix)
+			if rel == "" {
+				// The directory's own marker
+				continue
+			}
+			en, isDir := rel, false
+			if i := strings.Index(rel, "/"); i >= 0 {
+				en, isDir = rel[:i], true
+			}
+			e, ok := entries[en]
+			if !ok {
+				e = &charm.FileInfo{Name: en, IsDir: isDir, Mode: listedFileMode}
+				if isDir {
+					e.Mode = listedDirMode
+				}
+				entries[en] = e
+			}
+			e.Size += size
+			if modTime.After(e.ModTime) {
+				e.ModTime = modTime
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+	for _, e := range entries {
+		dir.Files = append(dir.Files, *e)
+	}
+	sort.Slice(dir.Files, func(i, j int) bool { return dir.Files[i].Name < dir.Files[j].Name })
+	return dir, nil
+}
+
+// eachObject calls fn with each page of objects whose keys start with prefix.
+func (s *S3FileStore) eachObject(ctx context.Context, prefix string, fn func([]types.Object) error) error {
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePrefix deletes every object whose key starts with prefix. A listing
+// page holds at most 1000 objects, as many as a single delete request takes.
+func (s *S3FileStore) deletePrefix(ctx context.Context, prefix string) error {
+	return s.eachObject(ctx, prefix, func(objs []types.Object) error {
+		if len(objs) == 0 {
+			return nil
+		}
+		ids := make([]types.ObjectIdentifier, 0, len(objs))
+		for _, o := range objs {
+			ids = append(ids, types.ObjectIdentifier{Key: o.Key})
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("cannot delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+		return nil
+	})
+}
+
+// object is an fs.File streaming the contents of an S3 object.
+type object struct {
+	io.ReadCloser
+	info fs.FileInfo
+}
+
+// Stat returns the object's fs.FileInfo.
+func (o *object) Stat() (fs.FileInfo, error) {
+	return o.info, nil
+}
+
+// objectInfo returns the fs.FileInfo of an object, with the mode stored in
+// its metadata.
+func objectInfo(name string, size *int64, modTime *time.Time, meta map[string]string) fs.FileInfo {
+	mode := listedFileMode
+	if m, err := strconv.ParseUint(meta[modeMetadata], 10, 32); err == nil {
+		mode = fs.FileMode(m)
+	}
+	return &charmfs.FileInfo{
+		FileInfo: charm.FileInfo{
+			Name:    name,
+			Size:    aws.ToInt64(size),
+			ModTime: aws.ToTime(modTime),
+			Mode:    mode,
+		},
+	}
+}
+
+// isNotFound reports whether err means the object doesn't exist.
+func isNotFound(err error) bool {
+	var nf *types.NotFound
+	var nsk *types.NoSuchKey
+	return errors.As(err, &nf) || errors.As(err, &nsk)
+}
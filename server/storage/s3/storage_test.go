@@ -0,0 +1,360 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/google/uuid"
+)
+
+// fakeS3 is an in-memory bucket implementing the calls the store makes.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// pageSize is how many keys a listing page holds
+	pageSize int
+}
+
+type fakeObject struct {
+	data    []byte
+	meta    map[string]string
+	modTime time.Time
+}
+
+var errMultipart = errors.New("multipart uploads aren't supported")
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string]fakeObject{}, pageSize: 1000}
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.ToString(in.Key)] = fakeObject{data: data, meta: in.Metadata, modTime: time.Now()}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) UploadPart(context.Context, *s3.UploadPartInput, ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return nil, errMultipart
+}
+
+func (f *fakeS3) CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, errMultipart
+}
+
+func (f *fakeS3) CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, errMultipart
+}
+
+func (f *fakeS3) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, errMultipart
+}
+
+func (f *fakeS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(o.data)),
+		ContentLength: aws.Int64(int64(len(o.data))),
+		LastModified:  aws.Time(o.modTime),
+		Metadata:      o.meta,
+	}, nil
+}
+
+func (f *fakeS3) HeadObject(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(o.data))),
+		LastModified:  aws.Time(o.modTime),
+		Metadata:      o.meta,
+	}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) && k > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, k := range keys {
+		o := f.objects[k]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(o.data))),
+			LastModified: aws.Time(o.modTime),
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(_ context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range in.Delete.Objects {
+		delete(f.objects, aws.ToString(id.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func newTestStore(t *testing.T) (*S3FileStore, *fakeS3, string) {
+	t.Helper()
+	f := newFakeS3()
+	return newS3FileStore(f, "bucket"), f, uuid.New().String()
+}
+
+func put(t *testing.T, s *S3FileStore, charmID, p, content string) {
+	t.Helper()
+	if err := s.Put(charmID, p, strings.NewReader(content), 0o600); err != nil {
+		t.Fatalf("failed to put %s: %v", p, err)
+	}
+}
+
+func TestPutAndGet(t *testing.T) {
+	s, f, charmID := newTestStore(t)
+	put(t, s, charmID, "/dir/hello.txt", "hello world")
+
+	if _, ok := f.objects[charmID+"/dir/hello.txt"]; !ok {
+		t.Fatalf("expected the object to be keyed by charm id and path, got %v", f.objects)
+	}
+	file, err := s.Get(charmID, "/dir/hello.txt")
+	if err != nil {
+		t.Fatalf("expected no error getting file, got %v", err)
+	}
+	defer file.Close() // nolint:errcheck
+	data, err := io.ReadAll(file)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("expected file contents, got %q, %v", data, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("expected no error statting file, got %v", err)
+	}
+	if info.Name() != "hello.txt" || info.Size() != 11 || info.Mode() != 0o600 || info.IsDir() {
+		t.Errorf("unexpected file info: %s %d %v %v", info.Name(), info.Size(), info.Mode(), info.IsDir())
+	}
+
+	if _, err := s.Get(charmID, "/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestGetDirectory(t *testing.T) {
+	s, f, charmID := newTestStore(t)
+	f.pageSize = 2
+	put(t, s, charmID, "/dir/a.txt", "hello")
+	put(t, s, charmID, "/dir/b.txt", "world!")
+	put(t, s, charmID, "/dir/sub/c.txt", "charm")
+	put(t, s, charmID, "/dir/sub/deep/d.txt", "!")
+	put(t, s, uuid.New().String(), "/dir/other.txt", "not mine")
+
+	file, err := s.Get(charmID, "/dir")
+	if err != nil {
+		t.Fatalf("expected no error getting directory, got %v", err)
+	}
+	var dir charm.FileInfo
+	if err := json.NewDecoder(file).Decode(&dir); err != nil {
+		t.Fatalf("failed to decode directory listing: %v", err)
+	}
+	if !dir.IsDir || dir.Name != "dir" || dir.Size != 17 {
+		t.Errorf("unexpected directory info: %+v", dir)
+	}
+	want := []struct {
+		name  string
+		isDir bool
+		size  int64
+	}{{"a.txt", false, 5}, {"b.txt", false, 6}, {"sub", true, 6}}
+	if len(dir.Files) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), dir.Files)
+	}
+	for i, w := range want {
+		e := dir.Files[i]
+		if e.Name != w.name || e.IsDir != w.isDir || e.Size != w.size {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+
+	info, err := s.Stat(charmID, "/dir/sub")
+	if err != nil {
+		t.Fatalf("expected no error statting directory, got %v", err)
+	}
+	if !info.IsDir() || info.Size() != 6 {
+		t.Errorf("expected a directory of 6 bytes, got %v %d", info.IsDir(), info.Size())
+	}
+
+	root, err := s.Get(charmID, "/")
+	if err != nil {
+		t.Fatalf("expected no error getting root, got %v", err)
+	}
+	dir = charm.FileInfo{}
+	if err := json.NewDecoder(root).Decode(&dir); err != nil {
+		t.Fatalf("failed to decode root listing: %v", err)
+	}
+	if len(dir.Files) != 1 || dir.Files[0].Name != "dir" {
+		t.Errorf("expected the root to list dir, got %+v", dir.Files)
+	}
+}
+
+func TestEmptyDirectory(t *testing.T) {
+	s, _, charmID := newTestStore(t)
+	if err := s.Put(charmID, "/empty", bytes.NewReader(nil), fs.ModeDir|0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	info, err := s.Stat(charmID, "/empty")
+	if err != nil {
+		t.Fatalf("expected no error statting an empty directory, got %v", err)
+	}
+	if !info.IsDir() || info.Size() != 0 {
+		t.Errorf("expected an empty directory, got %v %d", info.IsDir(), info.Size())
+	}
+	file, err := s.Get(charmID, "/empty")
+	if err != nil {
+		t.Fatalf("expected no error getting an empty directory, got %v", err)
+	}
+	var dir charm.FileInfo
+	if err := json.NewDecoder(file).Decode(&dir); err != nil {
+		t.Fatalf("failed to decode directory listing: %v", err)
+	}
+	if len(dir.Files) != 0 {
+		t.Errorf("expected no entries, got %+v", dir.Files)
+	}
+}
+
+func TestStatMissingFile(t *testing.T) {
+	s, _, charmID := newTestStore(t)
+	if _, err := s.Stat(charmID, "/nonexistent.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist for missing file, got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s, f, charmID := newTestStore(t)
+	put(t, s, charmID, "/dir/a.txt", "a")
+	put(t, s, charmID, "/dir/sub/b.txt", "b")
+	put(t, s, charmID, "/dirty.txt", "c")
+
+	if err := s.Delete(charmID, "/dir"); err != nil {
+		t.Fatalf("expected no error deleting directory, got %v", err)
+	}
+	if _, err := s.Stat(charmID, "/dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the directory to be gone, got %v", err)
+	}
+	if _, ok := f.objects[charmID+"/dirty.txt"]; !ok {
+		t.Error("expected a file sharing the directory's name prefix to be kept")
+	}
+	if err := s.Delete(charmID, "/dirty.txt"); err != nil {
+		t.Fatalf("expected no error deleting file, got %v", err)
+	}
+	if len(f.objects) != 0 {
+		t.Errorf("expected no objects left, got %v", f.objects)
+	}
+}
+
+func TestDeleteAllAndUsage(t *testing.T) {
+	s, f, charmID := newTestStore(t)
+	other := uuid.New().String()
+	f.pageSize = 1
+	put(t, s, charmID, "/a.txt", "hello")
+	put(t, s, charmID, "/dir/b.txt", "world!")
+	put(t, s, other, "/a.txt", "x")
+
+	used, err := s.Usage(charmID)
+	if err != nil || used != 11 {
+		t.Fatalf("expected usage 11, got %d, %v", used, err)
+	}
+	if err := s.Put(charmID, "/empty", nil, fs.ModeDir|0o755); err != nil {
+		t.Fatalf("failed to put directory: %v", err)
+	}
+	files, size, err := s.Stats(charmID)
+	if err != nil || files != 2 || size != 11 {
+		t.Errorf("expected 2 files of 11 bytes, got %d files of %d bytes, %v", files, size, err)
+	}
+	if err := s.DeleteAll(charmID); err != nil {
+		t.Fatalf("expected no error deleting all files, got %v", err)
+	}
+	used, err = s.Usage(charmID)
+	if err != nil || used != 0 {
+		t.Errorf("expected no usage after deleting all files, got %d, %v", used, err)
+	}
+	if _, err := s.Get(charmID, "/"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist after deleting all files, got %v", err)
+	}
+	if _, err := s.Stat(other, "/a.txt"); err != nil {
+		t.Errorf("expected other users' files to be kept, got %v", err)
+	}
+}
+
+func TestPathTraversalPrevention(t *testing.T) {
+	s, f, charmID := newTestStore(t)
+	put(t, s, charmID, "/../../escape.txt", "x")
+	if _, ok := f.objects[charmID+"/escape.txt"]; !ok {
+		t.Errorf("expected the path to stay under the charm id, got %v", f.objects)
+	}
+	for _, p := range []string{"/", "", "/.."} {
+		if err := s.Put(charmID, p, strings.NewReader("x"), 0o644); err == nil {
+			t.Errorf("expected an error putting %q", p)
+		}
+	}
+	for _, id := range []string{"", ".", "..", charmID + "/dir"} {
+		if err := s.DeleteAll(id); err == nil {
+			t.Errorf("expected an error deleting all files for %q", id)
+		}
+		if _, err := s.Stat(id, "/a.txt"); err == nil {
+			t.Errorf("expected an error statting for %q", id)
+		}
+	}
+}
+
+func TestObjectInfoMode(t *testing.T) {
+	info := objectInfo("a", aws.Int64(1), nil, map[string]string{modeMetadata: strconv.Itoa(0o640)})
+	if info.Mode() != 0o640 {
+		t.Errorf("expected the stored mode, got %v", info.Mode())
+	}
+	info = objectInfo("a", aws.Int64(1), nil, nil)
+	if info.Mode() != listedFileMode {
+		t.Errorf("expected the default mode without metadata, got %v", info.Mode())
+	}
+}
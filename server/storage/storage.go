@@ -13,6 +13,9 @@ type FileStore interface {
 	Get(charmID string, path string) (fs.File, error)
 	Put(charmID string, path string, r io.Reader, mode fs.FileMode) error
 	Delete(charmID string, path string) error
+	DeleteAll(charmID string) error
+	Usage(charmID string) (int64, error)
+	Stats(charmID string) (files int, size int64, err error)
 }
 
 // EnsureDir will create the directory for the provided path on the server
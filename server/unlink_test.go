@@ -0,0 +1,68 @@
+// ABOUTME: Tests for unlinking individual keys from a Charm account
+// ABOUTME: Covers unlinking by key and fingerprint and protecting the active key
+package server_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/charm/client"
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/keygen"
+)
+
+func TestUnlinkKey(t *testing.T) {
+	cl, srv := setupTestServerWithDB(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	u, err := srv.Config.DB.GetUserWithID(id)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	kp, err := keygen.New(filepath.Join(t.TempDir(), "other_ed25519"), keygen.WithKeyType(keygen.Ed25519))
+	if err != nil {
+		t.Fatalf("keygen error: %s", err)
+	}
+	other := kp.AuthorizedKey()
+	if err := srv.Config.DB.LinkUserKey(u, other); err != nil {
+		t.Fatalf("failed to link key: %v", err)
+	}
+
+	keys, err := cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata failed: %v", err)
+	}
+	if len(keys.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(keys.Keys))
+	}
+	active, err := client.FingerprintSHA256(*keys.Keys[keys.ActiveKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.UnlinkKeyByFingerprint(active.Value); !errors.Is(err, charm.ErrUnlinkActiveKey) {
+		t.Errorf("expected ErrUnlinkActiveKey, got %v", err)
+	}
+	if err := cl.UnlinkKey(keys.Keys[keys.ActiveKey].Key); !errors.Is(err, charm.ErrUnlinkActiveKey) {
+		t.Errorf("expected ErrUnlinkActiveKey, got %v", err)
+	}
+
+	if err := cl.UnlinkKey(other + " me@example.com"); err != nil {
+		t.Fatalf("UnlinkKey failed: %v", err)
+	}
+	keys, err = cl.AuthorizedKeysWithMetadata()
+	if err != nil {
+		t.Fatalf("AuthorizedKeysWithMetadata failed: %v", err)
+	}
+	if len(keys.Keys) != 1 {
+		t.Errorf("expected 1 key after unlinking, got %d", len(keys.Keys))
+	}
+	if err := cl.UnlinkKey(other); !errors.Is(err, charm.ErrMissingKey) {
+		t.Errorf("expected ErrMissingKey for an unlinked key, got %v", err)
+	}
+	if err := cl.UnlinkKeyByFingerprint("SHA256:nope"); !errors.Is(err, charm.ErrMissingKey) {
+		t.Errorf("expected ErrMissingKey for an unknown fingerprint, got %v", err)
+	}
+}
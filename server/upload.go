@@ -0,0 +1,389 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+	"github.com/google/uuid"
+	"goji.io/pat"
+)
+
+// uploadTTL is how long an upload is kept after its last part arrived
+// before it's discarded.
+const uploadTTL = 24 * time.Hour
+
+var (
+	// errUploadOffset is returned for a part that doesn't start where the
+	// data received so far ends.
+	errUploadOffset = errors.New("part doesn't start at the end of the received data")
+
+	// errUploadOverflow is returned for a part that goes past the size of the
+	// upload.
+	errUploadOverflow = errors.New("part goes past the end of the upload")
+
+	// errUploadIncomplete is returned when completing an upload that hasn't
+	// received all of its data.
+	errUploadIncomplete = errors.New("upload is incomplete")
+
+	// errUploadStorageLimit is returned when completing an upload would take
+	// the user past their storage limit.
+	errUploadStorageLimit = errors.New("user storage limit exceeded")
+)
+
+// uploadStore stages the parts of uploads on the server's disk until they're
+// complete and can be put in the FileStore. Each upload is a JSON file
+// describing it and a data file holding the parts received so far, in a
+// directory per user.
+type uploadStore struct {
+	dir   string
+	locks sync.Map // Charm ID/upload ID -> *sync.Mutex
+}
+
+// newUploadStore returns an uploadStore staging uploads in dir.
+func newUploadStore(dir string) *uploadStore {
+	return &uploadStore{dir: dir}
+}
+
+// paths returns the paths of the upload's JSON and data files. Upload IDs
+// are UUIDs, so any other ID doesn't exist.
+func (us *uploadStore) paths(charmID string, id string) (string, string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", "", fs.ErrNotExist
+	}
+	dir := filepath.Join(us.dir, charmID)
+	return filepath.Join(dir, id+".json"), filepath.Join(dir, id), nil
+}
+
+// lock locks the upload with the given ID, returning the function unlocking
+// it. Unlocking forgets the lock once the upload is gone, whether it was
+// completed, removed or expired, or never existed.
+func (us *uploadStore) lock(charmID string, id string) func() {
+	key := charmID + "/" + id
+	v, _ := us.locks.LoadOrStore(key, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return func() {
+		// Anyone still waiting on the forgotten lock finds the upload gone
+		mp, _, err := us.paths(charmID, id)
+		if err != nil {
+			us.locks.Delete(key)
+		} else if _, err := os.Stat(mp); errors.Is(err, fs.ErrNotExist) {
+			us.locks.Delete(key)
+		}
+		mu.Unlock()
+	}
+}
+
+// create stages a new, empty upload, setting its ID. The user's expired
+// uploads are discarded first.
+func (us *uploadStore) create(charmID string, u *charm.Upload) error {
+	us.removeExpired(charmID)
+	if err := storage.EnsureDir(filepath.Join(us.dir, charmID), 0o700); err != nil {
+		return err
+	}
+	u.ID = uuid.New().String()
+	u.Received = 0
+	u.CreatedAt = time.Now().UTC()
+	mp, dp, err := us.paths(charmID, u.ID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dp, nil, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(mp, b, 0o600)
+}
+
+// get returns the upload with the given ID, along with how much of it has
+// been received.
+func (us *uploadStore) get(charmID string, id string) (*charm.Upload, error) {
+	mp, dp, err := us.paths(charmID, id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(mp)
+	if err != nil {
+		return nil, err
+	}
+	u := &charm.Upload{}
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(dp)
+	if err != nil {
+		return nil, err
+	}
+	u.Received = fi.Size()
+	return u, nil
+}
+
+// write adds a part starting at offset to the upload. If reading the part
+// fails, what was read of it is kept, so the upload can carry on from there.
+func (us *uploadStore) write(charmID string, id string, offset int64, r io.Reader) (*charm.Upload, error) {
+	defer us.lock(charmID, id)()
+	u, err := us.get(charmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Received {
+		return u, errUploadOffset
+	}
+	_, dp, _ := us.paths(charmID, id)
+	f, err := os.OpenFile(dp, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	n, err := io.Copy(f, io.LimitReader(r, u.Size-u.Received+1))
+	if u.Received+n > u.Size {
+		if err := f.Truncate(u.Received); err != nil {
+			return nil, err
+		}
+		return u, errUploadOverflow
+	}
+	u.Received += n
+	if err != nil {
+		return u, err
+	}
+	return u, f.Close()
+}
+
+// complete calls put with the upload and its data once all of it has been
+// received, then discards the upload. If put fails the upload is kept, so
+// completing it can be retried.
+func (us *uploadStore) complete(charmID string, id string, put func(*charm.Upload, io.Reader) error) (*charm.Upload, error) {
+	defer us.lock(charmID, id)()
+	u, err := us.get(charmID, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Received != u.Size {
+		return u, errUploadIncomplete
+	}
+	_, dp, _ := us.paths(charmID, id)
+	f, err := os.Open(dp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	if err := put(u, f); err != nil {
+		return u, err
+	}
+	return u, us.removeFiles(charmID, id)
+}
+
+// remove discards the upload with the given ID.
+func (us *uploadStore) remove(charmID string, id string) error {
+	defer us.lock(charmID, id)()
+	mp, _, err := us.paths(charmID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(mp); err != nil {
+		return err
+	}
+	return us.removeFiles(charmID, id)
+}
+
+// removeFiles deletes the upload's files.
+func (us *uploadStore) removeFiles(charmID string, id string) error {
+	mp, dp, err := us.paths(charmID, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Remove(mp)
+}
+
+// removeAll discards all of the user's uploads.
+func (us *uploadStore) removeAll(charmID string) error {
+	if err := os.RemoveAll(filepath.Join(us.dir, charmID)); err != nil {
+		return err
+	}
+	us.locks.Range(func(key, _ any) bool {
+		if strings.HasPrefix(key.(string), charmID+"/") {
+			us.locks.Delete(key)
+		}
+		return true
+	})
+	return nil
+}
+
+// removeExpired discards the user's uploads that haven't received a part for
+// uploadTTL.
+func (us *uploadStore) removeExpired(charmID string) {
+	des, err := os.ReadDir(filepath.Join(us.dir, charmID))
+	if err != nil {
+		return
+	}
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok {
+			continue
+		}
+		_, dp, err := us.paths(charmID, id)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(dp)
+		if err == nil && time.Since(fi.ModTime()) < uploadTTL {
+			continue
+		}
+		unlock := us.lock(charmID, id)
+		if err := us.removeFiles(charmID, id); err != nil {
+			log.Error("cannot remove expired upload", "id", id, "err", err)
+		}
+		unlock()
+	}
+}
+
+// handlePostUpload starts an upload in parts, checking up front that the
+// file will fit in the user's storage.
+func (s *HTTPServer) handlePostUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	up := &charm.Upload{}
+	if err := json.NewDecoder(r.Body).Decode(up); err != nil {
+		s.renderCustomError(w, "invalid upload", http.StatusBadRequest)
+		return
+	}
+	if up.Path == "" || up.Size < 0 || up.ChunkSize < 0 {
+		s.renderCustomError(w, "invalid upload", http.StatusBadRequest)
+		return
+	}
+	up.Path = filepath.Clean(up.Path)
+	if up.Size > fsRequestLimit(s.cfg.MaxFSRequestBytes) {
+		s.renderCustomError(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	exceeded, err := s.storageLimitExceeded(u.CharmID, up.Path, up.Size)
+	if err != nil {
+		log.Error("cannot get user storage usage", "err", err)
+		s.renderError(w)
+		return
+	}
+	if exceeded {
+		s.renderCustomError(w, errUploadStorageLimit.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	if err := s.uploads.create(u.CharmID, up); err != nil {
+		log.Error("cannot create upload", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(up)
+}
+
+// handleGetUpload reports how much of an upload has been received, so an
+// interrupted upload knows where to resume.
+func (s *HTTPServer) handleGetUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	up, err := s.uploads.get(u.CharmID, pat.Param(r, "id"))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.renderCustomError(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot get upload", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(up)
+}
+
+// handlePutUpload adds the part in the request body to an upload. The offset
+// query parameter must be where the data received so far ends.
+func (s *HTTPServer) handlePutUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		s.renderCustomError(w, fmt.Sprintf("invalid offset: %s", r.URL.Query().Get("offset")), http.StatusBadRequest)
+		return
+	}
+	up, err := s.uploads.write(u.CharmID, pat.Param(r, "id"), offset, r.Body)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		s.renderCustomError(w, "upload not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errUploadOffset):
+		s.renderCustomError(w, fmt.Sprintf("%s: received %d bytes", err, up.Received), http.StatusConflict)
+		return
+	case errors.Is(err, errUploadOverflow):
+		s.renderCustomError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error("cannot write upload part", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(up)
+}
+
+// handlePostUploadComplete stores a fully received upload in the FileStore
+// and discards it.
+func (s *HTTPServer) handlePostUploadComplete(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	up, err := s.uploads.complete(u.CharmID, pat.Param(r, "id"), func(up *charm.Upload, data io.Reader) error {
+		exceeded, err := s.storageLimitExceeded(u.CharmID, up.Path, up.Size)
+		if err != nil {
+			return err
+		}
+		if exceeded {
+			return errUploadStorageLimit
+		}
+		return s.cfg.FileStore.Put(u.CharmID, up.Path, data, up.Mode)
+	})
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		s.renderCustomError(w, "upload not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errUploadIncomplete):
+		s.renderCustomError(w, fmt.Sprintf("%s: received %d of %d bytes", err, up.Received, up.Size), http.StatusConflict)
+		return
+	case errors.Is(err, errUploadStorageLimit):
+		s.renderCustomError(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	case err != nil:
+		log.Error("cannot complete upload", "err", err)
+		s.renderError(w)
+		return
+	}
+	s.recordFileWritten(u, up.Path, up.Size)
+}
+
+// handleDeleteUpload abandons an upload.
+func (s *HTTPServer) handleDeleteUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	err := s.uploads.remove(u.CharmID, pat.Param(r, "id"))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.renderCustomError(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot delete upload", "err", err)
+		s.renderError(w)
+		return
+	}
+}
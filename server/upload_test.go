@@ -0,0 +1,73 @@
+// ABOUTME: Unit tests for staging resumable uploads on the server's disk.
+// ABOUTME: Checks that the per-upload locks are forgotten once uploads are gone.
+package server
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/google/uuid"
+)
+
+func countLocks(us *uploadStore) int {
+	var n int
+	us.locks.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestUploadStoreForgetsLocks(t *testing.T) {
+	us := newUploadStore(t.TempDir())
+	charmID := uuid.New().String()
+	newUpload := func() *charm.Upload {
+		t.Helper()
+		u := &charm.Upload{Path: "a.txt", Size: 3}
+		if err := us.create(charmID, u); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+		if _, err := us.write(charmID, u.ID, 0, strings.NewReader("abc")); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		return u
+	}
+
+	completed := newUpload()
+	removed := newUpload()
+	expired := newUpload()
+	if n := countLocks(us); n != 3 {
+		t.Fatalf("expected a lock per upload, got %d", n)
+	}
+
+	if _, err := us.complete(charmID, completed.ID, func(*charm.Upload, io.Reader) error { return nil }); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if err := us.remove(charmID, removed.ID); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	_, dp, _ := us.paths(charmID, expired.ID)
+	old := time.Now().Add(-2 * uploadTTL)
+	if err := os.Chtimes(dp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	us.removeExpired(charmID)
+	if _, err := us.write(charmID, uuid.New().String(), 0, strings.NewReader("abc")); err == nil {
+		t.Fatal("expected writing to a missing upload to fail")
+	}
+	if n := countLocks(us); n != 0 {
+		t.Errorf("expected the locks to be forgotten, got %d", n)
+	}
+
+	newUpload()
+	if err := us.removeAll(charmID); err != nil {
+		t.Fatalf("removeAll failed: %v", err)
+	}
+	if n := countLocks(us); n != 0 {
+		t.Errorf("expected removeAll to forget the locks, got %d", n)
+	}
+}
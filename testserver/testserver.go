@@ -78,6 +78,7 @@ func SetupTestServer(tb testing.TB) *client.Client {
 	ccfg.Host = cfg.Host
 	ccfg.SSHPort = cfg.SSHPort
 	ccfg.HTTPPort = cfg.HTTPPort
+	ccfg.HealthPort = cfg.HealthPort
 	ccfg.DataDir = clientData
 
 	cl, err := client.NewClient(ccfg)
@@ -33,6 +33,7 @@ type styledKey struct {
 	keyLabel    string
 	dateLabel   string
 	dateVal     string
+	label       string
 	note        string
 }
 
@@ -57,6 +58,7 @@ func (m Model) newStyledKey(styles common.Styles, key charm.PublicKey, active bo
 		keyLabel:    "Key:",
 		dateLabel:   "Added:",
 		dateVal:     styles.LabelDim.Render(date),
+		label:       key.Label,
 		note:        note,
 	}
 }
@@ -83,9 +85,17 @@ func (k styledKey) render(state keyState) string {
 	case keyDeleting:
 		k.deleting()
 	}
+	var label string
+	if k.label != "" {
+		style := k.styles.LabelDim
+		if state == keyDeleting {
+			style = k.styles.DeleteDim
+		}
+		label = " " + style.Render(k.label)
+	}
 	return fmt.Sprintf(
-		"%s %s %s\n%s %s %s %s\n\n",
-		k.gutter, k.keyLabel, k.fingerprint.state(state, k.styles),
+		"%s %s %s%s\n%s %s %s %s\n\n",
+		k.gutter, k.keyLabel, k.fingerprint.state(state, k.styles), label,
 		k.gutter, k.dateLabel, k.dateVal, k.note,
 	)
 }
@@ -1,6 +1,7 @@
 package username
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -269,7 +270,7 @@ func setName(m Model) tea.Cmd {
 		}
 
 		u, err := m.cc.SetName(m.newName)
-		if err == charm.ErrNameTaken {
+		if errors.Is(err, client.ErrNameTaken) {
 			return NameTakenMsg{}
 		} else if err == charm.ErrNameInvalid {
 			return NameInvalidMsg{}